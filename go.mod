@@ -7,7 +7,7 @@ require (
 	github.com/stretchr/testify v1.8.4
 )
 
-require golang.org/x/term v0.41.0 // indirect
+require golang.org/x/term v0.41.0
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
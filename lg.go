@@ -6,6 +6,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"flag"
@@ -19,6 +20,7 @@ import (
 	"github.com/alimpfard/line"
 	"github.com/nooga/let-go/pkg/bytecode"
 	"github.com/nooga/let-go/pkg/compiler"
+	"github.com/nooga/let-go/pkg/debugger"
 	"github.com/nooga/let-go/pkg/nrepl"
 	"github.com/nooga/let-go/pkg/resolver"
 	"github.com/nooga/let-go/pkg/rt"
@@ -58,6 +60,18 @@ func runForm(ctx *compiler.Context, in string) (vm.Value, error) {
 	return val, err
 }
 
+// replEval evaluates REPL input, keeping its value in *1 or the error in *e.
+func replEval(ctx *compiler.Context, in string) (vm.Value, error) {
+	ctx.SetSource("REPL")
+	val, err := runForm(ctx, in)
+	if err != nil {
+		rt.SetLastError(err)
+		return nil, err
+	}
+	rt.SetLastValue(val)
+	return val, nil
+}
+
 var completionTerminators map[byte]bool
 var styles map[compiler.TokenKind]line.Style
 
@@ -106,6 +120,9 @@ func repl(ctx *compiler.Context) {
 			editor.Stylize(line.Span{Start: uint32(t.Start), End: uint32(t.End), Mode: line.SpanModeByte}, style)
 		}
 	})
+	if dbgCLI != nil {
+		dbgCLI.Input = editor.GetLine
+	}
 	for {
 		if interrupted {
 			break
@@ -119,8 +136,11 @@ func repl(ctx *compiler.Context) {
 			continue
 		}
 		editor.AddToHistory(in)
-		ctx.SetSource("REPL")
-		val, err := runForm(ctx, in)
+		if dbgCLI != nil && strings.HasPrefix(in, ":") {
+			dbgCLI.Command(in[1:])
+			continue
+		}
+		val, err := replEval(ctx, in)
 		if err != nil {
 			fmt.Print(vm.FormatError(err))
 		} else {
@@ -154,11 +174,7 @@ func runLGB(filename string) error {
 	}
 	resolve := func(nsName, name string) *vm.Var {
 		n := rt.DefNSBare(nsName)
-		v := n.LookupLocal(vm.Symbol(name))
-		if v == nil {
-			return n.Def(name, vm.NIL)
-		}
-		return v
+		return n.Intern(vm.Symbol(name))
 	}
 	unit, err := bytecode.DecodeToExecUnit(bytes.NewReader(data), resolve)
 	if err != nil {
@@ -361,6 +377,54 @@ func compileLG(ctx *compiler.Context, nsRes *resolver.NSResolver, src string, ds
 	return bytecode.EncodeCompilation(out, ctx.Consts(), chunk)
 }
 
+// writeProfile stops the profiler and writes what it collected to path.
+func writeProfile(path string) error {
+	p, err := vm.StopProfile()
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WritePprof(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeCoverage stops recording coverage and writes the requested reports.
+func writeCoverage() error {
+	c, err := vm.StopCoverage()
+	if err != nil {
+		return err
+	}
+	if cover {
+		if err := c.WriteText(os.Stdout); err != nil {
+			return err
+		}
+	}
+	write := func(path string, report func(io.Writer) error) error {
+		if path == "" {
+			return nil
+		}
+		out, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := report(out); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+	if err := write(coverHTML, c.WriteHTML); err != nil {
+		return err
+	}
+	return write(coverLCOV, c.WriteLCOV)
+}
+
 var nreplServer *nrepl.NreplServer
 
 func nreplServe(ctx *compiler.Context, port int) error {
@@ -379,6 +443,8 @@ var (
 )
 
 var nreplPort int
+var socketREPLPort int
+var preplPort int
 var runNREPL bool
 var runREPL bool
 var expr string
@@ -387,6 +453,14 @@ var showVersion bool
 var compileOutput string
 var bundleOutput string
 var wasmOutput string
+var maxDepth int
+var debugMode bool
+var dapPort int
+var dbgCLI *debugger.CLI
+var profileOutput string
+var cover bool
+var coverHTML string
+var coverLCOV string
 
 func init() {
 	flag.BoolVar(&runREPL, "r", false, "attach REPL after running given files")
@@ -394,11 +468,20 @@ func init() {
 	flag.BoolVar(&debug, "d", false, "enable VM debug mode")
 	flag.BoolVar(&runNREPL, "n", false, "enable nREPL server")
 	flag.IntVar(&nreplPort, "p", 2137, "set nREPL port, default is 2137")
+	flag.IntVar(&socketREPLPort, "socket-repl", 0, "serve a plain socket REPL on given port")
+	flag.IntVar(&preplPort, "prepl", 0, "serve a prepl, answering forms with EDN maps, on given port")
 	flag.BoolVar(&showVersion, "v", false, "print version and exit")
 	flag.BoolVar(&showVersion, "version", false, "print version and exit")
 	flag.StringVar(&compileOutput, "c", "", "compile .lg file to .lgb bytecode (specify output path)")
 	flag.StringVar(&bundleOutput, "b", "", "bundle .lg file into a standalone executable (specify output path)")
 	flag.StringVar(&wasmOutput, "w", "", "build .lg file into a WASM web app (specify output directory)")
+	flag.BoolVar(&debugMode, "debug", false, "run under the debugger, stopping on entry to files; in the REPL :help lists debugger commands")
+	flag.IntVar(&dapPort, "dap", 0, "serve the Debug Adapter Protocol on given port")
+	flag.StringVar(&profileOutput, "profile", "", "profile let-go code run from files and -e, writing pprof output to given path")
+	flag.BoolVar(&cover, "cover", false, "record line coverage of files and -e, printing a report after they run")
+	flag.StringVar(&coverHTML, "cover-html", "", "record line coverage, writing an HTML report to given path")
+	flag.StringVar(&coverLCOV, "cover-lcov", "", "record line coverage, writing an LCOV tracefile to given path")
+	flag.IntVar(&maxDepth, "max-depth", vm.DefaultMaxCallDepth, "max depth of nested calls before a StackOverflowError, 0 disables the check")
 
 	completionTerminators = map[byte]bool{
 		'(':  true,
@@ -451,11 +534,7 @@ func main() {
 
 		resolve := func(nsName, name string) *vm.Var {
 			n := rt.DefNSBare(nsName)
-			v := n.LookupLocal(vm.Symbol(name))
-			if v == nil {
-				return n.Def(name, vm.NIL)
-			}
-			return v
+			return n.Intern(vm.Symbol(name))
 		}
 		unit, err := bytecode.DecodeToExecUnit(bytes.NewReader(lgbData), resolve)
 		if err != nil {
@@ -487,6 +566,7 @@ func main() {
 	}
 
 	flag.Parse()
+	vm.SetMaxCallDepth(maxDepth)
 
 	if showVersion {
 		fmt.Printf("lg %s\n", versionString())
@@ -505,7 +585,7 @@ func main() {
 	// Compile mode: compile .lg → .lgb
 	if compileOutput != "" || bundleOutput != "" || wasmOutput != "" {
 		// Set *compiling-aot* so user code can detect AOT compilation
-		rt.CoreNS.Lookup("*compiling-aot*").(*vm.Var).SetRoot(vm.TRUE)
+		rt.CoreNS().Lookup("*compiling-aot*").(*vm.Var).SetRoot(vm.TRUE)
 	}
 	if compileOutput != "" {
 		if len(files) != 1 {
@@ -545,6 +625,49 @@ func main() {
 		return
 	}
 
+	// DAP mode: let an editor launch and debug programs
+	if dapPort != 0 {
+		server := debugger.NewDAPServer(func(program string) error {
+			return runFile(context, program)
+		})
+		if err := server.Start(dapPort); err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to run DAP server on port %d: %v\n", dapPort, err)
+			os.Exit(1)
+		}
+		if err := server.Serve(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if debugMode {
+		stdin := bufio.NewReader(os.Stdin)
+		dbgCLI = debugger.NewCLI(func(prompt string) (string, error) {
+			fmt.Print(prompt)
+			return stdin.ReadString('\n')
+		}, os.Stdout)
+		vm.AttachDebugger(dbgCLI.Debugger())
+		if len(files) >= 1 {
+			dbgCLI.Debugger().Pause()
+		}
+	}
+
+	if profileOutput != "" {
+		if err := vm.StartProfile(0); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	recordCoverage := cover || coverHTML != "" || coverLCOV != ""
+	if recordCoverage {
+		if err := vm.StartCoverage(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	ranSomething := false
 	if len(files) >= 1 {
 		for i := range files {
@@ -573,16 +696,52 @@ func main() {
 		ranSomething = true
 	}
 
-	if !ranSomething || runREPL {
+	if profileOutput != "" {
+		if err := writeProfile(profileOutput); err != nil {
+			fmt.Fprintf(os.Stderr, "error: writing profile: %v\n", err)
+		}
+	}
+
+	if recordCoverage {
+		if err := writeCoverage(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: writing coverage: %v\n", err)
+		}
+	}
+
+	// Socket servers: keep serving after files and -e ran, with the terminal
+	// REPL only if asked for with -r
+	serving := socketREPLPort != 0 || preplPort != 0
+	interactive := runREPL || (!ranSomething && !serving)
+	for _, s := range []struct {
+		name  string
+		port  int
+		prepl bool
+	}{{"Socket REPL", socketREPLPort, false}, {"prepl", preplPort, true}} {
+		if s.port == 0 {
+			continue
+		}
+		if err := socketServe(context.CurrentNS(), s.port, s.prepl); err != nil {
+			fmt.Fprintf(os.Stderr, "error: failed to run %s server on port %d: %v\n", s.name, s.port, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s server running at tcp://127.0.0.1:%d\n", s.name, s.port)
+	}
+
+	if interactive {
 		motd()
-		if runNREPL {
-			err := nreplServe(context, nreplPort)
-			if err != nil {
-				fmt.Println("failed to run nREPL server on port", nreplPort, err)
-			}
+	}
+	if runNREPL && (serving || interactive) {
+		if err := nreplServe(context, nreplPort); err != nil {
+			fmt.Println("failed to run nREPL server on port", nreplPort, err)
+		} else {
 			fmt.Printf("nREPL server running at tcp://127.0.0.1:%d\n", nreplPort)
 		}
-		repl(context)
 	}
 
+	// all servers run by now, block on the terminal REPL or forever
+	if interactive {
+		repl(context)
+	} else if serving {
+		select {}
+	}
 }
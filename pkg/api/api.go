@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"reflect"
 
 	"github.com/nooga/let-go/pkg/compiler"
@@ -9,22 +10,98 @@ import (
 	"github.com/nooga/let-go/pkg/vm"
 )
 
+// LetGo is an embedded let-go runtime. Every instance has its own
+// namespaces and vars, so instances don't see each other's definitions and
+// can be used from different goroutines at the same time.
 type LetGo struct {
-	cp     *vm.Consts
-	c      *compiler.Context
-	loader *resolver.NSResolver
+	rt      *rt.Runtime
+	cp      *vm.Consts
+	c       *compiler.Context
+	loader  *resolver.NSResolver
+	limits  vm.Limits
+	limited bool
 }
 
-func NewLetGo(ns string) (*LetGo, error) {
+// Option configures a LetGo instance.
+type Option func(*LetGo)
+
+// WithLimits bounds every evaluation run by the instance. Exceeding a limit
+// makes Run return an error for which vm.AsLimitError succeeds.
+func WithLimits(limits vm.Limits) Option {
+	return func(l *LetGo) {
+		access := l.limits.Access
+		l.limits = limits
+		if limits.Access == nil {
+			l.limits.Access = access
+		}
+		l.limited = true
+	}
+}
+
+// WithAllowList restricts scripts to the given namespaces ("string") and
+// qualified vars ("os/getenv"). The instance's own namespace is always allowed.
+func WithAllowList(allow ...string) Option {
+	return func(l *LetGo) {
+		l.limits.Access = vm.NewAccessPolicy(allow...)
+		l.limited = true
+	}
+}
+
+// sandboxNamespaces are the parts of the standard library without access
+// to files, processes, the network or the terminal.
+var sandboxNamespaces = []string{
+	"core", "string", "set", "walk", "edn", "pprint", "json", "transit",
+	"math", "async", "zip", "data", "test",
+}
+
+// sandboxDenied are the vars of core that reach the filesystem.
+var sandboxDenied = []string{
+	"core/slurp", "core/spit", "core/load-file", "core/open", "core/read-bytes",
+	"core/file-exists?", "core/delete-file", "core/mkdir",
+}
+
+// Sandboxed restricts scripts to the pure parts of the standard library,
+// leaving out the os, io, http, pods and term namespaces as well as the
+// file functions of core. Combine it with WithLimits to also bound resources.
+func Sandboxed() Option {
+	return func(l *LetGo) {
+		WithAllowList(sandboxNamespaces...)(l)
+		l.limits.Access.Deny(sandboxDenied...)
+	}
+}
+
+// SetMaxCallDepth sets how deep let-go calls may nest before failing with an
+// error for which vm.AsStackOverflowError succeeds. Scripts can catch it.
+// Zero disables the check, see vm.SetMaxCallDepth. The setting is global to
+// the process and guards the Go stack of every instance. To bound the depth
+// of a single instance use Limits.MaxDepth with WithLimits, which is checked
+// as well and fails with a LimitError scripts can't catch.
+func SetMaxCallDepth(n int) {
+	vm.SetMaxCallDepth(n)
+}
+
+func NewLetGo(ns string, opts ...Option) (*LetGo, error) {
+	r, err := compiler.NewRuntime()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Enter()()
 	cp := vm.NewConsts()
-	nso := rt.NS(ns)
+	nso := r.NS(ns)
 	c := compiler.NewCompiler(cp, nso)
 	ret := &LetGo{
+		rt:     r,
 		cp:     cp,
 		c:      c,
 		loader: resolver.NewNSResolver(c, []string{"."}),
 	}
-	rt.SetNSLoader(ret.loader)
+	for _, opt := range opts {
+		opt(ret)
+	}
+	if ret.limits.Access != nil {
+		ret.limits.Access.Allow(ns)
+	}
+	r.SetNSLoader(ret.loader)
 	return ret, nil
 }
 
@@ -43,6 +120,16 @@ func (l *LetGo) Def(name string, value interface{}) error {
 }
 
 func (l *LetGo) Run(expr string) (vm.Value, error) {
+	defer l.rt.Enter()()
+	if l.limited {
+		return vm.WithLimits(l.limits, func() (vm.Value, error) {
+			return l.run(expr)
+		})
+	}
+	return l.run(expr)
+}
+
+func (l *LetGo) run(expr string) (vm.Value, error) {
 	c, err := l.c.Compile(expr)
 	if err != nil {
 		return vm.NIL, err
@@ -52,3 +139,11 @@ func (l *LetGo) Run(expr string) (vm.Value, error) {
 	vm.ReleaseFrame(frame)
 	return result, err
 }
+
+// RunContext is like Run but stops the evaluation once ctx is done.
+// The returned error satisfies vm.IsInterrupted in that case.
+func (l *LetGo) RunContext(ctx context.Context, expr string) (vm.Value, error) {
+	return vm.WithContext(ctx, func() (vm.Value, error) {
+		return l.Run(expr)
+	})
+}
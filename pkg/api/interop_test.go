@@ -1,7 +1,13 @@
 package api_test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/nooga/let-go/pkg/api"
 	"github.com/nooga/let-go/pkg/vm"
@@ -148,6 +154,394 @@ func TestStructPassedToLetGoFunction(t *testing.T) {
 	assert.Equal(t, 13.5, float64(v.(vm.Float)))
 }
 
+func TestRunContextInterrupts(t *testing.T) {
+	c, err := api.NewLetGo("cancel")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// try/catch must not swallow the interruption
+	_, err = c.RunContext(ctx, `(loop [i 0] (recur (try (inc i) (catch e i))))`)
+	assert.Error(t, err)
+	assert.True(t, vm.IsInterrupted(err))
+
+	_, err = c.Run(`(defn spin [n] (spin (inc n)))`)
+	assert.NoError(t, err)
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	_, err = c.RunContext(ctx2, `(spin 0)`)
+	assert.True(t, vm.IsInterrupted(err))
+
+	// finally blocks still run, so dynamic bindings are undone
+	_, err = c.Run(`(def ^:dynamic *x* 0)`)
+	assert.NoError(t, err)
+	for _, code := range []string{
+		`(binding [*x* 1] (loop [] (recur)))`,
+		`(binding [*x* 1] (spin 0))`,
+		`(binding [*x* 1] (try (spin 0) (catch e :caught) (finally (set! *x* 2))))`,
+	} {
+		ctx3, cancel3 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err = c.RunContext(ctx3, code)
+		cancel3()
+		assert.True(t, vm.IsInterrupted(err), code)
+		v, err := c.Run(`*x*`)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, v.Unbox(), code)
+	}
+
+	// bindings pushed and never popped don't outlive the evaluation
+	_, err = c.Run(`(push-thread-bindings {#'*x* 3})`)
+	assert.NoError(t, err)
+	v, err := c.Run(`*x*`)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, v.Unbox())
+
+	v, err = c.RunContext(context.Background(), `(+ 1 2)`)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v.Unbox())
+}
+
+func TestLimits(t *testing.T) {
+	c, err := api.NewLetGo("limits", api.WithLimits(vm.Limits{
+		MaxSteps: 100000,
+		MaxDepth: 50,
+		Timeout:  time.Second,
+	}))
+	assert.NoError(t, err)
+
+	v, err := c.Run(`(reduce + (map inc (range 10)))`)
+	assert.NoError(t, err)
+	assert.Equal(t, 55, v.Unbox())
+
+	// the budget is per evaluation and can't be caught
+	_, err = c.Run(`(loop [i 0] (recur (try (inc i) (catch e i))))`)
+	le, ok := vm.AsLimitError(err)
+	assert.True(t, ok, err)
+	assert.Equal(t, vm.StepLimit, le.Kind)
+
+	_, err = c.Run(`(defn deep [n] (if (zero? n) 0 (inc (deep (dec n)))))`)
+	assert.NoError(t, err)
+	v, err = c.Run(`(deep 20)`)
+	assert.NoError(t, err)
+	assert.Equal(t, 20, v.Unbox())
+	_, err = c.Run(`(deep 100)`)
+	le, ok = vm.AsLimitError(err)
+	assert.True(t, ok, err)
+	assert.Equal(t, vm.DepthLimit, le.Kind)
+
+	// go blocks draw from the same budget
+	_, err = c.Run(`(<!! (go (loop [i 0] (recur (inc i)))))`)
+	le, ok = vm.AsLimitError(err)
+	assert.True(t, ok, err)
+	assert.Equal(t, vm.StepLimit, le.Kind)
+
+	t2, err := api.NewLetGo("timeout", api.WithLimits(vm.Limits{Timeout: 50 * time.Millisecond}))
+	assert.NoError(t, err)
+	_, err = t2.Run(`(loop [] (recur))`)
+	le, ok = vm.AsLimitError(err)
+	assert.True(t, ok, err)
+	assert.Equal(t, vm.TimeLimit, le.Kind)
+
+	// finally blocks run, but can't swallow the error or keep it from stopping
+	_, err = t2.Run(`(def ^:dynamic *x* 0)`)
+	assert.NoError(t, err)
+	for _, code := range []string{
+		`(binding [*x* 1] (loop [] (recur)))`,
+		`(try (loop [] (recur)) (finally (loop [] (recur))))`,
+		`(loop [] (try (try (loop [] (recur)) (finally (throw (ex-info "x" {})))) (catch e nil)) (recur))`,
+	} {
+		_, err = t2.Run(code)
+		le, ok = vm.AsLimitError(err)
+		assert.True(t, ok, "%s: %v", code, err)
+		v, err = t2.Run(`*x*`)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, v.Unbox(), code)
+	}
+
+	// natives give up too, whether they loop or block
+	t3, err := api.NewLetGo("natives", api.Sandboxed(), api.WithLimits(vm.Limits{Timeout: 200 * time.Millisecond}))
+	assert.NoError(t, err)
+	for _, expr := range []string{
+		`(reduce + (range 100000000))`,
+		`(count (vec (range 100000000)))`,
+		`(<!! (chan))`,
+		`(async/<!! (async/chan))`,
+		`(async/alts! [(async/chan)])`,
+		`@(promise)`,
+		`@(future (loop [] (recur)))`,
+	} {
+		start := time.Now()
+		_, err = t3.Run(expr)
+		le, ok = vm.AsLimitError(err)
+		assert.True(t, ok, "%s: %v", expr, err)
+		assert.Equal(t, vm.TimeLimit, le.Kind)
+		assert.Less(t, time.Since(start), 2*time.Second, expr)
+	}
+	t4, err := api.NewLetGo("native-steps", api.WithLimits(vm.Limits{MaxSteps: 1000000}))
+	assert.NoError(t, err)
+	_, err = t4.Run(`(reduce + (range 100000000))`)
+	le, ok = vm.AsLimitError(err)
+	assert.True(t, ok, err)
+	assert.Equal(t, vm.StepLimit, le.Kind)
+}
+
+func TestStackOverflow(t *testing.T) {
+	api.SetMaxCallDepth(100)
+	defer api.SetMaxCallDepth(vm.DefaultMaxCallDepth)
+
+	c, err := api.NewLetGo("overflow")
+	assert.NoError(t, err)
+	_, err = c.Run(`(defn deep [n] (if (zero? n) 0 (inc (deep (dec n)))))`)
+	assert.NoError(t, err)
+
+	v, err := c.Run(`(deep 50)`)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, v.Unbox())
+
+	_, err = c.Run(`(deep 1000)`)
+	se, ok := vm.AsStackOverflowError(err)
+	assert.True(t, ok, err)
+	assert.Equal(t, 100, se.Depth)
+	assert.Len(t, vm.StackTrace(err), 101)
+
+	// unlike limits, overflows can be caught
+	v, err = c.Run(`(try (deep 1000) (catch e :overflow))`)
+	assert.NoError(t, err)
+	assert.Equal(t, vm.Keyword("overflow"), v)
+
+	// and the limit holds through multimethods
+	for _, code := range []string{
+		`(defmulti walk (fn [n] (zero? n)))`,
+		`(defmethod walk true [_] 0)`,
+		`(defmethod walk false [n] (inc (walk (dec n))))`,
+	} {
+		_, err = c.Run(code)
+		assert.NoError(t, err, code)
+	}
+	v, err = c.Run(`(walk 50)`)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, v.Unbox())
+	_, err = c.Run(`(walk 1000)`)
+	_, ok = vm.AsStackOverflowError(err)
+	assert.True(t, ok, err)
+
+	// and through functions called back from natives
+	for _, code := range []string{
+		`(defn g [n] (inc (apply g [(inc n)])))`,
+		`(defn h [n] (swap! (atom n) h))`,
+		`(defn m [n] (first (map m [n])))`,
+	} {
+		_, err = c.Run(code)
+		assert.NoError(t, err, code)
+	}
+	for _, code := range []string{`(g 0)`, `(h 0)`, `(m 0)`} {
+		_, err = c.Run(code)
+		_, ok = vm.AsStackOverflowError(err)
+		assert.True(t, ok, code)
+	}
+	for _, code := range []string{`(try (g 0) (catch e :overflow))`, `(try (h 0) (catch e :overflow))`} {
+		v, err = c.Run(code)
+		assert.NoError(t, err, code)
+		assert.Equal(t, vm.Keyword("overflow"), v, code)
+	}
+	v, err = c.Run(`(apply deep [50])`)
+	assert.NoError(t, err)
+	assert.Equal(t, 50, v.Unbox())
+
+	// on more goroutines at once than there are chain slots
+	v, err = c.Run(`(set (map deref (doall (map (fn [_] (future (try (g 0) (catch e :overflow)))) (range 1000)))))`)
+	assert.NoError(t, err)
+	assert.Equal(t, "#{:overflow}", v.String())
+}
+
+func TestSandboxed(t *testing.T) {
+	c, err := api.NewLetGo("sandbox", api.Sandboxed())
+	assert.NoError(t, err)
+
+	_, err = c.Run(`(require 'string)`)
+	assert.NoError(t, err)
+	_, err = c.Run(`(defn shout [s] (string/upper-case s))`)
+	assert.NoError(t, err)
+	v, err := c.Run(`(shout "hi")`)
+	assert.NoError(t, err)
+	assert.Equal(t, "HI", v.Unbox())
+
+	for _, code := range []string{
+		`(os/sh "ls")`,
+		`(slurp "/etc/passwd")`,
+		`(http/get "http://example.com")`,
+		`(source-at* "/etc/passwd" 1 1)`,
+		`(repl/source-at* "/etc/passwd" 1 1)`,
+	} {
+		_, err = c.Run(code)
+		assert.Error(t, err, code)
+	}
+
+	// nor can they reach the filesystem through core
+	dir := t.TempDir()
+	victim := filepath.Join(dir, "victim")
+	assert.NoError(t, os.WriteFile(victim, []byte("data"), 0644))
+	for _, code := range []string{
+		fmt.Sprintf(`(delete-file %q)`, victim),
+		fmt.Sprintf(`(mkdir %q)`, filepath.Join(dir, "pwned")),
+		fmt.Sprintf(`(open %q)`, victim),
+		fmt.Sprintf(`(file-exists? %q)`, victim),
+		`(read-bytes *in* 1)`,
+		fmt.Sprintf(`(spit %q "x")`, victim),
+		fmt.Sprintf(`(load-file %q)`, victim),
+	} {
+		_, err = c.Run(code)
+		assert.Error(t, err, code)
+	}
+	data, err := os.ReadFile(victim)
+	assert.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.NoDirExists(t, filepath.Join(dir, "pwned"))
+
+	// scripts may still create their own namespaces, but not touch others
+	_, err = c.Run(`(ns scratch)`)
+	assert.NoError(t, err)
+	_, err = c.Run(`(defn f [] 42)`)
+	assert.NoError(t, err)
+	v, err = c.Run(`(scratch/f)`)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, v.Unbox())
+	_, err = c.Run(`(in-ns 'os)`)
+	assert.NoError(t, err)
+	_, err = c.Run(`(def sh 1)`)
+	assert.Error(t, err)
+
+	// the policy only applies to the sandboxed instance
+	o, err := api.NewLetGo("unrestricted")
+	assert.NoError(t, err)
+	_, err = o.Run(`(var os/sh)`)
+	assert.NoError(t, err)
+}
+
+func TestSandboxedIntrospection(t *testing.T) {
+	c, err := api.NewLetGo("sandbox", api.Sandboxed())
+	assert.NoError(t, err)
+
+	// hidden vars don't show up
+	for _, code := range []string{
+		`(contains? (ns-publics 'core) 'slurp)`,
+		`(contains? (ns-refers *ns*) 'slurp)`,
+		`(contains? (ns-publics 'os) 'sh)`,
+		`(some? (resolve 'core/slurp))`,
+		`(some? (ns-resolve 'os 'sh))`,
+	} {
+		v, err := c.Run(code)
+		assert.NoError(t, err, code)
+		assert.Equal(t, false, v.Unbox(), code)
+	}
+	v, err := c.Run(`(contains? (ns-publics 'core) 'map)`)
+	assert.NoError(t, err)
+	assert.Equal(t, true, v.Unbox())
+
+	// and namespaces outside the policy can't be changed
+	for _, code := range []string{
+		`(intern 'os 'sh 1)`,
+		`(ns-unmap 'os 'sh)`,
+		`(remove-ns 'os)`,
+	} {
+		_, err = c.Run(code)
+		assert.Error(t, err, code)
+	}
+
+	_, err = c.Run(`(intern (create-ns 'scratch) 'x 1)`)
+	assert.NoError(t, err)
+	v, err = c.Run(`(do (remove-ns 'scratch) (find-ns 'scratch))`)
+	assert.NoError(t, err)
+	assert.Nil(t, v.Unbox())
+}
+
+func TestIsolatedInstances(t *testing.T) {
+	a, err := api.NewLetGo("tenant")
+	assert.NoError(t, err)
+	b, err := api.NewLetGo("tenant")
+	assert.NoError(t, err)
+
+	_, err = a.Run(`(def x :a)`)
+	assert.NoError(t, err)
+	_, err = b.Run(`(def x :b)`)
+	assert.NoError(t, err)
+	v, err := a.Run(`x`)
+	assert.NoError(t, err)
+	assert.Equal(t, vm.Keyword("a"), v)
+	v, err = b.Run(`x`)
+	assert.NoError(t, err)
+	assert.Equal(t, vm.Keyword("b"), v)
+
+	// each instance has its own copy of core
+	for _, code := range []string{`(in-ns 'core)`, `(def greeting "hi")`, `(in-ns 'tenant)`} {
+		_, err = a.Run(code)
+		assert.NoError(t, err, code)
+	}
+	v, err = a.Run(`greeting`)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", v.Unbox())
+	_, err = b.Run(`greeting`)
+	assert.Error(t, err)
+
+	// namespaces created in one instance are invisible to the other
+	_, err = a.Run(`(ns only.in.a)`)
+	assert.NoError(t, err)
+	v, err = b.Run(`(find-ns 'only.in.a)`)
+	assert.NoError(t, err)
+	assert.Equal(t, vm.NIL, v)
+
+	// and its own global hierarchy
+	_, err = a.Run(`(derive ::square ::shape)`)
+	assert.NoError(t, err)
+	v, err = a.Run(`(isa? ::square ::shape)`)
+	assert.NoError(t, err)
+	assert.Equal(t, true, v.Unbox())
+	v, err = b.Run(`(isa? ::square ::shape)`)
+	assert.NoError(t, err)
+	assert.Equal(t, false, v.Unbox())
+
+	// agent actions see the instance's own *agent*
+	v, err = b.Run(`(let [ag (agent nil)] (send ag (fn [_] (= *agent* ag))) (await ag) @ag)`)
+	assert.NoError(t, err)
+	assert.Equal(t, true, v.Unbox())
+
+	// and its own taps
+	_, err = a.Run(`(def tapped (promise))`)
+	assert.NoError(t, err)
+	_, err = a.Run(`(add-tap (fn [x] (deliver tapped x)))`)
+	assert.NoError(t, err)
+	_, err = b.Run(`(tap> :from-b)`)
+	assert.NoError(t, err)
+	v, err = a.Run(`(do (tap> :from-a) @tapped)`)
+	assert.NoError(t, err)
+	assert.Equal(t, vm.Keyword("from-a"), v)
+
+	done := make(chan error, 2)
+	for _, l := range []*api.LetGo{a, b} {
+		go func(l *api.LetGo) {
+			_, err := l.Run(`(reduce + (range 10000))`)
+			done <- err
+		}(l)
+	}
+	assert.NoError(t, <-done)
+	assert.NoError(t, <-done)
+}
+
+func TestRequiringResolve(t *testing.T) {
+	c, err := api.NewLetGo("user")
+	assert.NoError(t, err)
+
+	// string and set come from the core bundle, their vars exist before
+	// the namespaces are loaded
+	v, err := c.Run(`((requiring-resolve 'string/join) [1 2])`)
+	assert.NoError(t, err)
+	assert.Equal(t, "12", v.Unbox())
+	v, err = c.Run(`(count ((requiring-resolve 'set/union) #{1} #{2}))`)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v.Unbox())
+}
+
 func BenchmarkUse(b *testing.B) {
 	c, err := api.NewLetGo("useBenchmark")
 	if err != nil {
@@ -161,3 +555,80 @@ func BenchmarkUse(b *testing.B) {
 		b.Fatal(err)
 	}
 }
+
+func TestCoverage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cov.lg")
+	err := os.WriteFile(path, []byte(`(ns cov)
+
+(defn used [x]
+  (if (pos? x)
+    (str "pos")
+    (str "neg")))
+
+(defn unused []
+  (println "never"))
+
+(used 1)
+
+(defn literal [x]
+  (if (pos? x)
+    x
+    :never))
+
+(defn one-liner [] (println "never"))
+
+(literal 1)
+`), 0o644)
+	assert.NoError(t, err)
+
+	c, err := api.NewLetGo("cov")
+	assert.NoError(t, err)
+	assert.NoError(t, vm.StartCoverage())
+	_, err = c.Run(fmt.Sprintf("(load-file %q)", path))
+	cov, stopErr := vm.StopCoverage()
+	assert.NoError(t, err)
+	assert.NoError(t, stopErr)
+
+	assert.Len(t, cov.Files, 1)
+	fc := cov.Files[0]
+	assert.Equal(t, path, fc.File)
+	hits := map[int]int64{}
+	for _, l := range fc.Lines {
+		hits[l.Line] = l.Hits
+	}
+	assert.Positive(t, hits[4])
+	assert.Positive(t, hits[5])
+	assert.Zero(t, hits[6])
+	assert.Zero(t, hits[9])
+	assert.Positive(t, hits[11])
+	// branches that are only a constant or a local have lines too
+	assert.Positive(t, hits[15])
+	assert.Contains(t, hits, 16)
+	assert.Zero(t, hits[16])
+
+	names := map[string]vm.FuncCoverage{}
+	for _, fn := range fc.Funcs {
+		names[fn.Name] = fn
+	}
+	assert.Equal(t, int64(1), names["cov/used"].Calls)
+	assert.Equal(t, 3, names["cov/used"].Line)
+	assert.Equal(t, int64(0), names["cov/unused"].Calls)
+	assert.Zero(t, names["cov/unused"].Covered)
+	assert.Equal(t, 2, names["cov/literal"].Covered)
+	assert.Equal(t, 3, names["cov/literal"].Lines)
+	// the defn line runs when the function is defined, not when it's called
+	assert.Equal(t, 18, names["cov/one-liner"].Line)
+	assert.Zero(t, names["cov/one-liner"].Covered)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, cov.WriteLCOV(buf))
+	assert.Contains(t, buf.String(), "SF:"+path+"\n")
+	assert.Contains(t, buf.String(), "FN:3,cov/used\n")
+	assert.Contains(t, buf.String(), "FNDA:1,cov/used\n")
+	assert.Contains(t, buf.String(), "DA:6,0\n")
+
+	buf.Reset()
+	assert.NoError(t, cov.WriteText(buf))
+	assert.Contains(t, buf.String(), "cov/unused")
+	assert.Contains(t, buf.String(), "total:")
+}
@@ -529,6 +529,73 @@ func TestEmptySetRoundtrip(t *testing.T) {
 	}
 }
 
+func TestSortedMapRoundtrip(t *testing.T) {
+	m, err := vm.NewSortedMap(nil, []vm.Value{vm.Int(3), vm.String("c"), vm.Int(1), vm.String("a"), vm.Int(2), vm.String("b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := roundtripValue(t, m)
+	gotMap, ok := got.(*vm.PersistentSortedMap)
+	if !ok {
+		t.Fatalf("expected *PersistentSortedMap, got %T", got)
+	}
+	if gotMap.String() != "{1 \"a\" 2 \"b\" 3 \"c\"}" {
+		t.Errorf("got %s", gotMap)
+	}
+}
+
+func TestSortedSetRoundtrip(t *testing.T) {
+	s, err := vm.NewSortedSet(nil, []vm.Value{vm.Keyword("b"), vm.Keyword("c"), vm.Keyword("a")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := roundtripValue(t, s)
+	gotSet, ok := got.(*vm.PersistentSortedSet)
+	if !ok {
+		t.Fatalf("expected *PersistentSortedSet, got %T", got)
+	}
+	if gotSet.String() != "#{:a :b :c}" {
+		t.Errorf("got %s", gotSet)
+	}
+}
+
+func TestInstRoundtrip(t *testing.T) {
+	inst, err := vm.ParseInst("2020-01-02T03:04:05.123456789+02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := roundtripValue(t, inst)
+	if !inst.Equals(got) {
+		t.Errorf("got %v, want %v", got, inst)
+	}
+}
+
+func TestUUIDRoundtrip(t *testing.T) {
+	u := vm.RandomUUID()
+	if got := roundtripValue(t, u); got != u {
+		t.Errorf("got %v, want %v", got, u)
+	}
+}
+
+func TestRatioRoundtrip(t *testing.T) {
+	r := vm.NewRatio(big.NewRat(-22, 7))
+	got := roundtripValue(t, r)
+	if _, ok := got.(*vm.Ratio); !ok || !vm.NumEq(r, got) {
+		t.Errorf("got %v, want %v", got, r)
+	}
+}
+
+func TestBigDecimalRoundtrip(t *testing.T) {
+	d, err := vm.ParseBigDecimal("-12345678901234567890.0100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := roundtripValue(t, d).(*vm.BigDecimal)
+	if !ok || got.String() != d.String() {
+		t.Errorf("got %v, want %v", got, d)
+	}
+}
+
 func TestNestedCollections(t *testing.T) {
 	// Vector containing a map containing a list
 	inner, _ := vm.ListType.Box([]vm.Value{vm.Int(1), vm.Int(2)})
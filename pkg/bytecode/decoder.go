@@ -1,11 +1,13 @@
 package bytecode
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"math/big"
 	"regexp"
 	"sort"
+	"time"
 
 	"github.com/nooga/let-go/pkg/vm"
 )
@@ -146,6 +148,147 @@ func DecodeToExecUnitWithParent(r io.Reader, resolve VarResolver, parent *vm.Con
 	return unit, nil
 }
 
+// Template is a decoded module which can be instantiated any number of times,
+// every instance with its own consts, vars and functions. The code, source
+// maps and consts holding plain data are decoded once and shared by all of
+// the instances.
+type Template struct {
+	flags      uint16
+	strings    []string
+	chunks     []*ChunkData
+	sourceMaps []*vm.SourceMap
+	consts     []vm.Value // nil where the const is decoded again for each instance
+	encoded    [][]byte   // encodings of the consts decoded for each instance
+	constsBase int
+	nsTable    map[string]int
+}
+
+// DecodeTemplate decodes the module in data into a Template.
+func DecodeTemplate(data []byte) (*Template, error) {
+	src := bytes.NewReader(data)
+	d := &decoder{r: NewReader(src)}
+	pos := func() int { return len(data) - src.Len() - d.r.r.Buffered() }
+
+	_, flags, err := d.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	d.flags = flags
+	if d.strings, err = d.readStringTable(); err != nil {
+		return nil, err
+	}
+	t := &Template{flags: flags, strings: d.strings}
+	if t.chunks, err = d.readChunks(); err != nil {
+		return nil, err
+	}
+	if len(t.chunks) == 0 {
+		return nil, fmt.Errorf("no chunks in module")
+	}
+	t.sourceMaps = make([]*vm.SourceMap, len(t.chunks))
+	for i, cd := range t.chunks {
+		if len(cd.SourceMap) == 0 {
+			continue
+		}
+		sm := vm.NewSourceMap()
+		for _, e := range cd.SourceMap {
+			sm.Add(e.StartIP, vm.SourceInfo{
+				File:      e.File,
+				Line:      e.Line,
+				Column:    e.Column,
+				EndLine:   e.EndLine,
+				EndColumn: e.EndColumn,
+			})
+		}
+		t.sourceMaps[i] = sm
+	}
+
+	// Func consts are checked against the chunk count, the chunks themselves
+	// are made per instance
+	d.chunks = make([]*vm.CodeChunk, len(t.chunks))
+	count, err := d.r.ReadVarint()
+	if err != nil {
+		return nil, fmt.Errorf("reading const count: %w", err)
+	}
+	if d.flags&FlagConstsBase != 0 {
+		base, err := d.r.ReadVarint()
+		if err != nil {
+			return nil, fmt.Errorf("reading consts base: %w", err)
+		}
+		t.constsBase = int(base)
+	}
+	t.consts = make([]vm.Value, count)
+	t.encoded = make([][]byte, count)
+	for i := range t.consts {
+		start := pos()
+		d.stateful = false
+		v, err := d.readValue()
+		if err != nil {
+			return nil, fmt.Errorf("reading const[%d]: %w", i, err)
+		}
+		if d.stateful {
+			t.encoded[i] = data[start:pos()]
+		} else {
+			t.consts[i] = v
+		}
+	}
+
+	if t.nsTable, err = d.readNSTable(); err != nil {
+		return nil, err
+	}
+	for name, idx := range t.nsTable {
+		if idx >= len(t.chunks) {
+			return nil, fmt.Errorf("NS table chunk index %d out of range for %q", idx, name)
+		}
+	}
+	return t, nil
+}
+
+// Instantiate makes a new instance of t, resolving var references with resolve.
+func (t *Template) Instantiate(resolve VarResolver) (*ExecUnit, error) {
+	consts := vm.NewConsts()
+	d := &decoder{
+		resolve:    resolve,
+		flags:      t.flags,
+		constsBase: t.constsBase,
+		strings:    t.strings,
+		chunks:     make([]*vm.CodeChunk, len(t.chunks)),
+	}
+	for i, cd := range t.chunks {
+		d.chunks[i] = vm.NewSharedCodeChunk(consts, cd.Code, cd.MaxStack, t.sourceMaps[i])
+	}
+	src := bytes.NewReader(nil)
+	d.r = NewReader(src)
+	for i, v := range t.consts {
+		if v == nil {
+			var err error
+			src.Reset(t.encoded[i])
+			d.r.r.Reset(src)
+			if v, err = d.readValue(); err != nil {
+				return nil, fmt.Errorf("reading const[%d]: %w", i, err)
+			}
+		}
+		consts.Append(v)
+	}
+
+	unit := &ExecUnit{Consts: consts, MainChunk: d.chunks[0]}
+	if len(t.nsTable) > 0 {
+		unit.NSChunks = make(map[string]*vm.CodeChunk, len(t.nsTable))
+		for name, idx := range t.nsTable {
+			unit.NSChunks[name] = d.chunks[idx]
+			unit.NSOrder = append(unit.NSOrder, name)
+		}
+		sort.Slice(unit.NSOrder, func(i, j int) bool {
+			return t.nsTable[unit.NSOrder[i]] < t.nsTable[unit.NSOrder[j]]
+		})
+		if coreChunk, ok := unit.NSChunks["core"]; ok {
+			unit.MainChunk = coreChunk
+		} else {
+			unit.MainChunk = unit.NSChunks[unit.NSOrder[len(unit.NSOrder)-1]]
+		}
+	}
+	return unit, nil
+}
+
 // DecodeWithResolver reads a binary module, resolving var references with the given function.
 func DecodeWithResolver(r io.Reader, resolve VarResolver) (*Module, error) {
 	d := &decoder{
@@ -166,6 +309,10 @@ type decoder struct {
 	constsBase int
 	strings    []string
 	chunks     []*vm.CodeChunk
+	// stateful is set when a value read refers to vars or functions, or has
+	// identity or state of its own, so that it can't be shared by Template
+	// instances.
+	stateful bool
 }
 
 func (d *decoder) readModule() (*Module, error) {
@@ -277,6 +424,26 @@ func (d *decoder) readStringRef() (string, error) {
 	return d.strings[idx], nil
 }
 
+func (d *decoder) readBigInt() (*big.Int, error) {
+	sign, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	magLen, err := d.r.ReadVarint()
+	if err != nil {
+		return nil, err
+	}
+	mag, err := d.r.ReadBytes(int(magLen))
+	if err != nil {
+		return nil, err
+	}
+	bi := new(big.Int).SetBytes(mag)
+	if sign != 0 {
+		bi.Neg(bi)
+	}
+	return bi, nil
+}
+
 func (d *decoder) readChunks() ([]*ChunkData, error) {
 	count, err := d.r.ReadVarint()
 	if err != nil {
@@ -444,26 +611,56 @@ func (d *decoder) readValue() (vm.Value, error) {
 		}
 		return vm.Char(v), nil
 	case TagBigInt:
-		sign, err := d.r.ReadByte()
+		bi, err := d.readBigInt()
 		if err != nil {
 			return nil, err
 		}
-		magLen, err := d.r.ReadVarint()
+		return vm.NewBigInt(bi), nil
+	case TagRatio:
+		num, err := d.readBigInt()
 		if err != nil {
 			return nil, err
 		}
-		mag, err := d.r.ReadBytes(int(magLen))
+		den, err := d.readBigInt()
 		if err != nil {
 			return nil, err
 		}
-		bi := new(big.Int).SetBytes(mag)
-		if sign != 0 {
-			bi.Neg(bi)
+		if den.Sign() == 0 {
+			return nil, fmt.Errorf("ratio with zero denominator")
 		}
-		return vm.NewBigInt(bi), nil
+		return vm.NewRatio(new(big.Rat).SetFrac(num, den)), nil
+	case TagDecimal:
+		scale, err := d.r.ReadSvarint()
+		if err != nil {
+			return nil, err
+		}
+		unscaled, err := d.readBigInt()
+		if err != nil {
+			return nil, err
+		}
+		return vm.NewBigDecimal(unscaled, int(scale)), nil
+	case TagInst:
+		sec, err := d.r.ReadSvarint()
+		if err != nil {
+			return nil, err
+		}
+		nsec, err := d.r.ReadVarint()
+		if err != nil {
+			return nil, err
+		}
+		return vm.NewInst(time.Unix(sec, int64(nsec))), nil
+	case TagUUID:
+		b, err := d.r.ReadBytes(16)
+		if err != nil {
+			return nil, err
+		}
+		var u vm.UUID
+		copy(u[:], b)
+		return u, nil
 	case TagVoid:
 		return vm.VOID, nil
 	case TagFunc:
+		d.stateful = true
 		chunkIdx, err := d.r.ReadVarint()
 		if err != nil {
 			return nil, err
@@ -485,8 +682,16 @@ func (d *decoder) readValue() (vm.Value, error) {
 		}
 		fn := vm.MakeFunc(int(arity), variadic != 0, d.chunks[chunkIdx])
 		fn.SetName(name)
+		if d.flags&FlagFuncNS != 0 {
+			ns, err := d.readStringRef()
+			if err != nil {
+				return nil, err
+			}
+			fn.SetNS(ns)
+		}
 		return fn, nil
 	case TagVarRef:
+		d.stateful = true
 		ns, err := d.readStringRef()
 		if err != nil {
 			return nil, err
@@ -547,7 +752,10 @@ func (d *decoder) readValue() (vm.Value, error) {
 			}
 		}
 		return vm.NewPersistentSet(items), nil
+	case TagSortedMap, TagSortedSet:
+		return d.readSortedValue(tag == TagSortedMap)
 	case TagRecordType:
+		d.stateful = true
 		name, err := d.readStringRef()
 		if err != nil {
 			return nil, err
@@ -566,6 +774,7 @@ func (d *decoder) readValue() (vm.Value, error) {
 		}
 		return vm.NewRecordType(name, fields), nil
 	case TagRecord:
+		d.stateful = true
 		// Read the record type inline
 		typeName, err := d.readStringRef()
 		if err != nil {
@@ -617,6 +826,7 @@ func (d *decoder) readValue() (vm.Value, error) {
 		v, _ := vm.RegexType.Box(re)
 		return v, nil
 	case TagAtom:
+		d.stateful = true
 		val, err := d.readValue()
 		if err != nil {
 			return nil, err
@@ -627,6 +837,40 @@ func (d *decoder) readValue() (vm.Value, error) {
 	}
 }
 
+func (d *decoder) readSortedValue(isMap bool) (vm.Value, error) {
+	cmpv, err := d.readValue()
+	if err != nil {
+		return nil, err
+	}
+	var cmp vm.Fn
+	if cmpv != vm.NIL {
+		fn, ok := cmpv.(vm.Fn)
+		if !ok {
+			return nil, fmt.Errorf("sorted collection comparator is not a function: %s", cmpv.Type().Name())
+		}
+		cmp = fn
+	}
+	count, err := d.r.ReadVarint()
+	if err != nil {
+		return nil, err
+	}
+	n := int(count)
+	if isMap {
+		n *= 2
+	}
+	items := make([]vm.Value, n)
+	for i := range items {
+		items[i], err = d.readValue()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isMap {
+		return vm.NewSortedMap(cmp, items)
+	}
+	return vm.NewSortedSet(cmp, items)
+}
+
 func (d *decoder) readMapValue() (vm.Value, error) {
 	count, err := d.r.ReadVarint()
 	if err != nil {
@@ -180,6 +180,7 @@ func (b *ModuleBuilder) internStringsForValue(v vm.Value) {
 		b.internString(string(val))
 	case *vm.Func:
 		b.internString(val.FuncName())
+		b.internString(val.FuncNS())
 		b.AddChunk(val.Chunk())
 	case *vm.Var:
 		b.internString(val.NS())
@@ -215,6 +216,22 @@ func (b *ModuleBuilder) internStringsForValue(v vm.Value) {
 			b.internStringsForValue(s.First())
 			s = s.Next()
 		}
+	case *vm.PersistentSortedMap:
+		b.internStringsForValue(val.Comparator())
+		s := val.Seq()
+		for s != nil && s != vm.EmptyList {
+			entry := s.First().(vm.ArrayVector)
+			b.internStringsForValue(entry[0])
+			b.internStringsForValue(entry[1])
+			s = s.Next()
+		}
+	case *vm.PersistentSortedSet:
+		b.internStringsForValue(val.Comparator())
+		s := val.Seq()
+		for s != nil && s != vm.EmptyList {
+			b.internStringsForValue(s.First())
+			s = s.Next()
+		}
 	case *vm.Record:
 		rt := val.RecordType()
 		b.internString(rt.TypeName())
@@ -246,7 +263,7 @@ func (b *ModuleBuilder) SetNSEntry(name string, chunk *vm.CodeChunk) {
 func (b *ModuleBuilder) Build() *Module {
 	m := &Module{
 		Version:    FormatVersion,
-		Flags:      0,
+		Flags:      FlagFuncNS,
 		Strings:    b.strings,
 		Chunks:     b.chunks,
 		Consts:     b.consts,
@@ -267,6 +284,7 @@ func (b *ModuleBuilder) ChunkIndex(c *vm.CodeChunk) (int, bool) {
 
 type encoder struct {
 	w        *Writer
+	flags    uint16
 	strings  []string
 	strIndex map[string]int
 	chunks   []*ChunkData
@@ -280,6 +298,7 @@ func (e *encoder) writeHeader(m *Module) error {
 	if err := e.w.WriteUint16(m.Version); err != nil {
 		return err
 	}
+	e.flags = m.Flags
 	return e.w.WriteUint16(m.Flags)
 }
 
@@ -315,6 +334,22 @@ func (e *encoder) writeStringRef(s string) error {
 	return e.w.WriteVarint(ref)
 }
 
+// writeBigInt writes a sign byte followed by the length-prefixed magnitude.
+func (e *encoder) writeBigInt(bi *big.Int) error {
+	sign := byte(0)
+	if bi.Sign() < 0 {
+		sign = 1
+	}
+	if err := e.w.WriteByte(sign); err != nil {
+		return err
+	}
+	mag := new(big.Int).Abs(bi).Bytes()
+	if err := e.w.WriteVarint(uint64(len(mag))); err != nil {
+		return err
+	}
+	return e.w.WriteBytes(mag)
+}
+
 func (e *encoder) writeChunks() error {
 	if err := e.w.WriteVarint(uint64(len(e.chunks))); err != nil {
 		return err
@@ -419,19 +454,37 @@ func (e *encoder) writeValue(v vm.Value) error {
 		if err := e.w.WriteByte(TagBigInt); err != nil {
 			return err
 		}
-		bi := val.Val()
-		sign := byte(0)
-		if bi.Sign() < 0 {
-			sign = 1
+		return e.writeBigInt(val.Val())
+	case *vm.Ratio:
+		if err := e.w.WriteByte(TagRatio); err != nil {
+			return err
+		}
+		if err := e.writeBigInt(val.Numerator()); err != nil {
+			return err
+		}
+		return e.writeBigInt(val.Denominator())
+	case *vm.BigDecimal:
+		if err := e.w.WriteByte(TagDecimal); err != nil {
+			return err
+		}
+		if err := e.w.WriteSvarint(int64(val.Scale())); err != nil {
+			return err
+		}
+		return e.writeBigInt(val.Unscaled())
+	case vm.Inst:
+		if err := e.w.WriteByte(TagInst); err != nil {
+			return err
 		}
-		if err := e.w.WriteByte(sign); err != nil {
+		t := val.Time()
+		if err := e.w.WriteSvarint(t.Unix()); err != nil {
 			return err
 		}
-		mag := new(big.Int).Abs(bi).Bytes()
-		if err := e.w.WriteVarint(uint64(len(mag))); err != nil {
+		return e.w.WriteVarint(uint64(t.Nanosecond()))
+	case vm.UUID:
+		if err := e.w.WriteByte(TagUUID); err != nil {
 			return err
 		}
-		return e.w.WriteBytes(mag)
+		return e.w.WriteBytes(val[:])
 	case *vm.Void:
 		return e.w.WriteByte(TagVoid)
 	case *vm.Func:
@@ -453,7 +506,13 @@ func (e *encoder) writeValue(v vm.Value) error {
 		if err := e.w.WriteByte(variadic); err != nil {
 			return err
 		}
-		return e.writeStringRef(val.FuncName())
+		if err := e.writeStringRef(val.FuncName()); err != nil {
+			return err
+		}
+		if e.flags&FlagFuncNS != 0 {
+			return e.writeStringRef(val.FuncNS())
+		}
+		return nil
 	case *vm.Var:
 		if err := e.w.WriteByte(TagVarRef); err != nil {
 			return err
@@ -493,6 +552,22 @@ func (e *encoder) writeValue(v vm.Value) error {
 			return err
 		}
 		return e.writeSetConsts(val)
+	case *vm.PersistentSortedMap:
+		if err := e.w.WriteByte(TagSortedMap); err != nil {
+			return err
+		}
+		if err := e.writeValue(val.Comparator()); err != nil {
+			return err
+		}
+		return e.writeSeqEntries(val.RawCount(), val.Seq(), true)
+	case *vm.PersistentSortedSet:
+		if err := e.w.WriteByte(TagSortedSet); err != nil {
+			return err
+		}
+		if err := e.writeValue(val.Comparator()); err != nil {
+			return err
+		}
+		return e.writeSeqEntries(val.RawCount(), val.Seq(), false)
 	case *vm.RecordType:
 		if err := e.w.WriteByte(TagRecordType); err != nil {
 			return err
@@ -627,6 +702,29 @@ func (e *encoder) writeSetConsts(set *vm.PersistentSet) error {
 	return nil
 }
 
+// writeSeqEntries writes count followed by the items of s, splitting map
+// entries into keys and values when pairs is set.
+func (e *encoder) writeSeqEntries(count int, s vm.Seq, pairs bool) error {
+	if err := e.w.WriteVarint(uint64(count)); err != nil {
+		return err
+	}
+	for s != nil && s != vm.EmptyList {
+		if pairs {
+			entry := s.First().(vm.ArrayVector)
+			if err := e.writeValue(entry[0]); err != nil {
+				return err
+			}
+			if err := e.writeValue(entry[1]); err != nil {
+				return err
+			}
+		} else if err := e.writeValue(s.First()); err != nil {
+			return err
+		}
+		s = s.Next()
+	}
+	return nil
+}
+
 func (e *encoder) writeNSTable(nsTable map[string]int) error {
 	if err := e.w.WriteVarint(uint64(len(nsTable))); err != nil {
 		return err
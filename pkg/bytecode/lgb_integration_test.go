@@ -258,3 +258,43 @@ func TestLGBParity(t *testing.T) {
 		(println (force d))
 	`)
 }
+
+func TestTemplateInstancesAreIndependent(t *testing.T) {
+	chunk, consts := compileSource(t, `(def counter (atom 0)) (defn bump [] (swap! counter inc)) (bump)`)
+	var buf bytes.Buffer
+	if err := bytecode.EncodeCompilation(&buf, consts, chunk); err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	template, err := bytecode.DecodeTemplate(buf.Bytes())
+	if err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+
+	run := func() *vm.Namespace {
+		ns := vm.NewNamespace("user")
+		unit, err := template.Instantiate(func(nsName, name string) *vm.Var {
+			if nsName == "user" {
+				return ns.Intern(vm.Symbol(name))
+			}
+			return rt.NS(nsName).Intern(vm.Symbol(name))
+		})
+		if err != nil {
+			t.Fatalf("instantiate failed: %v", err)
+		}
+		f := vm.NewFrame(unit.MainChunk, nil)
+		if _, err := f.RunProtected(); err != nil {
+			t.Fatalf("run failed: %v", err)
+		}
+		vm.ReleaseFrame(f)
+		return ns
+	}
+	a, b := run(), run()
+	for _, ns := range []*vm.Namespace{a, b} {
+		if v := ns.LookupLocal("counter").Deref().(vm.Reference).Deref(); v != vm.Int(1) {
+			t.Errorf("expected each instance to have its own counter, got %v", v)
+		}
+	}
+	if a.LookupLocal("bump").Deref() == b.LookupLocal("bump").Deref() {
+		t.Error("expected each instance to have its own functions")
+	}
+}
@@ -9,6 +9,7 @@ const FormatVersion uint16 = 1
 // Module flags.
 const (
 	FlagConstsBase uint16 = 1 << 0 // ConstsBase field is present in consts section
+	FlagFuncNS     uint16 = 1 << 1 // Func entries carry their namespace name
 )
 
 // Type tags for const pool entries.
@@ -24,6 +25,10 @@ const (
 	TagChar      byte = 0x08
 	TagBigInt    byte = 0x09
 	TagVoid      byte = 0x0A
+	TagInst      byte = 0x0B
+	TagUUID      byte = 0x0C
+	TagRatio     byte = 0x0D
+	TagDecimal   byte = 0x0E
 	TagFunc      byte = 0x10
 	TagVarRef    byte = 0x11
 	TagEmptyList byte = 0x20
@@ -31,6 +36,8 @@ const (
 	TagVector    byte = 0x22
 	TagMap       byte = 0x23
 	TagSet       byte = 0x24
+	TagSortedMap byte = 0x25
+	TagSortedSet byte = 0x26
 	TagRecordType byte = 0x30
 	TagRecord     byte = 0x31
 	TagRegex      byte = 0x32
@@ -16,12 +16,16 @@ import (
 
 type Context struct {
 	parent         *Context
+	runtime        *rt.Runtime
 	consts         *vm.Consts
 	chunk          *vm.CodeChunk
 	formalArgs     map[vm.Symbol]int
 	source         string
+	startLine      int
+	startColumn    int
 	variadric      bool
 	locals         []map[vm.Symbol]int
+	localVars      [][]int // handles of the chunk locals of each scope in locals
 	sp             int
 	spMax          int
 	isFunction     bool
@@ -33,13 +37,19 @@ type Context struct {
 	tailPosition   bool
 	debug          bool
 	defName        string
-	currentForm    vm.Value // tracks the form being compiled for error source info
-	currentList    vm.Value // tracks the enclosing list form for error source info
+	currentForm    vm.Value       // tracks the form being compiled for error source info
+	currentList    vm.Value       // tracks the enclosing list form for error source info
+	formSource     *vm.SourceInfo // source of the innermost form being compiled that has one
+	formList       vm.Value       // the form formSource belongs to
 }
 
+// NewCompiler returns a compiler for the runtime of the calling goroutine,
+// switching it to ns. A goroutine with *ns* bound only switches its binding.
 func NewCompiler(consts *vm.Consts, ns *vm.Namespace) *Context {
-	rt.CurrentNS.SetRoot(ns)
+	r := rt.Current()
+	r.CurrentNSVar().Set(ns)
 	return &Context{
+		runtime:     r,
 		consts:      consts,
 		source:      "<default>",
 		locals:      []map[vm.Symbol]int{},
@@ -59,28 +69,64 @@ func (c *Context) SetSource(source string) *Context {
 	return c
 }
 
+// Source returns the name of the file code is compiled from.
+func (c *Context) Source() string {
+	return c.source
+}
+
+// SetSourcePosition sets the 0-based line and column in the source file
+// where the code compiled next starts, for code taken from the middle of a
+// file like an editor's eval of a single form.
+func (c *Context) SetSourcePosition(line, column int) *Context {
+	c.startLine = line
+	c.startColumn = column
+	return c
+}
+
+// reader registers src for error display and returns a reader for it that
+// starts at the source position.
+func (c *Context) reader(src string) *LispReader {
+	if c.startLine == 0 && c.startColumn == 0 {
+		vm.SourceRegistry.Register(c.source, src)
+	} else {
+		vm.SourceRegistry.RegisterAt(c.source, src, c.startLine, c.startColumn)
+	}
+	r := NewLispReader(strings.NewReader(src), c.source)
+	r.SetPosition(c.startLine, c.startColumn)
+	return r
+}
+
 func (c *Context) Consts() *vm.Consts {
 	return c.consts
 }
 
 func (c *Context) CurrentNS() *vm.Namespace {
-	return rt.CurrentNS.Deref().(*vm.Namespace)
+	return c.runtime.CurrentNSVar().Deref().(*vm.Namespace)
 }
 
 func (c *Context) SetCurrentNS(ns *vm.Namespace) {
-	rt.CurrentNS.SetRoot(ns)
+	c.runtime.CurrentNSVar().Set(ns)
+}
+
+// Runtime returns the runtime the compiler resolves namespaces in.
+func (c *Context) Runtime() *rt.Runtime {
+	return c.runtime
 }
 
 func (c *Context) Compile(s string) (*vm.CodeChunk, error) {
-	vm.SourceRegistry.Register(c.source, s)
-	r := NewLispReader(strings.NewReader(s), c.source)
+	r := c.reader(s)
 	o, err := r.Read()
 	if err != nil {
 		return nil, err
 	}
+	return c.CompileForm(o)
+}
+
+// CompileForm compiles a single form that has already been read.
+func (c *Context) CompileForm(o vm.Value) (*vm.CodeChunk, error) {
 	c.resetSP()
 	c.chunk = vm.NewCodeChunk(c.consts)
-	err = c.compileForm(o)
+	err := c.compileForm(o)
 	c.chunk.SetMaxStack(c.spMax)
 	if err != nil {
 		return nil, err
@@ -97,8 +143,7 @@ func (c *Context) CompileMultiple(reader io.Reader) (*vm.CodeChunk, vm.Value, er
 		return nil, vm.NIL, err
 	}
 	src := string(srcBytes)
-	vm.SourceRegistry.Register(c.source, src)
-	r := NewLispReader(strings.NewReader(src), c.source)
+	r := c.reader(src)
 	chunk := vm.NewCodeChunk(c.consts)
 	var result vm.Value = vm.NIL
 	compiledForms := 0
@@ -154,6 +199,83 @@ func (c *Context) emitWithArg(op int32, arg int) {
 	c.chunk.Append32(arg)
 }
 
+// macroexpand1 expands a list form once if it is a macro call or a
+// (.member target ...) expression. It reports whether the form was expanded.
+func (c *Context) macroexpand1(lst *vm.List) (vm.Value, bool, error) {
+	fnsym, ok := lst.First().(vm.Symbol)
+	if !ok {
+		return lst, false, nil
+	}
+	if _, special := specialForms[fnsym]; special {
+		return lst, false, nil
+	}
+
+	if fnsym[0] == '.' && len(fnsym) > 1 {
+		newform := lst.Next()
+		if newform == nil {
+			return nil, false, NewCompileError("Malformed member expression, expecting (.member target ...)")
+		}
+		if coll, ok := newform.(vm.Collection); ok && coll.RawCount() < 1 {
+			return nil, false, NewCompileError("Malformed member expression, expecting (.member target ...)")
+		}
+		instance := newform.First()
+		member := vm.EmptyList.Cons(fnsym[1:]).Cons(vm.Symbol("quote"))
+		nxt := newform.Next()
+		if nxt == nil {
+			newform = vm.EmptyList.Cons(member).Cons(instance).Cons(vm.Symbol("."))
+		} else {
+			newform = nxt.Cons(member).Cons(instance).Cons(vm.Symbol("."))
+		}
+		return newform, true, nil
+	}
+
+	fvar := c.CurrentNS().Lookup(fnsym)
+	if fvar == vm.NIL || !fvar.(*vm.Var).IsMacro() {
+		return lst, false, nil
+	}
+	nxt := lst.Next()
+	var argvec []vm.Value
+	if nxt != nil {
+		if nl, ok := nxt.(*vm.List); ok {
+			argvec = nl.Unbox().([]vm.Value)
+		} else {
+			for s := nxt; s != nil; s = s.Next() {
+				argvec = append(argvec, s.First())
+			}
+		}
+	}
+	newform, err := fvar.(*vm.Var).Deref().(vm.Fn).Invoke(argvec)
+	if err != nil {
+		return nil, false, NewCompileError(fmt.Sprintf("Executing macro %s (%s) failed", fvar, fvar.(*vm.Var).Deref())).Wrap(err)
+	}
+	return newform, true, nil
+}
+
+// MacroExpand1 expands form once if it is a macro call, returning it as is otherwise.
+func (c *Context) MacroExpand1(form vm.Value) (vm.Value, error) {
+	lst, ok := form.(*vm.List)
+	if !ok {
+		return form, nil
+	}
+	expanded, _, err := c.macroexpand1(lst)
+	return expanded, err
+}
+
+// MacroExpand repeatedly expands form until it is no longer a macro call.
+func (c *Context) MacroExpand(form vm.Value) (vm.Value, error) {
+	for {
+		lst, ok := form.(*vm.List)
+		if !ok {
+			return form, nil
+		}
+		expanded, ok, err := c.macroexpand1(lst)
+		if err != nil || !ok {
+			return expanded, err
+		}
+		form = expanded
+	}
+}
+
 func (c *Context) constant(v vm.Value) int {
 	return c.consts.Intern(v)
 }
@@ -171,6 +293,7 @@ func (c *Context) enterFn(args []vm.Value) (*Context, error) {
 
 	fc := &Context{
 		parent:         c,
+		runtime:        c.runtime,
 		consts:         c.consts,
 		chunk:          fchunk,
 		formalArgs:     make(map[vm.Symbol]int),
@@ -179,6 +302,7 @@ func (c *Context) enterFn(args []vm.Value) (*Context, error) {
 		closedOversSeq: []vm.Symbol{},
 		isFunction:     true,
 		tailPosition:   true,
+		formList:       c.formList, // to locate atoms in the body
 	}
 
 	for i := range args {
@@ -201,6 +325,7 @@ func (c *Context) enterFn(args []vm.Value) (*Context, error) {
 			i = i - 1
 		}
 		fc.formalArgs[s] = i
+		fchunk.AddLocal(vm.LocalVar{Name: string(s), Kind: vm.LocalArg, Index: i, EndIP: -1})
 	}
 	return fc, nil
 }
@@ -210,6 +335,12 @@ func (c *Context) leaveFn(ctx *Context) {
 	fnchunk.SetMaxStack(ctx.spMax)
 	f := vm.MakeFunc(len(ctx.formalArgs), ctx.variadric, fnchunk)
 	f.SetName(c.defName)
+	if ns := c.CurrentNS(); ns != nil {
+		f.SetNS(ns.Name())
+	}
+	if c.formSource != nil {
+		f.SetDefinedAt(*c.formSource)
+	}
 	n := c.constant(f)
 	c.emitWithArg(vm.OP_LOAD_CONST, n)
 	c.incSP(1)
@@ -217,7 +348,8 @@ func (c *Context) leaveFn(ctx *Context) {
 	// if we have a closure on our hands then add closed overs
 	if ctx.isClosure {
 		c.emit(vm.OP_MAKE_CLOSURE)
-		for _, s := range ctx.closedOversSeq {
+		for i, s := range ctx.closedOversSeq {
+			fnchunk.AddLocal(vm.LocalVar{Name: string(s), Kind: vm.LocalClosedOver, Index: i, EndIP: -1})
 			clo := ctx.closedOvers[s]
 			_ = clo.source().emit()
 			c.emit(vm.OP_PUSH_CLOSEDOVER)
@@ -291,12 +423,31 @@ func (c *Context) compileForm(o vm.Value) error {
 	c.currentForm = o
 	defer func() { c.currentForm = prevForm }()
 
-	// Emit source location for this form
+	// Emit source location for this form, and the location of the enclosing
+	// form again once it's compiled, so that the rest of the enclosing form's
+	// code isn't attributed to this one
 	if info := vm.FormSource.Get(o); info != nil {
 		c.chunk.AddSourceInfo(*info)
+		outer, outerList, chunk := c.formSource, c.formList, c.chunk
+		c.formSource, c.formList = info, o
+		defer func() {
+			c.formSource, c.formList = outer, outerList
+			if outer != nil && c.chunk == chunk {
+				chunk.AddSourceInfo(*outer)
+			}
+		}()
+	} else if info := vm.FormSource.GetElem(c.formList, o); info != nil && (c.formSource == nil || info.Line != c.formSource.Line) {
+		// atoms like constants and locals on lines of their own
+		c.chunk.AddSourceInfo(*info)
+		outer, chunk := c.formSource, c.chunk
+		defer func() {
+			if outer != nil && c.chunk == chunk {
+				chunk.AddSourceInfo(*outer)
+			}
+		}()
 	}
 	switch o.Type() {
-	case vm.IntType, vm.FloatType, vm.StringType, vm.NilType, vm.BooleanType, vm.KeywordType, vm.CharType, vm.VoidType, vm.FuncType, vm.BigIntType:
+	case vm.IntType, vm.FloatType, vm.StringType, vm.NilType, vm.BooleanType, vm.KeywordType, vm.CharType, vm.VoidType, vm.FuncType, vm.BigIntType, vm.RatioType, vm.BigDecimalType, vm.InstType, vm.UUIDType:
 		n := c.constant(o)
 		c.emitWithArg(vm.OP_LOAD_CONST, n)
 		c.incSP(1)
@@ -306,7 +457,7 @@ func (c *Context) compileForm(o vm.Value) error {
 		if sns, inner := symVal.Namespaced(); sns != vm.NIL {
 			// Resolve core/* via global core ns so (ns ...) expansion works before refers
 			if string(sns.(vm.Symbol)) == rt.NameCoreNS {
-				target := rt.NS(rt.NameCoreNS)
+				target := c.runtime.NS(rt.NameCoreNS)
 				v := target.Lookup(inner.(vm.Symbol))
 				if v == vm.NIL {
 					return c.compileError(fmt.Sprintf("Can't resolve %s in this context", symVal))
@@ -350,7 +501,7 @@ func (c *Context) compileForm(o vm.Value) error {
 		//	c.incSP(1)
 		//	return nil
 		//}
-		vector := c.constant(rt.CoreNS.Lookup("vector"))
+		vector := c.constant(c.runtime.CoreNS().Lookup("vector"))
 		c.emitWithArg(vm.OP_LOAD_CONST, vector)
 		c.incSP(1)
 		for i := range v {
@@ -366,7 +517,7 @@ func (c *Context) compileForm(o vm.Value) error {
 		tp := c.tailPosition
 		c.tailPosition = false
 
-		hashMap := c.constant(rt.CoreNS.Lookup("hash-map"))
+		hashMap := c.constant(c.runtime.CoreNS().Lookup("hash-map"))
 		c.emitWithArg(vm.OP_LOAD_CONST, hashMap)
 		c.incSP(1)
 
@@ -425,43 +576,24 @@ func (c *Context) compileForm(o vm.Value) error {
 				return formCompiler(c, o)
 			}
 
-			if fnsym[0] == '.' && len(fnsym) > 1 {
-				newform := lst.Next()
-				if newform == nil {
-					return NewCompileError("Malformed member expression, expecting (.member target ...)")
-				}
-				if coll, ok := newform.(vm.Collection); ok && coll.RawCount() < 1 {
-					return NewCompileError("Malformed member expression, expecting (.member target ...)")
-				}
-				instance := newform.First()
-				member := vm.EmptyList.Cons(fnsym[1:]).Cons(vm.Symbol("quote"))
-				nxt := newform.Next()
-				if nxt == nil {
-					newform = vm.EmptyList.Cons(member).Cons(instance).Cons(vm.Symbol("."))
-				} else {
-					newform = nxt.Cons(member).Cons(instance).Cons(vm.Symbol("."))
-				}
-				return c.compileForm(newform)
+			expanded, ok, err := c.macroexpand1(lst)
+			if err != nil {
+				return err
 			}
-
-			fvar := c.CurrentNS().Lookup(fnsym)
-			if fvar != vm.NIL && fvar.(*vm.Var).IsMacro() {
-				nxt := lst.Next()
-				var argvec []vm.Value
-				if nxt != nil {
-					if nl, ok := nxt.(*vm.List); ok {
-						argvec = nl.Unbox().([]vm.Value)
-					} else {
-						for s := nxt; s != nil; s = s.Next() {
-							argvec = append(argvec, s.First())
-						}
-					}
+			if ok {
+				// expansions point back at the macro call, or at the form
+				// it came from when the call was itself made by a macro
+				from := o
+				info := vm.FormSource.Get(o)
+				if info == nil {
+					from = prevForm
+					info = vm.FormSource.Get(prevForm)
 				}
-				newform, err := fvar.(*vm.Var).Deref().(vm.Fn).Invoke(argvec)
-				if err != nil {
-					return NewCompileError(fmt.Sprintf("Executing macro %s (%s) failed", fvar, fvar.(*vm.Var).Deref())).Wrap(err)
+				if info != nil && vm.FormSource.Get(expanded) == nil {
+					vm.FormSource.Set(expanded, *info)
+					vm.FormSource.CopyElems(expanded, from)
 				}
-				return c.compileForm(newform)
+				return c.compileForm(expanded)
 			}
 		}
 
@@ -521,6 +653,11 @@ func (c *Context) compileForm(o vm.Value) error {
 		c.decSP(argc)
 
 		c.tailPosition = tp
+	default:
+		// Other values, e.g. returned by data readers, evaluate to themselves.
+		n := c.constant(o)
+		c.emitWithArg(vm.OP_LOAD_CONST, n)
+		c.incSP(1)
 	}
 	return nil
 }
@@ -589,14 +726,21 @@ func (c *Context) updatePlaceholderArg(placeholder int, arg int) {
 
 func (c *Context) pushLocals() {
 	c.locals = append(c.locals, map[vm.Symbol]int{})
+	c.localVars = append(c.localVars, nil)
 }
 
 func (c *Context) popLocals() {
+	for _, h := range c.localVars[len(c.localVars)-1] {
+		c.chunk.EndLocal(h)
+	}
 	c.locals = c.locals[0 : len(c.locals)-1]
+	c.localVars = c.localVars[0 : len(c.localVars)-1]
 }
 
 func (c *Context) addLocal(name vm.Symbol) {
 	c.locals[len(c.locals)-1][name] = c.sp - 1
+	h := c.chunk.AddLocal(vm.LocalVar{Name: string(name), Kind: vm.LocalStack, Index: c.sp - 1, StartIP: c.currentAddress(), EndIP: -1})
+	c.localVars[len(c.localVars)-1] = append(c.localVars[len(c.localVars)-1], h)
 }
 
 func (c *Context) incSP(i int) {
@@ -823,21 +967,43 @@ func tryCompiler(c *Context, form vm.Value) error {
 		c.decSP(1)
 		c.popLocals()
 	}
-	// else: no catch, the thrown value is already on stack as the result
+
+	var jumpOverUnwindAddr int
+	if hasCatch && len(finallyForms) > 0 {
+		jumpOverUnwindAddr = c.currentAddress()
+		c.emitWithArg(vm.OP_JUMP, 0) // placeholder
+	}
+
+	// Unwind block (if finally is present): runs finally with the thrown value
+	// on the stack, then rethrows it. The VM jumps here for errors try/catch
+	// can't handle, like interrupts. Without catch, it's the catch block too.
+	// The normal path runs its own copy of finally below.
+	if len(finallyForms) > 0 {
+		unwindAddr := c.currentAddress()
+		c.chunk.Update32(tryPushAddr+2, int32(unwindAddr-tryPushAddr))
+		for _, ff := range finallyForms {
+			err := c.compileForm(ff)
+			if err != nil {
+				return err
+			}
+			c.emit(vm.OP_POP)
+			c.decSP(1)
+		}
+		c.emit(vm.OP_THROW)
+	}
 
 	// Patch jump-over-catch
 	afterCatch := c.currentAddress()
 	c.chunk.Update32(jumpOverCatchAddr+1, int32(afterCatch-jumpOverCatchAddr))
+	if jumpOverUnwindAddr != 0 {
+		c.chunk.Update32(jumpOverUnwindAddr+1, int32(afterCatch-jumpOverUnwindAddr))
+	}
 
 	// Patch TRY_PUSH catchOffset (relative to TRY_PUSH instruction)
 	c.chunk.Update32(tryPushAddr+1, int32(catchAddr-tryPushAddr))
 
 	// Finally block (if present)
 	if len(finallyForms) > 0 {
-		finallyAddr := c.currentAddress()
-		// Patch TRY_PUSH finallyOffset
-		c.chunk.Update32(tryPushAddr+2, int32(finallyAddr-tryPushAddr))
-
 		for i, ff := range finallyForms {
 			err := c.compileForm(ff)
 			if err != nil {
@@ -1216,7 +1382,7 @@ func doCompiler(c *Context, form vm.Value) error {
 							if qqN != nil {
 								namev := qqN.First()
 								if namev.Type() == vm.SymbolType {
-									if ns := rt.NS(string(namev.(vm.Symbol))); ns != nil {
+									if ns := c.runtime.NS(string(namev.(vm.Symbol))); ns != nil {
 										c.SetCurrentNS(ns)
 									}
 								}
@@ -1248,7 +1414,7 @@ func doCompiler(c *Context, form vm.Value) error {
 									if qqaN != nil && qqbN != nil {
 										alias := qqaN.First().(vm.Symbol)
 										nsname := qqbN.First().(vm.Symbol)
-										if target := rt.NS(string(nsname)); target != nil {
+										if target := c.runtime.NS(string(nsname)); target != nil {
 											c.CurrentNS().Alias(alias, target)
 										}
 									}
@@ -1282,7 +1448,7 @@ func doCompiler(c *Context, form vm.Value) error {
 								qqN := qq.Next()
 								if qqN != nil {
 									nsname := qqN.First().(vm.Symbol)
-									if target := rt.NS(string(nsname)); target != nil {
+									if target := c.runtime.NS(string(nsname)); target != nil {
 										c.CurrentNS().Refer(target, aliasStr, all)
 									}
 								}
@@ -1309,7 +1475,7 @@ func doCompiler(c *Context, form vm.Value) error {
 									qffN := qff.Next()
 									qttN := qtt.Next()
 									if qnnN != nil && qffN != nil && qttN != nil && qnn.First() == vm.Symbol("quote") && qff.First() == vm.Symbol("quote") && qtt.First() == vm.Symbol("quote") {
-										fromNs := rt.NS(string(qnnN.First().(vm.Symbol)))
+										fromNs := c.runtime.NS(string(qnnN.First().(vm.Symbol)))
 										from := qffN.First().(vm.Symbol)
 										to := qttN.First().(vm.Symbol)
 										if fromNs != nil {
@@ -1332,7 +1498,7 @@ func doCompiler(c *Context, form vm.Value) error {
 								qqN := qq.Next()
 								if qqN != nil {
 									nsname := qqN.First().(vm.Symbol)
-									if target := rt.NS(string(nsname)); target != nil {
+									if target := c.runtime.NS(string(nsname)); target != nil {
 										c.CurrentNS().Refer(target, "", true)
 									}
 								}
@@ -1374,56 +1540,100 @@ func defCompiler(c *Context, form vm.Value) error {
 		}
 	}
 	l := len(args)
-	if l != 2 {
-		return NewCompileError(fmt.Sprintf("def: wrong number of forms (%d), need 2", l))
+	if l < 1 || l > 3 {
+		return NewCompileError(fmt.Sprintf("def: wrong number of forms (%d), need 1 to 3", l))
 	}
-	var meta vm.Value = vm.NIL
 	sym := args[0]
-	val := args[1]
-	if sym.Type() == vm.ListType {
+	// (def name) leaves the var unbound
+	var val vm.Value
+	if l > 1 {
+		val = args[l-1]
+	}
+	var metas []vm.Value
+	for sym.Type() == vm.ListType {
 		ss := sym.(vm.Seq)
 		if ss.First() != vm.Symbol("with-meta") {
 			return NewCompileError(fmt.Sprintf("def: first argument must be a symbol, got (%v)", sym))
 		}
 		ss = ss.Next()
 		sym = ss.First()
-		meta = ss.Next().First()
+		metas = append(metas, ss.Next().First())
 	}
 	if sym.Type() != vm.SymbolType {
 		return NewCompileError(fmt.Sprintf("def: first argument must be a symbol, got (%v)", sym))
 	}
-	c.defName = sym.String()
-	varr := c.CurrentNS().LookupOrAdd(sym.(vm.Symbol))
-	if meta != vm.NIL {
-		if m, ok := meta.(*vm.PersistentMap); ok {
-			if vm.IsTruthy(m.ValueAt(vm.Keyword("dynamic"))) {
-				varr.(*vm.Var).SetDynamic()
-			}
-			if vm.IsTruthy(m.ValueAt(vm.Keyword("private"))) {
-				varr.(*vm.Var).SetPrivate()
-			}
-		} else if m, ok := meta.(vm.Map); ok {
-			if m[vm.Keyword("dynamic")] == vm.TRUE {
-				varr.(*vm.Var).SetDynamic()
-			}
-			if m[vm.Keyword("private")] == vm.TRUE {
-				varr.(*vm.Var).SetPrivate()
+	meta := vm.EmptyPersistentMap
+	// (with-meta (with-meta name inner) outer), outer wins
+	for i := len(metas) - 1; i >= 0; i-- {
+		sq, ok := metas[i].(vm.Sequable)
+		if !ok || metas[i] == vm.NIL {
+			continue
+		}
+		for e := sq.Seq(); e != nil && e != vm.EmptyList; e = e.Next() {
+			kv, ok := e.First().(vm.ArrayVector)
+			if !ok || len(kv) != 2 {
+				continue
 			}
+			meta = meta.Assoc(kv[0], kv[1]).(*vm.PersistentMap)
+		}
+	}
+	if l == 3 {
+		doc, ok := args[1].(vm.String)
+		if !ok {
+			return NewCompileError(fmt.Sprintf("def: docstring must be a string, got %v", args[1]))
 		}
+		meta = meta.Assoc(vm.Keyword("doc"), doc).(*vm.PersistentMap)
+	}
+	if err := vm.CheckDef(c.CurrentNS(), sym.(vm.Symbol)); err != nil {
+		return NewCompileError("def").Wrap(err)
+	}
+	c.defName = sym.String()
+	varr := c.CurrentNS().LookupOrAdd(sym.(vm.Symbol))
+	if vm.IsTruthy(meta.ValueAt(vm.Keyword("dynamic"))) {
+		varr.(*vm.Var).SetDynamic()
+	}
+	if vm.IsTruthy(meta.ValueAt(vm.Keyword("private"))) {
+		varr.(*vm.Var).SetPrivate()
 	}
 	c.emitWithArg(vm.OP_LOAD_CONST, c.constant(varr))
 	c.incSP(1)
-	err := c.compileForm(val)
-	if err != nil {
-		return NewCompileError("compiling def value").Wrap(err)
+	if val != nil {
+		if err := c.compileForm(val); err != nil {
+			return NewCompileError("compiling def value").Wrap(err)
+		}
+		c.emit(vm.OP_SET_VAR)
+		c.decSP(1)
+	}
+	if err := c.compileForm(c.varMeta(form, meta)); err != nil {
+		return NewCompileError("compiling def metadata").Wrap(err)
 	}
-	c.emit(vm.OP_SET_VAR)
+	c.emit(vm.OP_SET_VAR_META)
 	c.decSP(1)
 	c.tailPosition = tc
 	c.defName = ""
 	return nil
 }
 
+// varMeta returns the metadata form of a def: the given metadata with
+// symbols quoted, and where the def comes from.
+func (c *Context) varMeta(form vm.Value, meta *vm.PersistentMap) vm.Value {
+	var m vm.Associative = vm.EmptyPersistentMap
+	for e := meta.Seq(); e != nil && e != vm.EmptyList; e = e.Next() {
+		kv := e.First().(vm.ArrayVector)
+		v := kv[1]
+		if v.Type() == vm.SymbolType {
+			v = vm.NewList([]vm.Value{vm.Symbol("quote"), v})
+		}
+		m = m.Assoc(kv[0], v)
+	}
+	if info := vm.FormSource.Get(form); info != nil && meta.ValueAt(vm.Keyword("line")) == vm.NIL {
+		m = m.Assoc(vm.Keyword("file"), vm.String(info.File))
+		m = m.Assoc(vm.Keyword("line"), vm.Int(info.Line+1))
+		m = m.Assoc(vm.Keyword("column"), vm.Int(info.Column+1))
+	}
+	return m
+}
+
 func setBangCompiler(c *Context, form vm.Value) error {
 	tc := c.tailPosition
 	c.tailPosition = false
@@ -1447,6 +1657,15 @@ func setBangCompiler(c *Context, form vm.Value) error {
 	if sym.Type() != vm.SymbolType {
 		return NewCompileError(fmt.Sprintf("set!: first argument must be a symbol, got (%v)", sym))
 	}
+	// locals, like the immutable fields of a deftype in its methods, can't change
+	if c.symbolLookup(sym.(vm.Symbol)) != nil {
+		return c.compileError(fmt.Sprintf("set!: cannot assign to non-mutable %s", sym))
+	}
+	// set! goes through var-set so that it updates the goroutine's dynamic
+	// binding when there is one and the root otherwise
+	varSet := c.constant(c.runtime.CoreNS().Lookup("var-set"))
+	c.emitWithArg(vm.OP_LOAD_CONST, varSet)
+	c.incSP(1)
 	varr := c.constant(c.CurrentNS().Lookup(sym.(vm.Symbol)))
 	c.emitWithArg(vm.OP_LOAD_CONST, varr)
 	c.incSP(1)
@@ -1454,8 +1673,8 @@ func setBangCompiler(c *Context, form vm.Value) error {
 	if err != nil {
 		return NewCompileError("compiling set! value").Wrap(err)
 	}
-	c.emit(vm.OP_SET_VAR)
-	c.decSP(1)
+	c.emitWithArg(vm.OP_INVOKE, 2)
+	c.decSP(2)
 	c.tailPosition = tc
 	return nil
 }
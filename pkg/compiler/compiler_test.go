@@ -112,6 +112,38 @@ func TestContext_CompileVar(t *testing.T) {
 	assert.Equal(t, out, out2)
 }
 
+func TestContext_CompileDefMetadata(t *testing.T) {
+	out, err := Eval("(def ^:private documented \"Some docs.\" 42)")
+	assert.NoError(t, err)
+	v, ok := out.(*vm.Var)
+	assert.True(t, ok)
+	assert.True(t, v.IsPrivate())
+
+	meta := v.Meta().(vm.Lookup)
+	assert.Equal(t, vm.String("Some docs."), meta.ValueAt(vm.Keyword("doc")))
+	assert.Equal(t, vm.Symbol("documented"), meta.ValueAt(vm.Keyword("name")))
+	assert.Equal(t, vm.Int(1), meta.ValueAt(vm.Keyword("line")))
+	assert.Equal(t, vm.Int(1), meta.ValueAt(vm.Keyword("column")))
+
+	_, err = Eval("(def bad 1 2)")
+	assert.Error(t, err)
+}
+
+func TestContext_SourcePosition(t *testing.T) {
+	c := NewCompiler(CoreConsts(), rt.NS(rt.NameCoreNS))
+	c.SetSource("positioned.lg").SetSourcePosition(9, 4)
+	_, out, err := c.CompileMultiple(strings.NewReader("(def positioned 1)\n(def below 2)"))
+	assert.NoError(t, err)
+	meta := out.(*vm.Var).Meta().(vm.Lookup)
+	assert.Equal(t, vm.String("positioned.lg"), meta.ValueAt(vm.Keyword("file")))
+	assert.Equal(t, vm.Int(11), meta.ValueAt(vm.Keyword("line")))
+	assert.Equal(t, vm.Int(1), meta.ValueAt(vm.Keyword("column")))
+	assert.Equal(t, "    (def positioned 1)", vm.SourceRegistry.GetLine("positioned.lg", 9))
+
+	_, _, err = c.CompileMultiple(strings.NewReader("(undefined-thing)"))
+	assert.Contains(t, vm.FormatError(err), "positioned.lg:10:5")
+}
+
 func TestContext_CompileMultiArityFn(t *testing.T) {
 	src := `(def f (fn* ([a] (+ a 1)) 
 						([a b] (+ a b)) 
@@ -132,3 +164,31 @@ func TestContext_CompileMultiArityFn(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, vm.TRUE, val)
 }
+
+func TestContext_StackTrace(t *testing.T) {
+	src := `(def trace-inner (fn [x] (+ 1 (first x))))
+			(def trace-outer (fn [x] (let [r (trace-inner x)] r)))
+			(trace-outer 5)`
+
+	ns := rt.NS(rt.NameCoreNS)
+	ctx := NewCompiler(vm.NewConsts(), ns)
+	ctx.SetSource("trace.lg")
+
+	_, _, err := ctx.CompileMultiple(strings.NewReader(src))
+	assert.Error(t, err)
+
+	stack := vm.StackTrace(err)
+	if assert.Len(t, stack, 4) {
+		assert.Equal(t, "first", stack[0].Fn)
+		assert.Nil(t, stack[0].Source)
+		assert.Equal(t, "trace-inner", stack[1].Fn)
+		assert.Equal(t, ns.Name(), stack[1].NS)
+		assert.Equal(t, "trace.lg", stack[1].Source.File)
+		assert.Equal(t, 0, stack[1].Source.Line)
+		assert.Equal(t, "trace-outer", stack[2].Fn)
+		assert.Equal(t, 1, stack[2].Source.Line)
+		assert.Equal(t, "", stack[3].Fn)
+		assert.Equal(t, 2, stack[3].Source.Line)
+	}
+	assert.Contains(t, vm.FormatError(err), "at core/trace-outer (trace.lg:2:")
+}
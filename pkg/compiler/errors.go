@@ -62,6 +62,11 @@ func (r *ReaderError) GetCause() error {
 	return r.cause
 }
 
+// Unwrap lets errors.Is and errors.As look through the cause.
+func (r *ReaderError) Unwrap() error {
+	return r.cause
+}
+
 type CompileError struct {
 	message string
 	source  *vm.SourceInfo
@@ -121,6 +126,11 @@ func (r *CompileError) GetCause() error {
 	return r.cause
 }
 
+// Unwrap lets errors.Is and errors.As look through the cause.
+func (r *CompileError) Unwrap() error {
+	return r.cause
+}
+
 func isErrorEOF(err error) bool {
 	if err == io.EOF {
 		return true
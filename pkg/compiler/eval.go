@@ -6,36 +6,32 @@
 package compiler
 
 import (
-	"bytes"
+	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/nooga/let-go/pkg/bytecode"
 	"github.com/nooga/let-go/pkg/rt"
 	"github.com/nooga/let-go/pkg/vm"
 )
 
-var consts *vm.Consts
-
-// CoreConsts returns the global const pool populated during core boot.
-// Used as parent for layered child pools during user code compilation.
+// CoreConsts returns the const pool populated during core boot of the current
+// runtime. Used as parent for layered child pools during user code compilation.
 func CoreConsts() *vm.Consts {
-	return consts
+	return rt.Current().Consts()
 }
 
-// precompiledNS holds decoded namespace chunks from the bundle.
-var precompiledNS map[string]*vm.CodeChunk
-
-// PrecompiledNSChunk returns the precompiled main chunk for a namespace, or nil.
+// PrecompiledNSChunk returns the precompiled main chunk for a namespace of the
+// current runtime, or nil.
 func PrecompiledNSChunk(name string) *vm.CodeChunk {
-	if precompiledNS == nil {
-		return nil
-	}
-	return precompiledNS[name]
+	return rt.Current().PrecompiledNSChunk(name)
 }
 
 func Eval(src string) (vm.Value, error) {
 	ns := rt.NS(rt.NameCoreNS)
-	compiler := NewCompiler(consts, ns)
+	compiler := NewCompiler(CoreConsts(), ns)
 
 	_, out, err := compiler.CompileMultiple(strings.NewReader(src))
 	if err != nil {
@@ -52,13 +48,44 @@ func ReadString(s string) (vm.Value, error) {
 }
 
 func evalInit() {
-	consts = vm.NewConsts()
+	if err := loadCore(rt.DefaultRuntime()); err != nil {
+		panic("core.lg compilation failed: " + err.Error())
+	}
+
+	// Wire up EDN reader for pod support
+	rt.SetReadEDN(func(s string) (vm.Value, error) {
+		return ReadString(s)
+	})
+
+	// Wire up namespace-aware eval for pod client-side code
+	rt.SetEvalInNS(func(code string, ns *vm.Namespace) (vm.Value, error) {
+		c := NewCompiler(CoreConsts(), ns)
+		_, out, err := c.CompileMultiple(strings.NewReader(code))
+		return out, err
+	})
+}
+
+// NewRuntime returns an isolated runtime with core loaded. It shares the
+// code of the precompiled core bundle with every other runtime, but has its
+// own consts, functions, namespaces and vars.
+func NewRuntime() (*rt.Runtime, error) {
+	r := rt.NewRuntime()
+	if err := loadCore(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadCore loads core into r, from the precompiled bundle when possible.
+func loadCore(r *rt.Runtime) error {
+	defer r.Enter()()
+	r.SetConsts(vm.NewConsts())
 
 	// Try loading pre-compiled bundle
 	if len(rt.CoreCompiledLGB) > 0 {
-		if err := loadPrecompiledBundle(); err == nil {
+		if err := loadPrecompiledBundle(r); err == nil {
 			postCoreInit()
-			return
+			return nil
 		}
 		// Fall through to source compilation on error
 	}
@@ -66,33 +93,38 @@ func evalInit() {
 	// Original path: compile from source
 	_, err := Eval(rt.CoreSrc)
 	if err != nil {
-		panic("core.lg compilation failed: " + err.Error())
+		return err
 	}
 	postCoreInit()
+	return nil
 }
 
-func loadPrecompiledBundle() error {
+// coreTemplate is the precompiled core bundle, decoded once for all runtimes.
+var coreTemplate = sync.OnceValues(func() (*bytecode.Template, error) {
+	return bytecode.DecodeTemplate(rt.CoreCompiledLGB)
+})
+
+func loadPrecompiledBundle(r *rt.Runtime) error {
+	template, err := coreTemplate()
+	if err != nil {
+		return err
+	}
 	resolve := func(nsName, name string) *vm.Var {
 		// Use DefNSBare to create minimal namespaces without triggering
 		// the loader. This ensures vars have a home namespace but the
 		// actual loading (executing precompiled chunks) happens on demand.
-		n := rt.DefNSBare(nsName)
-		// Use LookupLocal to check only the namespace's own registry,
-		// not refers. This matches how the compiler creates vars via
-		// LookupOrAdd (which also skips refers).
-		v := n.LookupLocal(vm.Symbol(name))
-		if v == nil {
-			return n.Def(name, vm.NIL)
-		}
-		return v
+		n := r.DefNSBare(nsName)
+		// Intern checks only the namespace's own registry, not refers.
+		// This matches how the compiler creates vars via LookupOrAdd.
+		return n.Intern(vm.Symbol(name))
 	}
-	unit, err := bytecode.DecodeToExecUnit(bytes.NewReader(rt.CoreCompiledLGB), resolve)
+	unit, err := template.Instantiate(resolve)
 	if err != nil {
 		return err
 	}
 
-	// Use the decoded const pool as the global pool
-	consts = unit.Consts
+	// Use the decoded const pool as the runtime's pool
+	r.SetConsts(unit.Consts)
 
 	// Execute core's main chunk to replay all def/defn/defmacro definitions
 	f := vm.NewFrame(unit.MainChunk, nil)
@@ -103,15 +135,10 @@ func loadPrecompiledBundle() error {
 	}
 
 	// Store remaining namespace chunks for on-demand loading by the resolver.
-	// Mark non-core namespaces as needing load so LookupOrRegisterNS triggers
-	// the loader even though the namespace already exists in the registry.
+	// Non-core namespaces are marked as needing load so LookupOrRegisterNS
+	// triggers the loader even though the namespace already exists.
 	if unit.NSChunks != nil {
-		precompiledNS = unit.NSChunks
-		for name := range precompiledNS {
-			if name != "core" {
-				rt.MarkNSNeedsLoad(name)
-			}
-		}
+		r.SetPrecompiledNS(unit.NSChunks)
 	}
 
 	return nil
@@ -120,30 +147,127 @@ func loadPrecompiledBundle() error {
 func postCoreInit() {
 	// Register read-string (needs the reader which lives in the compiler package)
 	readStringFn, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
-		if len(vs) != 1 {
+		if len(vs) != 1 && len(vs) != 2 {
 			return vm.NIL, nil
 		}
-		s, ok := vs[0].(vm.String)
+		s, ok := vs[len(vs)-1].(vm.String)
 		if !ok {
 			return vm.NIL, nil
 		}
-		return ReadString(string(s))
+		reader := NewLispReader(strings.NewReader(string(s)), "<read-string>")
+		if len(vs) == 2 {
+			// (read-string {:readers {tag fn} :default fn} s)
+			if opts, ok := vs[0].(vm.Lookup); ok {
+				reader.SetDataReaders(opts.ValueAt(vm.Keyword("readers")), opts.ValueAt(vm.Keyword("default")))
+			}
+		}
+		return reader.Read()
 	})
 	coreNS := rt.NS(rt.NameCoreNS)
 	rsVar := coreNS.LookupOrAdd(vm.Symbol("read-string"))
 	rsVar.(*vm.Var).SetRoot(readStringFn)
 
-	// Wire up EDN reader for pod support
-	rt.SetReadEDN(func(s string) (vm.Value, error) {
-		return ReadString(s)
+	installEvalFns(coreNS)
+
+	// test, walk, etc. are demand-loaded via resolver when required
+}
+
+// currentCompiler returns a compiler for the current namespace of the calling
+// goroutine's runtime. Each one gets its own const pool so evaluated code can
+// be collected once it's no longer referenced.
+func currentCompiler() *Context {
+	ns := rt.CurrentNSVar().Deref().(*vm.Namespace)
+	return NewCompiler(vm.NewConsts(), ns)
+}
+
+// evalForm compiles and runs form. Like in Clojure, the forms of a top-level
+// do are evaluated one by one so that macros they define can be used right away.
+func evalForm(form vm.Value) (vm.Value, error) {
+	if lst, ok := form.(*vm.List); ok && lst.First() == vm.Symbol("do") {
+		var out vm.Value = vm.NIL
+		for s := lst.Next(); s != nil; s = s.Next() {
+			var err error
+			out, err = evalForm(s.First())
+			if err != nil {
+				return vm.NIL, err
+			}
+		}
+		return out, nil
+	}
+	chunk, err := currentCompiler().CompileForm(form)
+	if err != nil {
+		return vm.NIL, err
+	}
+	f := vm.NewFrame(chunk, nil)
+	out, err := f.Run()
+	vm.ReleaseFrame(f)
+	return out, err
+}
+
+// loadReader compiles and runs all forms from src, restoring the current
+// namespace afterwards like Clojure's load does.
+func loadReader(src io.Reader, source string) (vm.Value, error) {
+	c := currentCompiler()
+	c.SetSource(source)
+	ons := c.CurrentNS()
+	defer c.SetCurrentNS(ons)
+	_, out, err := c.CompileMultiple(src)
+	return out, err
+}
+
+// installEvalFns defines the core functions exposing the compiler to let-go code.
+func installEvalFns(ns *vm.Namespace) {
+	eval, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		return evalForm(vs[0])
 	})
 
-	// Wire up namespace-aware eval for pod client-side code
-	rt.SetEvalInNS(func(code string, ns *vm.Namespace) (vm.Value, error) {
-		c := NewCompiler(consts, ns)
-		_, out, err := c.CompileMultiple(strings.NewReader(code))
-		return out, err
+	macroexpand1, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		return currentCompiler().MacroExpand1(vs[0])
 	})
 
-	// test, walk, etc. are demand-loaded via resolver when required
+	macroexpand, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		return currentCompiler().MacroExpand(vs[0])
+	})
+
+	loadString, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		s, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("load-string expected String")
+		}
+		return loadReader(strings.NewReader(string(s)), "<load-string>")
+	})
+
+	loadFile, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		path, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("load-file expected String")
+		}
+		f, err := os.Open(string(path))
+		if err != nil {
+			return vm.NIL, err
+		}
+		defer f.Close()
+		return loadReader(f, string(path))
+	})
+
+	ns.Def("eval", eval)
+	ns.Def("macroexpand-1", macroexpand1)
+	ns.Def("macroexpand", macroexpand)
+	ns.Def("load-string", loadString)
+	ns.Def("load-file", loadFile)
 }
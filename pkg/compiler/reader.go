@@ -9,6 +9,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math/big"
 	"strconv"
 	"strings"
 	"unicode"
@@ -52,6 +53,18 @@ type LispReader struct {
 	Tokens     []Token
 	tokenizing bool
 	splicing   bool
+
+	// readers and defaultReader take precedence over *data-readers* and
+	// *default-data-reader-fn* when reading tagged literals.
+	readers       vm.Value
+	defaultReader vm.Value
+}
+
+// SetDataReaders sets the tag readers used by r in addition to *data-readers*,
+// as given in the :readers and :default options of read-string.
+func (r *LispReader) SetDataReaders(readers vm.Value, defaultReader vm.Value) {
+	r.readers = readers
+	r.defaultReader = defaultReader
 }
 
 func NewLispReader(r io.Reader, inputName string) *LispReader {
@@ -61,6 +74,13 @@ func NewLispReader(r io.Reader, inputName string) *LispReader {
 	}
 }
 
+// SetPosition makes r count lines and columns (0-based) from line and column,
+// for reading a snippet taken from the middle of a file.
+func (r *LispReader) SetPosition(line, column int) {
+	r.line = line
+	r.column = column
+}
+
 func NewLispReaderTokenizing(r io.Reader, inputName string) *LispReader {
 	return &LispReader{
 		inputName:  inputName,
@@ -228,7 +248,7 @@ func interpretToken(r *LispReader, t vm.Value) (vm.Value, error) {
 					return vm.NIL, NewReaderError(r, fmt.Sprintf("invalid token: %s", ss))
 				}
 				// Look up alias in current namespace
-				cns := rt.CurrentNS.Deref().(*vm.Namespace)
+				cns := rt.CurrentNSVar().Deref().(*vm.Namespace)
 				resolved := cns.ResolveAlias(vm.Symbol(alias))
 				if resolved == nil {
 					return vm.NIL, NewReaderError(r, fmt.Sprintf("invalid token: %s, namespace alias %s not found", ss, alias))
@@ -239,7 +259,7 @@ func interpretToken(r *LispReader, t vm.Value) (vm.Value, error) {
 				if strings.ContainsAny(onom, ":") {
 					return vm.NIL, NewReaderError(r, fmt.Sprintf("invalid token: %s", ss))
 				}
-				nom = rt.CurrentNS.Deref().(*vm.Namespace).Name() + "/" + onom
+				nom = rt.CurrentNSVar().Deref().(*vm.Namespace).Name() + "/" + onom
 			}
 		}
 		if strings.ContainsAny(nom, ":") {
@@ -499,6 +519,25 @@ func readNumber(r *LispReader, ru rune) (vm.Value, error) {
 			return bi, nil
 		}
 	}
+	// BigDecimal suffix: 1.5M
+	if len(sn) > 1 && sn[len(sn)-1] == 'M' {
+		d, err := vm.ParseBigDecimal(sn[:len(sn)-1])
+		if err != nil {
+			return vm.NIL, NewReaderError(r, fmt.Sprintf("invalid number: %s", sn))
+		}
+		r.closeToken(TokenNumber)
+		return d, nil
+	}
+	// Ratio: 1/3
+	if i := strings.IndexByte(sn, '/'); i > 0 {
+		num, ok1 := new(big.Int).SetString(strings.TrimPrefix(sn[:i], "+"), 10)
+		den, ok2 := new(big.Int).SetString(sn[i+1:], 10)
+		if !ok1 || !ok2 || den.Sign() <= 0 {
+			return vm.NIL, NewReaderError(r, fmt.Sprintf("invalid number: %s", sn))
+		}
+		r.closeToken(TokenNumber)
+		return vm.NewRatio(new(big.Rat).SetFrac(num, den)), nil
+	}
 	// Try int first
 	i, err := strconv.Atoi(sn)
 	if err == nil {
@@ -521,6 +560,12 @@ func readNumber(r *LispReader, ru rune) (vm.Value, error) {
 	return vm.NIL, NewReaderError(r, fmt.Sprintf("invalid number: %s", sn))
 }
 
+// elemSource is where an element of a form being read starts.
+type elemSource struct {
+	form vm.Value
+	info vm.SourceInfo
+}
+
 func readList(r *LispReader, _ rune) (vm.Value, error) {
 	startLine := r.line
 	startCol := r.column - 1 // -1 because '(' was already consumed
@@ -528,6 +573,7 @@ func readList(r *LispReader, _ rune) (vm.Value, error) {
 		startCol = 0
 	}
 	var ret []vm.Value
+	var elems []elemSource
 	for {
 		ch2, err := r.eatWhitespace()
 		if err != nil {
@@ -540,10 +586,15 @@ func readList(r *LispReader, _ rune) (vm.Value, error) {
 		if err = r.unread(); err != nil {
 			return vm.NIL, NewReaderError(r, "unexpected error").Wrap(err)
 		}
+		line, col := r.line, r.column
 		form, err := r.Read()
 		if err != nil {
 			return vm.NIL, NewReaderError(r, "unexpected error").Wrap(err)
 		}
+		if line != startLine {
+			// where atoms on later lines are, for line coverage
+			elems = append(elems, elemSource{form, vm.SourceInfo{File: r.inputName, Line: line, Column: col}})
+		}
 		ret = appendNonVoid(r, ret, form)
 	}
 	result, err := vm.ListType.Box(ret)
@@ -553,6 +604,9 @@ func readList(r *LispReader, _ rune) (vm.Value, error) {
 	vm.FormSource.Set(result.(vm.Value), vm.SourceInfo{
 		File: r.inputName, Line: startLine, Column: startCol,
 	})
+	for _, e := range elems {
+		vm.FormSource.SetElem(result, e.form, e.info)
+	}
 	return result, nil
 }
 
@@ -1036,11 +1090,81 @@ func readHashMacro(r *LispReader, _ rune) (vm.Value, error) {
 	}
 	macro, ok := hashMacros[ch]
 	if !ok {
+		if unicode.IsLetter(ch) {
+			return readTagged(r, ch)
+		}
 		return vm.NIL, NewReaderError(r, "invalid hash macro")
 	}
 	return macro(r, ch)
 }
 
+// readTagged reads a tagged literal like #inst "2020-01-01" and passes the
+// form to the reader function registered for the tag.
+func readTagged(r *LispReader, ch rune) (vm.Value, error) {
+	r.openToken()
+	t, err := readToken(r, ch)
+	if err != nil {
+		return vm.NIL, NewReaderError(r, "reading tag").Wrap(err)
+	}
+	r.closeToken(TokenSpecial)
+	tag := t.(vm.Symbol)
+	form, err := r.Read()
+	if err != nil {
+		return vm.NIL, NewReaderError(r, fmt.Sprintf("reading form tagged with #%s", tag)).Wrap(err)
+	}
+	if r.tokenizing {
+		return form, nil
+	}
+	if fn := r.dataReader(tag); fn != nil {
+		v, err := fn.Invoke([]vm.Value{form})
+		if err != nil {
+			return vm.NIL, NewReaderError(r, fmt.Sprintf("reading #%s", tag)).Wrap(err)
+		}
+		return v, nil
+	}
+	if fn := r.defaultDataReader(); fn != nil {
+		v, err := fn.Invoke([]vm.Value{tag, form})
+		if err != nil {
+			return vm.NIL, NewReaderError(r, fmt.Sprintf("reading #%s", tag)).Wrap(err)
+		}
+		return v, nil
+	}
+	return vm.NIL, NewReaderError(r, fmt.Sprintf("no reader function for tag %s", tag))
+}
+
+// dataReader finds the reader function for tag in the reader's own readers,
+// *data-readers* and default-data-readers, in that order.
+func (r *LispReader) dataReader(tag vm.Symbol) vm.Fn {
+	core := rt.CoreNS()
+	for _, readers := range []vm.Value{r.readers, derefCore(core, "*data-readers*"), derefCore(core, "default-data-readers")} {
+		m, ok := readers.(vm.Lookup)
+		if !ok {
+			continue
+		}
+		if fn, ok := m.ValueAt(tag).(vm.Fn); ok {
+			return fn
+		}
+	}
+	return nil
+}
+
+func (r *LispReader) defaultDataReader() vm.Fn {
+	if fn, ok := r.defaultReader.(vm.Fn); ok {
+		return fn
+	}
+	if fn, ok := derefCore(rt.CoreNS(), "*default-data-reader-fn*").(vm.Fn); ok {
+		return fn
+	}
+	return nil
+}
+
+func derefCore(core *vm.Namespace, name string) vm.Value {
+	if v, ok := core.Lookup(vm.Symbol(name)).(*vm.Var); ok {
+		return v.Deref()
+	}
+	return vm.NIL
+}
+
 func unmatchedDelimReader(ru rune) readerFunc {
 	return func(r *LispReader, _ rune) (vm.Value, error) {
 		return nil, NewReaderError(r, fmt.Sprintf("unmatched delimiter %c", ru))
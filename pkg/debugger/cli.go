@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package debugger
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+const cliHelp = `debugger commands:
+  b, break FILE:LINE   set a breakpoint
+  clear FILE[:LINE]    remove breakpoints
+  breaks               list breakpoints
+  c, continue          run to the next breakpoint
+  s, step              step to the next line, entering calls
+  n, next              step to the next line, over calls
+  o, out               run until the current function returns
+  bt                   show the stack of the paused code
+  f, frame N           select frame N of the stack
+  l, locals            show the locals of the selected frame
+  p EXPR               evaluate EXPR in the selected frame
+  list                 show the source around the selected frame
+  q, quit              abort the paused evaluation
+`
+
+// CLI is a terminal frontend to the debugger. When code pauses it shows
+// where and reads commands until told to resume.
+type CLI struct {
+	Input func(prompt string) (string, error) // reads a command line
+	Out   io.Writer
+
+	d     *vm.Debugger
+	pause *vm.Pause
+	frame int
+}
+
+// NewCLI returns a terminal debugger reading commands with input.
+func NewCLI(input func(prompt string) (string, error), out io.Writer) *CLI {
+	c := &CLI{Input: input, Out: out}
+	c.d = vm.NewDebugger(c.onStop)
+	return c
+}
+
+// Debugger returns the debugger driven by c, to be attached to the VM.
+func (c *CLI) Debugger() *vm.Debugger {
+	return c.d
+}
+
+func (c *CLI) onStop(p *vm.Pause) vm.DebugAction {
+	c.pause, c.frame = p, 0
+	defer func() { c.pause = nil }()
+	frames := p.Frames()
+	fmt.Fprintf(c.Out, "paused (%s) at %s\n", p.Reason, frames[0])
+	c.listSource(frames[0], 0)
+	for {
+		in, err := c.Input("dbg> ")
+		if err != nil {
+			return vm.DebugAbort
+		}
+		if action, resume := c.Command(in); resume {
+			return action
+		}
+	}
+}
+
+// Command runs a debugger command. It returns true along with how to resume
+// when the command resumes paused code.
+func (c *CLI) Command(in string) (vm.DebugAction, bool) {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(in), " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case "":
+	case "b", "break":
+		file, line, err := parseLocation(arg)
+		if err != nil {
+			fmt.Fprintln(c.Out, err)
+			break
+		}
+		c.d.SetBreakpoint(file, line)
+		fmt.Fprintf(c.Out, "breakpoint at %s:%d\n", file, line)
+	case "clear":
+		if file, line, err := parseLocation(arg); err == nil {
+			c.d.ClearBreakpoint(file, line)
+		} else {
+			c.d.ClearBreakpoints(arg)
+		}
+	case "breaks":
+		for _, b := range c.d.Breakpoints() {
+			fmt.Fprintf(c.Out, "%s:%d\n", b.File, b.Line)
+		}
+	case "h", "help":
+		fmt.Fprint(c.Out, cliHelp)
+	case "c", "continue", "s", "step", "n", "next", "o", "out", "q", "quit":
+		if c.pause == nil {
+			fmt.Fprintln(c.Out, "not paused")
+			break
+		}
+		switch cmd {
+		case "c", "continue":
+			return vm.DebugContinue, true
+		case "s", "step":
+			return vm.DebugStepIn, true
+		case "n", "next":
+			return vm.DebugStepOver, true
+		case "o", "out":
+			return vm.DebugStepOut, true
+		}
+		return vm.DebugAbort, true
+	default:
+		if c.pause == nil {
+			fmt.Fprintf(c.Out, "unknown command %s, or code isn't paused\n", cmd)
+			break
+		}
+		c.pausedCommand(cmd, arg)
+	}
+	return vm.DebugContinue, false
+}
+
+// pausedCommand runs commands inspecting the paused code.
+func (c *CLI) pausedCommand(cmd string, arg string) {
+	frames := c.pause.Frames()
+	switch cmd {
+	case "bt":
+		for i, f := range frames {
+			mark := " "
+			if i == c.frame {
+				mark = "*"
+			}
+			fmt.Fprintf(c.Out, "%s %d %s\n", mark, i, f)
+		}
+	case "f", "frame":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || n >= len(frames) {
+			fmt.Fprintf(c.Out, "no frame %s\n", arg)
+			return
+		}
+		c.frame = n
+		fmt.Fprintf(c.Out, "%d %s\n", n, frames[n])
+	case "l", "locals":
+		for _, l := range frames[c.frame].Locals() {
+			fmt.Fprintf(c.Out, "%s = %s\n", l.Name, l.Value)
+		}
+	case "p", "print":
+		v, err := Eval(c.d, &frames[c.frame], arg)
+		if err != nil {
+			fmt.Fprint(c.Out, vm.FormatError(err))
+			return
+		}
+		fmt.Fprintln(c.Out, v)
+	case "list":
+		c.listSource(frames[c.frame], 5)
+	default:
+		fmt.Fprintf(c.Out, "unknown command %s, try help\n", cmd)
+	}
+}
+
+// listSource prints the lines around where frame is, context lines before
+// and after.
+func (c *CLI) listSource(frame vm.DebugFrame, context int) {
+	src := frame.Source
+	if src == nil {
+		return
+	}
+	for l := src.Line - context; l <= src.Line+context; l++ {
+		text := vm.SourceRegistry.GetLine(src.File, l)
+		if l < 0 || (text == "" && l != src.Line) {
+			continue
+		}
+		mark := "  "
+		if l == src.Line {
+			mark = "=>"
+		}
+		fmt.Fprintf(c.Out, "%s %4d  %s\n", mark, l+1, text)
+	}
+}
+
+// parseLocation parses FILE:LINE.
+func parseLocation(s string) (string, int, error) {
+	i := strings.LastIndexByte(s, ':')
+	if i <= 0 {
+		return "", 0, fmt.Errorf("expected FILE:LINE, got %q", s)
+	}
+	line, err := strconv.Atoi(s[i+1:])
+	if err != nil || line < 1 {
+		return "", 0, fmt.Errorf("bad line number in %q", s)
+	}
+	return s[:i], line, nil
+}
@@ -0,0 +1,427 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// threadID is the only thread reported to clients; only one goroutine can be
+// paused at a time.
+const threadID = 1
+
+// DAPServer serves the Debug Adapter Protocol over TCP, so that editors can
+// run let-go programs under the debugger.
+type DAPServer struct {
+	// run runs the program given in the launch request.
+	run func(program string) error
+
+	listener net.Listener
+}
+
+// NewDAPServer returns a DAP server running programs with run.
+func NewDAPServer(run func(program string) error) *DAPServer {
+	return &DAPServer{run: run}
+}
+
+// Start starts listening on the given port of localhost.
+func (s *DAPServer) Start(port int) error {
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	fmt.Printf("DAP server started on port %d on host 127.0.0.1\n", l.Addr().(*net.TCPAddr).Port)
+	return nil
+}
+
+// Addr returns the address the server listens on.
+func (s *DAPServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve handles debugging sessions, one connection at a time, until the
+// listener is closed.
+func (s *DAPServer) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return err
+		}
+		s.handle(conn)
+	}
+}
+
+// Stop closes the listener.
+func (s *DAPServer) Stop() error {
+	return s.listener.Close()
+}
+
+type dapMessage struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapResponse struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Command    string `json:"command"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+// dapSession is a single client connection.
+type dapSession struct {
+	server *DAPServer
+	d      *vm.Debugger
+	conn   net.Conn
+
+	mu  sync.Mutex // guards writes and seq
+	seq int
+
+	program     string
+	stopOnEntry bool
+	configured  bool
+	launched    bool
+
+	pmu    sync.Mutex
+	pause  *vm.Pause
+	resume chan vm.DebugAction
+}
+
+func (s *DAPServer) handle(conn net.Conn) {
+	ss := &dapSession{server: s, conn: conn, resume: make(chan vm.DebugAction)}
+	ss.d = vm.NewDebugger(ss.onStop)
+	vm.AttachDebugger(ss.d)
+	defer func() {
+		vm.DetachDebugger()
+		ss.abort()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readDAPMessage(r)
+		if err != nil {
+			return
+		}
+		if msg.Type != "request" {
+			continue
+		}
+		if !ss.request(msg) {
+			return
+		}
+	}
+}
+
+// readDAPMessage reads a message framed with a Content-Length header.
+func readDAPMessage(r *bufio.Reader) (*dapMessage, error) {
+	hdr, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(hdr.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("bad Content-Length: %w", err)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	msg := &dapMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (ss *dapSession) send(v any) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.seq++
+	switch m := v.(type) {
+	case *dapResponse:
+		m.Seq, m.Type = ss.seq, "response"
+	case *dapEvent:
+		m.Seq, m.Type = ss.seq, "event"
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(ss.conn, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (ss *dapSession) event(name string, body any) {
+	ss.send(&dapEvent{Event: name, Body: body})
+}
+
+// request handles a single request, returning false when the session ends.
+func (ss *dapSession) request(msg *dapMessage) bool {
+	resp := &dapResponse{RequestSeq: msg.Seq, Command: msg.Command, Success: true}
+	body, err := ss.dispatch(msg)
+	if err != nil {
+		resp.Success = false
+		resp.Message = err.Error()
+	}
+	resp.Body = body
+	ss.send(resp)
+
+	switch msg.Command {
+	case "initialize":
+		ss.event("initialized", nil)
+	case "launch", "configurationDone":
+		if err == nil && ss.configured && ss.program != "" && !ss.launched {
+			ss.launched = true
+			go ss.launch()
+		}
+	case "disconnect", "terminate":
+		return false
+	}
+	return true
+}
+
+func (ss *dapSession) dispatch(msg *dapMessage) (any, error) {
+	switch msg.Command {
+	case "initialize":
+		return map[string]any{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		if args.Program == "" {
+			return nil, fmt.Errorf("no program to launch")
+		}
+		ss.program, ss.stopOnEntry = args.Program, args.StopOnEntry
+		return nil, nil
+	case "configurationDone":
+		ss.configured = true
+		return nil, nil
+	case "setBreakpoints":
+		return ss.setBreakpoints(msg.Arguments)
+	case "threads":
+		return map[string]any{"threads": []any{map[string]any{"id": threadID, "name": "main"}}}, nil
+	case "stackTrace":
+		return ss.stackTrace()
+	case "scopes":
+		var args struct {
+			FrameID int `json:"frameId"`
+		}
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return map[string]any{"scopes": []any{map[string]any{
+			"name":               "Locals",
+			"variablesReference": args.FrameID + 1,
+			"expensive":          false,
+		}}}, nil
+	case "variables":
+		return ss.variables(msg.Arguments)
+	case "evaluate":
+		return ss.evaluate(msg.Arguments)
+	case "continue":
+		return map[string]any{"allThreadsContinued": true}, ss.resumeWith(vm.DebugContinue)
+	case "next":
+		return nil, ss.resumeWith(vm.DebugStepOver)
+	case "stepIn":
+		return nil, ss.resumeWith(vm.DebugStepIn)
+	case "stepOut":
+		return nil, ss.resumeWith(vm.DebugStepOut)
+	case "pause":
+		ss.d.Pause()
+		return nil, nil
+	case "disconnect", "terminate":
+		ss.abort()
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %s", msg.Command)
+}
+
+// launch runs the program and reports its end.
+func (ss *dapSession) launch() {
+	if ss.stopOnEntry {
+		ss.d.Pause()
+	}
+	exitCode := 0
+	if err := ss.server.run(ss.program); err != nil {
+		exitCode = 1
+		ss.event("output", map[string]any{"category": "stderr", "output": vm.FormatError(err)})
+	}
+	ss.event("exited", map[string]any{"exitCode": exitCode})
+	ss.event("terminated", nil)
+}
+
+// onStop runs on the paused goroutine, blocking it until the client resumes.
+func (ss *dapSession) onStop(p *vm.Pause) vm.DebugAction {
+	reason := p.Reason
+	if reason == "pause" && ss.stopOnEntry {
+		reason, ss.stopOnEntry = "entry", false
+	}
+	ss.pmu.Lock()
+	ss.pause = p
+	ss.pmu.Unlock()
+	ss.event("stopped", map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true})
+	action := <-ss.resume
+	ss.pmu.Lock()
+	ss.pause = nil
+	ss.pmu.Unlock()
+	return action
+}
+
+func (ss *dapSession) paused() *vm.Pause {
+	ss.pmu.Lock()
+	defer ss.pmu.Unlock()
+	return ss.pause
+}
+
+func (ss *dapSession) resumeWith(action vm.DebugAction) error {
+	if ss.paused() == nil {
+		return fmt.Errorf("not paused")
+	}
+	ss.resume <- action
+	return nil
+}
+
+// abort stops the paused program, if any.
+func (ss *dapSession) abort() {
+	if ss.paused() != nil {
+		ss.resume <- vm.DebugAbort
+	}
+}
+
+func (ss *dapSession) setBreakpoints(raw json.RawMessage) (any, error) {
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	ss.d.ClearBreakpoints(args.Source.Path)
+	bps := []any{}
+	for _, b := range args.Breakpoints {
+		ss.d.SetBreakpoint(args.Source.Path, b.Line)
+		bps = append(bps, map[string]any{"verified": true, "line": b.Line})
+	}
+	return map[string]any{"breakpoints": bps}, nil
+}
+
+func (ss *dapSession) frames() ([]vm.DebugFrame, error) {
+	p := ss.paused()
+	if p == nil {
+		return nil, fmt.Errorf("not paused")
+	}
+	return p.Frames(), nil
+}
+
+func (ss *dapSession) stackTrace() (any, error) {
+	frames, err := ss.frames()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(frames))
+	for i, f := range frames {
+		sf := map[string]any{"id": i, "name": f.Name(), "line": 0, "column": 0}
+		if f.Source != nil {
+			sf["line"] = f.Source.Line + 1
+			sf["column"] = f.Source.Column + 1
+			path := f.Source.File
+			if abs, err := filepath.Abs(path); err == nil && !strings.HasPrefix(path, "<") {
+				path = abs
+			}
+			sf["source"] = map[string]any{"name": filepath.Base(f.Source.File), "path": path}
+		}
+		out[i] = sf
+	}
+	return map[string]any{"stackFrames": out, "totalFrames": len(out)}, nil
+}
+
+func (ss *dapSession) variables(raw json.RawMessage) (any, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	frames, err := ss.frames()
+	if err != nil {
+		return nil, err
+	}
+	i := args.VariablesReference - 1
+	if i < 0 || i >= len(frames) {
+		return nil, fmt.Errorf("no variables %d", args.VariablesReference)
+	}
+	vars := []any{}
+	for _, l := range frames[i].Locals() {
+		vars = append(vars, map[string]any{
+			"name":               l.Name,
+			"value":              l.Value.String(),
+			"type":               l.Value.Type().Name(),
+			"variablesReference": 0,
+		})
+	}
+	return map[string]any{"variables": vars}, nil
+}
+
+func (ss *dapSession) evaluate(raw json.RawMessage) (any, error) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    *int   `json:"frameId"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	var frame *vm.DebugFrame
+	if args.FrameID != nil {
+		frames, err := ss.frames()
+		if err != nil {
+			return nil, err
+		}
+		if *args.FrameID < 0 || *args.FrameID >= len(frames) {
+			return nil, fmt.Errorf("no frame %d", *args.FrameID)
+		}
+		frame = &frames[*args.FrameID]
+	}
+	v, err := Eval(ss.d, frame, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"result": v.String(), "variablesReference": 0}, nil
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package debugger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nooga/let-go/pkg/compiler"
+	"github.com/nooga/let-go/pkg/rt"
+	"github.com/nooga/let-go/pkg/vm"
+	"github.com/stretchr/testify/assert"
+)
+
+const program = `(ns debugger-test)
+(defn add [a b]
+  (let [s (+ a b)]
+    (* s 2)))
+
+(defn run []
+  (let [x 10
+        y (add x 5)]
+    (inc y)))
+
+(run)
+`
+
+func runProgram(file string) (vm.Value, error) {
+	ctx := compiler.NewCompiler(vm.NewConsts(), rt.NS("user")).SetSource(file)
+	_, v, err := ctx.CompileMultiple(strings.NewReader(program))
+	return v, err
+}
+
+// scripted returns an input func feeding commands one by one.
+func scripted(cmds ...string) func(string) (string, error) {
+	return func(string) (string, error) {
+		if len(cmds) == 0 {
+			return "", io.EOF
+		}
+		c := cmds[0]
+		cmds = cmds[1:]
+		return c, nil
+	}
+}
+
+func TestCLI_BreakpointsAndStepping(t *testing.T) {
+	out := &bytes.Buffer{}
+	cli := NewCLI(scripted(
+		"l", "p (* s a)", "bt", "n", "l", "o", "c",
+	), out)
+	cli.Command("b cli.lg:4")
+	vm.AttachDebugger(cli.Debugger())
+	defer vm.DetachDebugger()
+
+	v, err := runProgram("cli.lg")
+	assert.NoError(t, err)
+	assert.Equal(t, vm.Int(31), v)
+
+	o := out.String()
+	assert.Contains(t, o, "paused (breakpoint) at debugger-test/add (cli.lg:4:5)")
+	assert.Contains(t, o, "a = 10\nb = 5\ns = 15\n")
+	assert.Contains(t, o, "150\n")
+	assert.Contains(t, o, "  1 debugger-test/run (cli.lg:8:11)")
+	assert.Contains(t, o, "paused (step) at debugger-test/run")
+	assert.Contains(t, o, "x = 10\ny = 30\n")
+	assert.Contains(t, o, "paused (step) at <top level> (cli.lg:11:1)")
+}
+
+func TestCLI_Abort(t *testing.T) {
+	cli := NewCLI(scripted("q"), io.Discard)
+	cli.Command("b cli.lg:3")
+	vm.AttachDebugger(cli.Debugger())
+	defer vm.DetachDebugger()
+
+	_, err := runProgram("cli.lg")
+	assert.Error(t, err)
+}
+
+type dapClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	seq  int
+}
+
+func (c *dapClient) request(cmd string, args any) {
+	c.seq++
+	body, _ := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": cmd, "arguments": args})
+	fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// expect reads messages until a response to the command or an event with
+// the given name arrives.
+func (c *dapClient) expect(typ string, name string) map[string]any {
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint:errcheck
+	for {
+		hdr, err := textproto.NewReader(c.r).ReadMIMEHeader()
+		if !assert.NoError(c.t, err) {
+			c.t.FailNow()
+		}
+		n, _ := strconv.Atoi(hdr.Get("Content-Length"))
+		body := make([]byte, n)
+		_, err = io.ReadFull(c.r, body)
+		assert.NoError(c.t, err)
+		m := map[string]any{}
+		assert.NoError(c.t, json.Unmarshal(body, &m))
+		if m["type"] == typ && (m["command"] == name || m["event"] == name) {
+			return m
+		}
+	}
+}
+
+func TestDAP(t *testing.T) {
+	server := NewDAPServer(func(string) error {
+		_, err := runProgram("dap.lg")
+		return err
+	})
+	assert.NoError(t, server.Start(0))
+	go server.Serve() //nolint:errcheck
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", server.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	c := &dapClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	c.request("initialize", map[string]any{"adapterID": "let-go"})
+	c.expect("event", "initialized")
+	c.request("launch", map[string]any{"program": "dap.lg"})
+	c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": "dap.lg"},
+		"breakpoints": []any{map[string]any{"line": 4}},
+	})
+	c.request("configurationDone", nil)
+	c.expect("event", "stopped")
+
+	c.request("stackTrace", map[string]any{"threadId": threadID})
+	st := c.expect("response", "stackTrace")
+	frames := st["body"].(map[string]any)["stackFrames"].([]any)
+	assert.Len(t, frames, 3)
+	assert.Equal(t, "debugger-test/add", frames[0].(map[string]any)["name"])
+	assert.Equal(t, float64(4), frames[0].(map[string]any)["line"])
+
+	c.request("variables", map[string]any{"variablesReference": 2})
+	vars := c.expect("response", "variables")["body"].(map[string]any)["variables"].([]any)
+	assert.Equal(t, "x", vars[0].(map[string]any)["name"])
+	assert.Equal(t, "10", vars[0].(map[string]any)["value"])
+
+	c.request("evaluate", map[string]any{"expression": "(+ a b s)", "frameId": 0})
+	ev := c.expect("response", "evaluate")
+	assert.Equal(t, "30", ev["body"].(map[string]any)["result"])
+
+	c.request("continue", map[string]any{"threadId": threadID})
+	c.expect("event", "exited")
+	c.expect("event", "terminated")
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect")
+}
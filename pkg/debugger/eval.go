@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+// Package debugger drives the let-go VM debugger, either from a terminal or
+// from an editor speaking the Debug Adapter Protocol.
+package debugger
+
+import (
+	"strings"
+
+	"github.com/nooga/let-go/pkg/compiler"
+	"github.com/nooga/let-go/pkg/rt"
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// Eval evaluates the expression in src as if it was written in frame, with
+// the locals of the frame in scope. With a nil frame it's evaluated in the
+// current namespace. Breakpoints are ignored while it runs.
+func Eval(d *vm.Debugger, frame *vm.DebugFrame, src string) (vm.Value, error) {
+	nsVar := rt.CurrentNSVar()
+	prev := nsVar.Deref().(*vm.Namespace)
+	defer nsVar.Set(prev)
+
+	ns := prev
+	var locals []vm.Local
+	if frame != nil {
+		if frame.NS != "" {
+			if fns := rt.LookupNS(frame.NS); fns != nil {
+				ns = fns
+			}
+		}
+		locals = frame.Locals()
+	}
+
+	form, err := compiler.NewLispReader(strings.NewReader(src), "<debugger>").Read()
+	if err != nil {
+		return vm.NIL, err
+	}
+	// wrap the expression in a fn taking the locals as arguments
+	names := make([]vm.Value, 0, len(locals))
+	args := make([]vm.Value, 0, len(locals))
+	for _, l := range locals {
+		if l.Name == "&" || strings.Contains(l.Name, "/") {
+			continue
+		}
+		names = append(names, vm.Symbol(l.Name))
+		args = append(args, l.Value)
+	}
+	fn := vm.NewList([]vm.Value{vm.Symbol("fn*"), vm.NewArrayVector(names), form})
+
+	ctx := compiler.NewCompiler(vm.NewConsts(), ns).SetSource("<debugger>")
+	return d.Muted(func() (vm.Value, error) {
+		chunk, err := ctx.CompileForm(fn)
+		if err != nil {
+			return vm.NIL, err
+		}
+		f := vm.NewFrame(chunk, nil)
+		v, err := f.RunProtected()
+		vm.ReleaseFrame(f)
+		if err != nil {
+			return vm.NIL, err
+		}
+		return v.(vm.Fn).Invoke(args)
+	})
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package nrepl
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/nooga/let-go/pkg/compiler"
+	"github.com/nooga/let-go/pkg/errors"
+	"github.com/nooga/let-go/pkg/rt"
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// handleNSList returns the names of all namespaces, except those matching
+// any of the regexps in filter-regexps.
+func (n *NreplServer) handleNSList(conn net.Conn, msg map[string]interface{}) {
+	var filters []*regexp.Regexp
+	for _, f := range msgStrs(msg, "filter-regexps") {
+		if re, err := regexp.Compile(f); err == nil {
+			filters = append(filters, re)
+		}
+	}
+	names := []string{}
+	for name := range rt.AllNSes() {
+		keep := true
+		for _, re := range filters {
+			keep = keep && !re.MatchString(name)
+		}
+		if keep {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	respond(conn, map[string]interface{}{
+		"id":      msgStr(msg, "id"),
+		"session": msgStr(msg, "session"),
+		"ns-list": names,
+		"status":  []string{"done"},
+	})
+}
+
+// handleNSVars returns the names of the public vars of a namespace, the
+// session's one if the message names none.
+func (n *NreplServer) handleNSVars(conn net.Conn, msg map[string]interface{}) {
+	resp := map[string]interface{}{
+		"id":      msgStr(msg, "id"),
+		"session": msgStr(msg, "session"),
+		"status":  []string{"done"},
+	}
+	ns := n.sessionNS(msgStr(msg, "session"))
+	if name := msgStr(msg, "ns"); name != "" {
+		ns = rt.LookupNS(name)
+	}
+	if ns == nil {
+		resp["status"] = []string{"done", "error", "namespace-not-found"}
+		respond(conn, resp)
+		return
+	}
+	names := []string{}
+	for sym, v := range ns.Interns() {
+		if v.NS() == ns.Name() && !v.IsPrivate() {
+			names = append(names, string(sym))
+		}
+	}
+	sort.Strings(names)
+	resp["ns-vars"] = names
+	respond(conn, resp)
+}
+
+// handleMacroexpand expands the form in code with the expander named in the
+// message: macroexpand-1, macroexpand (the default) or macroexpand-all, in
+// the namespace named by ns or else the session's one. It runs on the
+// session's goroutine as macros are let-go code.
+func (n *NreplServer) handleMacroexpand(conn net.Conn, sess *session, msg map[string]interface{}) {
+	resp := map[string]interface{}{
+		"id":      msgStr(msg, "id"),
+		"session": msgStr(msg, "session"),
+		"status":  []string{"done"},
+	}
+	if name := msgStr(msg, "ns"); name != "" {
+		ns := rt.LookupNS(name)
+		if ns == nil {
+			resp["status"] = []string{"done", "error", "namespace-not-found"}
+			respond(conn, resp)
+			return
+		}
+		prev := sess.ctx.CurrentNS()
+		sess.ctx.SetCurrentNS(ns)
+		defer sess.ctx.SetCurrentNS(prev)
+	}
+	form, err := compiler.NewLispReader(strings.NewReader(msgStr(msg, "code")), "<macroexpand>").Read()
+	if err == nil {
+		switch msgStr(msg, "expander") {
+		case "macroexpand-1":
+			form, err = sess.ctx.MacroExpand1(form)
+		case "macroexpand-all":
+			form, err = macroexpandAll(sess.ctx, form)
+		default:
+			form, err = sess.ctx.MacroExpand(form)
+		}
+	}
+	if err != nil {
+		resp["err"] = vm.FormatError(err) + "\n"
+		resp["status"] = []string{"done", "error", "macroexpand-error"}
+	} else {
+		resp["expansion"] = form.String()
+	}
+	respond(conn, resp)
+}
+
+// macroexpandAll expands form and every form nested in it, except quoted
+// ones, like clojure.walk/macroexpand-all.
+func macroexpandAll(c *compiler.Context, form vm.Value) (vm.Value, error) {
+	form, err := c.MacroExpand(form)
+	if err != nil {
+		return vm.NIL, err
+	}
+	var items []vm.Value
+	switch f := form.(type) {
+	case *vm.List:
+		if f.First() == vm.Symbol("quote") {
+			return form, nil
+		}
+		items = seqValues(f)
+	case vm.ArrayVector, *vm.PersistentVector:
+		items = seqValues(f)
+	default:
+		return form, nil
+	}
+	out := make([]vm.Value, len(items))
+	for i, item := range items {
+		if out[i], err = macroexpandAll(c, item); err != nil {
+			return vm.NIL, err
+		}
+	}
+	if _, ok := form.(*vm.List); ok {
+		return vm.NewList(out), nil
+	}
+	return vm.ArrayVector(out), nil
+}
+
+// handleStacktrace describes the last error of the session, sending a
+// message for the error and each of its causes, outermost first.
+func (n *NreplServer) handleStacktrace(conn net.Conn, msg map[string]interface{}) {
+	id := msgStr(msg, "id")
+	sessID := msgStr(msg, "session")
+	var err error
+	if s := n.lookupSession(sessID); s != nil {
+		err = s.lastError()
+	}
+	if err == nil {
+		respond(conn, map[string]interface{}{
+			"id":      id,
+			"session": sessID,
+			"status":  []string{"done", "no-error"},
+		})
+		return
+	}
+	for _, c := range errorCauses(err) {
+		c["id"] = id
+		c["session"] = sessID
+		respond(conn, c)
+	}
+	respond(conn, map[string]interface{}{
+		"id":      id,
+		"session": sessID,
+		"status":  []string{"done"},
+	})
+}
+
+// errorCauses describes err and the errors that caused it, outermost first,
+// each with its class, message, ex-data and stack trace.
+func errorCauses(err error) []map[string]interface{} {
+	var out []map[string]interface{}
+	for err != nil {
+		c := map[string]interface{}{"class": errorClass(err)}
+		out = append(out, c)
+		ei, ok := vm.ErrorValue(err).(*vm.ExInfo)
+		if !ok {
+			c["message"] = vm.ErrorValue(err).String()
+			c["stacktrace"] = stackFrames(vm.StackTrace(err))
+			break
+		}
+		c["message"] = ei.Message()
+		c["stacktrace"] = stackFrames(ei.Stack())
+		// :trace is the stack trace again, for errors that weren't thrown
+		if ei.Data() != nil {
+			if data := ei.Data().Dissoc(vm.Keyword("trace")).(*vm.PersistentMap); data.RawCount() > 0 {
+				c["data"] = data.String()
+			}
+		}
+		err = ei.Cause()
+	}
+	return out
+}
+
+// stackFrames describes the frames of a let-go stack trace the way CIDER
+// and Calva expect them.
+func stackFrames(stack []vm.StackFrame) []interface{} {
+	frames := make([]interface{}, 0, len(stack))
+	for _, sf := range stack {
+		frame := map[string]interface{}{
+			"name":  sf.Name(),
+			"fn":    sf.Fn,
+			"ns":    sf.NS,
+			"type":  "clj",
+			"flags": []string{"clj"},
+		}
+		if sf.Fn != "" && sf.NS != "" {
+			frame["var"] = sf.Name()
+		}
+		if sf.Source == nil {
+			frame["flags"] = []string{"clj", "native"}
+		} else {
+			frame["file"] = sf.Source.File
+			frame["line"] = sf.Source.Line + 1
+			frame["column"] = sf.Source.Column + 1
+			if abs, err := filepath.Abs(sf.Source.File); err == nil {
+				if _, err := os.Stat(abs); err == nil {
+					frame["file-url"] = "file:" + abs
+				}
+			}
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// errorClass names the kind of err, for the ex and class fields. Thrown
+// values are named after their type.
+func errorClass(err error) string {
+	for err != nil {
+		switch e := err.(type) {
+		case *vm.ThrownError:
+			return e.Value.Type().Name()
+		case *vm.StackOverflowError:
+			return "let-go.lang.StackOverflowError"
+		case *vm.LimitError:
+			return "let-go.lang.LimitError"
+		case *vm.InterruptedError:
+			return "let-go.lang.InterruptedError"
+		case *vm.TypeError:
+			return "let-go.lang.TypeError"
+		case *compiler.CompileError:
+			return "let-go.lang.CompileError"
+		case *compiler.ReaderError:
+			return "let-go.lang.ReaderError"
+		}
+		c, ok := err.(errors.Error)
+		if !ok {
+			break
+		}
+		err = c.GetCause()
+	}
+	return "let-go.lang.Error"
+}
+
+// rootCause follows the causes of ex-info errors down to the first one.
+func rootCause(err error) error {
+	for {
+		ei, ok := vm.ErrorValue(err).(*vm.ExInfo)
+		if !ok || ei.Cause() == nil {
+			return err
+		}
+		err = ei.Cause()
+	}
+}
+
+// msgStrs returns a list of strings from msg.
+func msgStrs(msg map[string]interface{}, key string) []string {
+	vs, _ := msg[key].([]interface{})
+	out := make([]string, 0, len(vs))
+	for _, v := range vs {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
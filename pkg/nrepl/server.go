@@ -6,11 +6,12 @@
 package nrepl
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -21,10 +22,279 @@ import (
 	"github.com/zeebo/bencode"
 )
 
-// session holds per-client state.
+// session holds per-client state. Each session evaluates on its own
+// goroutine, which carries the session's dynamic bindings: the current
+// namespace, *1, *2, *3 and *e, and whatever else gets bound there.
 type session struct {
 	id  string
-	ctx *compiler.Context
+	ctx *compiler.Context // only used by the session's goroutine
+
+	queue chan func() // evals run one at a time, in order of arrival
+	done  chan struct{}
+
+	mu         sync.Mutex
+	closed     bool
+	evalID     string             // id of the running eval message, if any
+	cancelEval context.CancelFunc // stops the running eval
+	nsVar      *vm.Var
+	bindings   map[*vm.Var]vm.Value
+
+	in      *sessionInput
+	inValue vm.Value            // *in* of the session's evals
+	lastErr error               // of the last eval that failed
+	failed  map[string][]string // tests that didn't pass in the last run, by ns
+}
+
+// newSession starts a session in runtime r with the given bindings, or
+// fresh REPL bindings in ns when there are none.
+func newSession(id string, r *rt.Runtime, ns *vm.Namespace, bindings map[*vm.Var]vm.Value) *session {
+	s := &session{
+		id:    id,
+		queue: make(chan func(), 32),
+		done:  make(chan struct{}),
+		nsVar: r.CurrentNSVar(),
+		in:    newSessionInput(),
+	}
+	s.inValue = vm.NewBoxed(rt.NewStreamHandle("nrepl-in", s.in, nil))
+	ready := make(chan struct{})
+	go func() {
+		defer r.Enter()()
+		if bindings == nil {
+			bindings = rt.ReplBindings(ns)
+		}
+		vm.PushThreadBindings(bindings)
+		defer vm.PopThreadBindings()
+		s.ctx = compiler.NewCompiler(vm.NewConsts(), bindings[s.nsVar].(*vm.Namespace))
+		s.ctx.SetSource("REPL")
+		s.saveBindings()
+		close(ready)
+		for {
+			select {
+			case task := <-s.queue:
+				task()
+				s.saveBindings()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	<-ready
+	return s
+}
+
+// saveBindings snapshots the bindings of the session's goroutine, for clones
+// and ops that don't run there.
+func (s *session) saveBindings() {
+	bindings := vm.GetThreadBindings()
+	s.mu.Lock()
+	s.bindings = bindings
+	s.mu.Unlock()
+}
+
+// snapshot returns the bindings the session had after its last eval.
+func (s *session) snapshot() map[*vm.Var]vm.Value {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bindings := make(map[*vm.Var]vm.Value, len(s.bindings))
+	for v, val := range s.bindings {
+		bindings[v] = val
+	}
+	return bindings
+}
+
+// streamIO binds *in*, *out* and *err* of the session's goroutine to the
+// client, on behalf of the message id running in ctx, until the returned
+// function is called.
+func (s *session) streamIO(ctx context.Context, conn net.Conn, id string) func() {
+	r := s.ctx.Runtime()
+	vm.PushThreadBindings(map[*vm.Var]vm.Value{
+		r.StdinVar():  s.inValue,
+		r.StdoutVar(): vm.NewBoxed(rt.NewStreamHandle("nrepl-out", nil, &evalWriter{conn, id, s.id, "out"})),
+		r.StderrVar(): vm.NewBoxed(rt.NewStreamHandle("nrepl-err", nil, &evalWriter{conn, id, s.id, "err"})),
+	})
+	s.in.begin(ctx, func() {
+		respond(conn, map[string]interface{}{
+			"id":      id,
+			"session": s.id,
+			"status":  []string{"need-input"},
+		})
+	})
+	return func() {
+		s.in.end()
+		vm.PopThreadBindings()
+	}
+}
+
+func (s *session) setLastError(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+func (s *session) lastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// currentNS returns the namespace the session was in after its last eval.
+func (s *session) currentNS() *vm.Namespace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ns, _ := s.bindings[s.nsVar].(*vm.Namespace)
+	return ns
+}
+
+// enqueue schedules an eval on the session's worker so that the connection
+// can keep reading messages (interrupt in particular) while it runs.
+func (s *session) enqueue(task func()) {
+	select {
+	case s.queue <- task:
+	case <-s.done:
+	}
+}
+
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.cancelEval != nil {
+		s.cancelEval()
+	}
+	close(s.done)
+}
+
+// startEval records a running eval so that it can be interrupted.
+func (s *session) startEval(id string, cancel context.CancelFunc) {
+	s.mu.Lock()
+	s.evalID = id
+	s.cancelEval = cancel
+	s.mu.Unlock()
+}
+
+func (s *session) finishEval() {
+	s.mu.Lock()
+	s.evalID = ""
+	s.cancelEval = nil
+	s.mu.Unlock()
+}
+
+// interrupt cancels the running eval. An empty id matches any eval.
+// Returns the nREPL status describing the outcome.
+func (s *session) interrupt(id string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelEval == nil {
+		return "session-idle"
+	}
+	if id != "" && id != s.evalID {
+		return "interrupt-id-mismatch"
+	}
+	s.cancelEval()
+	return "interrupted"
+}
+
+// evalWriter streams what an eval writes to *out* or *err* to the client as
+// it gets written, including from go blocks the eval started.
+type evalWriter struct {
+	conn    net.Conn
+	id      string
+	session string
+	key     string // out or err
+}
+
+func (w *evalWriter) Write(p []byte) (int, error) {
+	respond(w.conn, map[string]interface{}{
+		"id":      w.id,
+		"session": w.session,
+		w.key:     string(p),
+	})
+	return len(p), nil
+}
+
+// sessionInput is *in* of a session's evals. Reads wait for the client to
+// send input with the stdin op, asking for it with a need-input status.
+// Input left over after an eval is kept for the next one.
+type sessionInput struct {
+	mu      sync.Mutex
+	buf     []byte
+	eof     bool
+	arrived chan struct{}
+	ask     func()          // requests input for the running eval
+	ctx     context.Context // of the running eval
+}
+
+func newSessionInput() *sessionInput {
+	return &sessionInput{arrived: make(chan struct{}, 1)}
+}
+
+// add adds input sent by the client. Empty input stands for end of file.
+func (in *sessionInput) add(s string) {
+	in.mu.Lock()
+	if s == "" {
+		in.eof = true
+	}
+	in.buf = append(in.buf, s...)
+	in.mu.Unlock()
+	select {
+	case in.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// begin lets reads ask for input on behalf of the eval running in ctx.
+func (in *sessionInput) begin(ctx context.Context, ask func()) {
+	in.mu.Lock()
+	in.ctx, in.ask = ctx, ask
+	in.mu.Unlock()
+}
+
+func (in *sessionInput) end() {
+	in.begin(nil, nil)
+}
+
+func (in *sessionInput) Read(p []byte) (int, error) {
+	for {
+		in.mu.Lock()
+		if len(in.buf) > 0 {
+			n := copy(p, in.buf)
+			in.buf = in.buf[n:]
+			in.mu.Unlock()
+			return n, nil
+		}
+		if in.eof {
+			in.eof = false
+			in.mu.Unlock()
+			return 0, io.EOF
+		}
+		ask, ctx := in.ask, in.ctx
+		in.mu.Unlock()
+		if ask == nil {
+			return 0, io.EOF // no eval to ask input for
+		}
+		ask()
+		select {
+		case <-in.arrived:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// lockedConn serializes writes so that responses of concurrently running
+// evals and other ops don't interleave on the wire.
+type lockedConn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+func (c *lockedConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.Write(b)
 }
 
 // NreplServer implements the nREPL protocol over TCP.
@@ -45,26 +315,74 @@ func NewNreplServer(ctx *compiler.Context) *NreplServer {
 	}
 }
 
-func (n *NreplServer) newSession() *session {
+// newSession registers a new session, cloning the state of from if it's
+// not nil.
+func (n *NreplServer) newSession(from *session) *session {
 	id, err := uuid.GenerateUUID()
 	if err != nil {
 		id = "fallback-session"
 	}
-	// Each session gets its own compiler context sharing the same namespace
-	s := &session{
-		id:  id,
-		ctx: n.ctx,
+	var bindings map[*vm.Var]vm.Value
+	if from != nil {
+		bindings = from.snapshot()
 	}
+	s := newSession(id, n.ctx.Runtime(), n.ctx.CurrentNS(), bindings)
 	n.mu.Lock()
 	n.sessions[id] = s
 	n.mu.Unlock()
 	return s
 }
 
+// getSession returns the session with the given id, registering it if the
+// client uses one we don't know (including no session at all).
+func (n *NreplServer) getSession(id string) *session {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s, ok := n.sessions[id]
+	if !ok {
+		s = newSession(id, n.ctx.Runtime(), n.ctx.CurrentNS(), nil)
+		n.sessions[id] = s
+	}
+	return s
+}
+
+// lookupSession returns the session with the given id, or nil.
+func (n *NreplServer) lookupSession(id string) *session {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.sessions[id]
+}
+
+// sessionNS returns the current namespace of the session with the given id,
+// or of the server if there's no such session.
+func (n *NreplServer) sessionNS(id string) *vm.Namespace {
+	if s := n.lookupSession(id); s != nil {
+		if ns := s.currentNS(); ns != nil {
+			return ns
+		}
+	}
+	return n.ctx.CurrentNS()
+}
+
+// lookupNS returns the namespace named name, falling back to the current
+// one of the session when there's no name or no such namespace.
+func (n *NreplServer) lookupNS(id, name string) *vm.Namespace {
+	if name != "" {
+		if ns := rt.LookupNS(name); ns != nil {
+			return ns
+		}
+	}
+	return n.sessionNS(id)
+}
+
 func (n *NreplServer) closeSession(id string) {
 	n.mu.Lock()
+	s := n.sessions[id]
 	delete(n.sessions, id)
 	n.mu.Unlock()
+	if s != nil {
+		s.close()
+	}
 }
 
 func (n *NreplServer) sessionIDs() []string {
@@ -72,6 +390,9 @@ func (n *NreplServer) sessionIDs() []string {
 	defer n.mu.Unlock()
 	ids := make([]string, 0, len(n.sessions))
 	for id := range n.sessions {
+		if id == "" {
+			continue // implicit session of clients that don't clone
+		}
 		ids = append(ids, id)
 	}
 	return ids
@@ -125,8 +446,9 @@ func (n *NreplServer) Stop() {
 }
 
 // handleConn processes a single client connection.
-func (n *NreplServer) handleConn(conn net.Conn) {
-	defer conn.Close()
+func (n *NreplServer) handleConn(c net.Conn) {
+	defer c.Close()
+	conn := &lockedConn{Conn: c}
 	dec := bencode.NewDecoder(conn)
 
 	for {
@@ -150,7 +472,11 @@ func (n *NreplServer) handleMsg(conn net.Conn, msg map[string]interface{}) {
 
 	switch op {
 	case "clone":
-		s := n.newSession()
+		var from *session
+		if sessID != "" {
+			from = n.lookupSession(sessID)
+		}
+		s := n.newSession(from)
 		respond(conn, map[string]interface{}{
 			"id":          id,
 			"status":      []string{"done"},
@@ -178,8 +504,21 @@ func (n *NreplServer) handleMsg(conn net.Conn, msg map[string]interface{}) {
 				"completions": map[string]interface{}{},
 				"lookup":      map[string]interface{}{},
 				"info":        map[string]interface{}{},
+				"eldoc":       map[string]interface{}{},
 				"complete":    map[string]interface{}{},
 				"ls-sessions": map[string]interface{}{},
+				"interrupt":   map[string]interface{}{},
+				"stdin":       map[string]interface{}{},
+				"ns-list":     map[string]interface{}{},
+				"ns-vars":     map[string]interface{}{},
+				"macroexpand": map[string]interface{}{},
+				"test":        map[string]interface{}{},
+				"test-all":    map[string]interface{}{},
+				"retest":      map[string]interface{}{},
+				"stacktrace":  map[string]interface{}{},
+
+				"test-var-query":          map[string]interface{}{},
+				"analyze-last-stacktrace": map[string]interface{}{},
 			},
 			"versions": map[string]interface{}{
 				"let-go": map[string]interface{}{
@@ -194,13 +533,45 @@ func (n *NreplServer) handleMsg(conn net.Conn, msg map[string]interface{}) {
 		})
 
 	case "eval":
-		n.handleEval(conn, msg)
+		s := n.getSession(sessID)
+		s.enqueue(func() { n.handleEval(conn, s, msg) })
 
 	case "load-file":
-		// Transform to eval
-		code := msgStr(msg, "file")
-		msg["code"] = code
-		n.handleEval(conn, msg)
+		// Transform to eval of the file's contents, compiled under its path
+		msg["code"] = msgStr(msg, "file")
+		msg["file"] = msgStr(msg, "file-path")
+		if msg["file"] == "" {
+			msg["file"] = msgStr(msg, "file-name")
+		}
+		delete(msg, "line")
+		delete(msg, "column")
+		s := n.getSession(sessID)
+		s.enqueue(func() { n.handleEval(conn, s, msg) })
+
+	case "stdin":
+		n.getSession(sessID).in.add(msgStr(msg, "stdin"))
+		respond(conn, map[string]interface{}{
+			"id":      id,
+			"session": sessID,
+			"status":  []string{"done"},
+		})
+
+	case "macroexpand":
+		s := n.getSession(sessID)
+		s.enqueue(func() { n.handleMacroexpand(conn, s, msg) })
+
+	case "test", "test-all", "test-var-query", "retest":
+		s := n.getSession(sessID)
+		s.enqueue(func() { n.handleTest(conn, s, msg) })
+
+	case "ns-list":
+		n.handleNSList(conn, msg)
+
+	case "ns-vars":
+		n.handleNSVars(conn, msg)
+
+	case "stacktrace", "analyze-last-stacktrace":
+		n.handleStacktrace(conn, msg)
 
 	case "completions", "complete":
 		n.handleCompletions(conn, msg)
@@ -208,6 +579,9 @@ func (n *NreplServer) handleMsg(conn net.Conn, msg map[string]interface{}) {
 	case "info", "lookup":
 		n.handleInfo(conn, msg)
 
+	case "eldoc":
+		n.handleEldoc(conn, msg)
+
 	case "ls-sessions":
 		respond(conn, map[string]interface{}{
 			"id":       id,
@@ -217,10 +591,17 @@ func (n *NreplServer) handleMsg(conn net.Conn, msg map[string]interface{}) {
 		})
 
 	case "interrupt":
+		status := []string{"done"}
+		switch n.getSession(sessID).interrupt(msgStr(msg, "interrupt-id")) {
+		case "session-idle":
+			status = append(status, "session-idle")
+		case "interrupt-id-mismatch":
+			status = append(status, "error", "interrupt-id-mismatch")
+		}
 		respond(conn, map[string]interface{}{
 			"id":      id,
 			"session": sessID,
-			"status":  []string{"done", "session-idle"},
+			"status":  status,
 		})
 
 	default:
@@ -233,60 +614,63 @@ func (n *NreplServer) handleMsg(conn net.Conn, msg map[string]interface{}) {
 }
 
 // handleEval evaluates code and streams out/err/value/done messages.
-func (n *NreplServer) handleEval(conn net.Conn, msg map[string]interface{}) {
+func (n *NreplServer) handleEval(conn net.Conn, sess *session, msg map[string]interface{}) {
 	id := msgStr(msg, "id")
 	sessID := msgStr(msg, "session")
 	code := msgStr(msg, "code")
 
-	// Capture stdout
-	var outBuf bytes.Buffer
-	origStdout := os.Stdout
-	pr, pw, _ := os.Pipe()
-	os.Stdout = pw
-
-	// Read pipe in background
-	outDone := make(chan struct{})
-	go func() {
-		io.Copy(&outBuf, pr)
-		close(outDone)
+	evalCtx, cancel := context.WithCancel(context.Background())
+	sess.startEval(id, cancel)
+	defer func() {
+		sess.finishEval()
+		cancel()
 	}()
 
-	// Eval
-	_, val, err := n.ctx.CompileMultiple(strings.NewReader(code))
+	defer sess.streamIO(evalCtx, conn, id)()
 
-	// Restore stdout and flush
-	pw.Close()
-	os.Stdout = origStdout
-	<-outDone
+	// Compile under the editor's file and position so that errors and var
+	// metadata point into it. Both line and column are 1-based.
+	if file := msgStr(msg, "file"); file != "" {
+		prev := sess.ctx.Source()
+		sess.ctx.SetSource(file).SetSourcePosition(max(msgInt(msg, "line")-1, 0), max(msgInt(msg, "column")-1, 0))
+		defer func() { sess.ctx.SetSource(prev).SetSourcePosition(0, 0) }()
+	}
 
-	// Send stdout if any
-	if outBuf.Len() > 0 {
+	// Eval
+	var val vm.Value
+	_, err := vm.WithContext(evalCtx, func() (vm.Value, error) {
+		var err error
+		_, val, err = sess.ctx.CompileMultiple(strings.NewReader(code))
+		return val, err
+	})
+
+	if err != nil && vm.IsInterrupted(err) {
 		respond(conn, map[string]interface{}{
 			"id":      id,
 			"session": sessID,
-			"out":     outBuf.String(),
+			"status":  []string{"interrupted"},
 		})
-	}
-
-	if err != nil {
+	} else if err != nil {
+		rt.SetLastError(err)
+		sess.setLastError(err)
 		errStr := vm.FormatError(err)
 		respond(conn, map[string]interface{}{
 			"id":      id,
 			"session": sessID,
 			"err":     errStr + "\n",
-			"ex":      "let-go.lang.Error",
-			"root-ex": "let-go.lang.Error",
+			"ex":      errorClass(err),
+			"root-ex": errorClass(rootCause(err)),
 		})
 	} else {
-		valStr := "nil"
-		if val != nil {
-			valStr = val.String()
+		if val == nil {
+			val = vm.NIL
 		}
+		rt.SetLastValue(val)
 		respond(conn, map[string]interface{}{
 			"id":      id,
 			"session": sessID,
-			"value":   valStr,
-			"ns":      n.ctx.CurrentNS().Name(),
+			"value":   val.String(),
+			"ns":      sess.ctx.CurrentNS().Name(),
 		})
 	}
 
@@ -311,7 +695,7 @@ func (n *NreplServer) handleCompletions(conn net.Conn, msg map[string]interface{
 	var completions []interface{}
 	if prefix != "" {
 		sym := vm.Symbol(prefix)
-		matches := rt.FuzzyNamespacedSymbolLookup(n.ctx.CurrentNS(), sym)
+		matches := rt.FuzzyNamespacedSymbolLookup(n.sessionNS(sessID), sym)
 		for _, m := range matches {
 			completions = append(completions, map[string]interface{}{
 				"candidate": string(m),
@@ -343,11 +727,6 @@ func (n *NreplServer) handleInfo(conn net.Conn, msg map[string]interface{}) {
 		sym = msgStr(msg, "symbol")
 	}
 
-	nsName := msgStr(msg, "ns")
-	if nsName == "" {
-		nsName = n.ctx.CurrentNS().Name()
-	}
-
 	resp := map[string]interface{}{
 		"id":      id,
 		"session": sessID,
@@ -356,22 +735,15 @@ func (n *NreplServer) handleInfo(conn net.Conn, msg map[string]interface{}) {
 
 	if sym != "" {
 		// Try to look up the symbol
-		ns := n.ctx.CurrentNS()
-		if nsName != "" && nsName != ns.Name() {
-			if found := rt.NS(nsName); found != nil {
-				ns = found
-			}
-		}
-		v := ns.Lookup(vm.Symbol(sym))
-		if v != vm.NIL {
-			info := map[string]interface{}{
-				"name": sym,
-				"ns":   nsName,
-			}
+		ns := n.lookupNS(sessID, msgStr(msg, "ns"))
+		v, ok := ns.Lookup(vm.Symbol(sym)).(*vm.Var)
+		if ok {
+			info := varInfo(v)
 			if op == "info" {
 				// CIDER info returns flat
-				resp["name"] = sym
-				resp["ns"] = nsName
+				for k, val := range info {
+					resp[k] = val
+				}
 			} else {
 				// lookup nests under "info"
 				resp["info"] = info
@@ -384,6 +756,90 @@ func (n *NreplServer) handleInfo(conn net.Conn, msg map[string]interface{}) {
 	respond(conn, resp)
 }
 
+// handleEldoc returns the arglists and docstring of a symbol.
+func (n *NreplServer) handleEldoc(conn net.Conn, msg map[string]interface{}) {
+	sym := msgStr(msg, "sym")
+	if sym == "" {
+		sym = msgStr(msg, "symbol")
+	}
+	resp := map[string]interface{}{
+		"id":      msgStr(msg, "id"),
+		"session": msgStr(msg, "session"),
+		"status":  []string{"done"},
+	}
+
+	ns := n.lookupNS(msgStr(msg, "session"), msgStr(msg, "ns"))
+	v, ok := ns.Lookup(vm.Symbol(sym)).(*vm.Var)
+	if sym == "" || !ok {
+		resp["status"] = []string{"done", "no-eldoc"}
+		respond(conn, resp)
+		return
+	}
+	meta := v.Meta().(vm.Lookup)
+	eldoc := [][]string{}
+	for _, al := range seqValues(meta.ValueAt(vm.Keyword("arglists"))) {
+		args := []string{}
+		for _, a := range seqValues(al) {
+			args = append(args, a.String())
+		}
+		eldoc = append(eldoc, args)
+	}
+	resp["name"] = v.VarName()
+	resp["ns"] = v.NS()
+	resp["eldoc"] = eldoc
+	resp["type"] = "function"
+	if v.IsMacro() {
+		resp["type"] = "macro"
+	} else if _, ok := v.Deref().(vm.Fn); !ok {
+		resp["type"] = "variable"
+	}
+	if doc, ok := meta.ValueAt(vm.Keyword("doc")).(vm.String); ok {
+		resp["docstring"] = string(doc)
+	}
+	respond(conn, resp)
+}
+
+// varInfo describes a var from its metadata, as info and lookup report it.
+func varInfo(v *vm.Var) map[string]interface{} {
+	meta := v.Meta().(vm.Lookup)
+	info := map[string]interface{}{
+		"name": v.VarName(),
+		"ns":   v.NS(),
+	}
+	if doc, ok := meta.ValueAt(vm.Keyword("doc")).(vm.String); ok {
+		info["doc"] = string(doc)
+	}
+	if al := meta.ValueAt(vm.Keyword("arglists")); al != vm.NIL {
+		info["arglists-str"] = al.String()
+	}
+	if file, ok := meta.ValueAt(vm.Keyword("file")).(vm.String); ok {
+		info["file"] = string(file)
+	}
+	if line, ok := meta.ValueAt(vm.Keyword("line")).(vm.Int); ok {
+		info["line"] = int(line)
+	}
+	if col, ok := meta.ValueAt(vm.Keyword("column")).(vm.Int); ok {
+		info["column"] = int(col)
+	}
+	if v.IsMacro() {
+		info["macro"] = "true"
+	}
+	return info
+}
+
+// seqValues returns the elements of a seqable value.
+func seqValues(v vm.Value) []vm.Value {
+	sq, ok := v.(vm.Sequable)
+	if !ok || v == vm.NIL {
+		return nil
+	}
+	var vs []vm.Value
+	for s := sq.Seq(); s != nil && s != vm.EmptyList && s != vm.NIL; s = s.Next() {
+		vs = append(vs, s.First())
+	}
+	return vs
+}
+
 // --- Helpers ---
 
 func respond(conn net.Conn, msg map[string]interface{}) {
@@ -394,6 +850,12 @@ func respond(conn net.Conn, msg map[string]interface{}) {
 	conn.Write(bs)
 }
 
+// msgInt returns an integer from msg, or 0.
+func msgInt(msg map[string]interface{}, key string) int {
+	n, _ := strconv.Atoi(msgStr(msg, key))
+	return n
+}
+
 func msgStr(msg map[string]interface{}, key string) string {
 	v, ok := msg[key]
 	if !ok || v == nil {
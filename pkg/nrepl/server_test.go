@@ -0,0 +1,393 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package nrepl
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nooga/let-go/pkg/compiler"
+	"github.com/nooga/let-go/pkg/resolver"
+	"github.com/nooga/let-go/pkg/rt"
+	"github.com/nooga/let-go/pkg/vm"
+	"github.com/stretchr/testify/assert"
+	"github.com/zeebo/bencode"
+)
+
+// client is a minimal nREPL client collecting responses by message id.
+type client struct {
+	t     *testing.T
+	conn  net.Conn
+	dec   *bencode.Decoder
+	ids   int
+	inbox map[string][]map[string]interface{}
+}
+
+func startServer(t *testing.T) (*NreplServer, *client) {
+	t.Chdir(t.TempDir()) // for .nrepl-port
+	consts := vm.NewConsts()
+	rt.SetNSLoader(resolver.NewNSResolver(compiler.NewCompiler(consts, rt.NS(rt.NameCoreNS)), []string{"."}))
+	n := NewNreplServer(compiler.NewCompiler(consts, rt.NS("user")))
+	return n, connect(t, n)
+}
+
+// connect starts n and returns a client connected to it.
+func connect(t *testing.T, n *NreplServer) *client {
+	assert.NoError(t, n.Start(0))
+	t.Cleanup(n.Stop)
+	conn, err := net.Dial("tcp", n.listener.Addr().String())
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, dec: bencode.NewDecoder(conn), inbox: map[string][]map[string]interface{}{}}
+}
+
+// send sends msg with a fresh id and returns the id.
+func (c *client) send(msg map[string]interface{}) string {
+	c.ids++
+	id := fmt.Sprint(c.ids)
+	msg["id"] = id
+	bs, err := bencode.EncodeBytes(msg)
+	assert.NoError(c.t, err)
+	_, err = c.conn.Write(bs)
+	assert.NoError(c.t, err)
+	return id
+}
+
+// wait reads responses until the one to id is done, returning them merged.
+func (c *client) wait(id string) map[string]interface{} {
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for !c.done(id) {
+		var msg map[string]interface{}
+		if err := c.dec.Decode(&msg); err != nil {
+			c.t.Fatalf("reading response to %s: %v", id, err)
+		}
+		mid := msgStr(msg, "id")
+		c.inbox[mid] = append(c.inbox[mid], msg)
+	}
+	merged := map[string]interface{}{}
+	for _, msg := range c.inbox[id] {
+		for k, v := range msg {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+func (c *client) done(id string) bool {
+	for _, msg := range c.inbox[id] {
+		status, _ := msg["status"].([]interface{})
+		for _, s := range status {
+			if s == "done" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *client) clone(from string) string {
+	msg := map[string]interface{}{"op": "clone"}
+	if from != "" {
+		msg["session"] = from
+	}
+	return msgStr(c.wait(c.send(msg)), "new-session")
+}
+
+func (c *client) eval(session, code string) map[string]interface{} {
+	return c.wait(c.send(map[string]interface{}{"op": "eval", "session": session, "code": code}))
+}
+
+func TestSessionsAreIsolated(t *testing.T) {
+	_, c := startServer(t)
+	a, b := c.clone(""), c.clone("")
+
+	res := c.eval(a, "(ns nrepl-test.a)")
+	assert.Equal(t, "nrepl-test.a", res["ns"])
+	res = c.eval(b, "(ns-name *ns*)")
+	assert.Equal(t, "user", res["value"])
+	assert.Equal(t, "user", res["ns"])
+
+	c.eval(a, "(+ 1 2)")
+	c.eval(a, ":x")
+	assert.Equal(t, "3", c.eval(a, "*2")["value"])
+	assert.Equal(t, "user", c.eval(b, "*1")["value"])
+
+	res = c.eval(a, "(throw (ex-info \"boom\" {}))")
+	assert.Contains(t, res["err"], "boom")
+	assert.Equal(t, `"boom"`, c.eval(a, "(ex-message *e)")["value"])
+	assert.Equal(t, "nil", c.eval(b, "*e")["value"])
+
+	// completions follow the session's namespace
+	c.eval(a, "(def only-in-a 1)")
+	res = c.wait(c.send(map[string]interface{}{"op": "completions", "session": a, "prefix": "only-in"}))
+	assert.NotEmpty(t, res["completions"])
+	res = c.wait(c.send(map[string]interface{}{"op": "completions", "session": b, "prefix": "only-in"}))
+	assert.Empty(t, res["completions"])
+
+	// clones start off with the state of the session they're cloned from
+	ac := c.clone(a)
+	res = c.eval(ac, "*2")
+	assert.Equal(t, `"boom"`, res["value"])
+	assert.Equal(t, "nrepl-test.a", res["ns"])
+}
+
+func TestSessionsEvalConcurrently(t *testing.T) {
+	_, c := startServer(t)
+	a, b := c.clone(""), c.clone("")
+	c.eval(a, "(def released (atom false))")
+
+	// a spins until b releases it, which only works if b isn't stuck behind a
+	spin := c.send(map[string]interface{}{"op": "eval", "session": a, "code": "(loop [] (if @released :released (recur)))"})
+	assert.Equal(t, "true", c.eval(b, "(reset! released true)")["value"])
+	assert.Equal(t, ":released", c.wait(spin)["value"])
+}
+
+func TestSessionsKeepTheirReplVarsInARuntime(t *testing.T) {
+	t.Chdir(t.TempDir())
+	r, err := compiler.NewRuntime()
+	assert.NoError(t, err)
+	exit := r.Enter()
+	n := NewNreplServer(compiler.NewCompiler(vm.NewConsts(), r.NS("user")))
+	exit()
+	c := connect(t, n)
+	a, b := c.clone(""), c.clone("")
+
+	c.eval(a, "(ns nrepl-test.a)")
+	c.eval(a, "(def released (atom false))")
+	c.eval(a, ":a")
+	c.eval(b, ":b")
+
+	// a looks at its vars once b, evaluating meanwhile, releases it
+	spin := c.send(map[string]interface{}{"op": "eval", "session": a,
+		"code": "(loop [] (if @released [(ns-name *ns*) *1] (recur)))"})
+	assert.Equal(t, "[user :b]", c.eval(b, "[(ns-name *ns*) *1]")["value"])
+	c.eval(b, "(reset! nrepl-test.a/released true)")
+	assert.Equal(t, "[nrepl-test.a :a]", c.wait(spin)["value"])
+	assert.Equal(t, "[nrepl-test.a :a]", c.eval(a, "*1")["value"])
+	assert.Equal(t, "true", c.eval(b, "*1")["value"])
+}
+
+func TestSessionsDefineConcurrently(t *testing.T) {
+	_, c := startServer(t)
+	var evals []string
+	for i := 0; i < 4; i++ {
+		s := c.clone("")
+		code := fmt.Sprintf(`(dotimes [i 200]
+			(intern 'user (symbol (str "v%d-" i)) i)
+			(def ^{:session %d} shared i)
+			(meta #'shared)
+			(create-ns (symbol (str "nrepl-test.s%d-" (mod i 10))))
+			(resolve 'shared)
+			(ns-publics 'user))`, i, i, i)
+		evals = append(evals, c.send(map[string]interface{}{"op": "eval", "session": s, "code": code}))
+	}
+	for _, id := range evals {
+		assert.Nil(t, c.wait(id)["err"])
+	}
+	assert.Equal(t, "[0 199 800]", c.eval(c.clone(""),
+		`[@#'user/v0-0 @#'user/v3-199 (count (filter #(re-find #"^v\d-" (name %)) (keys (ns-publics 'user))))]`)["value"])
+}
+
+func TestInterrupt(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+	interrupt := func(id string) []interface{} {
+		res := c.wait(c.send(map[string]interface{}{"op": "interrupt", "session": s, "interrupt-id": id}))
+		status, _ := res["status"].([]interface{})
+		return status
+	}
+	assert.Equal(t, []interface{}{"done", "session-idle"}, interrupt(""))
+
+	// the interruption can't be caught by the eval
+	spin := c.send(map[string]interface{}{"op": "eval", "session": s, "code": "(try (loop [] (recur)) (catch e :caught))"})
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(time.Millisecond) {
+		status := interrupt(spin)
+		if len(status) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("eval never started: %v %v", status, c.inbox[spin])
+		}
+	}
+	res := c.wait(spin)
+	assert.Equal(t, []interface{}{"interrupted"}, c.inbox[spin][0]["status"])
+	assert.Nil(t, res["value"])
+	assert.Equal(t, []interface{}{"done", "session-idle"}, interrupt(spin))
+
+	// the session carries on
+	assert.Equal(t, "3", c.eval(s, "(+ 1 2)")["value"])
+}
+
+// output joins the key (out or err) of all responses to id, in order.
+func (c *client) output(id, key string) string {
+	var b strings.Builder
+	for _, msg := range c.inbox[id] {
+		b.WriteString(msgStr(msg, key))
+	}
+	return b.String()
+}
+
+func TestEvalStreamsOutput(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+
+	id := c.send(map[string]interface{}{"op": "eval", "session": s, "code": `(print "a") (println "b")
+(binding [*out* *err*] (println "oops"))
+(<!! (go (println "from go") :went))`})
+	res := c.wait(id)
+	assert.Equal(t, ":went", res["value"])
+	assert.Equal(t, "ab\nfrom go\n", c.output(id, "out"))
+	assert.Equal(t, "oops\n", c.output(id, "err"))
+	// each write is sent as it happens, before the value
+	assert.Equal(t, "a", msgStr(c.inbox[id][0], "out"))
+}
+
+func TestEvalReadsStdin(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+
+	id := c.send(map[string]interface{}{"op": "eval", "session": s, "code": "[(read-line) (read-line)]"})
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	for needed := false; !needed; {
+		var msg map[string]interface{}
+		assert.NoError(t, c.dec.Decode(&msg))
+		c.inbox[msgStr(msg, "id")] = append(c.inbox[msgStr(msg, "id")], msg)
+		status, _ := msg["status"].([]interface{})
+		needed = len(status) > 0 && status[0] == "need-input"
+	}
+	c.wait(c.send(map[string]interface{}{"op": "stdin", "session": s, "stdin": "hello\nworld\nleft over\n"}))
+	assert.Equal(t, `["hello" "world"]`, c.wait(id)["value"])
+	// what wasn't read is there for the next eval
+	assert.Equal(t, `"left over"`, c.eval(s, "(read-line)")["value"])
+}
+
+func TestNamespaceOps(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+	c.eval(s, "(ns nrepl-test.vars) (def pub 1) (def ^:private priv 2) (defn f [] pub)")
+
+	res := c.wait(c.send(map[string]interface{}{"op": "ns-list", "session": s, "filter-regexps": []interface{}{"^core$"}}))
+	assert.Contains(t, res["ns-list"], "nrepl-test.vars")
+	assert.NotContains(t, res["ns-list"], "core")
+
+	res = c.wait(c.send(map[string]interface{}{"op": "ns-vars", "session": s, "ns": "nrepl-test.vars"}))
+	assert.Equal(t, []interface{}{"f", "pub"}, res["ns-vars"])
+
+	res = c.wait(c.send(map[string]interface{}{"op": "macroexpand", "session": s, "code": "(when a b)", "expander": "macroexpand-1"}))
+	assert.Equal(t, "(if a (do b) nil)", res["expansion"])
+
+	// without ns they work in the session's namespace, unknown ones aren't created
+	res = c.wait(c.send(map[string]interface{}{"op": "ns-vars", "session": s}))
+	assert.Equal(t, []interface{}{"f", "pub"}, res["ns-vars"])
+	res = c.wait(c.send(map[string]interface{}{"op": "macroexpand", "session": s, "code": "(f)"}))
+	assert.Equal(t, "(f)", res["expansion"])
+	assert.Equal(t, "nil", c.eval(s, `(find-ns (symbol ""))`)["value"])
+	for _, op := range []string{"ns-vars", "macroexpand"} {
+		res = c.wait(c.send(map[string]interface{}{"op": op, "session": s, "ns": "nrepl-test.no-such-ns", "code": "(f)"}))
+		assert.Equal(t, []interface{}{"done", "error", "namespace-not-found"}, res["status"], op)
+	}
+	assert.Equal(t, "nil", c.eval(s, "(find-ns 'nrepl-test.no-such-ns)")["value"])
+}
+
+func TestEldocAndInfo(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+	c.eval(s, `(ns nrepl-test.eldoc) (defn greet "Says hi." [who] who)`)
+
+	// without ns the symbol is looked up in the session's namespace
+	res := c.wait(c.send(map[string]interface{}{"op": "eldoc", "session": s, "sym": "greet"}))
+	assert.Equal(t, []interface{}{[]interface{}{"who"}}, res["eldoc"])
+	assert.Equal(t, "Says hi.", res["docstring"])
+	res = c.wait(c.send(map[string]interface{}{"op": "info", "session": s, "sym": "greet"}))
+	assert.Equal(t, "nrepl-test.eldoc", res["ns"])
+
+	// an unknown ns falls back to the session's one and isn't created
+	res = c.wait(c.send(map[string]interface{}{"op": "eldoc", "session": s, "sym": "greet", "ns": "nrepl-test.nowhere"}))
+	assert.Equal(t, "greet", res["name"])
+	res = c.wait(c.send(map[string]interface{}{"op": "info", "session": s, "sym": "greet", "ns": "nrepl-test.nowhere"}))
+	assert.Equal(t, "greet", res["name"])
+	assert.Equal(t, "nil", c.eval(s, "(find-ns 'nrepl-test.nowhere)")["value"])
+	res = c.wait(c.send(map[string]interface{}{"op": "eldoc", "session": s, "sym": "inc", "ns": "core"}))
+	assert.Equal(t, "core", res["ns"])
+}
+
+func TestStacktrace(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+
+	res := c.wait(c.send(map[string]interface{}{"op": "stacktrace", "session": s}))
+	assert.Contains(t, res["status"], "no-error")
+
+	c.eval(s, "(defn explode [] (throw (ex-info \"boom\" {:a 1})))")
+	res = c.eval(s, "(explode)")
+	assert.NotEqual(t, "ex", res["ex"])
+
+	id := c.send(map[string]interface{}{"op": "analyze-last-stacktrace", "session": s})
+	c.wait(id)
+	cause := c.inbox[id][0]
+	assert.Equal(t, "boom", cause["message"])
+	assert.Equal(t, "{:a 1}", cause["data"])
+	frames, _ := cause["stacktrace"].([]interface{})
+	var found bool
+	for _, f := range frames {
+		frame := f.(map[string]interface{})
+		if frame["fn"] == "explode" {
+			found = true
+			assert.Equal(t, "user", frame["ns"])
+			assert.EqualValues(t, 1, frame["line"])
+		}
+	}
+	assert.True(t, found, "no frame for explode in %v", frames)
+}
+
+func TestRunTests(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+	c.eval(s, `(ns nrepl-test.tests (:require [test :refer :all]))
+(def fixed (atom false))
+(deftest passes (is (= 1 1)))
+(deftest fails (is (= 1 2) "one is not two") (is @fixed))`)
+
+	res := c.wait(c.send(map[string]interface{}{"op": "test", "session": s, "ns": "nrepl-test.tests"}))
+	summary := res["summary"].(map[string]interface{})
+	assert.EqualValues(t, 2, summary["var"])
+	assert.EqualValues(t, 1, summary["pass"])
+	assert.EqualValues(t, 2, summary["fail"])
+	fails := res["results"].(map[string]interface{})["nrepl-test.tests"].(map[string]interface{})["fails"].([]interface{})
+	first := fails[0].(map[string]interface{})
+	assert.Equal(t, "fail", first["type"])
+	assert.Equal(t, "one is not two", first["message"])
+	assert.Equal(t, "(= 1 2)\n", first["expected"])
+
+	// retest only runs what failed last time
+	c.eval(s, "(reset! fixed true)")
+	res = c.wait(c.send(map[string]interface{}{"op": "retest", "session": s}))
+	summary = res["summary"].(map[string]interface{})
+	assert.EqualValues(t, 1, summary["var"])
+	assert.EqualValues(t, 1, summary["pass"])
+}
+
+func TestEvalSourcePosition(t *testing.T) {
+	_, c := startServer(t)
+	s := c.clone("")
+
+	c.wait(c.send(map[string]interface{}{"op": "eval", "session": s, "code": "(def here 1)", "file": "src/app.lg", "line": 12, "column": 3}))
+	assert.Equal(t, `["src/app.lg" 12 3]`, c.eval(s, "((juxt :file :line :column) (meta #'here))")["value"])
+
+	res := c.wait(c.send(map[string]interface{}{"op": "eval", "session": s, "code": "(inc\n  (undefined-thing))", "file": "src/app.lg", "line": 20, "column": 1}))
+	assert.Contains(t, res["err"], "src/app.lg:21:")
+
+	// evals without a file are back to REPL
+	c.eval(s, "(def there 1)")
+	assert.Equal(t, `"REPL"`, c.eval(s, "(:file (meta #'there))")["value"])
+	assert.Contains(t, c.eval(s, "(undefined-thing)")["err"], "REPL:1:")
+
+	res = c.wait(c.send(map[string]interface{}{"op": "load-file", "session": s, "file": "\n\n(defn loaded [] 1)", "file-path": "/proj/src/loaded.lg", "file-name": "loaded.lg"}))
+	assert.Equal(t, `["/proj/src/loaded.lg" 3]`, c.eval(s, "((juxt :file :line) (meta #'loaded))")["value"])
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package nrepl
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/nooga/let-go/pkg/rt"
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// testSelection maps namespace names to the names of the tests to run in
+// them, nil meaning all of them.
+type testSelection map[string][]string
+
+// selectTests reads which tests to run from a test, test-all,
+// test-var-query or retest message.
+func (n *NreplServer) selectTests(sess *session, msg map[string]interface{}) testSelection {
+	sel := testSelection{}
+	switch msgStr(msg, "op") {
+	case "test":
+		if tests := msgStrs(msg, "tests"); len(tests) > 0 {
+			sel[msgStr(msg, "ns")] = tests
+		} else {
+			sel[msgStr(msg, "ns")] = nil
+		}
+	case "test-all":
+		for _, ns := range testedNamespaces() {
+			sel[ns] = nil
+		}
+	case "test-var-query":
+		query, _ := msg["var-query"].(map[string]interface{})
+		nsQuery, _ := query["ns-query"].(map[string]interface{})
+		for _, ns := range msgStrs(nsQuery, "exactly") {
+			sel[ns] = nil
+		}
+		for _, v := range msgStrs(query, "exactly") {
+			if i := strings.LastIndexByte(v, '/'); i > 0 {
+				sel[v[:i]] = append(sel[v[:i]], v[i+1:])
+			}
+		}
+	case "retest":
+		sess.mu.Lock()
+		for ns, tests := range sess.failed {
+			sel[ns] = tests
+		}
+		sess.mu.Unlock()
+	}
+	return sel
+}
+
+// testedNamespaces returns the names of namespaces with tests defined.
+func testedNamespaces() []string {
+	testNS := rt.LookupNS("test")
+	if testNS == nil {
+		return nil
+	}
+	var names []string
+	for _, e := range seqValues(testNS.LookupLocal("*registered-tests*").Deref()) {
+		if ns, ok := seqValues(e)[0].(*vm.Namespace); ok {
+			names = append(names, ns.Name())
+		}
+	}
+	return names
+}
+
+// registeredTests returns the test vars defined in ns, in order.
+func registeredTests(testNS *vm.Namespace, ns *vm.Namespace) []*vm.Var {
+	registered, ok := testNS.LookupLocal("*registered-tests*").Deref().(vm.Lookup)
+	if !ok {
+		return nil
+	}
+	var tests []*vm.Var
+	seen := map[*vm.Var]bool{}
+	for _, t := range seqValues(registered.ValueAt(ns)) {
+		// reloading a namespace registers its tests again
+		if v, ok := t.(*vm.Var); ok && !seen[v] {
+			seen[v] = true
+			tests = append(tests, v)
+		}
+	}
+	return tests
+}
+
+// handleTest runs tests with the test namespace on the session's goroutine,
+// streaming their output, and reports the outcome of every assertion the way
+// cider-nrepl does.
+func (n *NreplServer) handleTest(conn net.Conn, sess *session, msg map[string]interface{}) {
+	id := msgStr(msg, "id")
+	sessID := msgStr(msg, "session")
+	sel := n.selectTests(sess, msg)
+
+	evalCtx, cancel := context.WithCancel(context.Background())
+	sess.startEval(id, cancel)
+	defer func() {
+		sess.finishEval()
+		cancel()
+	}()
+	defer sess.streamIO(evalCtx, conn, id)()
+
+	results := map[string]interface{}{}
+	summary := map[string]interface{}{"ns": 0, "var": 0, "test": 0, "pass": 0, "fail": 0, "error": 0}
+	count := func(key string) { summary[key] = summary[key].(int) + 1 }
+	failed := map[string][]string{}
+	var nss []string
+	for ns := range sel {
+		nss = append(nss, ns)
+	}
+	sort.Strings(nss)
+
+	testNS := rt.LookupNS("test")
+	interrupted := false
+	for _, nsName := range nss {
+		ns := rt.LookupNS(nsName)
+		if testNS == nil || ns == nil {
+			continue
+		}
+		only := map[string]bool{}
+		for _, t := range sel[nsName] {
+			only[t] = true
+		}
+		nsResults := map[string]interface{}{}
+		for _, v := range registeredTests(testNS, ns) {
+			if len(only) > 0 && !only[v.VarName()] {
+				continue
+			}
+			var varResults []interface{}
+			add := func(r map[string]interface{}) {
+				r["ns"] = nsName
+				r["var"] = v.VarName()
+				r["index"] = len(varResults)
+				if r["type"] != "pass" {
+					meta, _ := v.Meta().(vm.Lookup)
+					if file, ok := meta.ValueAt(vm.Keyword("file")).(vm.String); meta != nil && ok {
+						r["file"] = string(file)
+					}
+					if line, ok := meta.ValueAt(vm.Keyword("line")).(vm.Int); meta != nil && ok {
+						r["line"] = int(line)
+					}
+				}
+				count(r["type"].(string))
+				count("test")
+				varResults = append(varResults, r)
+			}
+			err := runTest(evalCtx, testNS, v, func(report vm.Lookup) {
+				add(assertionResult(report))
+			})
+			if err != nil && vm.IsInterrupted(err) {
+				interrupted = true
+				break
+			}
+			if err != nil {
+				sess.setLastError(err)
+				add(map[string]interface{}{
+					"type":    "error",
+					"error":   errorClass(err),
+					"message": "Uncaught exception, not in assertion",
+					"actual":  vm.FormatError(err),
+				})
+			}
+			count("var")
+			for _, r := range varResults {
+				if r.(map[string]interface{})["type"] != "pass" {
+					failed[nsName] = append(failed[nsName], v.VarName())
+					break
+				}
+			}
+			nsResults[v.VarName()] = varResults
+		}
+		if len(nsResults) > 0 {
+			count("ns")
+			results[nsName] = nsResults
+		}
+		if interrupted {
+			break
+		}
+	}
+
+	sess.mu.Lock()
+	sess.failed = failed
+	sess.mu.Unlock()
+
+	resp := map[string]interface{}{
+		"id":        id,
+		"session":   sessID,
+		"results":   results,
+		"summary":   summary,
+		"gen-input": []string{},
+		"status":    []string{"done"},
+	}
+	if len(nss) > 0 {
+		resp["testing-ns"] = nss[0]
+	}
+	if interrupted {
+		resp["status"] = []string{"done", "interrupted"}
+	}
+	respond(conn, resp)
+}
+
+// runTest runs the test in v with test/test-var, passing each assertion's
+// report to report. The test gets its own bindings of the test namespace's
+// state so that runs don't interfere with each other.
+func runTest(ctx context.Context, testNS *vm.Namespace, v *vm.Var, report func(vm.Lookup)) error {
+	reporter, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) == 1 {
+			if m, ok := vs[0].(vm.Lookup); ok {
+				report(m)
+			}
+		}
+		return vm.NIL, nil
+	})
+	bindings := map[*vm.Var]vm.Value{testNS.LookupLocal("*report*"): reporter}
+	for _, name := range []string{"*testing-vars*", "*testing-contexts*", "*report-counters*", "*test-result*"} {
+		if tv := testNS.LookupLocal(vm.Symbol(name)); tv != nil {
+			bindings[tv] = tv.Deref()
+		}
+	}
+	vm.PushThreadBindings(bindings)
+	defer vm.PopThreadBindings()
+	testVar, ok := testNS.LookupLocal("test-var").Deref().(vm.Fn)
+	if !ok {
+		return vm.NewExecutionError("test/test-var is not a function")
+	}
+	_, err := vm.WithContext(ctx, func() (vm.Value, error) {
+		return testVar.Invoke([]vm.Value{v})
+	})
+	return err
+}
+
+// assertionResult describes the report of an assertion made with is.
+func assertionResult(report vm.Lookup) map[string]interface{} {
+	r := map[string]interface{}{
+		"type":     "pass",
+		"expected": report.ValueAt(vm.Keyword("expected")).String() + "\n",
+		"actual":   report.ValueAt(vm.Keyword("actual")).String() + "\n",
+		"message":  "",
+		"context":  "",
+	}
+	if t, ok := report.ValueAt(vm.Keyword("type")).(vm.Keyword); ok {
+		r["type"] = string(t)
+	}
+	if msg, ok := report.ValueAt(vm.Keyword("message")).(vm.String); ok {
+		r["message"] = string(msg)
+	}
+	if c, ok := report.ValueAt(vm.Keyword("context")).(vm.String); ok {
+		r["context"] = string(c)
+	}
+	return r
+}
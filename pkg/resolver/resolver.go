@@ -84,7 +84,7 @@ func (r *NSResolver) Load(name string) *vm.Namespace {
 // loadEmbedded loads bundled namespaces from embedded sources
 func (r *NSResolver) loadEmbedded(name string) *vm.Namespace {
 	// Try precompiled bytecode first
-	if chunk := compiler.PrecompiledNSChunk(name); chunk != nil {
+	if chunk := r.ctx.Runtime().PrecompiledNSChunk(name); chunk != nil {
 		return r.execPrecompiled(name, chunk)
 	}
 
@@ -112,9 +112,11 @@ func (r *NSResolver) loadEmbedded(name string) *vm.Namespace {
 		src = rt.ZipSrc
 	case "data":
 		src = rt.DataSrc
+	case "repl":
+		src = rt.ReplSrc
 	case "term":
 		// term is a pure Go namespace, already registered in init()
-		return rt.NS("term")
+		return r.ctx.Runtime().NS("term")
 	default:
 		return nil
 	}
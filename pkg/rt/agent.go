@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+func asAgent(name string, v vm.Value) (*vm.Agent, error) {
+	a, ok := v.(*vm.Agent)
+	if !ok {
+		return nil, fmt.Errorf("%s expected Agent, got %s", name, v.Type().Name())
+	}
+	return a, nil
+}
+
+func parseErrorMode(v vm.Value) (vm.AgentErrorMode, error) {
+	switch v {
+	case vm.Keyword("fail"):
+		return vm.AgentFail, nil
+	case vm.Keyword("continue"):
+		return vm.AgentContinue, nil
+	}
+	return vm.AgentFail, fmt.Errorf("unknown agent error mode %s", v)
+}
+
+// nolint
+func installAgentBuiltins(ns *vm.Namespace) {
+	ns.Def("*agent*", vm.NIL).SetDynamic()
+
+	// agent — (agent state & {:keys [meta validator error-handler error-mode]})
+	agentf, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		opts, err := refOptions("agent", vs[1:])
+		if err != nil {
+			return vm.NIL, err
+		}
+		a := vm.NewAgent(vs[0])
+		if m, ok := opts["meta"]; ok {
+			a.ResetMeta(m)
+		}
+		if err := applyValidator(a, opts); err != nil {
+			return vm.NIL, err
+		}
+		// Like in Clojure, agents with an error handler continue by default
+		if h, ok := opts["error-handler"]; ok && h != vm.NIL {
+			fn, ok := h.(vm.Fn)
+			if !ok {
+				return vm.NIL, fmt.Errorf("error-handler must be a Fn")
+			}
+			a.SetErrorHandler(fn)
+			a.SetErrorMode(vm.AgentContinue)
+		}
+		if m, ok := opts["error-mode"]; ok {
+			mode, err := parseErrorMode(m)
+			if err != nil {
+				return vm.NIL, err
+			}
+			a.SetErrorMode(mode)
+		}
+		return a, nil
+	})
+
+	// sender makes send and send-off. Actions run with the bindings in effect
+	// at the time of the send, plus *agent* of the runtime they run in bound
+	// to the agent.
+	sender := func(name string, solo bool) vm.Value {
+		f, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+			if len(vs) < 2 {
+				return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+			}
+			a, err := asAgent(name, vs[0])
+			if err != nil {
+				return vm.NIL, err
+			}
+			fn, ok := vs[1].(vm.Fn)
+			if !ok {
+				return vm.NIL, fmt.Errorf("%s expected Fn", name)
+			}
+			action, _ := vm.NativeFnType.Wrap(func(args []vm.Value) (vm.Value, error) {
+				agentVar := Current().CoreNS().LookupLocal("*agent*")
+				vm.PushThreadBindings(map[*vm.Var]vm.Value{agentVar: a})
+				defer vm.PopThreadBindings()
+				return fn.Invoke(args)
+			})
+			args := append([]vm.Value{}, vs[2:]...)
+			if err := a.Send(vm.BindingConveyor(action.(vm.Fn)), args, solo); err != nil {
+				return vm.NIL, err
+			}
+			return a, nil
+		})
+		return f
+	}
+
+	// await — (await & agents)
+	await, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		for _, v := range vs {
+			a, err := asAgent("await", v)
+			if err != nil {
+				return vm.NIL, err
+			}
+			if _, err := a.Await(0); err != nil {
+				return vm.NIL, err
+			}
+		}
+		return vm.NIL, nil
+	})
+
+	// await-for — (await-for timeout-ms & agents), false on timeout
+	awaitFor, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		ms, ok := vs[0].(vm.Int)
+		if !ok {
+			return vm.NIL, fmt.Errorf("await-for expected Int timeout")
+		}
+		deadline := time.Now().Add(time.Duration(ms) * time.Millisecond)
+		for _, v := range vs[1:] {
+			a, err := asAgent("await-for", v)
+			if err != nil {
+				return vm.NIL, err
+			}
+			left := time.Until(deadline)
+			if left <= 0 {
+				return vm.FALSE, nil
+			}
+			ok, err := a.Await(left)
+			if err != nil || !ok {
+				return vm.FALSE, err
+			}
+		}
+		return vm.TRUE, nil
+	})
+
+	agentError, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		a, err := asAgent("agent-error", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return a.ErrorValue(), nil
+	})
+
+	// restart-agent — (restart-agent a new-state & {:keys [clear-actions]})
+	restartAgent, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		a, err := asAgent("restart-agent", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		opts, err := refOptions("restart-agent", vs[2:])
+		if err != nil {
+			return vm.NIL, err
+		}
+		clear := opts["clear-actions"] != nil && vm.IsTruthy(opts["clear-actions"])
+		if err := a.Restart(vs[1], clear); err != nil {
+			return vm.NIL, err
+		}
+		return vs[1], nil
+	})
+
+	setErrorHandler, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		a, err := asAgent("set-error-handler!", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if vs[1] == vm.NIL {
+			a.SetErrorHandler(nil)
+			return a, nil
+		}
+		fn, ok := vs[1].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("set-error-handler! expected Fn")
+		}
+		a.SetErrorHandler(fn)
+		return a, nil
+	})
+
+	errorHandler, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		a, err := asAgent("error-handler", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if fn := a.ErrorHandler(); fn != nil {
+			return fn, nil
+		}
+		return vm.NIL, nil
+	})
+
+	setErrorMode, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		a, err := asAgent("set-error-mode!", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		mode, err := parseErrorMode(vs[1])
+		if err != nil {
+			return vm.NIL, err
+		}
+		a.SetErrorMode(mode)
+		return a, nil
+	})
+
+	errorMode, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		a, err := asAgent("error-mode", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if a.ErrorMode() == vm.AgentContinue {
+			return vm.Keyword("continue"), nil
+		}
+		return vm.Keyword("fail"), nil
+	})
+
+	releasePendingSends, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 0 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		return vm.Int(vm.ReleasePendingSends()), nil
+	})
+
+	// shutdown-agents — agents run on goroutines, there's no pool to stop
+	shutdownAgents, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		return vm.NIL, nil
+	})
+
+	ns.Def("agent", agentf)
+	ns.Def("send", sender("send", false))
+	ns.Def("send-off", sender("send-off", true))
+	ns.Def("await", await)
+	ns.Def("await-for", awaitFor)
+	ns.Def("agent-error", agentError)
+	ns.Def("restart-agent", restartAgent)
+	ns.Def("set-error-handler!", setErrorHandler)
+	ns.Def("error-handler", errorHandler)
+	ns.Def("set-error-mode!", setErrorMode)
+	ns.Def("error-mode", errorMode)
+	ns.Def("release-pending-sends", releasePendingSends)
+	ns.Def("shutdown-agents", shutdownAgents)
+}
@@ -37,7 +37,7 @@ type Pub struct {
 // nolint
 func installAsyncNS() {
 	// Look up the core builtins to re-export
-	coreNS := nsRegistry[NameCoreNS]
+	coreNS := CoreNS()
 
 	// close! — close a channel
 	closeChan, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
@@ -310,7 +310,14 @@ func installAsyncNS() {
 			return vm.NIL, fmt.Errorf("alts! requires at least one port")
 		}
 
+		// the evaluation being stopped is the last case, with no port
+		if done := vm.Done(); done != nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+		}
 		chosen, value, ok := reflect.Select(cases)
+		if chosen == len(ports) {
+			return vm.NIL, vm.Stopped()
+		}
 		port := ports[chosen]
 
 		var result vm.Value
@@ -711,8 +718,33 @@ func installAsyncNS() {
 		return out, nil
 	})
 
+	// thread-call — run f on its own goroutine, returns a channel receiving the result
+	threadCall, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("thread-call expects 1 arg")
+		}
+		fn, ok := vs[0].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("thread-call expected Fn")
+		}
+		fn = vm.BindingConveyor(fn)
+		stderr := Stderr()
+		ch := make(vm.Chan, 1)
+		go func() {
+			v, err := fn.Invoke(nil)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+			}
+			if v != vm.NIL {
+				ch <- v
+			}
+			close(ch)
+		}()
+		return ch, nil
+	})
+
 	ns := vm.NewNamespace("async")
-	ns.Refer(CoreNS, "", true)
+	ns.Refer(CoreNS(), "", true)
 
 	// Re-export core primitives (extract root value from Var)
 	ns.Def("go*", coreNS.Lookup("go*").(*vm.Var).Deref())
@@ -723,6 +755,7 @@ func installAsyncNS() {
 	ns.Def("chan", chanBuf)
 	ns.Def("close!", closeChan)
 	ns.Def("timeout", timeout)
+	ns.Def("thread-call", threadCall)
 	ns.Def("pipe", pipe)
 	ns.Def("onto-chan!", ontoChan)
 	ns.Def("to-chan!", toChan)
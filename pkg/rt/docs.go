@@ -0,0 +1,269 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"strings"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// nativeDoc is the :arglists and :doc metadata of a var holding a Go native.
+// The arglists are written like in Clojure docs, e.g. "[x] [x & more]".
+type nativeDoc struct {
+	arglists string
+	doc      string
+}
+
+// nativeDocs documents the natives installed in core. Vars defined in
+// core.lg get their metadata from defn and friends.
+var nativeDocs = map[string]nativeDoc{
+	"+":                   {"[] [x] [x y] [x y & more]", "Returns the sum of nums. (+) returns 0."},
+	"-":                   {"[x] [x y] [x y & more]", "If no ys are supplied, returns the negation of x, else subtracts the ys from x."},
+	"*":                   {"[] [x] [x y] [x y & more]", "Returns the product of nums. (*) returns 1."},
+	"/":                   {"[x] [x y] [x y & more]", "If no denominators are supplied, returns 1/numerator, else returns numerator divided by all of the denominators."},
+	"=":                   {"[x] [x y] [x y & more]", "Equality. Returns true if x equals y, false if not."},
+	"==":                  {"[x] [x y] [x y & more]", "Returns true if the numbers are all equal in value, whatever their types."},
+	"gt":                  {"[x] [x y] [x y & more]", "Returns non-nil if nums are in monotonically decreasing order."},
+	"lt":                  {"[x] [x y] [x y & more]", "Returns non-nil if nums are in monotonically increasing order."},
+	"ge":                  {"[x] [x y] [x y & more]", "Returns non-nil if nums are in monotonically non-increasing order."},
+	"le":                  {"[x] [x y] [x y & more]", "Returns non-nil if nums are in monotonically non-decreasing order."},
+	"mod":                 {"[num div]", "Modulus of num and div. Truncates toward negative infinity."},
+	"quot":                {"[num div]", "Quotient of dividing numerator by denominator."},
+	"rem":                 {"[num div]", "Remainder of dividing numerator by denominator."},
+	"abs":                 {"[a]", "Returns the absolute value of a."},
+	"max":                 {"[x] [x y] [x y & more]", "Returns the greatest of the nums."},
+	"min":                 {"[x] [x y] [x y & more]", "Returns the least of the nums."},
+	"not":                 {"[x]", "Returns true if x is logical false, false otherwise."},
+	"gensym":              {"[] [prefix-string]", "Returns a new symbol with a unique name."},
+	"in-ns":               {"[name]", "Sets *ns* to the namespace named by the symbol, creating it if needed."},
+	"use":                 {"[& args]", "Like require, but refers all public vars of the namespace."},
+	"alias":               {"[alias namespace-sym]", "Add an alias in the current namespace to another namespace."},
+	"name":                {"[x]", "Returns the name String of a string, symbol or keyword."},
+	"namespace":           {"[x]", "Returns the namespace String of a symbol or keyword, or nil if not present."},
+	"vector":              {"[& args]", "Creates a new vector containing the args."},
+	"vec":                 {"[coll]", "Creates a new vector containing the contents of coll."},
+	"hash-map":            {"[& keyvals]", "Returns a new hash map with supplied mappings."},
+	"list":                {"[& items]", "Creates a new list containing the items."},
+	"range":               {"[] [end] [start end] [start end step]", "Returns a seq of nums from start (inclusive) to end (exclusive), by step."},
+	"keyword":             {"[name] [ns name]", "Returns a Keyword with the given namespace and name."},
+	"symbol":              {"[name] [ns name]", "Returns a Symbol with the given namespace and name."},
+	"hash-set":            {"[& keys]", "Returns a new hash set with supplied keys."},
+	"seq":                 {"[coll]", "Returns a seq on the collection. If the collection is empty, returns nil."},
+	"seq?":                {"[x]", "Return true if x is a seq."},
+	"coll?":               {"[x]", "Returns true if x is a collection."},
+	"empty":               {"[coll]", "Returns an empty collection of the same category as coll, or nil."},
+	"assoc":               {"[map key val] [map key val & kvs]", "Returns a new map (or vector) that contains the mapping of key(s) to val(s)."},
+	"dissoc":              {"[map] [map key] [map key & ks]", "Returns a new map that does not contain a mapping for key(s)."},
+	"update":              {"[m k f] [m k f & args]", "Updates the value at k in m with (apply f old-value args)."},
+	"cons":                {"[x seq]", "Returns a new seq where x is the first element and seq is the rest."},
+	"conj":                {"[] [coll] [coll x] [coll x & xs]", "Returns a new collection with the xs 'added'."},
+	"disj":                {"[set] [set key] [set key & ks]", "Returns a new set that does not contain key(s)."},
+	"first":               {"[coll]", "Returns the first item in the collection, or nil."},
+	"second":              {"[coll]", "Returns the second item in the collection, or nil."},
+	"next":                {"[coll]", "Returns a seq of the items after the first, or nil if there are none."},
+	"rest":                {"[coll]", "Returns a possibly empty seq of the items after the first."},
+	"get":                 {"[map key] [map key not-found]", "Returns the value mapped to key, not-found or nil if key not present."},
+	"nth":                 {"[coll index] [coll index not-found]", "Returns the value at the index."},
+	"count":               {"[coll]", "Returns the number of items in the collection. (count nil) returns 0."},
+	"contains?":           {"[coll key]", "Returns true if key is present in the given collection, otherwise false."},
+	"mapv":                {"[f coll] [f c1 c2]", "Returns a vector of the result of applying f to the items of the colls."},
+	"reduce":              {"[f coll] [f val coll]", "Reduces coll with f, starting from val or the first item."},
+	"some":                {"[pred coll]", "Returns the first logical true value of (pred x) for any x in coll, else nil."},
+	"println":             {"[& more]", "Prints the args separated by spaces, followed by a newline."},
+	"print":               {"[& more]", "Prints the args separated by spaces."},
+	"prn":                 {"[& more]", "Prints the args readably, followed by a newline."},
+	"pr":                  {"[& more]", "Prints the args readably."},
+	"pr-str":              {"[& xs]", "Prints the args readably to a string and returns it."},
+	"type":                {"[x]", "Returns the type of x."},
+	"deref":               {"[ref] [ref timeout-ms timeout-val]", "Returns the current value of a reference type. Also reader macro: @ref."},
+	"atom":                {"[x] [x & options]", "Creates and returns an Atom with an initial value of x."},
+	"reset!":              {"[atom newval]", "Sets the value of atom to newval. Returns newval."},
+	"swap!":               {"[atom f] [atom f & args]", "Swaps the value of atom to be (apply f current-value-of-atom args). Returns the new value."},
+	"swap-vals!":          {"[atom f] [atom f & args]", "Like swap! but returns [old new]."},
+	"reset-vals!":         {"[atom newval]", "Sets the value of atom to newval. Returns [old new]."},
+	"now":                 {"[]", "Returns the current time."},
+	"slurp":               {"[f]", "Reads the file named by f and returns its contents as a string."},
+	"spit":                {"[f content]", "Writes content to the file named by f."},
+	"parse-int":           {"[s]", "Parses s as an integer, returns nil if it's not one."},
+	"parse-double":        {"[s]", "Parses s as a floating point number, returns nil if it's not one."},
+	"parse-boolean":       {"[s]", "Parses \"true\" or \"false\", returns nil otherwise."},
+	"sort":                {"[coll] [comp coll]", "Returns a sorted sequence of the items in coll, using comp (default compare)."},
+	"int":                 {"[x]", "Coerce to int."},
+	"float":               {"[x]", "Coerce to float."},
+	"double":              {"[x]", "Coerce to double."},
+	"char":                {"[x]", "Coerce to char."},
+	"number?":             {"[x]", "Returns true if x is a number."},
+	"float?":              {"[x]", "Returns true if x is a floating point number."},
+	"int?":                {"[x]", "Returns true if x is an integer."},
+	"boolean?":            {"[x]", "Returns true if x is a Boolean."},
+	"char?":               {"[x]", "Returns true if x is a Character."},
+	"fn?":                 {"[x]", "Returns true if x is a function."},
+	"var?":                {"[v]", "Returns true if v is a Var."},
+	"str":                 {"[] [x] [x & ys]", "With no args, returns the empty string. With one arg x, returns x as a string. With more, returns the concatenation of their strings."},
+	"subs":                {"[s start] [s start end]", "Returns the substring of s beginning at start inclusive, and ending at end exclusive."},
+	"format":              {"[fmt & args]", "Formats a string using Go fmt verbs."},
+	"re-pattern":          {"[s]", "Returns a regex pattern for s."},
+	"re-find":             {"[re s]", "Returns the next regex match, if any, of string to pattern."},
+	"re-matches":          {"[re s]", "Returns the match, if any, of string to pattern."},
+	"re-seq":              {"[re s]", "Returns a lazy sequence of successive matches of pattern in string."},
+	"re-groups":           {"[m]", "Returns the groups from the most recent match."},
+	"rand":                {"[] [n]", "Returns a random floating point number between 0 (inclusive) and n (default 1) (exclusive)."},
+	"rand-int":            {"[n]", "Returns a random integer between 0 (inclusive) and n (exclusive)."},
+	"rand-nth":            {"[coll]", "Return a random element of the collection."},
+	"shuffle":             {"[coll]", "Return a random permutation of coll."},
+	"transient":           {"[coll]", "Returns a new, transient version of the collection."},
+	"persistent!":         {"[coll]", "Returns a new, persistent version of the transient collection."},
+	"conj!":               {"[coll x]", "Adds x to the transient collection."},
+	"assoc!":              {"[coll key val]", "Sets the value of key to val in the transient collection."},
+	"dissoc!":             {"[map key]", "Removes key from the transient map."},
+	"record?":             {"[x]", "Returns true if x is a record."},
+	"satisfies?":          {"[protocol x]", "Returns true if x satisfies the protocol."},
+	"methods":             {"[multifn]", "Given a multimethod, returns a map of dispatch values -> dispatch fns."},
+	"require":             {"[& args]", "Loads namespaces, optionally aliasing them or referring their vars."},
+	"find-ns":             {"[sym]", "Returns the namespace named by the symbol or nil if it doesn't exist."},
+	"all-ns":              {"[]", "Returns a sequence of all namespaces."},
+	"the-ns":              {"[x]", "If passed a namespace, returns it. Else, when passed a symbol, returns the namespace named by it."},
+	"ns-name":             {"[ns]", "Returns the name of the namespace, a symbol."},
+	"peek":                {"[coll]", "For a list, same as first, for a vector, same as last."},
+	"pop":                 {"[coll]", "For a list, returns a new list without the first item, for a vector, returns a new vector without the last item."},
+	"iterate":             {"[f x]", "Returns a lazy sequence of x, (f x), (f (f x)) etc."},
+	"repeat":              {"[x] [n x]", "Returns a lazy (infinite!, or length n if supplied) sequence of xs."},
+	"with-meta":           {"[obj m]", "Returns an object of the same type and value as obj, with map m as its metadata."},
+	"meta":                {"[obj]", "Returns the metadata of obj, returns nil if there is no metadata."},
+	"alter-meta!":         {"[iref f & args]", "Atomically sets the metadata for a reference to be (apply f its-current-meta args)."},
+	"var-set":             {"[v val]", "Sets the value in the var object to val. The var must be thread-locally bound."},
+	"get-thread-bindings": {"[]", "Get a map with the Var/value pairs which are currently in effect for the current thread."},
+	"thread-bound?":       {"[& vars]", "Returns true if all of the vars provided as arguments have thread-local bindings."},
+	"throw":               {"[x]", "Throws x as an exception."},
+	"ex-info":             {"[msg map] [msg map cause]", "Create an instance of ExceptionInfo carrying a map of additional data."},
+	"ex-message":          {"[ex]", "Returns the message attached to ex."},
+	"ex-data":             {"[ex]", "Returns exception data (a map) if ex is an ExceptionInfo, otherwise nil."},
+	"ex-cause":            {"[ex]", "Returns the cause of ex."},
+	"ex-stack":            {"[ex]", "Returns the let-go stack trace of a caught exception ex as a vector of maps with :fn, :ns, :file, :line and :column keys, innermost frame first, or nil."},
+	"force":               {"[x]", "If x is a Delay, returns the (possibly cached) value of its expression, else returns x."},
+	"delay?":              {"[x]", "Returns true if x is a Delay."},
+	"realized?":           {"[x]", "Returns true if a value has been produced for a promise, delay, future or lazy sequence."},
+	"volatile!":           {"[val]", "Creates and returns a Volatile with an initial value of val."},
+	"vreset!":             {"[vol newval]", "Sets the value of volatile to newval without regard for the current value."},
+	"vswap!":              {"[vol f & args]", "Sets the value of volatile to (apply f current-value args)."},
+	"bigint":              {"[x]", "Coerce to BigInt."},
+	"bigint?":             {"[x]", "Returns true if x is a BigInt."},
+	"bigdec":              {"[x]", "Coerce to BigDecimal."},
+	"ratio?":              {"[n]", "Returns true if n is a Ratio."},
+	"decimal?":            {"[n]", "Returns true if n is a BigDecimal."},
+	"numerator":           {"[r]", "Returns the numerator part of a Ratio."},
+	"denominator":         {"[r]", "Returns the denominator part of a Ratio."},
+	"rationalize":         {"[num]", "Returns the rational value of num."},
+	"compare":             {"[x y]", "Returns a negative number, zero, or a positive number when x is logically 'less than', 'equal to', or 'greater than' y."},
+	"sorted-map":          {"[& keyvals]", "Returns a new sorted map with supplied mappings."},
+	"sorted-map-by":       {"[comparator & keyvals]", "Returns a new sorted map with supplied mappings, using the supplied comparator."},
+	"sorted-set":          {"[& keys]", "Returns a new sorted set with supplied keys."},
+	"sorted-set-by":       {"[comparator & keys]", "Returns a new sorted set with supplied keys, using the supplied comparator."},
+	"sorted?":             {"[coll]", "Returns true if coll is sorted."},
+	"rseq":                {"[rev]", "Returns, in constant time, a seq of the items in rev (which can be a vector or sorted-map), in reverse order."},
+	"subseq":              {"[sc test key] [sc start-test start-key end-test end-key]", "Returns a seq of those entries of the sorted collection sc with keys ek for which (test ek key) is true."},
+	"rsubseq":             {"[sc test key] [sc start-test start-key end-test end-key]", "Like subseq, but in reverse order."},
+	"inst?":               {"[x]", "Returns true if x is an instant."},
+	"inst-ms":             {"[inst]", "Return the number of milliseconds since January 1, 1970, 00:00:00 GMT."},
+	"uuid?":               {"[x]", "Returns true if x is a UUID."},
+	"random-uuid":         {"[]", "Returns a pseudo-randomly generated UUID."},
+	"parse-uuid":          {"[s]", "Parses a string representing a UUID, returns nil if it's not one."},
+	"promise":             {"[]", "Returns a promise object that can be read with deref, and set, once only, with deliver."},
+	"deliver":             {"[promise val]", "Delivers the supplied value to the promise."},
+	"add-watch":           {"[reference key fn]", "Adds a watch function to a reference. The fn is called with key, reference, old-state and new-state."},
+	"remove-watch":        {"[reference key]", "Removes a watch (set by add-watch) from a reference."},
+	"subvec":              {"[v start] [v start end]", "Returns a vector of the items in vector from start (inclusive) to end (exclusive)."},
+	"reduced":             {"[x]", "Wraps x in a way such that a reduce will terminate with the value x."},
+	"reduced?":            {"[x]", "Returns true if x is the result of a call to reduced."},
+	"unreduced":           {"[x]", "If x is reduced?, returns (deref x), else returns x."},
+	"ensure-reduced":      {"[x]", "If x is already reduced?, returns it, else returns (reduced x)."},
+	"hash":                {"[x]", "Returns the hash code of its argument."},
+	"NaN?":                {"[num]", "Returns true if num is NaN, else false."},
+	"infinite?":           {"[num]", "Returns true if num is negative or positive infinity, else false."},
+	"chan":                {"[] [n]", "Creates a channel, buffered if n is given."},
+	">!":                  {"[ch val]", "Puts val into ch. Must be called inside a go block."},
+	"<!":                  {"[ch]", "Takes a val from ch. Must be called inside a go block."},
+	">!!":                 {"[ch val]", "Puts val into ch, blocking if necessary."},
+	"<!!":                 {"[ch]", "Takes a val from ch, blocking if nothing is available."},
+	"ref":                 {"[x] [x & options]", "Creates and returns a Ref with an initial value of x."},
+	"ref-set":             {"[ref val]", "Must be called in a transaction. Sets the value of ref. Returns val."},
+	"alter":               {"[ref fun & args]", "Must be called in a transaction. Sets the in-transaction-value of ref to (apply fun in-transaction-value-of-ref args)."},
+	"commute":             {"[ref fun & args]", "Must be called in a transaction. Like alter, but fun is applied again at commit time."},
+	"ensure":              {"[ref]", "Must be called in a transaction. Protects the ref from modification by other transactions."},
+	"in-transaction?":     {"[]", "Returns true if called within a transaction."},
+	"set-validator!":      {"[iref validator-fn]", "Sets the validator-fn for a var/ref/agent/atom."},
+	"get-validator":       {"[iref]", "Gets the validator-fn for a var/ref/agent/atom."},
+	"tap>":                {"[x]", "Sends x to the tap set, to be handed to every tap on another goroutine. Returns false if the queue was full and x was dropped."},
+	"add-tap":             {"[f]", "Adds f, a fn of one argument, to the tap set. It will be called with anything sent with tap>."},
+	"remove-tap":          {"[f]", "Removes f from the tap set."},
+	"agent":               {"[state & options]", "Creates and returns an agent with an initial value of state."},
+	"send":                {"[a f & args]", "Dispatch an action to an agent. The state of the agent will be set to (apply f state-of-agent args)."},
+	"send-off":            {"[a f & args]", "Dispatch a potentially blocking action to an agent."},
+	"await":               {"[& agents]", "Blocks until all actions dispatched thus far to the agents have occurred."},
+	"await-for":           {"[timeout-ms & agents]", "Like await, but returns false if the timeout elapses first."},
+	"agent-error":         {"[a]", "Returns the exception thrown during an asynchronous action of the agent if the agent is failed."},
+	"restart-agent":       {"[a new-state & options]", "When an agent is failed, changes the agent state to new-state and then un-fails the agent."},
+	"make-hierarchy":      {"[]", "Creates a hierarchy object for use with derive, isa? etc."},
+	"derive":              {"[tag parent] [h tag parent]", "Establishes a parent/child relationship between parent and tag."},
+	"underive":            {"[tag parent] [h tag parent]", "Removes a parent/child relationship between parent and tag."},
+	"isa?":                {"[child parent] [h child parent]", "Returns true if (= child parent), or child is directly or indirectly derived from parent."},
+	"parents":             {"[tag] [h tag]", "Returns the immediate parents of tag."},
+	"ancestors":           {"[tag] [h tag]", "Returns the immediate and indirect parents of tag."},
+	"descendants":         {"[tag] [h tag]", "Returns the immediate and indirect children of tag."},
+	"remove-method":       {"[multifn dispatch-val]", "Removes the method of multimethod associated with dispatch-value."},
+	"remove-all-methods":  {"[multifn]", "Removes all of the methods of multimethod."},
+	"get-method":          {"[multifn dispatch-val]", "Given a multimethod and a dispatch value, returns the dispatch fn that would apply to that value, or nil if none apply and no default."},
+	"prefer-method":       {"[multifn dispatch-val-x dispatch-val-y]", "Causes the multimethod to prefer matches of dispatch-val-x over dispatch-val-y when there is a conflict."},
+	"ns-publics":          {"[ns]", "Returns a map of the public intern mappings for the namespace."},
+	"ns-interns":          {"[ns]", "Returns a map of the intern mappings for the namespace."},
+	"ns-refers":           {"[ns]", "Returns a map of the refer mappings for the namespace."},
+	"ns-map":              {"[ns]", "Returns a map of all the mappings for the namespace."},
+	"ns-aliases":          {"[ns]", "Returns a map of the aliases for the namespace."},
+	"ns-unmap":            {"[ns sym]", "Removes the mappings for the symbol from the namespace."},
+	"ns-unalias":          {"[ns sym]", "Removes the alias for the symbol from the namespace."},
+	"create-ns":           {"[sym]", "Create a new namespace named by the symbol if one doesn't already exist, returns it or the already-existing namespace of the same name."},
+	"remove-ns":           {"[sym]", "Removes the namespace named by the symbol. Use with caution."},
+	"intern":              {"[ns name] [ns name val]", "Finds or creates a var named by the symbol name in the namespace ns, setting its root binding to val if supplied."},
+	"resolve":             {"[sym] [env sym]", "Returns the var to which the symbol will be resolved in the current namespace, else nil."},
+	"ns-resolve":          {"[ns sym] [ns env sym]", "Returns the var to which the symbol will be resolved in the namespace, else nil."},
+	"alter-var-root":      {"[v f & args]", "Atomically alters the root binding of var v by applying f to its current value plus any args."},
+	"bound?":              {"[& vars]", "Returns true if all of the vars provided as arguments have any bound value, root or thread-local."},
+	"var-get":             {"[x]", "Gets the value in the var object."},
+	"with-redefs-fn":      {"[binding-map func]", "Temporarily redefines vars during a call to func. Each val of binding-map will replace the root value of its key, which must be a var. After func is called with no args, the root values of all the vars will be set back to their old values."},
+	"requiring-resolve":   {"[sym]", "Resolves namespace-qualified sym, requiring its namespace first if needed."},
+	"prefers":             {"[multifn]", "Given a multimethod, returns a map of preferred value -> set of other values."},
+}
+
+// parseArglists turns "[x] [x & more]" into the list ([x] [x & more]).
+func parseArglists(s string) vm.Value {
+	var lists []vm.Value
+	for _, part := range strings.Split(s, "]") {
+		i := strings.Index(part, "[")
+		if i < 0 {
+			continue
+		}
+		var args []vm.Value
+		for _, a := range strings.Fields(part[i+1:]) {
+			args = append(args, vm.Symbol(a))
+		}
+		lists = append(lists, vm.ArrayVector(args))
+	}
+	return vm.NewList(lists)
+}
+
+// installNativeDocs attaches nativeDocs to the vars of ns.
+func installNativeDocs(ns *vm.Namespace) {
+	for name, d := range nativeDocs {
+		v := ns.LookupLocal(vm.Symbol(name))
+		if v == nil {
+			continue
+		}
+		m := vm.EmptyPersistentMap.Assoc(vm.Keyword("arglists"), parseArglists(d.arglists))
+		m = m.Assoc(vm.Keyword("doc"), vm.String(d.doc))
+		v.ResetMeta(m)
+	}
+}
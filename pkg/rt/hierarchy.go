@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// globalHierarchy returns the var holding the hierarchy used by derive, isa?
+// and friends when no hierarchy is given, and by multimethods defined without
+// :hierarchy. Each runtime has its own.
+func globalHierarchy() *vm.Var {
+	return Current().CoreNS().LookupLocal("global-hierarchy")
+}
+
+// globalHierarchyMu serializes updates of global hierarchies.
+var globalHierarchyMu sync.Mutex
+
+func asMultiFn(name string, v vm.Value) (*vm.MultiFn, error) {
+	mf, ok := v.(*vm.MultiFn)
+	if !ok {
+		return nil, fmt.Errorf("%s expected MultiFn, got %s", name, v.Type().Name())
+	}
+	return mf, nil
+}
+
+// updateGlobalHierarchy replaces the global hierarchy with f applied to it.
+func updateGlobalHierarchy(f func(h vm.Value) (vm.Value, error)) (vm.Value, error) {
+	globalHierarchyMu.Lock()
+	defer globalHierarchyMu.Unlock()
+	v := globalHierarchy()
+	h, err := f(v.Root())
+	if err != nil {
+		return vm.NIL, err
+	}
+	v.SetRoot(h)
+	return vm.NIL, nil
+}
+
+// hierarchyQuery makes parents, ancestors and descendants.
+func hierarchyQuery(table vm.Keyword) vm.Value {
+	f, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		switch len(vs) {
+		case 1:
+			return vm.HierarchySet(globalHierarchy().Deref(), table, vs[0]), nil
+		case 2:
+			return vm.HierarchySet(vs[0], table, vs[1]), nil
+		}
+		return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+	})
+	return f
+}
+
+// nolint
+func installHierarchyBuiltins(ns *vm.Namespace) {
+	ns.Def("global-hierarchy", vm.NewHierarchy()).SetPrivate()
+
+	makeHierarchy, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 0 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		return vm.NewHierarchy(), nil
+	})
+
+	// derive — (derive tag parent) updates the global hierarchy,
+	// (derive h tag parent) returns a new hierarchy
+	derive, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		switch len(vs) {
+		case 2:
+			return updateGlobalHierarchy(func(h vm.Value) (vm.Value, error) {
+				return vm.Derive(h, vs[0], vs[1])
+			})
+		case 3:
+			return vm.Derive(vs[0], vs[1], vs[2])
+		}
+		return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+	})
+
+	// underive — (underive tag parent) or (underive h tag parent)
+	underive, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		switch len(vs) {
+		case 2:
+			return updateGlobalHierarchy(func(h vm.Value) (vm.Value, error) {
+				return vm.Underive(h, vs[0], vs[1])
+			})
+		case 3:
+			return vm.Underive(vs[0], vs[1], vs[2])
+		}
+		return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+	})
+
+	// isa? — (isa? child parent) or (isa? h child parent)
+	isa, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		switch len(vs) {
+		case 2:
+			return vm.Boolean(vm.Isa(globalHierarchy().Deref(), vs[0], vs[1])), nil
+		case 3:
+			return vm.Boolean(vm.Isa(vs[0], vs[1], vs[2])), nil
+		}
+		return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+	})
+
+	// remove-method — (remove-method multifn dispatch-val)
+	removeMethod, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		mf, err := asMultiFn("remove-method", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return mf.RemoveMethod(vs[1]), nil
+	})
+
+	removeAllMethods, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		mf, err := asMultiFn("remove-all-methods", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return mf.RemoveAllMethods(), nil
+	})
+
+	// get-method — (get-method multifn dispatch-val), nil when nothing matches
+	getMethod, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		mf, err := asMultiFn("get-method", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return mf.GetMethod(vs[1])
+	})
+
+	// prefer-method — (prefer-method multifn x y) prefers x over y
+	preferMethod, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 3 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		mf, err := asMultiFn("prefer-method", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if err := mf.PreferMethod(vs[1], vs[2]); err != nil {
+			return vm.NIL, err
+		}
+		return mf, nil
+	})
+
+	prefers, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		mf, err := asMultiFn("prefers", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return mf.Prefers(), nil
+	})
+
+	ns.Def("make-hierarchy", makeHierarchy)
+	ns.Def("derive", derive)
+	ns.Def("underive", underive)
+	ns.Def("isa?", isa)
+	ns.Def("parents", hierarchyQuery("parents"))
+	ns.Def("ancestors", hierarchyQuery("ancestors"))
+	ns.Def("descendants", hierarchyQuery("descendants"))
+	ns.Def("remove-method", removeMethod)
+	ns.Def("remove-all-methods", removeAllMethods)
+	ns.Def("get-method", getMethod)
+	ns.Def("prefer-method", preferMethod)
+	ns.Def("prefers", prefers)
+}
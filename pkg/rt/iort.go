@@ -8,36 +8,114 @@ package rt
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/nooga/let-go/pkg/vm"
 )
 
 // IOHandle wraps an *os.File with optional buffered reader for line-based reads.
+// Handles made with NewStreamHandle read and write arbitrary streams instead.
 type IOHandle struct {
 	File   *os.File
 	reader *bufio.Reader
+
+	name string
+	in   io.Reader
+	out  io.Writer
 }
 
 func NewIOHandle(f *os.File) *IOHandle {
 	return &IOHandle{File: f}
 }
 
+// NewStreamHandle returns a handle reading from in and writing to out, either
+// of which may be nil. It's meant for binding *in*, *out* and *err* to
+// something other than a file. When in is a *bufio.Reader, line-based reads
+// go through it, so that it can be shared with another reader.
+func NewStreamHandle(name string, in io.Reader, out io.Writer) *IOHandle {
+	h := &IOHandle{name: name, in: in, out: out}
+	if br, ok := in.(*bufio.Reader); ok {
+		h.reader = br
+	}
+	return h
+}
+
 func (h *IOHandle) String() string {
+	if h.File == nil {
+		return fmt.Sprintf("#<IOHandle %s>", h.name)
+	}
 	return fmt.Sprintf("#<IOHandle %s>", h.File.Name())
 }
 
 func (h *IOHandle) Reader() *bufio.Reader {
 	if h.reader == nil {
-		h.reader = bufio.NewReader(h.File)
+		h.reader = bufio.NewReader(h)
 	}
 	return h.reader
 }
 
+func (h *IOHandle) Read(p []byte) (int, error) {
+	if h.File != nil {
+		return h.File.Read(p)
+	}
+	if h.in == nil {
+		return 0, fmt.Errorf("%s is not readable", h)
+	}
+	return h.in.Read(p)
+}
+
+func (h *IOHandle) Write(p []byte) (int, error) {
+	if h.File != nil {
+		return h.File.Write(p)
+	}
+	if h.out == nil {
+		return 0, fmt.Errorf("%s is not writable", h)
+	}
+	return h.out.Write(p)
+}
+
+// Flush commits what was written to the handle.
+func (h *IOHandle) Flush() error {
+	if h.File != nil {
+		return h.File.Sync()
+	}
+	if f, ok := h.out.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
 func (h *IOHandle) Close() error {
-	return h.File.Close()
+	if h.File != nil {
+		return h.File.Close()
+	}
+	if c, ok := h.out.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// stdHandle returns the handle v is bound to in the calling goroutine,
+// falling back to f if it isn't bound to one.
+func stdHandle(v *vm.Var, f *os.File) *IOHandle {
+	if v != nil {
+		if h, err := getIOHandle(v.Deref()); err == nil {
+			return h
+		}
+	}
+	return NewIOHandle(f)
 }
 
+// Stdin returns *in* of the calling goroutine.
+func Stdin() *IOHandle { return stdHandle(Current().stdin, os.Stdin) }
+
+// Stdout returns *out* of the calling goroutine, where printing goes.
+func Stdout() io.Writer { return stdHandle(Current().stdout, os.Stdout) }
+
+// Stderr returns *err* of the calling goroutine.
+func Stderr() io.Writer { return stdHandle(Current().stderr, os.Stderr) }
+
 // getIOHandle extracts an *IOHandle from a Boxed value or wraps a raw *os.File.
 func getIOHandle(v vm.Value) (*IOHandle, error) {
 	b, ok := v.(*vm.Boxed)
@@ -111,14 +189,18 @@ func installIOBuiltins(ns *vm.Namespace) {
 		return vm.NIL, h.Close()
 	})
 
-	// read-line — (read-line handle) → String or nil at EOF
+	// read-line — (read-line) or (read-line handle) → String or nil at EOF,
+	// reading *in* by default
 	readLine, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
-		if len(vs) != 1 {
-			return vm.NIL, fmt.Errorf("read-line expects 1 arg")
+		if len(vs) > 1 {
+			return vm.NIL, fmt.Errorf("read-line expects 0-1 args")
 		}
-		h, err := getIOHandle(vs[0])
-		if err != nil {
-			return vm.NIL, err
+		h := Stdin()
+		if len(vs) == 1 {
+			var err error
+			if h, err = getIOHandle(vs[0]); err != nil {
+				return vm.NIL, err
+			}
 		}
 		line, err := h.Reader().ReadString('\n')
 		if err != nil {
@@ -153,7 +235,7 @@ func installIOBuiltins(ns *vm.Namespace) {
 		} else {
 			s = vs[1].String()
 		}
-		_, err = h.File.WriteString(s)
+		_, err = io.WriteString(h, s)
 		return vm.NIL, err
 	})
 
@@ -166,7 +248,7 @@ func installIOBuiltins(ns *vm.Namespace) {
 		if err != nil {
 			return vm.NIL, err
 		}
-		return vm.NIL, h.File.Sync()
+		return vm.NIL, h.Flush()
 	})
 
 	// read-bytes — (read-bytes handle n) → String or nil at EOF
@@ -183,7 +265,7 @@ func installIOBuiltins(ns *vm.Namespace) {
 			return vm.NIL, fmt.Errorf("read-bytes expected Int count")
 		}
 		buf := make([]byte, int(n))
-		nread, err := h.File.Read(buf)
+		nread, err := h.Read(buf)
 		if nread == 0 {
 			return vm.NIL, nil // EOF
 		}
@@ -247,7 +329,10 @@ func installIOBuiltins(ns *vm.Namespace) {
 	ns.Def("file-exists?", fileExists)
 	ns.Def("delete-file", deleteFile)
 	ns.Def("mkdir", mkdirf)
-	ns.Def("*in*", stdinHandle)
-	ns.Def("*out*", stdoutHandle)
-	ns.Def("*err*", stderrHandle)
+	defaultRuntime.stdin = ns.Def("*in*", stdinHandle)
+	defaultRuntime.stdout = ns.Def("*out*", stdoutHandle)
+	defaultRuntime.stderr = ns.Def("*err*", stderrHandle)
+	defaultRuntime.stdin.SetDynamic()
+	defaultRuntime.stdout.SetDynamic()
+	defaultRuntime.stderr.SetDynamic()
 }
@@ -105,13 +105,21 @@ func fromValue(v vm.Value) (interface{}, error) {
 		return float64(v.(vm.Float)), nil
 	case vm.BooleanType:
 		return bool(v.(vm.Boolean)), nil
-	case vm.MapType, vm.PersistentMapType:
+	case vm.MapType, vm.PersistentMapType, vm.SortedMapType:
 		return fromMapValue(v)
 	case vm.KeywordType:
 		kw := string(v.(vm.Keyword))
 		return kw, nil
 	case vm.NilType:
 		return nil, nil
+	case vm.BigDecimalType:
+		return json.Number(v.(*vm.BigDecimal).Text()), nil
+	case vm.RatioType:
+		return v.(*vm.Ratio).Float64(), nil
+	case vm.InstType:
+		return v.(vm.Inst).Format(), nil
+	case vm.UUIDType:
+		return v.(vm.UUID).Format(), nil
 	case vm.ArrayVectorType, vm.PersistentVectorType:
 		if sq, ok := v.(vm.Sequable); ok {
 			return fromSeqValue(sq.Seq())
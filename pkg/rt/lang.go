@@ -8,35 +8,34 @@ package rt
 import (
 	_ "embed"
 	"fmt"
+	"io"
+	"maps"
 	"math"
+	"math/big"
 	"math/rand"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/nooga/let-go/pkg/vm"
 )
 
-var nsRegistry map[string]*vm.Namespace
-
 type NSLoader interface {
 	Load(string) *vm.Namespace
 }
 
-var nsLoader NSLoader
-
+// SetNSLoader sets the namespace loader of the current runtime.
 func SetNSLoader(loader NSLoader) {
-	nsLoader = loader
+	Current().SetNSLoader(loader)
 }
 
 func init() {
-	nsRegistry = make(map[string]*vm.Namespace)
-
 	// Register global namespace lookup so qualified symbols (foo/x) work
 	vm.SetNSLookup(func(name string) *vm.Namespace {
-		return nsRegistry[name]
+		return Current().LookupNS(name)
 	})
 
 	// Wire up ValueEquals for OP_EQ fast path in the VM
@@ -44,29 +43,41 @@ func init() {
 		return valueEquals(a, b)
 	})
 
+	// Let BigDecimal arithmetic see the precision set by with-precision
+	vm.SetMathContextLookup(mathContext)
+
 	initTypeMappings()
 	installLangNS()
 	installHttpNS()
 	installOsNS()
 	installJSONNS()
 	installIoNS()
+	installReplNS()
 	installAsyncNS()
 	installTransitNS()
 	installPodsNS()
 	installMathNS()
 	installTermNS()
+	installProfileNS()
 	// walk namespace is embedded via WalkSrc and will be loaded on demand
+
+	pristine = vm.CloneNamespaces(defaultRuntime.registry)
+	pristineNeedsLoad = maps.Clone(defaultRuntime.needsLoad)
 }
 
+// AllNSes returns the namespaces of the current runtime.
 func AllNSes() map[string]*vm.Namespace {
-	return nsRegistry
+	r := Current()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return maps.Clone(r.registry)
 }
 
 func FuzzyNamespacedSymbolLookup(currentNS *vm.Namespace, s vm.Symbol) []vm.Symbol {
 	sns := s.Namespace()
 	var ns *vm.Namespace
 	if sns != vm.NIL {
-		ns = nsRegistry[string(sns.(vm.String))]
+		ns = Current().LookupNS(string(sns.(vm.String)))
 	} else {
 		ns = currentNS
 	}
@@ -75,76 +86,118 @@ func FuzzyNamespacedSymbolLookup(currentNS *vm.Namespace, s vm.Symbol) []vm.Symb
 }
 
 func NS(name string) *vm.Namespace {
-	return LookupOrRegisterNS(name)
+	return Current().NS(name)
 }
 
 func RegisterNS(namespace *vm.Namespace) *vm.Namespace {
-	nsRegistry[namespace.Name()] = namespace
-	return namespace
+	return Current().RegisterNS(namespace)
 }
 
-// nsNeedsLoad tracks namespaces that were pre-registered during bytecode
-// decoding but still need their precompiled chunks executed.
-var nsNeedsLoad = map[string]bool{}
-
 // MarkNSNeedsLoad flags a namespace as needing on-demand loading even though
 // it already exists in the registry (created during bytecode decoding).
 func MarkNSNeedsLoad(name string) {
-	nsNeedsLoad[name] = true
+	r := Current()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.needsLoad[name] = true
 }
 
 // LookupNS returns a namespace if it exists, nil otherwise. Does not create.
 func LookupNS(name string) *vm.Namespace {
-	return nsRegistry[name]
+	return Current().LookupNS(name)
 }
 
 // DefNSBare creates and registers a minimal namespace (with CoreNS refer)
 // without triggering the loader. Used during bytecode decoding to create
 // var homes for not-yet-loaded namespaces.
 func DefNSBare(name string) *vm.Namespace {
-	if e := nsRegistry[name]; e != nil {
+	return Current().DefNSBare(name)
+}
+
+func LookupOrRegisterNS(name string) *vm.Namespace {
+	return Current().LookupOrRegisterNS(name)
+}
+
+func LookupOrRegisterNSNoLoad(name string) *vm.Namespace {
+	return Current().LookupOrRegisterNSNoLoad(name)
+}
+
+func (r *Runtime) NS(name string) *vm.Namespace {
+	return r.LookupOrRegisterNS(name)
+}
+
+func (r *Runtime) RegisterNS(namespace *vm.Namespace) *vm.Namespace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registry[namespace.Name()] = namespace
+	return namespace
+}
+
+// LookupNS returns a namespace of r if it exists, nil otherwise. Does not create.
+func (r *Runtime) LookupNS(name string) *vm.Namespace {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.registry[name]
+}
+
+// RemoveNS removes the namespace from r and returns it, or nil if there was
+// no such namespace.
+func (r *Runtime) RemoveNS(name string) *vm.Namespace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ns := r.registry[name]
+	delete(r.registry, name)
+	delete(r.needsLoad, name)
+	return ns
+}
+
+func (r *Runtime) DefNSBare(name string) *vm.Namespace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e := r.registry[name]; e != nil {
 		return e
 	}
 	ns := vm.NewNamespace(name)
-	if CoreNS != nil {
-		ns.Refer(CoreNS, "", true)
+	if r.core != nil {
+		ns.Refer(r.core, "", true)
 	}
-	nsRegistry[name] = ns
+	r.registry[name] = ns
 	return ns
 }
 
-func LookupOrRegisterNS(name string) *vm.Namespace {
-	e := nsRegistry[name]
-	if e != nil && !nsNeedsLoad[name] {
+// LookupOrRegisterNS returns the namespace of r named name, loading or
+// creating it if needed. The lock isn't held while the loader runs, loading
+// a namespace looks up others.
+func (r *Runtime) LookupOrRegisterNS(name string) *vm.Namespace {
+	r.mu.Lock()
+	e := r.registry[name]
+	load := r.needsLoad[name]
+	// Clear the flag before loading to prevent re-entrancy loops
+	delete(r.needsLoad, name)
+	r.mu.Unlock()
+	if e != nil && !load {
 		return e
 	}
-	if nsLoader != nil {
-		// Clear the flag before loading to prevent re-entrancy loops
-		delete(nsNeedsLoad, name)
-		n := nsLoader.Load(name)
+	if r.loader != nil {
+		n := r.loader.Load(name)
 		if n != nil {
-			nsRegistry[name] = n
-			return n
+			return r.RegisterNS(n)
 		}
 	}
 	// Check if loading side-effected the registry (in-ns during load creates the ns)
-	if e := nsRegistry[name]; e != nil {
-		delete(nsNeedsLoad, name)
-		return e
-	}
-	nsRegistry[name] = vm.NewNamespace(name)
-	nsRegistry[name].Refer(CoreNS, "", true)
-	return nsRegistry[name]
+	return r.LookupOrRegisterNSNoLoad(name)
 }
 
-func LookupOrRegisterNSNoLoad(name string) *vm.Namespace {
-	e := nsRegistry[name]
-	if e != nil {
+func (r *Runtime) LookupOrRegisterNSNoLoad(name string) *vm.Namespace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e := r.registry[name]; e != nil {
 		return e
 	}
-	nsRegistry[name] = vm.NewNamespace(name)
-	nsRegistry[name].Refer(CoreNS, "", true)
-	return nsRegistry[name]
+	ns := vm.NewNamespace(name)
+	ns.Refer(r.core, "", true)
+	r.registry[name] = ns
+	return ns
 }
 
 //go:embed core/core.lg
@@ -152,14 +205,11 @@ var CoreSrc string
 
 const NameCoreNS = "core"
 
-var CoreNS *vm.Namespace
-var CurrentNS *vm.Var
-
-var gensymID = 0
+// gensymID is shared by all runtimes so generated symbols never collide.
+var gensymID atomic.Int64
 
 func nextID() int {
-	gensymID++
-	return gensymID
+	return int(gensymID.Add(1))
 }
 
 // valueEquals performs deep equality comparison for Clojure semantics
@@ -234,10 +284,7 @@ func valueEquals(a, b vm.Value) bool {
 		}
 		return true
 	case *vm.PersistentMap:
-		if bm, ok := b.(*vm.PersistentMap); ok {
-			return av.Equals(bm)
-		}
-		return false
+		return av.Equals(b)
 	case vm.Set:
 		bs := b.(vm.Set)
 		if len(av) != len(bs) {
@@ -250,6 +297,9 @@ func valueEquals(a, b vm.Value) bool {
 		}
 		return true
 	case *vm.PersistentSet:
+		if ss, ok := b.(*vm.PersistentSortedSet); ok {
+			return ss.Equals(av)
+		}
 		bs, ok := b.(*vm.PersistentSet)
 		if !ok {
 			return false
@@ -280,6 +330,28 @@ func valueEquals(a, b vm.Value) bool {
 	}
 }
 
+// mathContext returns the math context bound to *math-context*, a map with
+// :precision and :rounding keys, or nil when there is none.
+func mathContext() *vm.MathContext {
+	v := CoreNS().LookupLocal("*math-context*")
+	if v == nil {
+		return nil
+	}
+	m, ok := v.Deref().(vm.Lookup)
+	if !ok {
+		return nil
+	}
+	prec, ok := m.ValueAt(vm.Keyword("precision")).(vm.Int)
+	if !ok {
+		return nil
+	}
+	mc := &vm.MathContext{Precision: int(prec)}
+	if r, ok := m.ValueAt(vm.Keyword("rounding")).(vm.Symbol); ok {
+		mc.Rounding, _ = vm.ParseRoundingMode(string(r))
+	}
+	return mc
+}
+
 func seqOf(v vm.Value) (vm.Seq, error) {
 	if v == vm.NIL {
 		return nil, nil
@@ -306,6 +378,27 @@ func seqOf(v vm.Value) (vm.Seq, error) {
 	return nil, fmt.Errorf("don't know how to create ISeq from %s", v.Type())
 }
 
+// varBindings converts a map of vars to values into the form expected by vm.PushThreadBindings.
+func varBindings(m vm.Value) (map[*vm.Var]vm.Value, error) {
+	s, err := seqOf(m)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[*vm.Var]vm.Value{}
+	for ; s != nil; s = s.Next() {
+		e, ok := s.First().(vm.ArrayVector)
+		if !ok || len(e) != 2 {
+			return nil, fmt.Errorf("expected a map of Var to value")
+		}
+		v, ok := e[0].(*vm.Var)
+		if !ok {
+			return nil, fmt.Errorf("can't bind %s, expected Var", e[0])
+		}
+		ret[v] = e[1]
+	}
+	return ret, nil
+}
+
 func mapLazy1(f vm.Fn, s vm.Seq) vm.Seq {
 	if s == nil {
 		return nil
@@ -414,6 +507,9 @@ func installLangNS() {
 		if len(vs) < 1 {
 			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
+		if len(vs) == 1 {
+			return vm.NumDiv(vm.Int(1), vs[0])
+		}
 		acc := vs[0]
 		for i := 1; i < len(vs); i++ {
 			var err error
@@ -439,6 +535,23 @@ func installLangNS() {
 		return vm.TRUE, nil
 	})
 
+	numEquiv, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		for _, v := range vs {
+			if !vm.IsNumber(v) {
+				return vm.NIL, fmt.Errorf("== expected Number, got %s", v.Type().Name())
+			}
+		}
+		for i := 1; i < len(vs); i++ {
+			if !vm.NumEquiv(vs[0], vs[i]) {
+				return vm.FALSE, nil
+			}
+		}
+		return vm.TRUE, nil
+	})
+
 	gt, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
 		if len(vs) < 2 {
 			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
@@ -751,6 +864,12 @@ func installLangNS() {
 				result = result.Disj(v)
 			}
 			return result, nil
+		case *vm.PersistentSortedSet:
+			result := s
+			for _, v := range vs[1:] {
+				result = result.Disj(v)
+			}
+			return result, nil
 		case vm.Set:
 			for _, v := range vs[1:] {
 				s = s.Disj(v)
@@ -1138,6 +1257,9 @@ func installLangNS() {
 			seq = seq.Next()
 		}
 		for seq != nil {
+			if err := vm.Checkpoint(); err != nil {
+				return vm.NIL, err
+			}
 			acc, err = mfn.Invoke([]vm.Value{acc, seq.First()})
 			if err != nil {
 				return vm.NIL, err
@@ -1190,10 +1312,19 @@ func installLangNS() {
 				b.WriteRune(rune(vs[i].(vm.Char)))
 				continue
 			}
+			switch v := vs[i].(type) {
+			case vm.UUID:
+				b.WriteString(v.Format())
+				continue
+			case vm.Inst:
+				b.WriteString(v.Format())
+				continue
+			}
 			b.WriteString(vs[i].String())
 		}
-		fmt.Println(b)
-		return vm.NIL, nil
+		b.WriteByte('\n')
+		_, err := io.WriteString(Stdout(), b.String())
+		return vm.NIL, err
 	})
 
 	str, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
@@ -1209,6 +1340,17 @@ func installLangNS() {
 				b.WriteRune(rune(vs[i].(vm.Char)))
 				continue
 			}
+			switch v := vs[i].(type) {
+			case vm.UUID:
+				b.WriteString(v.Format())
+				continue
+			case vm.Inst:
+				b.WriteString(v.Format())
+				continue
+			case *vm.BigDecimal:
+				b.WriteString(v.Text())
+				continue
+			}
 			b.WriteString(vs[i].String())
 		}
 		return vm.String(b.String()), nil
@@ -1263,7 +1405,9 @@ func installLangNS() {
 			return vm.NIL, fmt.Errorf("in-ns expected Symbol")
 		}
 		nns := LookupOrRegisterNSNoLoad(string(sym.(vm.Symbol)))
-		CurrentNS.SetRoot(nns)
+		if err := CurrentNSVar().Set(nns); err != nil {
+			return vm.NIL, err
+		}
 		return nns, nil
 	})
 
@@ -1271,7 +1415,7 @@ func installLangNS() {
 		if len(vs) < 1 {
 			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
-		cns := CurrentNS.Deref().(*vm.Namespace)
+		cns := CurrentNSVar().Deref().(*vm.Namespace)
 		for i := range vs {
 			s, ok := vs[i].(vm.Symbol)
 			if !ok {
@@ -1294,7 +1438,7 @@ func installLangNS() {
 		if !ok {
 			return vm.NIL, fmt.Errorf("alias expected Symbol")
 		}
-		cns := CurrentNS.Deref().(*vm.Namespace)
+		cns := CurrentNSVar().Deref().(*vm.Namespace)
 		target := NS(string(nsSym))
 		cns.Alias(al, target)
 		return vm.NIL, nil
@@ -1318,7 +1462,7 @@ func installLangNS() {
 				syms = append(syms, s)
 			}
 		}
-		cns := CurrentNS.Deref().(*vm.Namespace)
+		cns := CurrentNSVar().Deref().(*vm.Namespace)
 		target := NS(string(nsSym))
 		// Convert []vm.Symbol to []vm.Symbol type alias in vm
 		vmSyms := make([]vm.Symbol, len(syms))
@@ -1356,6 +1500,12 @@ func installLangNS() {
 		if !ok {
 			return vm.NIL, fmt.Errorf("deref expected Reference")
 		}
+		if p, ok := ref.(*vm.Promise); ok {
+			if v, ok := p.DerefUntil(vm.Done()); ok {
+				return v, nil
+			}
+			return vm.NIL, vm.Stopped()
+		}
 		return ref.Deref(), nil
 	})
 
@@ -1445,11 +1595,26 @@ func installLangNS() {
 		return named.Namespace(), nil
 	})
 
+	// (atom x & {:keys [meta validator]})
 	atom, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
-		if len(vs) != 1 {
+		if len(vs) < 1 {
 			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
-		return vm.NewAtom(vs[0]), nil
+		a := vm.NewAtom(vs[0])
+		if len(vs) == 1 {
+			return a, nil
+		}
+		opts, err := refOptions("atom", vs[1:])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if m, ok := opts["meta"]; ok {
+			a = a.WithMeta(m).(*vm.Atom)
+		}
+		if err := applyValidator(a, opts); err != nil {
+			return vm.NIL, err
+		}
+		return a, nil
 	})
 
 	// (swap! a fn)
@@ -1477,7 +1642,7 @@ func installLangNS() {
 		if !ok {
 			return vm.NIL, fmt.Errorf("reset expected Atom")
 		}
-		return at.Reset(vs[1]), nil
+		return at.Reset(vs[1])
 	})
 
 	// swap-vals!: like swap! but returns [old new]
@@ -1511,7 +1676,9 @@ func installLangNS() {
 			return vm.NIL, fmt.Errorf("reset-vals! expected Atom")
 		}
 		old := at.Deref()
-		at.Reset(vs[1])
+		if _, err := at.Reset(vs[1]); err != nil {
+			return vm.NIL, err
+		}
 		return vm.ArrayVector{old, vs[1]}, nil
 	})
 
@@ -1523,11 +1690,13 @@ func installLangNS() {
 		if !ok {
 			return vm.NIL, fmt.Errorf("go expected Fn")
 		}
+		at = vm.BindingConveyor(at)
+		stderr := Stderr()
 		ret := make(vm.Chan)
 		go func() {
 			v, err := at.Invoke(nil)
 			if err != nil {
-				fmt.Println(err)
+				fmt.Fprintln(stderr, err)
 			}
 			ret <- v
 			close(ret)
@@ -1553,8 +1722,12 @@ func installLangNS() {
 		if vs[1] == vm.NIL {
 			return vm.NIL, fmt.Errorf(">! can't put nil on chan")
 		}
-		ch <- vs[1]
-		return vm.TRUE, nil
+		select {
+		case ch <- vs[1]:
+			return vm.TRUE, nil
+		case <-vm.Done():
+			return vm.NIL, vm.Stopped()
+		}
 	})
 
 	changet, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
@@ -1565,11 +1738,15 @@ func installLangNS() {
 		if !ok {
 			return vm.NIL, fmt.Errorf("<! expected Chan")
 		}
-		v, ok := <-ch
-		if !ok {
-			return vm.NIL, nil // this is not an error
+		select {
+		case v, ok := <-ch:
+			if !ok {
+				return vm.NIL, nil // this is not an error
+			}
+			return v, nil
+		case <-vm.Done():
+			return vm.NIL, vm.Stopped()
 		}
-		return v, nil
 	})
 
 	lines, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
@@ -1651,7 +1828,6 @@ func installLangNS() {
 				return vm.NIL, fmt.Errorf("sort expected a Collection")
 			}
 		} else {
-			comp = lt.(vm.Fn)
 			coll, ok = vs[0].(vm.Collection)
 			if !ok {
 				return vm.NIL, fmt.Errorf("sort expected a Collection")
@@ -1668,11 +1844,20 @@ func installLangNS() {
 			if err != nil {
 				return false // abort: previous comparison failed
 			}
+			if comp == nil {
+				var c int
+				c, err = vm.Compare(temp[i], temp[j])
+				return c < 0
+			}
 			var b vm.Value
 			b, err = comp.Invoke([]vm.Value{temp[i], temp[j]})
 			if err != nil {
 				return false
 			}
+			// comparators like compare return a number, predicates a boolean
+			if n, ok := b.(vm.Int); ok {
+				return n < 0
+			}
 			return vm.IsTruthy(b)
 		})
 		if err != nil {
@@ -1742,6 +1927,9 @@ func installLangNS() {
 		if i, ok := vs[0].(vm.Char); ok {
 			return vm.Int(int(i)), nil
 		}
+		if i, ok := vm.ToInt(vs[0]); ok {
+			return vm.MakeInt(i), nil
+		}
 		return vm.NIL, fmt.Errorf("%s can't be coerced to int", vs[0])
 	})
 
@@ -1890,7 +2078,7 @@ func installLangNS() {
 		if len(vs) < 2 || len(vs) > 3 {
 			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
-		cns := CurrentNS.Deref().(*vm.Namespace)
+		cns := CurrentNSVar().Deref().(*vm.Namespace)
 		s, ok := vs[0].(vm.Symbol)
 		if !ok {
 			return vm.NIL, fmt.Errorf("refer expected Symbol")
@@ -2085,6 +2273,18 @@ func installLangNS() {
 				}
 			case vm.Float:
 				args[vi] = float64(v)
+			case *vm.Ratio:
+				if verb == 'f' || verb == 'e' || verb == 'g' || verb == 'E' || verb == 'G' {
+					args[vi] = v.Float64()
+				} else {
+					args[vi] = v.String()
+				}
+			case *vm.BigDecimal:
+				if verb == 'f' || verb == 'e' || verb == 'g' || verb == 'E' || verb == 'G' {
+					args[vi] = new(big.Float).SetPrec(256).SetRat(v.Rat())
+				} else {
+					args[vi] = v.Text()
+				}
 			case vm.String:
 				args[vi] = string(v)
 			case vm.Boolean:
@@ -2332,6 +2532,91 @@ func installLangNS() {
 		return vm.Boolean(ok), nil
 	})
 
+	// make-deftype: create a DefType from a name and field keywords (called by deftype macro)
+	makeDefType, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		name, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("make-deftype expected String name")
+		}
+		fields := make([]vm.Keyword, len(vs)-1)
+		for i := 1; i < len(vs); i++ {
+			kw, ok := vs[i].(vm.Keyword)
+			if !ok {
+				return vm.NIL, fmt.Errorf("make-deftype expected Keyword fields")
+			}
+			fields[i-1] = kw
+		}
+		return vm.NewDefType(string(name), fields), nil
+	})
+
+	// new: create an instance of a deftype or record from positional field values
+	newf, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		switch t := vs[0].(type) {
+		case *vm.DefType:
+			return t.New(vs[1:])
+		case *vm.RecordType:
+			fields := t.Fields()
+			if len(vs)-1 != len(fields) {
+				return vm.NIL, fmt.Errorf("%s expects %d fields, got %d", t.Name(), len(fields), len(vs)-1)
+			}
+			kvs := make([]vm.Value, 0, 2*len(fields))
+			for i, f := range fields {
+				kvs = append(kvs, f, vs[i+1])
+			}
+			return vm.NewRecord(t, vm.NewPersistentMap(kvs)), nil
+		}
+		return vm.NIL, fmt.Errorf("new expected a deftype or record type, got %s", vs[0].Type().Name())
+	})
+
+	// deftype-set!: set a mutable field of a deftype instance (set! in method bodies)
+	deftypeSet, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 3 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		o, ok := vs[0].(*vm.Instance)
+		if !ok {
+			return vm.NIL, fmt.Errorf("deftype-set! expected a deftype instance")
+		}
+		field, ok := vs[1].(vm.Keyword)
+		if !ok {
+			return vm.NIL, fmt.Errorf("deftype-set! expected Keyword field")
+		}
+		if err := o.SetField(field, vs[2]); err != nil {
+			return vm.NIL, err
+		}
+		return vs[2], nil
+	})
+
+	// reify*: create an anonymous protocol implementation (called by reify macro)
+	reify, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 || len(vs)%2 != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		name, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("reify* expected String name")
+		}
+		impls := make(map[*vm.Protocol]*vm.PersistentMap, len(vs)/2)
+		for i := 1; i < len(vs); i += 2 {
+			protocol, ok := vs[i].(*vm.Protocol)
+			if !ok {
+				return vm.NIL, fmt.Errorf("reify* expected Protocol, got %s", vs[i].Type().Name())
+			}
+			implMap, ok := vs[i+1].(*vm.PersistentMap)
+			if !ok {
+				return vm.NIL, fmt.Errorf("reify* expected map of implementations")
+			}
+			impls[protocol] = implMap
+		}
+		return vm.NewDefType(string(name), nil).Reify(impls), nil
+	})
+
 	// defprotocol*: create a protocol (called by defprotocol macro)
 	defProtocol, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
 		if len(vs) < 1 {
@@ -2407,8 +2692,9 @@ func installLangNS() {
 	})
 
 	// defmulti*: create a multimethod (called by defmulti macro)
+	// (defmulti* name dispatch-fn default-val hierarchy-ref), nil hierarchy means the global one
 	defMulti, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
-		if len(vs) < 2 || len(vs) > 3 {
+		if len(vs) < 2 || len(vs) > 4 {
 			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
 		name, ok := vs[0].(vm.String)
@@ -2420,10 +2706,17 @@ func installLangNS() {
 			return vm.NIL, fmt.Errorf("defmulti* expected Fn")
 		}
 		var defaultVal vm.Value = vm.Keyword("default")
-		if len(vs) == 3 {
+		if len(vs) >= 3 {
 			defaultVal = vs[2]
 		}
-		return vm.NewMultiFn(string(name), dispatchFn, defaultVal), nil
+		var hierarchy vm.Reference = globalHierarchy()
+		if len(vs) == 4 && vs[3] != vm.NIL {
+			hierarchy, ok = vs[3].(vm.Reference)
+			if !ok {
+				return vm.NIL, fmt.Errorf("defmulti* expected a hierarchy reference, got %s", vs[3].Type().Name())
+			}
+		}
+		return vm.NewMultiFn(string(name), dispatchFn, defaultVal, hierarchy), nil
 	})
 
 	// defmethod*: add a method to a multimethod (called by defmethod macro)
@@ -2476,8 +2769,9 @@ func installLangNS() {
 			}
 			b.WriteString(vs[i].String())
 		}
-		fmt.Println(b)
-		return vm.NIL, nil
+		b.WriteByte('\n')
+		_, err := io.WriteString(Stdout(), b.String())
+		return vm.NIL, err
 	})
 
 	// re-find: find first match of regex in string
@@ -2561,7 +2855,7 @@ func installLangNS() {
 	// require loads a namespace by name (like Clojure's require function for REPL use)
 	// Supports: (require 'foo), (require '[foo :as f]), (require '[foo :refer [a b]])
 	requiref, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
-		cns := CurrentNS.Deref().(*vm.Namespace)
+		cns := CurrentNSVar().Deref().(*vm.Namespace)
 		for _, v := range vs {
 			switch arg := v.(type) {
 			case vm.Symbol:
@@ -2613,7 +2907,7 @@ func installLangNS() {
 		if !ok {
 			return vm.NIL, fmt.Errorf("find-ns expected Symbol")
 		}
-		ns := nsRegistry[string(s)]
+		ns := LookupNS(string(s))
 		if ns == nil {
 			return vm.NIL, nil
 		}
@@ -2623,7 +2917,7 @@ func installLangNS() {
 	// all-ns returns a list of all loaded namespaces
 	allNs, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
 		var nss []vm.Value
-		for _, ns := range nsRegistry {
+		for _, ns := range AllNSes() {
 			nss = append(nss, ns)
 		}
 		return vm.NewList(nss), nil
@@ -2642,7 +2936,7 @@ func installLangNS() {
 			}
 			return vm.NIL, fmt.Errorf("the-ns expected Symbol or Namespace")
 		}
-		ns := nsRegistry[string(s)]
+		ns := LookupNS(string(s))
 		if ns == nil {
 			return vm.NIL, fmt.Errorf("no namespace: %s found", s)
 		}
@@ -2697,6 +2991,95 @@ func installLangNS() {
 		return vm.NIL, nil
 	})
 
+	// push-thread-bindings — (push-thread-bindings {#'*x* 1 #'*y* 2})
+	pushThreadBindings, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("push-thread-bindings expects 1 arg")
+		}
+		bs, err := varBindings(vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		vm.PushThreadBindings(bs)
+		return vm.NIL, nil
+	})
+
+	popThreadBindings, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 0 {
+			return vm.NIL, fmt.Errorf("pop-thread-bindings expects 0 args")
+		}
+		return vm.NIL, vm.PopThreadBindings()
+	})
+
+	getThreadBindings, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 0 {
+			return vm.NIL, fmt.Errorf("get-thread-bindings expects 0 args")
+		}
+		var ret vm.Associative = vm.EmptyPersistentMap
+		for v, val := range vm.GetThreadBindings() {
+			ret = ret.Assoc(v, val)
+		}
+		return ret, nil
+	})
+
+	// with-bindings* — (with-bindings* {#'*x* 1} f & args)
+	withBindingsStar, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 2 {
+			return vm.NIL, fmt.Errorf("with-bindings* expects at least 2 args")
+		}
+		bs, err := varBindings(vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		fn, ok := vs[1].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("with-bindings* expected Fn")
+		}
+		vm.PushThreadBindings(bs)
+		defer vm.PopThreadBindings()
+		return fn.Invoke(vs[2:])
+	})
+
+	// bound-fn* — returns a fn that runs f with the bindings in effect now
+	boundFnStar, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("bound-fn* expects 1 arg")
+		}
+		fn, ok := vs[0].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("bound-fn* expected Fn")
+		}
+		return vm.BindingConveyor(fn), nil
+	})
+
+	isThreadBound, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		for _, x := range vs {
+			v, ok := x.(*vm.Var)
+			if !ok {
+				return vm.NIL, fmt.Errorf("thread-bound? expected Var")
+			}
+			if !v.IsThreadBound() {
+				return vm.FALSE, nil
+			}
+		}
+		return vm.TRUE, nil
+	})
+
+	// var-set — sets the current goroutine's binding of a var, or its root when unbound
+	varSet, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("var-set expects 2 args")
+		}
+		v, ok := vs[0].(*vm.Var)
+		if !ok {
+			return vm.NIL, fmt.Errorf("var-set expected Var")
+		}
+		if err := v.Set(vs[1]); err != nil {
+			return vm.NIL, err
+		}
+		return vs[1], nil
+	})
+
 	withMeta, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
 		if len(vs) != 2 {
 			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
@@ -2718,7 +3101,8 @@ func installLangNS() {
 		if vs[0] == vm.NIL {
 			return vm.NIL, nil
 		}
-		m, ok := vs[0].(vm.IMeta)
+		// Reference types like refs carry metadata without supporting with-meta
+		m, ok := vs[0].(interface{ Meta() vm.Value })
 		if !ok {
 			return vm.NIL, nil
 		}
@@ -2792,6 +3176,17 @@ func installLangNS() {
 		return vm.NIL, nil
 	})
 
+	// ex-stack — the let-go stack trace recorded when ex was caught
+	exStack, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments")
+		}
+		if ei, ok := vs[0].(*vm.ExInfo); ok && len(ei.Stack()) > 0 {
+			return vm.StackValue(ei.Stack()), nil
+		}
+		return vm.NIL, nil
+	})
+
 	// transformer-seq* — (transformer-seq* xform coll) → lazy seq
 	// Lazily pulls elements from coll through the transducer xform.
 	// Uses a buffer-based approach: each source element may produce 0, 1, or many outputs.
@@ -3009,100 +3404,224 @@ func installLangNS() {
 		if len(vs) != 2 {
 			return vm.NIL, fmt.Errorf("compare expects 2 args")
 		}
-		a, b := vs[0], vs[1]
-		// nil sorts before everything
-		if a == vm.NIL && b == vm.NIL {
-			return vm.MakeInt(0), nil
+		c, err := vm.Compare(vs[0], vs[1])
+		if err != nil {
+			return vm.NIL, err
 		}
-		if a == vm.NIL {
-			return vm.MakeInt(-1), nil
+		return vm.MakeInt(c), nil
+	})
+
+	sortedMap, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		return vm.NewSortedMap(nil, vs)
+	})
+
+	sortedMapBy, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
-		if b == vm.NIL {
-			return vm.MakeInt(1), nil
+		cmp, ok := vs[0].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("sorted-map-by expected a comparator function")
 		}
-		// Numbers (including BigInt)
-		if vm.IsNumber(a) && vm.IsNumber(b) {
-			lt, err := vm.NumLt(a, b)
-			if err != nil {
-				return vm.NIL, err
+		return vm.NewSortedMap(cmp, vs[1:])
+	})
+
+	sortedSet, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		return vm.NewSortedSet(nil, vs)
+	})
+
+	sortedSetBy, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		cmp, ok := vs[0].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("sorted-set-by expected a comparator function")
+		}
+		return vm.NewSortedSet(cmp, vs[1:])
+	})
+
+	isSorted, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		switch vs[0].(type) {
+		case *vm.PersistentSortedMap, *vm.PersistentSortedSet:
+			return vm.TRUE, nil
+		}
+		return vm.FALSE, nil
+	})
+
+	rseq, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		var s vm.Seq
+		switch c := vs[0].(type) {
+		case *vm.PersistentSortedMap:
+			s = c.RSeq()
+		case *vm.PersistentSortedSet:
+			s = c.RSeq()
+		case vm.ArrayVector, vm.PersistentVector:
+			items := c.Unbox().([]vm.Value)
+			rev := make([]vm.Value, len(items))
+			for i, v := range items {
+				rev[len(items)-1-i] = v
 			}
-			if lt {
-				return vm.MakeInt(-1), nil
+			return vm.ListType.Box(rev)
+		default:
+			return vm.NIL, fmt.Errorf("rseq expected a vector or a sorted collection, got %s", vs[0].Type().Name())
+		}
+		if s == vm.EmptyList {
+			return vm.NIL, nil
+		}
+		return s, nil
+	})
+
+	// subseqf implements subseq (asc) and rsubseq (desc) over sorted collections.
+	subseqf := func(name string, asc bool) (vm.Value, error) {
+		return vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+			if len(vs) != 3 && len(vs) != 5 {
+				return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+			}
+			test, ok := vs[1].(vm.Fn)
+			if !ok {
+				return vm.NIL, fmt.Errorf("%s expected a test function", name)
+			}
+			key := vs[2]
+			var endTest vm.Fn
+			var endKey vm.Value
+			if len(vs) == 5 {
+				if endTest, ok = vs[3].(vm.Fn); !ok {
+					return vm.NIL, fmt.Errorf("%s expected a test function", name)
+				}
+				endKey = vs[4]
+				// rsubseq walks from the end bound towards the start bound
+				if !asc {
+					test, key, endTest, endKey = endTest, endKey, test, key
+				}
+			}
+			var s vm.Seq
+			var err error
+			switch c := vs[0].(type) {
+			case *vm.PersistentSortedMap:
+				s, err = c.SubSeq(asc, false, test, key, endTest, endKey)
+			case *vm.PersistentSortedSet:
+				s, err = c.SubSeq(asc, test, key, endTest, endKey)
+			default:
+				return vm.NIL, fmt.Errorf("%s expected a sorted collection, got %s", name, vs[0].Type().Name())
 			}
-			gt, err := vm.NumGt(a, b)
 			if err != nil {
 				return vm.NIL, err
 			}
-			if gt {
-				return vm.MakeInt(1), nil
+			if s == vm.EmptyList {
+				return vm.NIL, nil
 			}
-			return vm.MakeInt(0), nil
+			return s, nil
+		})
+	}
+	subseq, err := subseqf("subseq", true)
+	rsubseq, err := subseqf("rsubseq", false)
+
+	readInst, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
-		// Strings
-		if sa, ok := a.(vm.String); ok {
-			if sb, ok := b.(vm.String); ok {
-				as, bs := string(sa), string(sb)
-				if as < bs {
-					return vm.MakeInt(-1), nil
-				}
-				if as > bs {
-					return vm.MakeInt(1), nil
-				}
-				return vm.MakeInt(0), nil
-			}
+		s, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("#inst expects a string, got %s", vs[0].Type().Name())
 		}
-		// Keywords
-		if ka, ok := a.(vm.Keyword); ok {
-			if kb, ok := b.(vm.Keyword); ok {
-				as, bs := string(ka), string(kb)
-				if as < bs {
-					return vm.MakeInt(-1), nil
-				}
-				if as > bs {
-					return vm.MakeInt(1), nil
-				}
-				return vm.MakeInt(0), nil
-			}
+		return vm.ParseInst(string(s))
+	})
+
+	readUUID, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
-		// Booleans (false < true)
-		if ba, ok := a.(vm.Boolean); ok {
-			if bb, ok := b.(vm.Boolean); ok {
-				if ba == bb {
-					return vm.MakeInt(0), nil
-				}
-				if !bool(ba) {
-					return vm.MakeInt(-1), nil
-				}
-				return vm.MakeInt(1), nil
-			}
+		s, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("#uuid expects a string, got %s", vs[0].Type().Name())
+		}
+		return vm.ParseUUID(string(s))
+	})
+
+	parseUUID, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		s, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("parse-uuid expected String")
+		}
+		u, err := vm.ParseUUID(string(s))
+		if err != nil {
+			return vm.NIL, nil
+		}
+		return u, nil
+	})
+
+	randomUUID, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 0 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
 		}
-		return vm.NIL, fmt.Errorf("compare: cannot compare %s and %s", a.Type().Name(), b.Type().Name())
+		return vm.RandomUUID(), nil
+	})
+
+	isUUID, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		_, ok := vs[0].(vm.UUID)
+		return vm.Boolean(ok), nil
+	})
+
+	isInst, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		_, ok := vs[0].(vm.Inst)
+		return vm.Boolean(ok), nil
+	})
+
+	instMs, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		i, ok := vs[0].(vm.Inst)
+		if !ok {
+			return vm.NIL, fmt.Errorf("inst-ms expected Inst, got %s", vs[0].Type().Name())
+		}
+		return vm.MakeInt(int(i.Millis())), nil
 	})
 
 	// print — like println but no newline, space-separated
 	printf, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		b := &strings.Builder{}
 		for i, v := range vs {
 			if i > 0 {
-				fmt.Print(" ")
+				b.WriteByte(' ')
 			}
 			if s, ok := v.(vm.String); ok {
-				fmt.Print(string(s))
+				b.WriteString(string(s))
 			} else {
-				fmt.Print(v.String())
+				b.WriteString(v.String())
 			}
 		}
-		return vm.NIL, nil
+		_, err := io.WriteString(Stdout(), b.String())
+		return vm.NIL, err
 	})
 
 	// pr — print readably (like prn without newline)
 	prf, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		b := &strings.Builder{}
 		for i, v := range vs {
 			if i > 0 {
-				fmt.Print(" ")
+				b.WriteByte(' ')
 			}
-			fmt.Print(v.String())
+			b.WriteString(v.String())
 		}
-		return vm.NIL, nil
+		_, err := io.WriteString(Stdout(), b.String())
+		return vm.NIL, err
 	})
 
 	// --- Bitwise ops ---
@@ -3308,6 +3827,7 @@ func installLangNS() {
 		if !ok {
 			return vm.NIL, fmt.Errorf("future* expected Fn")
 		}
+		fn = vm.BindingConveyor(fn)
 		p := vm.NewPromise()
 		go func() {
 			v, err := fn.Invoke(nil)
@@ -3320,14 +3840,14 @@ func installLangNS() {
 		return p, nil
 	})
 
-	// add-watch — (add-watch atom key fn)
+	// add-watch — (add-watch ref key fn)
 	addWatch, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
 		if len(vs) != 3 {
 			return vm.NIL, fmt.Errorf("add-watch expects 3 args")
 		}
-		a, ok := vs[0].(*vm.Atom)
+		a, ok := vs[0].(vm.Watchable)
 		if !ok {
-			return vm.NIL, fmt.Errorf("add-watch expected Atom")
+			return vm.NIL, fmt.Errorf("add-watch expected a reference")
 		}
 		fn, ok := vs[2].(vm.Fn)
 		if !ok {
@@ -3337,14 +3857,14 @@ func installLangNS() {
 		return vs[0], nil
 	})
 
-	// remove-watch — (remove-watch atom key)
+	// remove-watch — (remove-watch ref key)
 	removeWatch, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
 		if len(vs) != 2 {
 			return vm.NIL, fmt.Errorf("remove-watch expects 2 args")
 		}
-		a, ok := vs[0].(*vm.Atom)
+		a, ok := vs[0].(vm.Watchable)
 		if !ok {
-			return vm.NIL, fmt.Errorf("remove-watch expected Atom")
+			return vm.NIL, fmt.Errorf("remove-watch expected a reference")
 		}
 		a.RemoveWatch(vs[1])
 		return vs[0], nil
@@ -3355,15 +3875,19 @@ func installLangNS() {
 		if len(vs) < 2 {
 			return vm.NIL, fmt.Errorf("alter-meta! expects at least 2 args")
 		}
-		a, ok := vs[0].(*vm.Atom)
-		if !ok {
-			return vm.NIL, fmt.Errorf("alter-meta! expected Atom")
-		}
 		fn, ok := vs[1].(vm.Fn)
 		if !ok {
 			return vm.NIL, fmt.Errorf("alter-meta! expected Fn")
 		}
-		return a.AlterMeta(fn, vs[2:])
+		switch a := vs[0].(type) {
+		case *vm.Atom:
+			return a.AlterMeta(fn, vs[2:])
+		case *vm.Ref:
+			return a.AlterMeta(fn, vs[2:])
+		case *vm.Agent:
+			return a.AlterMeta(fn, vs[2:])
+		}
+		return vm.NIL, fmt.Errorf("alter-meta! expected Atom, Ref or Agent")
 	})
 
 	// subvec — (subvec v start) or (subvec v start end)
@@ -3453,7 +3977,7 @@ func installLangNS() {
 	ns := vm.NewNamespace(NameCoreNS)
 
 	// vars
-	CurrentNS = ns.Def("*ns*", ns)
+	defaultRuntime.currentNS = ns.Def("*ns*", ns)
 	ns.Def("*compiling-aot*", vm.FALSE)
 	ns.Def("*in-wasm*", vm.FALSE)
 
@@ -3484,6 +4008,7 @@ func installLangNS() {
 	ns.Def("/", div)
 
 	ns.Def("=", equals)
+	ns.Def("==", numEquiv)
 	ns.Def("gt", gt)
 	ns.Def("lt", lt)
 	ns.Def("ge", ge)
@@ -3614,6 +4139,10 @@ func installLangNS() {
 	ns.Def("make-record-type", makeRecordType)
 	ns.Def("make-record", makeRecord)
 	ns.Def("record?", isRecord)
+	ns.Def("make-deftype", makeDefType)
+	ns.Def("new", newf)
+	ns.Def("deftype-set!", deftypeSet)
+	ns.Def("reify*", reify)
 	ns.Def("defprotocol*", defProtocol)
 	ns.Def("make-protocol-fn", makeProtocolFn)
 	ns.Def("extend-type*", extendType)
@@ -3643,12 +4172,20 @@ func installLangNS() {
 	ns.Def("meta", metaf)
 	ns.Def("push-binding!", pushBinding)
 	ns.Def("pop-binding!", popBinding)
+	ns.Def("push-thread-bindings", pushThreadBindings)
+	ns.Def("pop-thread-bindings", popThreadBindings)
+	ns.Def("get-thread-bindings", getThreadBindings)
+	ns.Def("with-bindings*", withBindingsStar)
+	ns.Def("bound-fn*", boundFnStar)
+	ns.Def("thread-bound?", isThreadBound)
+	ns.Def("var-set", varSet)
 
 	ns.Def("throw", throwf)
 	ns.Def("ex-info", exInfo)
 	ns.Def("ex-message", exMessage)
 	ns.Def("ex-data", exData)
 	ns.Def("ex-cause", exCause)
+	ns.Def("ex-stack", exStack)
 
 	ns.Def("delay*", delayStar)
 	ns.Def("force", force)
@@ -3669,6 +4206,11 @@ func installLangNS() {
 			return vm.NewBigIntFromInt64(int64(v)), nil
 		case *vm.BigInt:
 			return v, nil
+		case *vm.Ratio:
+			return vm.NewBigInt(new(big.Int).Quo(v.Numerator(), v.Denominator())), nil
+		case *vm.BigDecimal:
+			r := v.Rat()
+			return vm.NewBigInt(new(big.Int).Quo(r.Num(), r.Denom())), nil
 		case vm.String:
 			bi, ok := vm.NewBigIntFromString(string(v))
 			if !ok {
@@ -3679,6 +4221,81 @@ func installLangNS() {
 		return vm.NIL, fmt.Errorf("cannot coerce %s to bigint", vs[0].Type().Name())
 	})
 
+	// bigdec — coerce to BigDecimal
+	bigdecf, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		switch v := vs[0].(type) {
+		case vm.Float:
+			return vm.ParseBigDecimal(strconv.FormatFloat(float64(v), 'g', -1, 64))
+		case vm.String:
+			return vm.ParseBigDecimal(string(v))
+		}
+		if !vm.IsNumber(vs[0]) {
+			return vm.NIL, fmt.Errorf("cannot coerce %s to bigdec", vs[0].Type().Name())
+		}
+		return vm.ToBigDecimal(vs[0])
+	})
+
+	isRatio, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		_, ok := vs[0].(*vm.Ratio)
+		return vm.Boolean(ok), nil
+	})
+
+	isDecimal, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		_, ok := vs[0].(*vm.BigDecimal)
+		return vm.Boolean(ok), nil
+	})
+
+	numerator, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, ok := vs[0].(*vm.Ratio)
+		if !ok {
+			return vm.NIL, fmt.Errorf("numerator expected Ratio, got %s", vs[0].Type().Name())
+		}
+		return vm.MaybeDowngrade(new(big.Int).Set(r.Numerator())), nil
+	})
+
+	denominator, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, ok := vs[0].(*vm.Ratio)
+		if !ok {
+			return vm.NIL, fmt.Errorf("denominator expected Ratio, got %s", vs[0].Type().Name())
+		}
+		return vm.MaybeDowngrade(new(big.Int).Set(r.Denominator())), nil
+	})
+
+	// rationalize — floats become the ratio of their shortest decimal form
+	rationalize, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		switch v := vs[0].(type) {
+		case vm.Int, *vm.BigInt, *vm.Ratio:
+			return v, nil
+		case vm.Float:
+			d, err := vm.ParseBigDecimal(strconv.FormatFloat(float64(v), 'g', -1, 64))
+			if err != nil {
+				return vm.NIL, err
+			}
+			return vm.NewRatio(d.Rat()), nil
+		case *vm.BigDecimal:
+			return vm.NewRatio(v.Rat()), nil
+		}
+		return vm.NIL, fmt.Errorf("rationalize expected a number, got %s", vs[0].Type().Name())
+	})
+
 	// bigint? — test if value is BigInt
 	isBigInt, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
 		if len(vs) != 1 {
@@ -3689,8 +4306,33 @@ func installLangNS() {
 
 	ns.Def("bigint", bigintf)
 	ns.Def("bigint?", isBigInt)
+	ns.Def("bigdec", bigdecf)
+	ns.Def("ratio?", isRatio)
+	ns.Def("decimal?", isDecimal)
+	ns.Def("numerator", numerator)
+	ns.Def("denominator", denominator)
+	ns.Def("rationalize", rationalize)
+	ns.Def("*math-context*", vm.NIL).SetDynamic()
 	ns.Def("transformer-seq*", transformerSeq)
 	ns.Def("compare", comparef)
+	ns.Def("sorted-map", sortedMap)
+	ns.Def("sorted-map-by", sortedMapBy)
+	ns.Def("sorted-set", sortedSet)
+	ns.Def("sorted-set-by", sortedSetBy)
+	ns.Def("sorted?", isSorted)
+	ns.Def("rseq", rseq)
+	ns.Def("subseq", subseq)
+	ns.Def("rsubseq", rsubseq)
+	ns.Def("inst?", isInst)
+	ns.Def("inst-ms", instMs)
+	ns.Def("uuid?", isUUID)
+	ns.Def("random-uuid", randomUUID)
+	ns.Def("parse-uuid", parseUUID)
+	ns.Def("default-data-readers", vm.EmptyPersistentMap.
+		Assoc(vm.Symbol("inst"), readInst).
+		Assoc(vm.Symbol("uuid"), readUUID))
+	ns.Def("*data-readers*", vm.EmptyPersistentMap).SetDynamic()
+	ns.Def("*default-data-reader-fn*", vm.NIL).SetDynamic()
 	ns.Def("fn?", isFn)
 	ns.Def("bit-and", bitAnd)
 	ns.Def("bit-or", bitOr)
@@ -4239,8 +4881,16 @@ func installLangNS() {
 
 	// IO builtins (open, close!, read-line, write!, etc.)
 	installIOBuiltins(ns)
-
-	CoreNS = ns
+	installSTMBuiltins(ns)
+	installAgentBuiltins(ns)
+	installTapBuiltins(ns)
+	installHierarchyBuiltins(ns)
+	installNSBuiltins(ns)
+	installVarBuiltins(ns)
+	installReplBuiltins(ns)
+	installNativeDocs(ns)
+
+	defaultRuntime.core = ns
 
 	RegisterNS(ns)
 }
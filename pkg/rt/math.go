@@ -76,7 +76,7 @@ func mathFn2(f func(float64, float64) float64) vm.Value {
 // nolint
 func installMathNS() {
 	ns := vm.NewNamespace("math")
-	ns.Refer(CoreNS, "", true)
+	ns.Refer(CoreNS(), "", true)
 
 	// Constants
 	ns.Def("E", vm.Float(math.E))
@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// asNamespace returns the namespace v or the one named by the symbol v.
+func asNamespace(name string, v vm.Value) (*vm.Namespace, error) {
+	switch x := v.(type) {
+	case *vm.Namespace:
+		return x, nil
+	case vm.Symbol:
+		if ns := LookupNS(string(x)); ns != nil {
+			return ns, nil
+		}
+		return nil, fmt.Errorf("no namespace: %s found", x)
+	}
+	return nil, fmt.Errorf("%s expected Symbol or Namespace, got %s", name, v.Type().Name())
+}
+
+// varMap makes a map of symbol to var of the vars in vars which keep passes.
+// Vars hidden by the access policy are left out.
+func varMap(vars map[vm.Symbol]*vm.Var, keep func(*vm.Var) bool) vm.Value {
+	var m vm.Associative = vm.EmptyPersistentMap
+	for s, v := range vars {
+		if vm.CheckAccess(v) != nil || !keep(v) {
+			continue
+		}
+		m = m.Assoc(s, v)
+	}
+	return m
+}
+
+// nsFn makes a native taking a namespace (or its name) and optionally more
+// arguments, like ns-publics or ns-unmap.
+func nsFn(name string, arity int, f func(ns *vm.Namespace, args []vm.Value) (vm.Value, error)) vm.Value {
+	fn, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != arity {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		ns, err := asNamespace(name, vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return f(ns, vs[1:])
+	})
+	return fn
+}
+
+func asSymbol(name string, v vm.Value) (vm.Symbol, error) {
+	s, ok := v.(vm.Symbol)
+	if !ok {
+		return "", fmt.Errorf("%s expected Symbol, got %s", name, v.Type().Name())
+	}
+	return s, nil
+}
+
+// resolveIn returns the var named by sym in ns, or nil.
+func resolveIn(ns *vm.Namespace, sym vm.Symbol) vm.Value {
+	if v, ok := ns.Lookup(sym).(*vm.Var); ok {
+		return v
+	}
+	return vm.NIL
+}
+
+// nolint
+func installNSBuiltins(ns *vm.Namespace) {
+	// own tells whether v was interned in n rather than imported into it
+	own := func(n *vm.Namespace) func(*vm.Var) bool {
+		return func(v *vm.Var) bool { return v.NS() == n.Name() }
+	}
+
+	// ns-publics — (ns-publics ns) maps symbols to the public vars of ns
+	nsPublics := nsFn("ns-publics", 1, func(n *vm.Namespace, _ []vm.Value) (vm.Value, error) {
+		return varMap(n.Interns(), func(v *vm.Var) bool { return own(n)(v) && !v.IsPrivate() }), nil
+	})
+
+	// ns-interns — (ns-interns ns) maps symbols to all the vars of ns
+	nsInterns := nsFn("ns-interns", 1, func(n *vm.Namespace, _ []vm.Value) (vm.Value, error) {
+		return varMap(n.Interns(), own(n)), nil
+	})
+
+	// ns-refers — (ns-refers ns) maps symbols to the vars referred into ns
+	nsRefers := nsFn("ns-refers", 1, func(n *vm.Namespace, _ []vm.Value) (vm.Value, error) {
+		return varMap(n.Refers(), func(*vm.Var) bool { return true }), nil
+	})
+
+	// ns-map — (ns-map ns) maps all symbols that name vars in ns
+	nsMap := nsFn("ns-map", 1, func(n *vm.Namespace, _ []vm.Value) (vm.Value, error) {
+		vars := n.Refers()
+		for s, v := range n.Interns() {
+			vars[s] = v
+		}
+		return varMap(vars, func(*vm.Var) bool { return true }), nil
+	})
+
+	// ns-aliases — (ns-aliases ns) maps aliases to namespaces
+	nsAliases := nsFn("ns-aliases", 1, func(n *vm.Namespace, _ []vm.Value) (vm.Value, error) {
+		var m vm.Associative = vm.EmptyPersistentMap
+		for s, a := range n.Aliases() {
+			m = m.Assoc(s, a)
+		}
+		return m, nil
+	})
+
+	// ns-unmap — (ns-unmap ns sym)
+	nsUnmap := nsFn("ns-unmap", 2, func(n *vm.Namespace, args []vm.Value) (vm.Value, error) {
+		sym, err := asSymbol("ns-unmap", args[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if err := vm.CheckDef(n, sym); err != nil {
+			return vm.NIL, err
+		}
+		n.Unmap(sym)
+		return vm.NIL, nil
+	})
+
+	// ns-unalias — (ns-unalias ns alias)
+	nsUnalias := nsFn("ns-unalias", 2, func(n *vm.Namespace, args []vm.Value) (vm.Value, error) {
+		sym, err := asSymbol("ns-unalias", args[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if err := vm.CheckNamespace(n); err != nil {
+			return vm.NIL, err
+		}
+		n.Unalias(sym)
+		return vm.NIL, nil
+	})
+
+	// create-ns — (create-ns sym) finds or creates the namespace, without
+	// loading it
+	createNs, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		sym, err := asSymbol("create-ns", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return LookupOrRegisterNSNoLoad(string(sym)), nil
+	})
+
+	// remove-ns — (remove-ns sym) returns the removed namespace or nil
+	removeNs, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		sym, err := asSymbol("remove-ns", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if sym == NameCoreNS {
+			return vm.NIL, fmt.Errorf("cannot remove core namespace")
+		}
+		n := LookupNS(string(sym))
+		if n == nil {
+			return vm.NIL, nil
+		}
+		if err := vm.CheckNamespace(n); err != nil {
+			return vm.NIL, err
+		}
+		return Current().RemoveNS(string(sym)), nil
+	})
+
+	// intern — (intern ns name) finds or creates the var, (intern ns name val)
+	// also sets its root
+	intern, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 && len(vs) != 3 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		n, err := asNamespace("intern", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		sym, err := asSymbol("intern", vs[1])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if err := vm.CheckDef(n, sym); err != nil {
+			return vm.NIL, err
+		}
+		v := n.LookupLocal(sym)
+		if v == nil || v.NS() != n.Name() {
+			v = n.Def(string(sym), vm.NIL)
+		}
+		if len(vs) == 3 {
+			v.SetRoot(vs[2])
+		}
+		return v, nil
+	})
+
+	// resolve — (resolve sym) or (resolve env sym), the var named by sym in
+	// the current namespace, or nil
+	resolve, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 && len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		sym, err := asSymbol("resolve", vs[len(vs)-1])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return resolveIn(CurrentNSVar().Deref().(*vm.Namespace), sym), nil
+	})
+
+	// ns-resolve — (ns-resolve ns sym) or (ns-resolve ns env sym)
+	nsResolve, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 && len(vs) != 3 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		n, err := asNamespace("ns-resolve", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		sym, err := asSymbol("ns-resolve", vs[len(vs)-1])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return resolveIn(n, sym), nil
+	})
+
+	// requiring-resolve — (requiring-resolve 'ns/name) loads ns if needed
+	requiringResolve, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		sym, err := asSymbol("requiring-resolve", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		nsName, ok := sym.Namespace().(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("requiring-resolve expected a qualified symbol, got %s", sym)
+		}
+		cur := CurrentNSVar().Deref().(*vm.Namespace)
+		// the var may exist before its namespace is loaded, without a root
+		if cur.ResolveAlias(vm.Symbol(nsName)) == nil {
+			NS(string(nsName)) // triggers loading
+		}
+		return resolveIn(cur, sym), nil
+	})
+
+	ns.Def("ns-publics", nsPublics)
+	ns.Def("ns-interns", nsInterns)
+	ns.Def("ns-refers", nsRefers)
+	ns.Def("ns-map", nsMap)
+	ns.Def("ns-aliases", nsAliases)
+	ns.Def("ns-unmap", nsUnmap)
+	ns.Def("ns-unalias", nsUnalias)
+	ns.Def("create-ns", createNs)
+	ns.Def("remove-ns", removeNs)
+	ns.Def("intern", intern)
+	ns.Def("resolve", resolve)
+	ns.Def("ns-resolve", nsResolve)
+	ns.Def("requiring-resolve", requiringResolve)
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// profileValue returns the samples of p as a vector of maps with :stack and
+// :samples keys, most sampled stacks first.
+func profileValue(p *vm.Profile) vm.Value {
+	samples := append([]vm.ProfileSample{}, p.Samples...)
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Count > samples[j].Count })
+	out := make(vm.ArrayVector, len(samples))
+	for i, s := range samples {
+		var m vm.Associative = vm.EmptyPersistentMap
+		m = m.Assoc(vm.Keyword("stack"), vm.StackValue(s.Stack))
+		m = m.Assoc(vm.Keyword("samples"), vm.Int(s.Count))
+		out[i] = m
+	}
+	return out
+}
+
+// nolint
+func installProfileNS() {
+	// profile/start — (profile/start) (profile/start {:interval-ms 10})
+	start, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) > 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		interval := vm.DefaultProfileInterval
+		if len(vs) == 1 && vs[0] != vm.NIL {
+			opts, ok := vs[0].(vm.Lookup)
+			if !ok {
+				return vm.NIL, fmt.Errorf("profile/start expected options map")
+			}
+			if ms, ok := opts.ValueAt(vm.Keyword("interval-ms")).(vm.Int); ok && ms > 0 {
+				interval = time.Duration(ms) * time.Millisecond
+			}
+		}
+		return vm.NIL, vm.StartProfile(interval)
+	})
+
+	// profile/stop — (profile/stop) returns the samples, (profile/stop path)
+	// writes them to path in pprof format
+	stop, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) > 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		p, err := vm.StopProfile()
+		if err != nil {
+			return vm.NIL, err
+		}
+		if len(vs) == 0 {
+			return profileValue(p), nil
+		}
+		path, ok := vs[0].(vm.String)
+		if !ok {
+			return vm.NIL, fmt.Errorf("profile/stop expected String")
+		}
+		f, err := os.Create(string(path))
+		if err != nil {
+			return vm.NIL, err
+		}
+		if err := p.WritePprof(f); err != nil {
+			f.Close()
+			return vm.NIL, err
+		}
+		return path, f.Close()
+	})
+
+	// profile/running? — (profile/running?)
+	running, err := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		return vm.Boolean(vm.Profiling()), nil
+	})
+
+	if err != nil {
+		panic("profile NS init failed")
+	}
+
+	ns := vm.NewNamespace("profile")
+	ns.Def("start", start)
+	ns.Def("stop", stop)
+	ns.Def("running?", running)
+	RegisterNS(ns)
+}
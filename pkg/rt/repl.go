@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+//go:embed core/repl.lg
+var ReplSrc string
+
+// embeddedSources maps the bundled namespaces to their source, so source can
+// show vars loaded from the precompiled bundle.
+var embeddedSources = map[string]*string{
+	"core":   &CoreSrc,
+	"walk":   &WalkSrc,
+	"string": &StringSrc,
+	"set":    &SetSrc,
+	"pprint": &PprintSrc,
+	"edn":    &EdnSrc,
+	"io":     &IoSrc,
+	"async":  &AsyncSrc,
+	"test":   &TestSrc,
+	"zip":    &ZipSrc,
+	"data":   &DataSrc,
+	"repl":   &ReplSrc,
+}
+
+// sourceText returns the source of file if it was compiled in this process
+// or is one of the embedded namespaces. It never reads the disk, so that
+// source can't be used to read files in a sandbox.
+func sourceText(file string) (string, bool) {
+	if src, ok := vm.SourceRegistry.Get(file); ok {
+		return src, true
+	}
+	if strings.HasPrefix(file, "<embedded:") && strings.HasSuffix(file, ">") {
+		if src, ok := embeddedSources[file[len("<embedded:"):len(file)-1]]; ok {
+			return *src, true
+		}
+	}
+	return "", false
+}
+
+// formAt returns the text of the form starting at the 1-based line and
+// column of src, or "" if there's none.
+func formAt(src string, line, column int) string {
+	start := 0
+	for l := 1; l < line; l++ {
+		i := strings.IndexByte(src[start:], '\n')
+		if i < 0 {
+			return ""
+		}
+		start += i + 1
+	}
+	start += column - 1
+	if start < 0 || start >= len(src) {
+		return ""
+	}
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth <= 0 {
+				return src[start : i+1]
+			}
+		case '\\':
+			i++
+		case ';':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case '"':
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// SetLastError sets *e of the current runtime to err, for REPLs to call
+// when an evaluation fails.
+func SetLastError(err error) {
+	Current().lastError.Set(vm.ErrorValue(err))
+}
+
+// SetLastValue shifts v into *1, *2 and *3 of the current runtime, for REPLs
+// to call when an evaluation succeeds.
+func SetLastValue(v vm.Value) {
+	vals := Current().lastVals
+	vals[2].Set(vals[1].Deref())
+	vals[1].Set(vals[0].Deref())
+	vals[0].Set(v)
+}
+
+// ReplBindings returns bindings of the vars of the current runtime a REPL
+// session keeps to itself, with *ns* set to ns and no history. REPLs serving
+// several sessions push them in each session's goroutine.
+func ReplBindings(ns *vm.Namespace) map[*vm.Var]vm.Value {
+	r := Current()
+	return map[*vm.Var]vm.Value{
+		r.currentNS:   ns,
+		r.lastVals[0]: vm.NIL,
+		r.lastVals[1]: vm.NIL,
+		r.lastVals[2]: vm.NIL,
+		r.lastError:   vm.NIL,
+	}
+}
+
+// nolint
+func installReplBuiltins(ns *vm.Namespace) {
+	defaultRuntime.lastError = ns.Def("*e", vm.NIL)
+	defaultRuntime.lastError.SetDynamic()
+	for i := range defaultRuntime.lastVals {
+		defaultRuntime.lastVals[i] = ns.Def(fmt.Sprintf("*%d", i+1), vm.NIL)
+		defaultRuntime.lastVals[i].SetDynamic()
+	}
+}
+
+// installReplNS registers the native part of the repl namespace, the rest
+// comes from ReplSrc.
+// nolint
+func installReplNS() {
+	// source-at* — (source-at* file line column) returns the text of the form
+	// defined there, or nil
+	sourceAt, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 3 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		file, ok := vs[0].(vm.String)
+		line, lok := vs[1].(vm.Int)
+		column, cok := vs[2].(vm.Int)
+		if !ok || !lok || !cok {
+			return vm.NIL, nil
+		}
+		src, ok := sourceText(string(file))
+		if !ok {
+			return vm.NIL, nil
+		}
+		if form := formAt(src, int(line), int(column)); form != "" {
+			return vm.String(form), nil
+		}
+		return vm.NIL, nil
+	})
+
+	ns := vm.NewNamespace("repl")
+	ns.Refer(CoreNS(), "", true)
+	ns.Def("source-at*", sourceAt)
+	RegisterNS(ns)
+	MarkNSNeedsLoad("repl")
+}
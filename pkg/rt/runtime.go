@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// Runtime is an independent set of namespaces with its own loader and its
+// own copy of core. The process starts with a default runtime which the
+// package-level functions operate on unless the calling goroutine entered
+// another one.
+type Runtime struct {
+	mu        sync.RWMutex // guards registry and needsLoad, sessions share the runtime
+	registry  map[string]*vm.Namespace
+	needsLoad map[string]bool
+	loader    NSLoader
+	core      *vm.Namespace
+	currentNS *vm.Var
+	stdin     *vm.Var
+	stdout    *vm.Var
+	stderr    *vm.Var
+	lastError *vm.Var    // *e
+	lastVals  [3]*vm.Var // *1, *2 and *3
+	taps      *tapSet
+
+	// filled in by the compiler when core is loaded into the runtime
+	consts      *vm.Consts
+	precompiled map[string]*vm.CodeChunk
+}
+
+var defaultRuntime = &Runtime{
+	registry:  map[string]*vm.Namespace{},
+	needsLoad: map[string]bool{},
+	taps:      newTapSet(),
+}
+
+// pristine holds a copy of the namespaces installed by Go code, before any
+// let-go code ran. New runtimes start from a copy of it.
+var pristine map[string]*vm.Namespace
+
+// pristineNeedsLoad names the namespaces in pristine that are completed by
+// let-go source on first use.
+var pristineNeedsLoad map[string]bool
+
+// DefaultRuntime returns the runtime shared by the process.
+func DefaultRuntime() *Runtime {
+	return defaultRuntime
+}
+
+// Current returns the runtime of the calling goroutine.
+func Current() *Runtime {
+	if r, ok := vm.CurrentRuntime().(*Runtime); ok {
+		return r
+	}
+	return defaultRuntime
+}
+
+// NewRuntime returns a runtime with fresh copies of the built-in namespaces.
+// It has no let-go code loaded yet, see compiler.NewRuntime.
+func NewRuntime() *Runtime {
+	registry := vm.CloneNamespaces(pristine)
+	core := registry[NameCoreNS]
+	r := &Runtime{
+		registry:  registry,
+		needsLoad: maps.Clone(pristineNeedsLoad),
+		core:      core,
+		currentNS: core.LookupLocal("*ns*"),
+		stdin:     core.LookupLocal("*in*"),
+		stdout:    core.LookupLocal("*out*"),
+		stderr:    core.LookupLocal("*err*"),
+		lastError: core.LookupLocal("*e"),
+		taps:      newTapSet(),
+	}
+	for i := range r.lastVals {
+		r.lastVals[i] = core.LookupLocal(vm.Symbol(fmt.Sprintf("*%d", i+1)))
+	}
+	return r
+}
+
+// Enter makes r the runtime of the calling goroutine until the returned
+// function is called.
+func (r *Runtime) Enter() func() {
+	return vm.EnterRuntime(r)
+}
+
+// CoreNS returns the core namespace of r.
+func (r *Runtime) CoreNS() *vm.Namespace { return r.core }
+
+// CurrentNSVar returns the *ns* var of r.
+func (r *Runtime) CurrentNSVar() *vm.Var { return r.currentNS }
+
+// StdinVar returns the *in* var of r.
+func (r *Runtime) StdinVar() *vm.Var { return r.stdin }
+
+// StdoutVar returns the *out* var of r.
+func (r *Runtime) StdoutVar() *vm.Var { return r.stdout }
+
+// StderrVar returns the *err* var of r.
+func (r *Runtime) StderrVar() *vm.Var { return r.stderr }
+
+// SetNSLoader sets the loader used to load namespaces into r on demand.
+func (r *Runtime) SetNSLoader(loader NSLoader) { r.loader = loader }
+
+// Consts returns the const pool core was compiled into.
+func (r *Runtime) Consts() *vm.Consts { return r.consts }
+
+// SetConsts sets the const pool core was compiled into.
+func (r *Runtime) SetConsts(c *vm.Consts) { r.consts = c }
+
+// PrecompiledNSChunk returns the precompiled main chunk for a namespace, or nil.
+func (r *Runtime) PrecompiledNSChunk(name string) *vm.CodeChunk {
+	return r.precompiled[name]
+}
+
+// SetPrecompiledNS stores namespace chunks decoded from a bundle and marks
+// them for loading on first use.
+func (r *Runtime) SetPrecompiledNS(chunks map[string]*vm.CodeChunk) {
+	r.precompiled = chunks
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range chunks {
+		if name != NameCoreNS {
+			r.needsLoad[name] = true
+		}
+	}
+}
+
+// CoreNS returns the core namespace of the current runtime.
+func CoreNS() *vm.Namespace {
+	return Current().core
+}
+
+// CurrentNSVar returns the *ns* var of the current runtime.
+func CurrentNSVar() *vm.Var {
+	return Current().currentNS
+}
@@ -0,0 +1,266 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// refOptions parses the trailing key/value options of atom and ref, e.g.
+// (ref x :validator pos? :meta {:a 1}).
+func refOptions(name string, vs []vm.Value) (map[vm.Keyword]vm.Value, error) {
+	if len(vs)%2 != 0 {
+		return nil, fmt.Errorf("%s expects key/value options", name)
+	}
+	opts := map[vm.Keyword]vm.Value{}
+	for i := 0; i < len(vs); i += 2 {
+		k, ok := vs[i].(vm.Keyword)
+		if !ok {
+			return nil, fmt.Errorf("%s expected Keyword option, got %s", name, vs[i].Type().Name())
+		}
+		opts[k] = vs[i+1]
+	}
+	return opts, nil
+}
+
+// applyValidator installs the :validator option on r, if given.
+func applyValidator(r vm.Validatable, opts map[vm.Keyword]vm.Value) error {
+	v, ok := opts["validator"]
+	if !ok || v == vm.NIL {
+		return nil
+	}
+	fn, ok := v.(vm.Fn)
+	if !ok {
+		return fmt.Errorf("validator must be a Fn")
+	}
+	return r.SetValidator(fn)
+}
+
+func asRef(name string, v vm.Value) (*vm.Ref, error) {
+	r, ok := v.(*vm.Ref)
+	if !ok {
+		return nil, fmt.Errorf("%s expected Ref, got %s", name, v.Type().Name())
+	}
+	return r, nil
+}
+
+// nolint
+func installSTMBuiltins(ns *vm.Namespace) {
+	// ref — (ref x & {:keys [meta validator min-history max-history]})
+	reff, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		opts, err := refOptions("ref", vs[1:])
+		if err != nil {
+			return vm.NIL, err
+		}
+		r := vm.NewRef(vs[0])
+		if m, ok := opts["meta"]; ok {
+			r.ResetMeta(m)
+		}
+		if n, ok := opts["min-history"].(vm.Int); ok {
+			r.SetMinHistory(int(n))
+		}
+		if n, ok := opts["max-history"].(vm.Int); ok {
+			r.SetMaxHistory(int(n))
+		}
+		if err := applyValidator(r, opts); err != nil {
+			return vm.NIL, err
+		}
+		return r, nil
+	})
+
+	// sync* — runs a fn in a transaction, see dosync
+	syncf, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		fn, ok := vs[0].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("sync* expected Fn")
+		}
+		return vm.RunInTransaction(fn)
+	})
+
+	// ref-set — (ref-set ref val)
+	refSet, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, err := asRef("ref-set", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		tx, err := vm.CurrentTransaction()
+		if err != nil {
+			return vm.NIL, err
+		}
+		return tx.Set(r, vs[1])
+	})
+
+	// alter — (alter ref f & args)
+	alter, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, err := asRef("alter", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		fn, ok := vs[1].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("alter expected Fn")
+		}
+		tx, err := vm.CurrentTransaction()
+		if err != nil {
+			return vm.NIL, err
+		}
+		return tx.Alter(r, fn, vs[2:])
+	})
+
+	// commute — (commute ref f & args)
+	commute, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, err := asRef("commute", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		fn, ok := vs[1].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("commute expected Fn")
+		}
+		tx, err := vm.CurrentTransaction()
+		if err != nil {
+			return vm.NIL, err
+		}
+		return tx.Commute(r, fn, vs[2:])
+	})
+
+	// ensure — (ensure ref)
+	ensure, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, err := asRef("ensure", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		tx, err := vm.CurrentTransaction()
+		if err != nil {
+			return vm.NIL, err
+		}
+		return tx.Ensure(r)
+	})
+
+	inTransaction, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 0 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		return vm.Boolean(vm.InTransaction()), nil
+	})
+
+	refHistoryCount, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, err := asRef("ref-history-count", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return vm.Int(r.HistoryCount()), nil
+	})
+
+	// ref-min-history — (ref-min-history ref) or (ref-min-history ref n)
+	refMinHistory, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 || len(vs) > 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, err := asRef("ref-min-history", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if len(vs) == 1 {
+			return vm.Int(r.MinHistory()), nil
+		}
+		n, ok := vs[1].(vm.Int)
+		if !ok {
+			return vm.NIL, fmt.Errorf("ref-min-history expected Int")
+		}
+		r.SetMinHistory(int(n))
+		return r, nil
+	})
+
+	// ref-max-history — (ref-max-history ref) or (ref-max-history ref n)
+	refMaxHistory, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 1 || len(vs) > 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, err := asRef("ref-max-history", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		if len(vs) == 1 {
+			return vm.Int(r.MaxHistory()), nil
+		}
+		n, ok := vs[1].(vm.Int)
+		if !ok {
+			return vm.NIL, fmt.Errorf("ref-max-history expected Int")
+		}
+		r.SetMaxHistory(int(n))
+		return r, nil
+	})
+
+	// set-validator! — (set-validator! ref f), nil f removes the validator
+	setValidator, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, ok := vs[0].(vm.Validatable)
+		if !ok {
+			return vm.NIL, fmt.Errorf("set-validator! expected a reference, got %s", vs[0].Type().Name())
+		}
+		if vs[1] == vm.NIL {
+			return vm.NIL, r.SetValidator(nil)
+		}
+		fn, ok := vs[1].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("set-validator! expected Fn")
+		}
+		return vm.NIL, r.SetValidator(fn)
+	})
+
+	getValidator, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		r, ok := vs[0].(vm.Validatable)
+		if !ok {
+			return vm.NIL, fmt.Errorf("get-validator expected a reference, got %s", vs[0].Type().Name())
+		}
+		if fn := r.Validator(); fn != nil {
+			return fn, nil
+		}
+		return vm.NIL, nil
+	})
+
+	ns.Def("ref", reff)
+	ns.Def("sync*", syncf)
+	ns.Def("ref-set", refSet)
+	ns.Def("alter", alter)
+	ns.Def("commute", commute)
+	ns.Def("ensure", ensure)
+	ns.Def("in-transaction?", inTransaction)
+	ns.Def("ref-history-count", refHistoryCount)
+	ns.Def("ref-min-history", refMinHistory)
+	ns.Def("ref-max-history", refMaxHistory)
+	ns.Def("set-validator!", setValidator)
+	ns.Def("get-validator", getValidator)
+}
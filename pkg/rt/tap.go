@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// tapQueueSize is how many values tap> queues before it starts dropping them.
+const tapQueueSize = 1024
+
+type tapFn struct {
+	fn       vm.Value // as given to add-tap, for remove-tap
+	conveyed vm.Fn    // called in the runtime and bindings of add-tap's caller
+}
+
+// tapSet holds the taps of a runtime. Values sent with tap> are handed to
+// every tap, one at a time, on a single goroutine.
+type tapSet struct {
+	mu    sync.Mutex
+	fns   []tapFn
+	queue chan vm.Value
+	once  sync.Once
+}
+
+func newTapSet() *tapSet {
+	return &tapSet{queue: make(chan vm.Value, tapQueueSize)}
+}
+
+func sameValue(a, b vm.Value) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.TypeOf(a).Comparable() && a == b
+}
+
+// AddTap adds fn to the tap set of the current runtime, unless it's there
+// already.
+func AddTap(fn vm.Fn) {
+	taps := Current().taps
+	taps.mu.Lock()
+	defer taps.mu.Unlock()
+	for _, t := range taps.fns {
+		if sameValue(t.fn, fn) {
+			return
+		}
+	}
+	taps.fns = append(taps.fns, tapFn{fn: fn, conveyed: vm.BindingConveyor(fn)})
+	taps.once.Do(func() { go taps.loop() })
+}
+
+// RemoveTap removes fn from the tap set of the current runtime.
+func RemoveTap(fn vm.Value) {
+	taps := Current().taps
+	taps.mu.Lock()
+	defer taps.mu.Unlock()
+	for i, t := range taps.fns {
+		if sameValue(t.fn, fn) {
+			taps.fns = append(taps.fns[:i:i], taps.fns[i+1:]...)
+			return
+		}
+	}
+}
+
+// Tap sends v to the tap set of the current runtime, returning false if the
+// queue is full.
+func Tap(v vm.Value) bool {
+	select {
+	case Current().taps.queue <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func (taps *tapSet) loop() {
+	for v := range taps.queue {
+		taps.mu.Lock()
+		fns := taps.fns
+		taps.mu.Unlock()
+		for _, t := range fns {
+			// like in Clojure, a failing tap doesn't stop the others
+			t.conveyed.Invoke([]vm.Value{v}) //nolint:errcheck
+		}
+	}
+}
+
+// nolint
+func installTapBuiltins(ns *vm.Namespace) {
+	tap, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		return vm.Boolean(Tap(vs[0])), nil
+	})
+
+	addTap, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		fn, ok := vs[0].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("add-tap expected Fn, got %s", vs[0].Type().Name())
+		}
+		AddTap(fn)
+		return vm.NIL, nil
+	})
+
+	removeTap, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		RemoveTap(vs[0])
+		return vm.NIL, nil
+	})
+
+	ns.Def("tap>", tap)
+	ns.Def("add-tap", addTap)
+	ns.Def("remove-tap", removeTap)
+}
@@ -21,7 +21,7 @@ var termOldState *term.State
 // nolint
 func installTermNS() {
 	ns := vm.NewNamespace("term")
-	ns.Refer(CoreNS, "", true)
+	ns.Refer(CoreNS(), "", true)
 
 	// raw-mode! — enter raw terminal mode, returns true
 	rawMode, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
@@ -24,10 +24,10 @@ import (
 
 func installTermNS() {
 	// Set *in-wasm* so user code can detect WASM environment
-	CoreNS.Lookup("*in-wasm*").(*vm.Var).SetRoot(vm.TRUE)
+	CoreNS().Lookup("*in-wasm*").(*vm.Var).SetRoot(vm.TRUE)
 
 	ns := vm.NewNamespace("term")
-	ns.Refer(CoreNS, "", true)
+	ns.Refer(CoreNS(), "", true)
 
 	// raw-mode! — no-op in WASM (xterm.js is always raw)
 	rawMode, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
@@ -9,7 +9,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/nooga/let-go/pkg/vm"
 )
@@ -181,10 +183,9 @@ func parseTransit(s string) vm.Value {
 		}
 		return vm.String(s)
 	case 'f':
-		// Float as string
-		var f float64
-		if _, err := fmt.Sscanf(s[2:], "%g", &f); err == nil {
-			return vm.Float(f)
+		// Arbitrary precision decimal
+		if d, err := vm.ParseBigDecimal(s[2:]); err == nil {
+			return d
 		}
 		return vm.String(s)
 	case 'b':
@@ -195,6 +196,23 @@ func parseTransit(s string) vm.Value {
 		return vm.FALSE
 	case 'n':
 		return vm.NIL
+	case 'm':
+		// Instant as milliseconds since the epoch
+		if ms, err := strconv.ParseInt(s[2:], 10, 64); err == nil {
+			return vm.NewInst(time.UnixMilli(ms))
+		}
+		return vm.String(s)
+	case 't':
+		// Instant as an RFC 3339 string (verbose mode)
+		if inst, err := vm.ParseInst(s[2:]); err == nil {
+			return inst
+		}
+		return vm.String(s)
+	case 'u':
+		if u, err := vm.ParseUUID(s[2:]); err == nil {
+			return u
+		}
+		return vm.String(s)
 	case '#':
 		// Tag marker - not a final value, but we may encounter it standalone
 		return vm.String(s)
@@ -283,6 +301,23 @@ func (d *TransitDecoder) decodeTagged(tag string, payload interface{}) (vm.Value
 			}
 			return result, nil
 		}
+	case "ratio":
+		if items, ok := payload.([]interface{}); ok && len(items) == 2 {
+			num, err := d.decodeValue(items[0])
+			if err != nil {
+				return vm.NIL, err
+			}
+			den, err := d.decodeValue(items[1])
+			if err != nil {
+				return vm.NIL, err
+			}
+			n, nok := vm.ToBigInt(num)
+			m, mok := vm.ToBigInt(den)
+			if !nok || !mok || m.Sign() == 0 {
+				return vm.NIL, fmt.Errorf("transit: invalid ratio %v", payload)
+			}
+			return vm.NewRatio(new(big.Rat).SetFrac(n, m)), nil
+		}
 	case "'":
 		// Quoted value - unwrap
 		return d.decodeValue(payload)
@@ -401,12 +436,29 @@ func (e *TransitEncoder) encodeValue(v vm.Value) (interface{}, error) {
 	case vm.BigIntType:
 		bi := v.(*vm.BigInt)
 		return "~i" + bi.String(), nil
+	case vm.BigDecimalType:
+		return "~f" + v.(*vm.BigDecimal).Text(), nil
+	case vm.RatioType:
+		r := v.(*vm.Ratio)
+		num, err := e.encodeValue(vm.MaybeDowngrade(r.Numerator()))
+		if err != nil {
+			return nil, err
+		}
+		den, err := e.encodeValue(vm.MaybeDowngrade(r.Denominator()))
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{"~#ratio", []interface{}{num, den}}, nil
+	case vm.InstType:
+		return fmt.Sprintf("~m%d", v.(vm.Inst).Millis()), nil
+	case vm.UUIDType:
+		return "~u" + v.(vm.UUID).Format(), nil
 
 	case vm.ArrayVectorType, vm.PersistentVectorType:
 		return e.encodeSeqAsArray(v)
-	case vm.MapType, vm.PersistentMapType:
+	case vm.MapType, vm.PersistentMapType, vm.SortedMapType:
 		return e.encodeMap(v)
-	case vm.SetType:
+	case vm.SetType, vm.SortedSetType:
 		items, err := e.encodeSeqAsArray(v)
 		if err != nil {
 			return nil, err
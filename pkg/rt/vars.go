@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package rt
+
+import (
+	"fmt"
+
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+func asVar(name string, v vm.Value) (*vm.Var, error) {
+	vr, ok := v.(*vm.Var)
+	if !ok {
+		return nil, fmt.Errorf("%s expected Var, got %s", name, v.Type().Name())
+	}
+	return vr, nil
+}
+
+// nolint
+func installVarBuiltins(ns *vm.Namespace) {
+	// alter-var-root — (alter-var-root v f & args)
+	alterVarRoot, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) < 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		v, err := asVar("alter-var-root", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		fn, ok := vs[1].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("alter-var-root expected Fn")
+		}
+		return v.AlterRoot(fn, vs[2:])
+	})
+
+	// bound? — (bound? & vars) tells whether all vars have a value
+	bound, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		for _, x := range vs {
+			v, err := asVar("bound?", x)
+			if err != nil {
+				return vm.NIL, err
+			}
+			if !v.IsBound() {
+				return vm.FALSE, nil
+			}
+		}
+		return vm.TRUE, nil
+	})
+
+	varGet, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 1 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		v, err := asVar("var-get", vs[0])
+		if err != nil {
+			return vm.NIL, err
+		}
+		return v.Deref(), nil
+	})
+
+	// with-redefs-fn — (with-redefs-fn {var val} f) sets the roots of the
+	// vars while f runs and restores them afterwards, even if f fails. The
+	// new roots are seen by all goroutines.
+	withRedefsFn, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+		if len(vs) != 2 {
+			return vm.NIL, fmt.Errorf("wrong number of arguments %d", len(vs))
+		}
+		bindings, ok := vs[0].(vm.Sequable)
+		if !ok || vs[0] == vm.NIL {
+			return vm.NIL, fmt.Errorf("with-redefs-fn expected a map of vars to values")
+		}
+		fn, ok := vs[1].(vm.Fn)
+		if !ok {
+			return vm.NIL, fmt.Errorf("with-redefs-fn expected Fn")
+		}
+		type redef struct {
+			v   *vm.Var
+			old vm.Value
+		}
+		var redefs []redef
+		defer func() {
+			for i := len(redefs) - 1; i >= 0; i-- {
+				redefs[i].v.SetRoot(redefs[i].old)
+			}
+		}()
+		for s := bindings.Seq(); s != nil && s != vm.EmptyList; s = s.Next() {
+			kv, ok := s.First().(vm.ArrayVector)
+			if !ok || len(kv) != 2 {
+				return vm.NIL, fmt.Errorf("with-redefs-fn expected a map of vars to values")
+			}
+			v, err := asVar("with-redefs-fn", kv[0])
+			if err != nil {
+				return vm.NIL, err
+			}
+			old := v.Root()
+			if err := v.BindRoot(kv[1]); err != nil {
+				return vm.NIL, err
+			}
+			redefs = append(redefs, redef{v, old})
+		}
+		return fn.Invoke(nil)
+	})
+
+	ns.Def("alter-var-root", alterVarRoot)
+	ns.Def("bound?", bound)
+	ns.Def("var-get", varGet)
+	ns.Def("with-redefs-fn", withRedefsFn)
+}
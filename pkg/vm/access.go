@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AccessPolicy restricts the vars visible to code evaluated under Limits.
+// Entries are either namespace names ("string") or qualified var names
+// ("core/slurp"). A var is visible when it, or its namespace, is allowed and
+// the var is not denied. Namespaces created while the policy is in effect
+// are always visible, so scripts can define their own.
+type AccessPolicy struct {
+	namespaces map[string]bool
+	vars       map[string]bool
+	denied     map[string]bool
+}
+
+// NewAccessPolicy returns a policy allowing the given namespaces and vars.
+func NewAccessPolicy(allow ...string) *AccessPolicy {
+	p := &AccessPolicy{
+		namespaces: map[string]bool{},
+		vars:       map[string]bool{},
+		denied:     map[string]bool{},
+	}
+	return p.Allow(allow...)
+}
+
+// Allow adds namespaces or qualified vars to the policy.
+func (p *AccessPolicy) Allow(names ...string) *AccessPolicy {
+	for _, n := range names {
+		if strings.Contains(n, "/") && n != "/" {
+			p.vars[n] = true
+			delete(p.denied, n)
+		} else {
+			p.namespaces[n] = true
+		}
+	}
+	return p
+}
+
+// Deny hides qualified vars even if their namespace is allowed.
+func (p *AccessPolicy) Deny(vars ...string) *AccessPolicy {
+	for _, n := range vars {
+		p.denied[n] = true
+		delete(p.vars, n)
+	}
+	return p
+}
+
+func (p *AccessPolicy) allows(ns *Namespace, nsName string, name string) bool {
+	qualified := nsName + "/" + name
+	if p.denied[qualified] {
+		return false
+	}
+	if ns != nil && ns.owner == p {
+		return true
+	}
+	return p.namespaces[nsName] || p.vars[qualified]
+}
+
+// Allows tells whether code running under p may refer to v.
+func (p *AccessPolicy) Allows(v *Var) bool {
+	return p.allows(v.nsref, v.ns, v.name)
+}
+
+// currentPolicy returns the access policy of the evaluation running on the
+// calling goroutine, if any.
+func currentPolicy() *AccessPolicy {
+	if envsActive.Load() == 0 {
+		return nil
+	}
+	env := currentEnv()
+	if env == nil {
+		return nil
+	}
+	return env.budget.limits.Access
+}
+
+// CheckAccess returns an error if the evaluation running on the calling
+// goroutine is not allowed to refer to v.
+func CheckAccess(v *Var) error {
+	if p := currentPolicy(); p != nil && !p.Allows(v) {
+		return NewExecutionError(fmt.Sprintf("access to %s/%s is not allowed", v.ns, v.name))
+	}
+	return nil
+}
+
+// CheckNamespace returns an error if the evaluation running on the calling
+// goroutine is not allowed to change ns as a whole, e.g. remove it. Only the
+// namespaces it created or which are allowed entirely may be changed.
+func CheckNamespace(ns *Namespace) error {
+	if p := currentPolicy(); p != nil && ns.owner != p && !p.namespaces[ns.name] {
+		return NewExecutionError(fmt.Sprintf("access to namespace %s is not allowed", ns.name))
+	}
+	return nil
+}
+
+// CheckDef returns an error if the evaluation running on the calling
+// goroutine is not allowed to define name in ns.
+func CheckDef(ns *Namespace, name Symbol) error {
+	if p := currentPolicy(); p != nil && !p.allows(ns, ns.name, string(name)) {
+		return NewExecutionError(fmt.Sprintf("access to %s/%s is not allowed", ns.name, name))
+	}
+	return nil
+}
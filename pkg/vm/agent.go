@@ -0,0 +1,402 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type theAgentType struct{}
+
+func (t *theAgentType) String() string     { return t.Name() }
+func (t *theAgentType) Type() ValueType    { return TypeType }
+func (t *theAgentType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theAgentType) Name() string       { return "let-go.lang.Agent" }
+
+func (t *theAgentType) Box(b interface{}) (Value, error) {
+	val, err := BoxValue(reflect.ValueOf(b))
+	if err != nil {
+		return NIL, err
+	}
+	return NewAgent(val), nil
+}
+
+var AgentType *theAgentType = &theAgentType{}
+
+// AgentErrorMode tells what an agent does when an action fails.
+type AgentErrorMode int
+
+const (
+	// AgentFail stops the agent until restart-agent is called.
+	AgentFail AgentErrorMode = iota
+	// AgentContinue drops the failed action and goes on with the next one.
+	AgentContinue
+)
+
+// agentAction is a queued call of fn on the state of an agent. Actions
+// without fn only signal done when they're reached, or with err set when
+// the agent fails before, see Await.
+type agentAction struct {
+	fn   Fn
+	args []Value
+	solo bool // sent with send-off, may block
+	done chan struct{}
+	err  error
+}
+
+// sendPool bounds the number of actions dispatched with send that run at
+// once. Actions sent with send-off are not bounded.
+var sendPool = make(chan struct{}, runtime.GOMAXPROCS(0)+2)
+
+// heldSends maps goroutines running an agent action to the sends that action
+// made. They're dispatched once the action completes.
+var heldSends sync.Map
+
+// Agent is a reference whose state changes asynchronously, by applying
+// actions one at a time in the order they were sent.
+type Agent struct {
+	mu       sync.Mutex
+	state    Value
+	queue    []*agentAction
+	running  bool
+	err      error
+	errValue Value
+
+	errorMode    AgentErrorMode
+	errorHandler Fn
+
+	meta      Value
+	watches   map[Value]Fn
+	validator Fn
+}
+
+func NewAgent(state Value) *Agent {
+	return &Agent{state: state}
+}
+
+func (a *Agent) Deref() Value {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.state
+}
+
+// Send queues fn to be applied to the state of a. Sends made inside a
+// transaction are held until it commits, and sends made inside an agent
+// action are held until the action completes.
+func (a *Agent) Send(fn Fn, args []Value, solo bool) error {
+	if err := a.Error(); err != nil {
+		return NewExecutionError("agent is failed, needs restart").Wrap(err)
+	}
+	act := &agentAction{fn: fn, args: args, solo: solo}
+	if tx := currentTransaction(); tx != nil {
+		tx.AfterCommit(func() { a.dispatch(act) })
+		return nil
+	}
+	a.dispatch(act)
+	return nil
+}
+
+func (a *Agent) dispatch(act *agentAction) {
+	if held, ok := heldSends.Load(gid()); ok {
+		h := held.(*[]func())
+		*h = append(*h, func() { a.enqueue(act) })
+		return
+	}
+	a.enqueue(act)
+}
+
+func (a *Agent) enqueue(act *agentAction) {
+	a.mu.Lock()
+	if act.fn == nil && a.err != nil {
+		act.err = a.err
+		close(act.done)
+		a.mu.Unlock()
+		return
+	}
+	a.queue = append(a.queue, act)
+	start := !a.running && a.err == nil
+	if start {
+		a.running = true
+	}
+	a.mu.Unlock()
+	if start {
+		go a.process()
+	}
+}
+
+// process runs queued actions until the queue is empty or an action fails
+// in AgentFail mode.
+func (a *Agent) process() {
+	for {
+		a.mu.Lock()
+		if len(a.queue) == 0 || a.err != nil {
+			a.running = false
+			a.mu.Unlock()
+			return
+		}
+		act := a.queue[0]
+		a.queue = a.queue[1:]
+		a.mu.Unlock()
+
+		if act.fn == nil {
+			close(act.done)
+			continue
+		}
+		if !act.solo {
+			sendPool <- struct{}{}
+		}
+		a.run(act)
+		if !act.solo {
+			<-sendPool
+		}
+	}
+}
+
+func (a *Agent) run(act *agentAction) {
+	id := gid()
+	var held []func()
+	heldSends.Store(id, &held)
+	old := a.Deref()
+	state, err := act.fn.Invoke(append([]Value{old}, act.args...))
+	if err == nil {
+		err = validate(a.Validator(), state)
+	}
+	heldSends.Delete(id)
+
+	if err != nil {
+		a.fail(err)
+		return
+	}
+	a.mu.Lock()
+	a.state = state
+	a.mu.Unlock()
+	a.notifyWatches(old, state)
+	for _, send := range held {
+		send()
+	}
+}
+
+func (a *Agent) fail(err error) {
+	a.mu.Lock()
+	handler, mode := a.errorHandler, a.errorMode
+	if mode == AgentFail {
+		a.err = err
+		a.errValue = errorToValue(err)
+		// awaits can't be reached until a restart, which may drop them
+		queue := a.queue[:0]
+		for _, act := range a.queue {
+			if act.fn != nil {
+				queue = append(queue, act)
+				continue
+			}
+			act.err = err
+			close(act.done)
+		}
+		a.queue = queue
+	}
+	a.mu.Unlock()
+	if handler != nil {
+		handler.Invoke([]Value{a, errorToValue(err)})
+	}
+}
+
+// Error returns the error that stopped a, or nil.
+func (a *Agent) Error() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// ErrorValue returns the error that stopped a as a catchable value, or NIL.
+func (a *Agent) ErrorValue() Value {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err == nil {
+		return NIL
+	}
+	return a.errValue
+}
+
+// Restart sets the state of a failed agent and lets it process actions
+// again, or drops the queued ones when clear is set.
+func (a *Agent) Restart(state Value, clear bool) error {
+	if a.Error() == nil {
+		return NewExecutionError("agent does not need a restart")
+	}
+	if err := validate(a.Validator(), state); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.state = state
+	a.err = nil
+	a.errValue = nil
+	if clear {
+		a.queue = nil
+	}
+	start := !a.running && len(a.queue) > 0
+	if start {
+		a.running = true
+	}
+	a.mu.Unlock()
+	if start {
+		go a.process()
+	}
+	return nil
+}
+
+// Await blocks until all actions sent to a so far have run. It gives up
+// after timeout, if positive, and tells whether it didn't. It fails if a
+// is failed or fails before the actions have run.
+func (a *Agent) Await(timeout time.Duration) (bool, error) {
+	if _, ok := heldSends.Load(gid()); ok {
+		return false, NewExecutionError("can't await in agent action")
+	}
+	done := make(chan struct{})
+	act := &agentAction{done: done}
+	a.enqueue(act)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	select {
+	case <-done:
+		if act.err != nil {
+			return false, NewExecutionError("agent is failed, needs restart").Wrap(act.err)
+		}
+		return true, nil
+	case <-expired:
+		return false, nil
+	case <-Done():
+		return false, Stopped()
+	}
+}
+
+// ReleasePendingSends dispatches the sends held by the current agent action
+// right away and returns their number.
+func ReleasePendingSends() int {
+	held, ok := heldSends.Load(gid())
+	if !ok {
+		return 0
+	}
+	h := held.(*[]func())
+	sends := *h
+	*h = nil
+	for _, send := range sends {
+		send()
+	}
+	return len(sends)
+}
+
+func (a *Agent) SetErrorMode(mode AgentErrorMode) {
+	a.mu.Lock()
+	a.errorMode = mode
+	a.mu.Unlock()
+}
+
+func (a *Agent) ErrorMode() AgentErrorMode {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.errorMode
+}
+
+func (a *Agent) SetErrorHandler(fn Fn) {
+	a.mu.Lock()
+	a.errorHandler = fn
+	a.mu.Unlock()
+}
+
+func (a *Agent) ErrorHandler() Fn {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.errorHandler
+}
+
+// SetValidator installs fn as the validator of a. The current state has to
+// pass it. A nil fn removes the validator.
+func (a *Agent) SetValidator(fn Fn) error {
+	if err := validate(fn, a.Deref()); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.validator = fn
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *Agent) Validator() Fn {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.validator
+}
+
+func (a *Agent) AddWatch(key Value, fn Fn) {
+	a.mu.Lock()
+	if a.watches == nil {
+		a.watches = make(map[Value]Fn)
+	}
+	a.watches[key] = fn
+	a.mu.Unlock()
+}
+
+func (a *Agent) RemoveWatch(key Value) {
+	a.mu.Lock()
+	delete(a.watches, key)
+	a.mu.Unlock()
+}
+
+func (a *Agent) notifyWatches(oldVal, newVal Value) {
+	a.mu.Lock()
+	watches := make(map[Value]Fn, len(a.watches))
+	for k, fn := range a.watches {
+		watches[k] = fn
+	}
+	a.mu.Unlock()
+	for key, fn := range watches {
+		fn.Invoke([]Value{key, a, oldVal, newVal})
+	}
+}
+
+func (a *Agent) Meta() Value {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.meta == nil {
+		return NIL
+	}
+	return a.meta
+}
+
+// ResetMeta replaces the metadata of a in place, agents have identity.
+func (a *Agent) ResetMeta(m Value) {
+	a.mu.Lock()
+	a.meta = m
+	a.mu.Unlock()
+}
+
+func (a *Agent) AlterMeta(fn Fn, args []Value) (Value, error) {
+	newMeta, err := fn.Invoke(append([]Value{a.Meta()}, args...))
+	if err != nil {
+		return NIL, err
+	}
+	a.ResetMeta(newMeta)
+	return newMeta, nil
+}
+
+func (a *Agent) Type() ValueType { return AgentType }
+
+func (a *Agent) Unbox() interface{} {
+	return a.Deref().Unbox()
+}
+
+func (a *Agent) String() string {
+	if a.Error() != nil {
+		return fmt.Sprintf("<%s FAILED %s>", AgentType, a.Deref())
+	}
+	return fmt.Sprintf("<%s %s>", AgentType, a.Deref())
+}
@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAgentActionsRunInOrder(t *testing.T) {
+	a := NewAgent(EmptyList)
+	conj := fnOf(func(args []Value) (Value, error) {
+		return args[0].(*List).Cons(args[1]), nil
+	})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := a.Send(conj, []Value{Int(i)}, w%2 == 0); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ok, err := a.Await(time.Second); !ok || err != nil {
+		t.Fatalf("await: %v %v", ok, err)
+	}
+	if n := a.Deref().(*List).Count(); n != Int(200) {
+		t.Errorf("got %v actions, want 200", n)
+	}
+}
+
+func TestAgentHoldsSendsUntilActionCompletes(t *testing.T) {
+	log := NewAgent(Int(0))
+	a := NewAgent(NIL)
+	inc := fnOf(func(args []Value) (Value, error) { return args[0].(Int) + 1, nil })
+	a.Send(fnOf(func(args []Value) (Value, error) {
+		log.Send(inc, nil, false)
+		time.Sleep(10 * time.Millisecond)
+		return log.Deref(), nil
+	}), nil, false)
+	a.Await(time.Second)
+	log.Await(time.Second)
+	if a.Deref() != Int(0) || log.Deref() != Int(1) {
+		t.Errorf("got %v and %v, want 0 and 1", a.Deref(), log.Deref())
+	}
+}
+
+func TestAgentErrorModes(t *testing.T) {
+	boom := fnOf(func([]Value) (Value, error) { return NIL, NewExecutionError("boom") })
+	inc := fnOf(func(args []Value) (Value, error) { return args[0].(Int) + 1, nil })
+
+	a := NewAgent(Int(1))
+	a.Send(boom, nil, false)
+	if ok, err := a.Await(time.Second); ok || err == nil {
+		t.Error("await on a failing agent should fail")
+	}
+	if a.Error() == nil {
+		t.Fatal("expected the agent to fail")
+	}
+	if _, err := a.Await(0); err == nil {
+		t.Error("await on a failed agent should fail")
+	}
+	if err := a.Send(inc, nil, false); err == nil {
+		t.Error("send to a failed agent should fail")
+	}
+	if err := a.Restart(Int(5), false); err != nil {
+		t.Fatal(err)
+	}
+	a.Send(inc, nil, false)
+	a.Await(time.Second)
+	if a.Deref() != Int(6) {
+		t.Errorf("got %v, want 6", a.Deref())
+	}
+
+	c := NewAgent(Int(1))
+	c.SetErrorMode(AgentContinue)
+	c.Send(boom, nil, false)
+	c.Send(inc, nil, false)
+	c.Await(time.Second)
+	if c.Error() != nil || c.Deref() != Int(2) {
+		t.Errorf("got %v %v, want 2 and no error", c.Deref(), c.Error())
+	}
+}
@@ -36,8 +36,9 @@ type Atom struct {
 	val      Value
 	gen      uint64 // generation counter — incremented on every mutation
 	mu       sync.Mutex
-	meta     Value
-	watches  map[Value]Fn // key → watch fn
+	meta      Value
+	watches   map[Value]Fn // key → watch fn
+	validator Fn
 }
 
 func NewAtom(root Value) *Atom {
@@ -68,6 +69,24 @@ func (a *Atom) RemoveWatch(key Value) {
 	a.mu.Unlock()
 }
 
+// SetValidator installs fn as the validator of a. The current value has to
+// pass it. A nil fn removes the validator.
+func (a *Atom) SetValidator(fn Fn) error {
+	if err := validate(fn, a.Deref()); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.validator = fn
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *Atom) Validator() Fn {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.validator
+}
+
 func (a *Atom) Meta() Value {
 	if a.meta == nil {
 		return NIL
@@ -78,7 +97,7 @@ func (a *Atom) Meta() Value {
 func (a *Atom) WithMeta(m Value) Value {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	return &Atom{val: a.val, gen: a.gen, meta: m, watches: a.watches}
+	return &Atom{val: a.val, gen: a.gen, meta: m, watches: a.watches, validator: a.validator}
 }
 
 func (a *Atom) AlterMeta(fn Fn, args []Value) (Value, error) {
@@ -97,7 +116,10 @@ func (a *Atom) AlterMeta(fn Fn, args []Value) (Value, error) {
 	return newMeta, nil
 }
 
-func (a *Atom) Reset(newVal Value) Value {
+func (a *Atom) Reset(newVal Value) (Value, error) {
+	if err := validate(a.Validator(), newVal); err != nil {
+		return NIL, err
+	}
 	a.mu.Lock()
 	oldVal := a.val
 	a.val = newVal
@@ -107,7 +129,7 @@ func (a *Atom) Reset(newVal Value) Value {
 	if len(watches) > 0 {
 		a.notifyWatches(oldVal, newVal)
 	}
-	return newVal
+	return newVal, nil
 }
 
 // Swap applies fn to the current value and atomically sets the result.
@@ -126,6 +148,9 @@ func (a *Atom) Swap(fn Fn, args []Value) (Value, error) {
 		if err != nil {
 			return NIL, err
 		}
+		if err := validate(a.Validator(), newVal); err != nil {
+			return NIL, err
+		}
 
 		// Try to set — only if generation hasn't changed
 		a.mu.Lock()
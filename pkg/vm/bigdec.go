@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type theBigDecimalType struct{}
+
+func (t *theBigDecimalType) String() string     { return t.Name() }
+func (t *theBigDecimalType) Type() ValueType    { return TypeType }
+func (t *theBigDecimalType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theBigDecimalType) Name() string       { return "let-go.lang.BigDecimal" }
+
+func (t *theBigDecimalType) Box(bare interface{}) (Value, error) {
+	switch v := bare.(type) {
+	case string:
+		return ParseBigDecimal(v)
+	case *big.Int:
+		return NewBigDecimal(v, 0), nil
+	}
+	return NIL, NewTypeError(bare, "can't be boxed as", t)
+}
+
+var BigDecimalType *theBigDecimalType = &theBigDecimalType{}
+
+// BigDecimal is an arbitrary-precision decimal number, unscaled × 10^-scale,
+// modelled after java.math.BigDecimal.
+type BigDecimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+func NewBigDecimal(unscaled *big.Int, scale int) *BigDecimal {
+	return &BigDecimal{unscaled: unscaled, scale: scale}
+}
+
+// ParseBigDecimal parses decimal notation with an optional exponent, like
+// "1.50" or "-2.5e-3".
+func ParseBigDecimal(s string) (*BigDecimal, error) {
+	mant, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(strings.TrimPrefix(s[i+1:], "+"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal: %s", s)
+		}
+		mant, exp = s[:i], e
+	}
+	scale := 0
+	if i := strings.IndexByte(mant, '.'); i >= 0 {
+		scale = len(mant) - i - 1
+		mant = mant[:i] + mant[i+1:]
+	}
+	if mant == "" || mant == "-" || mant == "+" || strings.ContainsAny(mant[1:], "+-") {
+		return nil, fmt.Errorf("invalid decimal: %s", s)
+	}
+	u, ok := new(big.Int).SetString(mant, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal: %s", s)
+	}
+	return &BigDecimal{unscaled: u, scale: scale - exp}, nil
+}
+
+func (d *BigDecimal) Unscaled() *big.Int { return d.unscaled }
+func (d *BigDecimal) Scale() int         { return d.scale }
+
+func (d *BigDecimal) Type() ValueType    { return BigDecimalType }
+func (d *BigDecimal) Unbox() interface{} { return d }
+
+// String prints d as a literal with the M suffix.
+func (d *BigDecimal) String() string {
+	return d.Text() + "M"
+}
+
+// Text formats d like java.math.BigDecimal.toString: plain notation unless
+// the exponent is large, then scientific notation.
+func (d *BigDecimal) Text() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	sign := ""
+	if d.unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	adjusted := len(digits) - 1 - d.scale
+	if d.scale >= 0 && adjusted >= -6 {
+		if d.scale == 0 {
+			return sign + digits
+		}
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		point := len(digits) - d.scale
+		return sign + digits[:point] + "." + digits[point:]
+	}
+	b := strings.Builder{}
+	b.WriteString(sign)
+	b.WriteString(digits[:1])
+	if len(digits) > 1 {
+		b.WriteByte('.')
+		b.WriteString(digits[1:])
+	}
+	b.WriteByte('E')
+	if adjusted >= 0 {
+		b.WriteByte('+')
+	}
+	b.WriteString(strconv.Itoa(adjusted))
+	return b.String()
+}
+
+// Rat returns the exact value of d as a fraction.
+func (d *BigDecimal) Rat() *big.Rat {
+	r := new(big.Rat).SetInt(d.unscaled)
+	if d.scale > 0 {
+		return r.Quo(r, new(big.Rat).SetInt(pow10(d.scale)))
+	}
+	if d.scale < 0 {
+		return r.Mul(r, new(big.Rat).SetInt(pow10(-d.scale)))
+	}
+	return r
+}
+
+func (d *BigDecimal) Float64() float64 {
+	f, _ := d.Rat().Float64()
+	return f
+}
+
+func (d *BigDecimal) Hash() uint32 {
+	return hashRat(d.Rat())
+}
+
+// Equals tells whether other is a number equal to d. Scale doesn't matter:
+// 1.0M equals 1.00M.
+func (d *BigDecimal) Equals(other Value) bool {
+	return NumEq(d, other)
+}
+
+// withScale returns d rescaled to scale, which must not be lower than
+// d's own scale.
+func (d *BigDecimal) withScale(scale int) *BigDecimal {
+	if scale == d.scale {
+		return d
+	}
+	u := new(big.Int).Mul(d.unscaled, pow10(scale-d.scale))
+	return &BigDecimal{unscaled: u, scale: scale}
+}
+
+// stripZeros removes trailing zeros from d, but not past the scale floor.
+func (d *BigDecimal) stripZeros(floor int) *BigDecimal {
+	u, scale := new(big.Int).Set(d.unscaled), d.scale
+	if u.Sign() == 0 {
+		return &BigDecimal{unscaled: u, scale: floor}
+	}
+	ten, q, r := big.NewInt(10), new(big.Int), new(big.Int)
+	for scale > floor {
+		q.QuoRem(u, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		u.Set(q)
+		scale--
+	}
+	return &BigDecimal{unscaled: u, scale: scale}
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// numDigits returns the number of decimal digits in |n|.
+func numDigits(n *big.Int) int {
+	if n.Sign() == 0 {
+		return 1
+	}
+	return len(new(big.Int).Abs(n).String())
+}
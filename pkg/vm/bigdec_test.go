@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseBigDecimal(t *testing.T) {
+	cases := map[string]string{
+		"1.50":     "1.50",
+		"-2.5e-3":  "-0.0025",
+		"+7":       "7",
+		"1e3":      "1E+3",
+		"12.3E+1":  "123",
+		"0.000001": "0.000001",
+		"1e-7":     "1E-7",
+	}
+	for in, want := range cases {
+		d, err := ParseBigDecimal(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if got := d.Text(); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+	for _, bad := range []string{"", "-", "1.2.3", "1e", "1-2", "abc"} {
+		if _, err := ParseBigDecimal(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestBigDecimalDivision(t *testing.T) {
+	defer SetMathContextLookup(func() *MathContext { return nil })
+
+	one, _ := ParseBigDecimal("1")
+	if _, err := NumDiv(one, Int(3)); err == nil {
+		t.Error("expected non-terminating division to fail without a math context")
+	}
+	q, err := NumDiv(one, Int(8))
+	if err != nil || q.String() != "0.125M" {
+		t.Errorf("got %v (%v), want 0.125M", q, err)
+	}
+
+	cases := []struct {
+		mc   MathContext
+		a, b string
+		want string
+	}{
+		{MathContext{5, RoundHalfUp}, "2", "3", "0.66667M"},
+		{MathContext{5, RoundDown}, "2", "3", "0.66666M"},
+		{MathContext{2, RoundHalfEven}, "0.25", "1", "0.25M"},
+		{MathContext{1, RoundHalfEven}, "0.25", "1", "0.2M"},
+		{MathContext{1, RoundHalfUp}, "0.25", "1", "0.3M"},
+		{MathContext{3, RoundCeiling}, "-10", "3", "-3.33M"},
+		{MathContext{3, RoundFloor}, "-10", "3", "-3.34M"},
+	}
+	for _, c := range cases {
+		mc := c.mc
+		SetMathContextLookup(func() *MathContext { return &mc })
+		a, _ := ParseBigDecimal(c.a)
+		b, _ := ParseBigDecimal(c.b)
+		got, err := NumDiv(a, b)
+		if err != nil {
+			t.Errorf("%s/%s: %v", c.a, c.b, err)
+			continue
+		}
+		if got.String() != c.want {
+			t.Errorf("%s/%s with %v: got %s, want %s", c.a, c.b, c.mc, got, c.want)
+		}
+	}
+}
+
+func TestRatioNormalization(t *testing.T) {
+	if v := NewRatio(big.NewRat(6, 3)); v != Int(2) {
+		t.Errorf("got %v, want 2", v)
+	}
+	v, err := NumDiv(Int(2), Int(-6))
+	if err != nil || v.String() != "-1/3" {
+		t.Errorf("got %v (%v), want -1/3", v, err)
+	}
+	half, _ := ParseBigDecimal("0.50")
+	r := NewRatio(big.NewRat(1, 2)).(*Ratio)
+	if !NumEq(r, half) || r.Hash() != half.Hash() {
+		t.Error("1/2 and 0.50M should be equal and hash alike")
+	}
+	if r.Hash() == NewRatio(big.NewRat(1, 3)).(*Ratio).Hash() {
+		t.Error("1/2 and 1/3 should hash differently")
+	}
+}
+
+func TestFloatsEqualAndHashLikeExactNumbers(t *testing.T) {
+	exact := func(f float64) Value {
+		return NewRatio(new(big.Rat).SetFloat64(f))
+	}
+	for _, f := range []float64{0, 1, -3, 0.5, -0.375, 1e300, 1e-300, 0x1p-70, 0x1p40 + 0.25} {
+		x := exact(f)
+		if !NumEq(Float(f), x) || !NumEq(x, Float(f)) {
+			t.Errorf("%v should equal %v", f, x)
+		}
+		if Float(f).Hash() != hashValue(x) {
+			t.Errorf("%v should hash like %v", f, x)
+		}
+	}
+	big2, _ := NewBigIntFromString("9007199254740993")
+	if NumEq(Float(9007199254740992), Int(9007199254740993)) || NumEq(Float(9007199254740992), big2) {
+		t.Error("floats should only equal integers they are exactly")
+	}
+	tenth := NewRatio(big.NewRat(1, 10))
+	if NumEq(Float(0.1), tenth) || !NumEquiv(Float(0.1), tenth) {
+		t.Error("0.1 should be == but not = to 1/10")
+	}
+}
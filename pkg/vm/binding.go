@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// bindingCell holds a single dynamic binding. Cells are shared between a frame
+// and every frame conveyed from it, so set! is only allowed from the goroutine
+// that established the binding, and the value is swapped atomically for the
+// others to read.
+type bindingCell struct {
+	val   atomic.Pointer[Value]
+	owner uint64
+}
+
+func newBindingCell(val Value, owner uint64) *bindingCell {
+	c := &bindingCell{owner: owner}
+	c.val.Store(&val)
+	return c
+}
+
+func (c *bindingCell) get() Value {
+	return *c.val.Load()
+}
+
+// BindingFrame is an immutable set of dynamic var bindings, chained to the
+// frame that was current when it was pushed, which holds the bindings it
+// doesn't shadow. Each goroutine has its own current frame.
+type BindingFrame struct {
+	bindings map[*Var]*bindingCell
+	prev     *BindingFrame // looked up for vars not bound here
+	restore  *BindingFrame // made current again when this frame is popped
+}
+
+func (f *BindingFrame) lookup(v *Var) *bindingCell {
+	for ; f != nil; f = f.prev {
+		if c, ok := f.bindings[v]; ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// count adds n to the live binding count of every var bound in f, and in
+// the frames it's chained to if chain is set.
+func (f *BindingFrame) count(n int32, chain bool) {
+	for ; f != nil; f = f.prev {
+		for v := range f.bindings {
+			v.bindings.Add(n)
+		}
+		if !chain {
+			return
+		}
+	}
+}
+
+// threadFrames maps goroutines to their current binding frame. Goroutines
+// without bindings have no entry.
+var threadFrames sync.Map
+
+// framesLive counts the entries of threadFrames, so that goroutines don't
+// look for a frame while no goroutine has one.
+var framesLive atomic.Int32
+
+func currentFrame() *BindingFrame {
+	if framesLive.Load() == 0 {
+		return nil
+	}
+	f, ok := threadFrames.Load(gid())
+	if !ok {
+		return nil
+	}
+	return f.(*BindingFrame)
+}
+
+// switchFrame makes f the current frame of the calling goroutine in place of
+// prev, keeping the live binding counts of vars in step: a var's count is the
+// number of goroutines whose current frame chain binds it, so that vars
+// nobody binds right now are dereferenced without looking for bindings.
+func switchFrame(prev, f *BindingFrame) {
+	switch {
+	case f != nil && f.prev == prev && f.restore == prev:
+		f.count(1, false) // push
+	case prev != nil && prev.prev == f && prev.restore == f:
+		prev.count(-1, false) // pop
+	default:
+		f.count(1, true)
+		prev.count(-1, true)
+	}
+	switch {
+	case f == nil:
+		if prev != nil {
+			threadFrames.Delete(gid())
+			framesLive.Add(-1)
+		}
+	case prev == nil:
+		threadFrames.Store(gid(), f)
+		framesLive.Add(1)
+	default:
+		threadFrames.Store(gid(), f)
+	}
+}
+
+// PushThreadBindings establishes new dynamic bindings for the calling goroutine
+// on top of the ones currently in effect.
+func PushThreadBindings(bindings map[*Var]Value) {
+	prev := currentFrame()
+	id := gid()
+	m := make(map[*Var]*bindingCell, len(bindings))
+	for v, val := range bindings {
+		m[v] = newBindingCell(val, id)
+	}
+	switchFrame(prev, &BindingFrame{bindings: m, prev: prev, restore: prev})
+}
+
+// PopThreadBindings discards the most recently pushed bindings of the calling goroutine.
+func PopThreadBindings() error {
+	f := currentFrame()
+	if f == nil {
+		return NewExecutionError("pop without matching push")
+	}
+	switchFrame(f, f.restore)
+	return nil
+}
+
+// GetThreadBindings returns a snapshot of all bindings in effect for the calling goroutine.
+func GetThreadBindings() map[*Var]Value {
+	ret := map[*Var]Value{}
+	for f := currentFrame(); f != nil; f = f.prev {
+		for v, c := range f.bindings {
+			if _, shadowed := ret[v]; !shadowed {
+				ret[v] = c.get()
+			}
+		}
+	}
+	return ret
+}
+
+// CloneThreadBindingFrame captures the bindings in effect for the calling goroutine
+// so they can be installed in another one with ResetThreadBindingFrame.
+// The clone has nothing to restore, so the receiving goroutine can't pop past it.
+func CloneThreadBindingFrame() *BindingFrame {
+	f := currentFrame()
+	if f == nil {
+		return nil
+	}
+	return &BindingFrame{prev: f}
+}
+
+// ResetThreadBindingFrame installs f as the current frame of the calling goroutine
+// and returns the previous one so it can be restored.
+func ResetThreadBindingFrame(f *BindingFrame) *BindingFrame {
+	prev := currentFrame()
+	switchFrame(prev, f)
+	return prev
+}
+
+// BindingConveyor returns a function which runs fn with the bindings that are
+// in effect now, regardless of the goroutine it is eventually called from.
+// The runtime and limits of the current evaluation, if any, are conveyed as well.
+func BindingConveyor(fn Fn) Fn {
+	frame := CloneThreadBindingFrame()
+	env := conveyedEnv()
+	rt := CurrentRuntime()
+	if frame == nil && env == nil && rt == nil {
+		return fn
+	}
+	conveyed := &NativeFn{arity: -1, fn: fn}
+	conveyed.proxy = func(args []Value) (Value, error) {
+		prev := ResetThreadBindingFrame(frame)
+		defer ResetThreadBindingFrame(prev)
+		if rt != nil {
+			defer EnterRuntime(rt)()
+		}
+		if env != nil {
+			defer enterEnv(&execEnv{budget: env.budget})()
+		}
+		return fn.Invoke(args)
+	}
+	return conveyed
+}
+
+// IsThreadBound tells whether v has a dynamic binding in the calling goroutine.
+func (v *Var) IsThreadBound() bool {
+	if v.bindings.Load() == 0 {
+		return false
+	}
+	return currentFrame().lookup(v) != nil
+}
+
+// Set changes the value of v's dynamic binding in the calling goroutine.
+// Unbound vars have their root changed instead.
+func (v *Var) Set(val Value) error {
+	if v.bindings.Load() != 0 {
+		if c := currentFrame().lookup(v); c != nil {
+			if c.owner != gid() {
+				return NewExecutionError(fmt.Sprintf("can't set!: %s from non-binding goroutine", v))
+			}
+			c.val.Store(&val)
+			return nil
+		}
+	}
+	return v.BindRoot(val)
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Compare implements the default ordering used by compare and sorted
+// collections. nil sorts before everything, numbers compare numerically,
+// strings, keywords, symbols and chars lexicographically, false before true,
+// insts chronologically, UUIDs by their bytes and vectors by length first,
+// then element by element.
+func Compare(a, b Value) (int, error) {
+	if a == NIL && b == NIL {
+		return 0, nil
+	}
+	if a == NIL {
+		return -1, nil
+	}
+	if b == NIL {
+		return 1, nil
+	}
+	if IsNumber(a) && IsNumber(b) {
+		lt, err := NumLt(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if lt {
+			return -1, nil
+		}
+		gt, err := NumGt(a, b)
+		if err != nil {
+			return 0, err
+		}
+		if gt {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	switch av := a.(type) {
+	case String:
+		if bv, ok := b.(String); ok {
+			return compareStrings(string(av), string(bv)), nil
+		}
+	case Keyword:
+		if bv, ok := b.(Keyword); ok {
+			return compareStrings(string(av), string(bv)), nil
+		}
+	case Symbol:
+		if bv, ok := b.(Symbol); ok {
+			return compareStrings(string(av), string(bv)), nil
+		}
+	case Char:
+		if bv, ok := b.(Char); ok {
+			return compareStrings(string(av), string(bv)), nil
+		}
+	case Boolean:
+		if bv, ok := b.(Boolean); ok {
+			if av == bv {
+				return 0, nil
+			}
+			if !bool(av) {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case Inst:
+		if bv, ok := b.(Inst); ok {
+			return av.t.Compare(bv.t), nil
+		}
+	case UUID:
+		if bv, ok := b.(UUID); ok {
+			return bytes.Compare(av[:], bv[:]), nil
+		}
+	case ArrayVector, PersistentVector:
+		if bv, ok := vectorItems(b); ok {
+			av, _ := vectorItems(a)
+			if len(av) != len(bv) {
+				return compareInts(len(av), len(bv)), nil
+			}
+			for i := range av {
+				c, err := Compare(av[i], bv[i])
+				if err != nil || c != 0 {
+					return c, err
+				}
+			}
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("compare: cannot compare %s and %s", a.Type().Name(), b.Type().Name())
+}
+
+// CompareWith orders a and b using a let-go comparator. Like in Clojure,
+// comparators may return a number or act as a "less than" predicate.
+func CompareWith(fn Fn, a, b Value) (int, error) {
+	r, err := fn.Invoke([]Value{a, b})
+	if err != nil {
+		return 0, err
+	}
+	switch r := r.(type) {
+	case Int:
+		return compareInts(int(r), 0), nil
+	case Float:
+		if r < 0 {
+			return -1, nil
+		}
+		if r > 0 {
+			return 1, nil
+		}
+		return 0, nil
+	case Boolean:
+		if r {
+			return -1, nil
+		}
+		r2, err := fn.Invoke([]Value{b, a})
+		if err != nil {
+			return 0, err
+		}
+		if IsTruthy(r2) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("comparator returned %s, expected a number or a boolean", r.Type().Name())
+}
+
+func compareStrings(a, b string) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+func vectorItems(v Value) ([]Value, bool) {
+	switch v := v.(type) {
+	case ArrayVector:
+		return v, true
+	case PersistentVector:
+		return v.Unbox().([]Value), true
+	}
+	return nil, false
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// coverage counts how many times each instruction of the chunks compiled
+// while it's active gets executed.
+type coverage struct {
+	mu       sync.Mutex
+	chunks   []*CodeChunk
+	funcs    []*Func
+	counters sync.Map // *CodeChunk → []uint32
+}
+
+var activeCoverage atomic.Pointer[coverage]
+
+// StartCoverage starts recording which parts of let-go code compiled from
+// now on get executed.
+func StartCoverage() error {
+	if !activeCoverage.CompareAndSwap(nil, &coverage{}) {
+		return fmt.Errorf("coverage already being recorded")
+	}
+	return nil
+}
+
+// StopCoverage stops recording and returns line coverage of source files
+// found on disk, ordered by file name.
+func StopCoverage() (*Coverage, error) {
+	cv := activeCoverage.Swap(nil)
+	if cv == nil {
+		return nil, fmt.Errorf("coverage not being recorded")
+	}
+	return cv.report(), nil
+}
+
+// coverChunk registers a newly made chunk with the active coverage, if any.
+func coverChunk(c *CodeChunk) {
+	if cv := activeCoverage.Load(); cv != nil {
+		cv.mu.Lock()
+		cv.chunks = append(cv.chunks, c)
+		cv.mu.Unlock()
+	}
+}
+
+// coverFunc registers a newly made function with the active coverage, if any.
+func coverFunc(f *Func) {
+	if cv := activeCoverage.Load(); cv != nil {
+		cv.mu.Lock()
+		cv.funcs = append(cv.funcs, f)
+		cv.mu.Unlock()
+	}
+}
+
+func (cv *coverage) countersOf(c *CodeChunk) []uint32 {
+	if cs, ok := cv.counters.Load(c); ok {
+		return cs.([]uint32)
+	}
+	cs, _ := cv.counters.LoadOrStore(c, make([]uint32, len(c.code)))
+	return cs.([]uint32)
+}
+
+// hit counts the instruction f is about to execute.
+func (cv *coverage) hit(f *Frame) {
+	if f.covCode != f.code {
+		f.covCode = f.code
+		f.cov = cv.countersOf(f.code)
+	}
+	if f.ip < len(f.cov) {
+		atomic.AddUint32(&f.cov[f.ip], 1)
+	}
+}
+
+// Coverage is line coverage of let-go source files.
+type Coverage struct {
+	Files []FileCoverage
+}
+
+// FileCoverage is the line coverage of a single source file.
+type FileCoverage struct {
+	File  string
+	Lines []LineCoverage // lines with code, in order
+	Funcs []FuncCoverage // functions, in order of their first line
+}
+
+// LineCoverage tells how many times code on a line was run.
+type LineCoverage struct {
+	Line int // 1-based
+	Hits int64
+}
+
+// FuncCoverage is the coverage of a function's own lines, not counting
+// functions nested in it.
+type FuncCoverage struct {
+	Name    string
+	Line    int   // line of the form defining the function, 1-based
+	Calls   int64 // times the function was entered
+	Lines   int   // lines with code
+	Covered int   // lines with code that were run
+}
+
+// Covered returns the number of lines with code that were run.
+func (fc *FileCoverage) Covered() int {
+	n := 0
+	for _, l := range fc.Lines {
+		if l.Hits > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Percent returns the share of covered lines as a percentage.
+func (fc *FileCoverage) Percent() float64 {
+	return percent(fc.Covered(), len(fc.Lines))
+}
+
+// Percent returns the share of covered lines of the function as a percentage.
+func (fc *FuncCoverage) Percent() float64 {
+	return percent(fc.Covered, fc.Lines)
+}
+
+// Totals returns the number of lines covered and with code in all files.
+func (c *Coverage) Totals() (covered int, lines int) {
+	for i := range c.Files {
+		covered += c.Files[i].Covered()
+		lines += len(c.Files[i].Lines)
+	}
+	return covered, lines
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 100
+	}
+	return float64(n) * 100 / float64(of)
+}
+
+type fileLine struct {
+	file string
+	line int
+}
+
+// chunkLines returns the hits of each line with code in c. An instruction
+// belongs to the line of the last source map entry at or before it, so each
+// entry covers the instructions up to the next one.
+func (cv *coverage) chunkLines(c *CodeChunk) map[fileLine]int64 {
+	lines := map[fileLine]int64{}
+	if c.sourceMap == nil {
+		return lines
+	}
+	var counts []uint32
+	if cs, ok := cv.counters.Load(c); ok {
+		counts = cs.([]uint32)
+	}
+	entries := c.sourceMap.entries
+	for i, e := range entries {
+		end := len(c.code)
+		if i+1 < len(entries) {
+			end = entries[i+1].startIP
+		}
+		if end <= e.startIP {
+			continue // superseded by the entry starting at the same place
+		}
+		fl := fileLine{e.info.File, e.info.Line + 1}
+		var hits int64
+		for ip := e.startIP; ip < end && ip < len(counts); ip++ {
+			if int64(counts[ip]) > hits {
+				hits = int64(counts[ip])
+			}
+		}
+		if prev, ok := lines[fl]; !ok || hits > prev {
+			lines[fl] = hits
+		}
+	}
+	return lines
+}
+
+func (cv *coverage) report() *Coverage {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	lines := map[fileLine]int64{}
+	for _, c := range cv.chunks {
+		for fl, hits := range cv.chunkLines(c) {
+			lines[fl] += hits
+		}
+	}
+	files := map[string]*FileCoverage{}
+	exists := map[string]bool{}
+	fileOf := func(name string) *FileCoverage {
+		if ok, seen := exists[name]; seen && !ok {
+			return nil
+		}
+		if fc, ok := files[name]; ok {
+			return fc
+		}
+		st, err := os.Stat(name)
+		exists[name] = err == nil && !st.IsDir()
+		if !exists[name] {
+			return nil
+		}
+		files[name] = &FileCoverage{File: name}
+		return files[name]
+	}
+	for fl, hits := range lines {
+		if fc := fileOf(fl.file); fc != nil {
+			fc.Lines = append(fc.Lines, LineCoverage{Line: fl.line, Hits: hits})
+		}
+	}
+
+	type funcKey struct {
+		file string
+		name string
+	}
+	funcs := map[funcKey]*FuncCoverage{}
+	funcLines := map[funcKey]map[int]int64{}
+	for _, f := range cv.funcs {
+		// only lines of the function's own code count, not those of the
+		// form defining it, which run when it's defined rather than called
+		own := cv.chunkLines(f.chunk)
+		if len(own) == 0 {
+			continue
+		}
+		fk := funcKey{name: fnName(f)}
+		if f.ns != "" {
+			fk.name = f.ns + "/" + fk.name
+		}
+		var first fileLine
+		for fl := range own {
+			if first.line == 0 || fl.line < first.line {
+				first = fl
+			}
+		}
+		if f.defined != nil && f.defined.File == first.file && f.defined.Line+1 <= first.line {
+			first.line = f.defined.Line + 1
+		}
+		fk.file = first.file
+		if fileOf(fk.file) == nil {
+			continue
+		}
+		// arities and redefinitions of a function count as one
+		fn, ok := funcs[fk]
+		if !ok {
+			fn = &FuncCoverage{Name: fk.name, Line: first.line}
+			funcs[fk] = fn
+			funcLines[fk] = map[int]int64{}
+		}
+		if first.line < fn.Line {
+			fn.Line = first.line
+		}
+		if cs, ok := cv.counters.Load(f.chunk); ok && len(cs.([]uint32)) > 0 {
+			fn.Calls += int64(cs.([]uint32)[0])
+		}
+		for fl, hits := range own {
+			if fl.file == fk.file {
+				funcLines[fk][fl.line] += hits
+			}
+		}
+	}
+	for fk, fn := range funcs {
+		for _, hits := range funcLines[fk] {
+			fn.Lines++
+			if hits > 0 {
+				fn.Covered++
+			}
+		}
+		fc := files[fk.file]
+		fc.Funcs = append(fc.Funcs, *fn)
+	}
+
+	out := &Coverage{}
+	for _, fc := range files {
+		sort.Slice(fc.Lines, func(i, j int) bool { return fc.Lines[i].Line < fc.Lines[j].Line })
+		sort.Slice(fc.Funcs, func(i, j int) bool {
+			if fc.Funcs[i].Line != fc.Funcs[j].Line {
+				return fc.Funcs[i].Line < fc.Funcs[j].Line
+			}
+			return fc.Funcs[i].Name < fc.Funcs[j].Name
+		})
+		out.Files = append(out.Files, *fc)
+	}
+	sort.Slice(out.Files, func(i, j int) bool { return out.Files[i].File < out.Files[j].File })
+	return out
+}
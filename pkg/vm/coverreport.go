@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteText writes the coverage of each function and file, in the style of
+// go tool cover -func.
+func (c *Coverage) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, '\t', 0)
+	for i := range c.Files {
+		fc := &c.Files[i]
+		for _, fn := range fc.Funcs {
+			fmt.Fprintf(tw, "%s:%d:\t%s\t%.1f%%\n", fc.File, fn.Line, fn.Name, fn.Percent())
+		}
+		fmt.Fprintf(tw, "%s:\t(%d/%d lines)\t%.1f%%\n", fc.File, fc.Covered(), len(fc.Lines), fc.Percent())
+	}
+	covered, lines := c.Totals()
+	fmt.Fprintf(tw, "total:\t(%d/%d lines)\t%.1f%%\n", covered, lines, percent(covered, lines))
+	return tw.Flush()
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile, understood by genhtml
+// and most CI coverage services.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i := range c.Files {
+		fc := &c.Files[i]
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", fc.File)
+		hit := 0
+		for _, fn := range fc.Funcs {
+			fmt.Fprintf(bw, "FN:%d,%s\n", fn.Line, fn.Name)
+		}
+		for _, fn := range fc.Funcs {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", fn.Calls, fn.Name)
+			if fn.Calls > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(fc.Funcs), hit)
+		for _, l := range fc.Lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", l.Line, l.Hits)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(fc.Lines), fc.Covered())
+	}
+	return bw.Flush()
+}
+
+const coverHTMLHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>let-go coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table.summary td, table.summary th { padding: 0.2em 1em; text-align: left; }
+pre { background: #fafafa; border: 1px solid #ddd; padding: 0.5em; }
+.n { color: #999; user-select: none; }
+.cov { background: #d7f5d7; }
+.miss { background: #f7d4d4; }
+</style>
+</head>
+<body>
+<h1>let-go coverage</h1>
+`
+
+// WriteHTML writes the coverage as a single page, with the source of every
+// file and its covered lines in green and missed ones in red.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(coverHTMLHead)
+	covered, lines := c.Totals()
+	fmt.Fprintf(bw, "<p>%d of %d lines covered (%.1f%%)</p>\n", covered, lines, percent(covered, lines))
+	bw.WriteString("<table class=\"summary\">\n<tr><th>File</th><th>Lines</th><th>Coverage</th></tr>\n")
+	for i := range c.Files {
+		fc := &c.Files[i]
+		fmt.Fprintf(bw, "<tr><td><a href=\"#file%d\">%s</a></td><td>%d/%d</td><td>%.1f%%</td></tr>\n",
+			i, html.EscapeString(fc.File), fc.Covered(), len(fc.Lines), fc.Percent())
+	}
+	bw.WriteString("</table>\n")
+	for i := range c.Files {
+		fc := &c.Files[i]
+		fmt.Fprintf(bw, "<h2 id=\"file%d\">%s</h2>\n", i, html.EscapeString(fc.File))
+		if len(fc.Funcs) > 0 {
+			bw.WriteString("<table class=\"summary\">\n<tr><th>Function</th><th>Calls</th><th>Coverage</th></tr>\n")
+			for _, fn := range fc.Funcs {
+				fmt.Fprintf(bw, "<tr><td><a href=\"#file%d-L%d\">%s</a></td><td>%d</td><td>%.1f%%</td></tr>\n",
+					i, fn.Line, html.EscapeString(fn.Name), fn.Calls, fn.Percent())
+			}
+			bw.WriteString("</table>\n")
+		}
+		hits := make(map[int]int64, len(fc.Lines))
+		for _, l := range fc.Lines {
+			hits[l.Line] = l.Hits
+		}
+		src, ok := SourceRegistry.Get(fc.File)
+		if !ok {
+			data, err := os.ReadFile(fc.File)
+			if err != nil {
+				return err
+			}
+			src = string(data)
+		}
+		bw.WriteString("<pre>")
+		for n, text := range strings.Split(strings.TrimSuffix(src, "\n"), "\n") {
+			n++
+			class := ""
+			if h, ok := hits[n]; ok {
+				class = "miss"
+				if h > 0 {
+					class = "cov"
+				}
+			}
+			fmt.Fprintf(bw, "<span id=\"file%d-L%d\" class=\"%s\"><span class=\"n\">%5d  </span>%s</span>\n",
+				i, n, class, n, html.EscapeString(text))
+		}
+		bw.WriteString("</pre>\n")
+	}
+	bw.WriteString("</body>\n</html>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DebugAction tells a paused goroutine how to go on.
+type DebugAction int
+
+const (
+	DebugContinue DebugAction = iota // run until the next breakpoint
+	DebugStepIn                      // stop at the next line, entering calls
+	DebugStepOver                    // stop at the next line of this frame or its callers
+	DebugStepOut                     // stop once the current frame returns
+	DebugAbort                       // stop the evaluation with an InterruptedError
+)
+
+// Breakpoint is a source location, with a 1-based line, to pause at.
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+// Debugger pauses let-go code at breakpoints and steps through it, line by
+// line. While a debugger is attached all frames run instrumented, which is
+// much slower than usual.
+type Debugger struct {
+	// onStop is called on the goroutine that paused, blocking it until it
+	// returns how to resume.
+	onStop func(*Pause) DebugAction
+
+	mu          sync.Mutex
+	breakpoints map[string]map[int]bool // absolute file → lines
+	files       map[string]string       // file names as compiled → absolute
+	stepping    DebugAction
+	stepGoid    uint64
+	stepDepth   int
+	top         map[uint64]*debugState // innermost frame of each goroutine
+	formStarts  map[*CodeChunk]map[int]bool
+
+	pauseRequested atomic.Bool
+	muted          atomic.Int32
+	stopMu         sync.Mutex // only one goroutine is paused at a time
+}
+
+// debugState is what the debugger knows about a running frame.
+type debugState struct {
+	frame  *Frame
+	caller *debugState
+	goid   uint64
+	depth  int
+	code   *CodeChunk // to notice tail calls replacing the code
+	file   string
+	line   int
+}
+
+// NewDebugger returns a debugger calling onStop whenever a goroutine pauses.
+func NewDebugger(onStop func(*Pause) DebugAction) *Debugger {
+	return &Debugger{
+		onStop:      onStop,
+		breakpoints: map[string]map[int]bool{},
+		files:       map[string]string{},
+		top:         map[uint64]*debugState{},
+		formStarts:  map[*CodeChunk]map[int]bool{},
+	}
+}
+
+var activeDebugger atomic.Pointer[Debugger]
+
+// AttachDebugger makes d watch all let-go code started from now on.
+func AttachDebugger(d *Debugger) {
+	activeDebugger.Store(d)
+}
+
+// DetachDebugger stops debugging; paused code is not resumed by it.
+func DetachDebugger() {
+	activeDebugger.Store(nil)
+}
+
+// absFile returns file as an absolute path, so that breakpoints match
+// however the file was named. Callers hold d.mu.
+func (d *Debugger) absFile(file string) string {
+	if abs, ok := d.files[file]; ok {
+		return abs
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+	d.files[file] = abs
+	return abs
+}
+
+// SetBreakpoint makes code pause when it reaches line (1-based) of file.
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f := d.absFile(file)
+	if d.breakpoints[f] == nil {
+		d.breakpoints[f] = map[int]bool{}
+	}
+	d.breakpoints[f][line] = true
+}
+
+// ClearBreakpoint removes the breakpoint at line of file.
+func (d *Debugger) ClearBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints[d.absFile(file)], line)
+}
+
+// ClearBreakpoints removes all breakpoints in file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, d.absFile(file))
+}
+
+// Breakpoints returns all breakpoints, ordered by file and line.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []Breakpoint
+	for f, lines := range d.breakpoints {
+		for l := range lines {
+			out = append(out, Breakpoint{File: f, Line: l})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].File != out[j].File {
+			return out[i].File < out[j].File
+		}
+		return out[i].Line < out[j].Line
+	})
+	return out
+}
+
+// Pause makes the next goroutine reaching a source location pause.
+func (d *Debugger) Pause() {
+	d.pauseRequested.Store(true)
+}
+
+// Muted runs fn without pausing anywhere, for evaluating code on behalf of
+// the user while paused.
+func (d *Debugger) Muted(fn func() (Value, error)) (Value, error) {
+	d.muted.Add(1)
+	defer d.muted.Add(-1)
+	return fn()
+}
+
+// run runs f keeping track of the frames of its goroutine.
+func (d *Debugger) run(f *Frame) (Value, error) {
+	id := gid()
+	d.mu.Lock()
+	st := &debugState{frame: f, caller: d.top[id], goid: id, code: f.code, line: -1}
+	if st.caller != nil {
+		st.depth = st.caller.depth + 1
+	}
+	d.top[id] = st
+	d.mu.Unlock()
+	f.dbg = st
+
+	v, err := f.run()
+
+	d.mu.Lock()
+	if st.caller != nil {
+		d.top[id] = st.caller
+		// stepping out stops in the caller right after the return, even
+		// though it's still on the line it was when making the call
+		if d.stepping == DebugStepOut && d.stepGoid == id && st.depth <= d.stepDepth {
+			st.caller.line = -1
+		}
+	} else {
+		delete(d.top, id)
+	}
+	d.mu.Unlock()
+	f.dbg = nil
+	if err != nil {
+		return NIL, f.traced(f.escaping(err))
+	}
+	return v, nil
+}
+
+// check pauses the goroutine running f if it reached a breakpoint or the
+// next line it's stepping to.
+func (d *Debugger) check(f *Frame) error {
+	st := f.dbg
+	if st == nil || d.muted.Load() > 0 {
+		return nil
+	}
+	if st.code != f.code {
+		st.code = f.code
+		st.line = -1
+	}
+	src := f.code.LookupSource(f.ip)
+	if src == nil {
+		return nil
+	}
+	if src.Line == st.line && src.File == st.file && !d.pauseRequested.Load() {
+		return nil
+	}
+
+	reason := ""
+	d.mu.Lock()
+	// stepping out stops right after the return, elsewhere only where a form
+	// starts
+	out := d.stepping == DebugStepOut && d.stepGoid == st.goid && st.depth < d.stepDepth
+	switch {
+	case d.pauseRequested.Swap(false):
+		reason = "pause"
+	case out:
+		reason = "step"
+	case !d.formStart(f.code, f.ip):
+	case d.breakpoints[d.absFile(src.File)][src.Line+1]:
+		reason = "breakpoint"
+	case d.stepGoid == st.goid:
+		switch d.stepping {
+		case DebugStepIn:
+			reason = "step"
+		case DebugStepOver:
+			if st.depth <= d.stepDepth {
+				reason = "step"
+			}
+		}
+	}
+	if reason != "" || d.formStart(f.code, f.ip) {
+		st.file, st.line = src.File, src.Line
+	}
+	d.mu.Unlock()
+	if reason == "" {
+		return nil
+	}
+	return d.stop(st, reason)
+}
+
+// formStart tells whether ip is where a form starts in c, as opposed to
+// where the code of an enclosing form goes on after a nested one. Only form
+// starts are stepped to. Callers hold d.mu.
+func (d *Debugger) formStart(c *CodeChunk, ip int) bool {
+	starts, ok := d.formStarts[c]
+	if !ok {
+		starts = map[int]bool{}
+		seen := map[SourceInfo]bool{}
+		for _, e := range c.sourceMap.entries {
+			if !seen[e.info] {
+				seen[e.info] = true
+				starts[e.startIP] = true
+			}
+		}
+		d.formStarts[c] = starts
+	}
+	return starts[ip]
+}
+
+func (d *Debugger) stop(st *debugState, reason string) error {
+	d.stopMu.Lock()
+	action := d.onStop(&Pause{Reason: reason, state: st})
+	d.stopMu.Unlock()
+
+	d.mu.Lock()
+	d.stepping = action
+	d.stepGoid = st.goid
+	d.stepDepth = st.depth
+	d.mu.Unlock()
+	if action == DebugAbort {
+		return &InterruptedError{cause: NewExecutionError("stopped by debugger")}
+	}
+	return nil
+}
+
+// Pause describes where a goroutine stopped. It's only valid until the
+// goroutine is resumed.
+type Pause struct {
+	Reason string // "breakpoint", "step" or "pause"
+	state  *debugState
+}
+
+// Frames returns the frames of the paused goroutine, innermost first.
+func (p *Pause) Frames() []DebugFrame {
+	var out []DebugFrame
+	for st := p.state; st != nil; st = st.caller {
+		out = append(out, DebugFrame{StackFrame: st.frame.stackFrame(), frame: st.frame})
+	}
+	return out
+}
+
+// DebugFrame is a frame of a paused goroutine.
+type DebugFrame struct {
+	StackFrame
+	frame *Frame
+}
+
+// Locals returns the arguments, locals and closed-over values visible at the
+// point the frame is at, by name.
+func (df DebugFrame) Locals() []Local {
+	return df.frame.locals()
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// DefType is a ValueType created by deftype or reify. Unlike records, its
+// instances have no map semantics: fields are only reachable through
+// (.-field x) interop and the type's own method bodies.
+type DefType struct {
+	typeName string
+	fields   []Keyword
+	fieldIdx map[Keyword]int
+	impls    []*PersistentMap // protocol implementations, for (.method x) calls
+}
+
+func NewDefType(name string, fields []Keyword) *DefType {
+	idx := make(map[Keyword]int, len(fields))
+	for i, f := range fields {
+		idx[f] = i
+	}
+	return &DefType{typeName: name, fields: fields, fieldIdx: idx}
+}
+
+func (t *DefType) String() string     { return t.Name() }
+func (t *DefType) Type() ValueType    { return TypeType }
+func (t *DefType) Unbox() interface{} { return t }
+func (t *DefType) Name() string       { return t.typeName }
+func (t *DefType) Fields() []Keyword  { return t.fields }
+
+func (t *DefType) Box(bare interface{}) (Value, error) {
+	return NIL, NewTypeError(bare, "can't be boxed as", t)
+}
+
+// New creates an instance of t from positional field values.
+func (t *DefType) New(vals []Value) (*Instance, error) {
+	if len(vals) != len(t.fields) {
+		return nil, fmt.Errorf("%s expects %d fields, got %d", t.typeName, len(t.fields), len(vals))
+	}
+	fields := make([]Value, len(vals))
+	copy(fields, vals)
+	return &Instance{dtype: t, fields: fields, id: instanceIDs.Add(1)}, nil
+}
+
+// Reify creates a field-less instance of t carrying its own protocol
+// implementations. The implementations may close over locals, so they live
+// on the instance rather than on the type.
+func (t *DefType) Reify(impls map[*Protocol]*PersistentMap) *Instance {
+	return &Instance{dtype: t, impls: impls, id: instanceIDs.Add(1)}
+}
+
+var instanceIDs atomic.Uint64
+
+// Instance is a value created by a deftype constructor or by reify.
+// Instances compare by identity, like in Clojure.
+type Instance struct {
+	dtype  *DefType
+	fields []Value
+	impls  map[*Protocol]*PersistentMap
+	id     uint64
+}
+
+func (o *Instance) Type() ValueType    { return o.dtype }
+func (o *Instance) Unbox() interface{} { return o }
+
+func (o *Instance) String() string {
+	return fmt.Sprintf("#object[%s 0x%x]", o.dtype.typeName, o.id)
+}
+
+func (o *Instance) Hash() uint32 {
+	return hashUint64(o.id)
+}
+
+// DefType returns the type o was created from.
+func (o *Instance) DefType() *DefType { return o.dtype }
+
+// Field returns the value of a field.
+func (o *Instance) Field(name Keyword) (Value, error) {
+	idx, ok := o.dtype.fieldIdx[name]
+	if !ok {
+		return NIL, fmt.Errorf("no field %s on %s", name, o.dtype.typeName)
+	}
+	return o.fields[idx], nil
+}
+
+// SetField changes the value of a mutable field. It is used by set! inside
+// deftype method bodies.
+func (o *Instance) SetField(name Keyword, val Value) error {
+	idx, ok := o.dtype.fieldIdx[name]
+	if !ok {
+		return fmt.Errorf("no field %s on %s", name, o.dtype.typeName)
+	}
+	o.fields[idx] = val
+	return nil
+}
+
+func (o *Instance) protocolImpl(p *Protocol) *PersistentMap {
+	return o.impls[p]
+}
+
+// method finds an inline implementation named name in the protocols o
+// satisfies, so that (.method o args) works like in Clojure.
+func (o *Instance) method(name string) (Fn, bool) {
+	key := Keyword(name)
+	if o.impls != nil {
+		for _, impl := range o.impls {
+			if fn, ok := impl.ValueAt(key).(Fn); ok {
+				return fn, true
+			}
+		}
+		return nil, false
+	}
+	for _, impl := range o.dtype.impls {
+		if fn, ok := impl.ValueAt(key).(Fn); ok {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// InvokeMethod implements Receiver: (.-field o) and (.field o) read fields,
+// (.method o args) calls a protocol method implemented by o's type.
+func (o *Instance) InvokeMethod(name Symbol, args []Value) (Value, error) {
+	field := strings.TrimPrefix(string(name), "-")
+	if idx, ok := o.dtype.fieldIdx[Keyword(field)]; ok {
+		return o.fields[idx], nil
+	}
+	if fn, ok := o.method(string(name)); ok {
+		return fn.Invoke(append([]Value{o}, args...))
+	}
+	return NIL, fmt.Errorf("no field or method %s on %s", name, o.dtype.typeName)
+}
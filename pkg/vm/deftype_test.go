@@ -0,0 +1,31 @@
+package vm
+
+import "testing"
+
+func TestDefTypeFields(t *testing.T) {
+	dt := NewDefType("Point", []Keyword{"x", "y"})
+	if _, err := dt.New([]Value{Int(1)}); err == nil {
+		t.Fatal("expected an error for a missing field")
+	}
+	p, err := dt.New([]Value{Int(1), Int(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := p.InvokeMethod("-y", nil)
+	if err != nil || y != Int(2) {
+		t.Fatalf("(.-y p): got %v, %v", y, err)
+	}
+	if err := p.SetField("x", Int(5)); err != nil {
+		t.Fatal(err)
+	}
+	if x, _ := p.Field("x"); x != Int(5) {
+		t.Errorf("x after SetField: got %v", x)
+	}
+	if _, err := p.InvokeMethod("z", nil); err == nil {
+		t.Error("expected an error for an unknown member")
+	}
+	q, _ := dt.New([]Value{Int(5), Int(2)})
+	if p.Hash() == q.Hash() {
+		t.Error("distinct instances should hash differently")
+	}
+}
@@ -17,6 +17,13 @@ type compileErrorLike interface {
 	InnermostSource() *SourceInfo
 }
 
+// Long stack traces, like those of a stack overflow, are shown by their
+// innermost traceHead and outermost traceTail frames.
+const (
+	traceHead = 20
+	traceTail = 10
+)
+
 // FormatError produces a user-friendly error display with source snippets,
 // inspired by Rust/Elm-style error reporting.
 func FormatError(err error) string {
@@ -31,7 +38,6 @@ func FormatError(err error) string {
 	type frame struct {
 		msg    string
 		source *SourceInfo
-		fnName string
 	}
 
 	var frames []frame
@@ -39,8 +45,9 @@ func FormatError(err error) string {
 	for current != nil {
 		switch e := current.(type) {
 		case *ExecutionError:
-			name := strings.TrimPrefix(e.message, "calling ")
-			frames = append(frames, frame{msg: e.message, source: e.source, fnName: name})
+			if e.message != "" {
+				frames = append(frames, frame{msg: e.message, source: e.source})
+			}
 			current = e.cause
 		case *TypeError:
 			frames = append(frames, frame{msg: current.Error()})
@@ -61,31 +68,34 @@ func FormatError(err error) string {
 	// Error header
 	fmt.Fprintf(&b, "\x1b[1;31merror:\x1b[0m %s\n", root.msg)
 
-	// Source snippet for the deepest frame that has source info
-	for i := len(frames) - 1; i >= 0; i-- {
-		if frames[i].source != nil {
-			writeSnippet(&b, frames[i].source)
-			break
-		}
-	}
+	stack := StackTrace(err)
 
-	// Stack trace (if more than one frame with a name)
-	hasTrace := false
-	for i := 0; i < len(frames)-1; i++ {
-		if frames[i].fnName != "" {
-			hasTrace = true
+	// Source snippet for the innermost location we know of
+	var snippet *SourceInfo
+	for _, sf := range stack {
+		if sf.Source != nil {
+			snippet = sf.Source
 			break
 		}
 	}
-	if hasTrace {
+	for i := len(frames) - 1; snippet == nil && i >= 0; i-- {
+		snippet = frames[i].source
+	}
+	if snippet != nil {
+		writeSnippet(&b, snippet)
+	}
+
+	// Stack trace, innermost frame first
+	if len(stack) > 0 {
 		b.WriteString("\n\x1b[1mstack trace:\x1b[0m\n")
-		for i := len(frames) - 2; i >= 0; i-- {
-			f := frames[i]
-			loc := "<unknown>"
-			if f.source != nil {
-				loc = f.source.String()
+		for i, sf := range stack {
+			if len(stack) > traceHead+traceTail && i == traceHead {
+				fmt.Fprintf(&b, "  ... %d more\n", len(stack)-traceHead-traceTail)
+			}
+			if len(stack) > traceHead+traceTail && i >= traceHead && i < len(stack)-traceTail {
+				continue
 			}
-			fmt.Fprintf(&b, "  at %s (%s)\n", f.fnName, loc)
+			fmt.Fprintf(&b, "  at %s\n", sf)
 		}
 	}
 
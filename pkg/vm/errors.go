@@ -59,10 +59,16 @@ func (te *TypeError) GetCause() error {
 	return te.cause
 }
 
+// Unwrap lets errors.Is and errors.As look through the cause.
+func (te *TypeError) Unwrap() error {
+	return te.cause
+}
+
 type ExecutionError struct {
 	message string
 	source  *SourceInfo
 	cause   error
+	frame   *StackFrame // stack frame the error went through, if any
 }
 
 func NewExecutionError(m string) *ExecutionError {
@@ -70,6 +76,10 @@ func NewExecutionError(m string) *ExecutionError {
 }
 
 func (ve *ExecutionError) Error() string {
+	if ve.message == "" && ve.cause != nil {
+		// frames recorded while unwinding don't show up in the message
+		return ve.cause.Error()
+	}
 	return errors.AddCause(ve, fmt.Sprintf("ExecutionError: %s", ve.message))
 }
 
@@ -87,6 +97,16 @@ func (ve *ExecutionError) GetCause() error {
 	return ve.cause
 }
 
+// Unwrap lets errors.Is and errors.As look through the cause.
+func (ve *ExecutionError) Unwrap() error {
+	return ve.cause
+}
+
+// Stack returns the let-go stack trace of the error, innermost frame first.
+func (ve *ExecutionError) Stack() []StackFrame {
+	return StackTrace(ve)
+}
+
 // ThrownError wraps a Value that was explicitly thrown.
 // It propagates through the normal error return path.
 type ThrownError struct {
@@ -104,15 +124,24 @@ func (e *ThrownError) Error() string {
 	return e.Value.String()
 }
 
+// ErrorValue returns err as the value a catch clause would bind.
+func ErrorValue(err error) Value {
+	return errorToValue(err)
+}
+
 // errorToValue extracts the catchable Value from an error.
 // For ThrownError, returns the thrown Value.
 // For any other error, wraps the error message as an ExInfo with a :trace key
 // containing the let-go call stack.
+// Either way the ExInfo carries the stack trace of err, see ExInfo.Stack.
 func errorToValue(err error) Value {
 	// Walk the chain to find a ThrownError
 	current := err
 	for current != nil {
 		if te, ok := current.(*ThrownError); ok {
+			if ei, ok := te.Value.(*ExInfo); ok && len(ei.stack) == 0 {
+				return ei.withStack(StackTrace(err))
+			}
 			return te.Value
 		}
 		if ee, ok := current.(*ExecutionError); ok {
@@ -125,33 +154,17 @@ func errorToValue(err error) Value {
 	msg := innermostMessage(err)
 	data := EmptyPersistentMap
 
-	// Collect call stack frames
-	var traceEntries []Value
-	current = err
-	for current != nil {
-		if ee, ok := current.(*ExecutionError); ok {
-			fnName := ""
-			if len(ee.message) > 8 && ee.message[:8] == "calling " {
-				fnName = ee.message[8:]
-			}
-			if fnName != "" {
-				loc := "<unknown>"
-				if ee.source != nil {
-					loc = ee.source.String()
-				}
-				traceEntries = append(traceEntries, String(fnName+" ("+loc+")"))
-			}
-			current = ee.cause
-		} else {
-			current = nil
+	stack := StackTrace(err)
+	if len(stack) > 0 {
+		traceEntries := make([]Value, len(stack))
+		for i := range stack {
+			traceEntries[i] = String(stack[i].String())
 		}
-	}
-	if len(traceEntries) > 0 {
 		traceList, _ := ListType.Box(traceEntries)
 		data = data.Assoc(Keyword("trace"), traceList).(*PersistentMap)
 	}
 
-	return NewExInfo(msg, data, nil)
+	return NewExInfo(msg, data, nil).withStack(stack)
 }
 
 // thrownPanic is used to propagate errors through native Go code (map, filter, sort).
@@ -8,6 +8,7 @@ type ExInfo struct {
 	message string
 	data    *PersistentMap
 	cause   error
+	stack   []StackFrame
 }
 
 func NewExInfo(message string, data *PersistentMap, cause error) *ExInfo {
@@ -24,6 +25,20 @@ func (e *ExInfo) Message() string      { return e.message }
 func (e *ExInfo) Data() *PersistentMap { return e.data }
 func (e *ExInfo) Cause() error         { return e.cause }
 
+// Stack returns the stack trace recorded when the exception was first caught,
+// innermost frame first.
+func (e *ExInfo) Stack() []StackFrame { return e.stack }
+
+// withStack returns a copy of e carrying stack.
+func (e *ExInfo) withStack(stack []StackFrame) *ExInfo {
+	if len(stack) == 0 {
+		return e
+	}
+	c := *e
+	c.stack = stack
+	return &c
+}
+
 type theExInfoType struct{}
 
 func (t *theExInfoType) String() string     { return t.Name() }
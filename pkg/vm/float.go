@@ -6,6 +6,9 @@
 package vm
 
 import (
+	"math"
+	"math/big"
+	"math/bits"
 	"reflect"
 	"strconv"
 	"unsafe"
@@ -35,10 +38,31 @@ var FloatType *theFloatType = &theFloatType{zero: 0}
 // Float is boxed float64
 type Float float64
 
-// Hash implements Hashable.
+// Hash implements Hashable. Floats hash like the exact numbers they equal,
+// see NumEq.
 func (l Float) Hash() uint32 {
 	f := float64(l)
-	return hashUint64(*(*uint64)(unsafe.Pointer(&f)))
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return hashUint64(*(*uint64)(unsafe.Pointer(&f)))
+	}
+	if f == math.Trunc(f) {
+		if f >= -(1<<63) && f < 1<<63 {
+			return Int(f).Hash()
+		}
+		return hashRat(new(big.Rat).SetFloat64(f))
+	}
+	// f is num/2^k with num odd, which hashRat hashes from the two as int64s
+	// as long as they fit
+	mant, exp := math.Frexp(f)
+	num := int64(mant * (1 << 53))
+	k := 53 - exp
+	tz := bits.TrailingZeros64(uint64(num))
+	num >>= tz
+	k -= tz
+	if k < 63 {
+		return hashUint64(uint64(num))*31 ^ hashUint64(uint64(1)<<k)
+	}
+	return hashRat(new(big.Rat).SetFloat64(f))
 }
 
 // Type implements Value
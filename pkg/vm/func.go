@@ -25,23 +25,38 @@ var FuncType *theFuncType = &theFuncType{}
 
 type Func struct {
 	name        string
+	ns          string
 	arity       int
 	isVariadric bool
 	chunk       *CodeChunk
+	defined     *SourceInfo // form the function was compiled from, if known
 }
 
 func MakeFunc(arity int, variadric bool, c *CodeChunk) *Func {
-	return &Func{
+	f := &Func{
 		arity:       arity,
 		isVariadric: variadric,
 		chunk:       c,
 	}
+	coverFunc(f)
+	return f
 }
 
 func (l *Func) SetName(n string) {
 	l.name = n
 }
 
+// SetDefinedAt records the location of the form the function was compiled
+// from, like its defn.
+func (l *Func) SetDefinedAt(info SourceInfo) {
+	l.defined = &info
+}
+
+// SetNS records the namespace the function was defined in.
+func (l *Func) SetNS(ns string) {
+	l.ns = ns
+}
+
 func (l *Func) Type() ValueType { return FuncType }
 
 type FuncInterface func(interface{})
@@ -69,19 +84,33 @@ func (l *Func) Arity() int {
 	return l.arity
 }
 
-func (l *Func) Invoke(pargs []Value) (result Value, err error) {
+// frame prepares a frame running l with pargs, packing variadic arguments.
+func (l *Func) frame(pargs []Value) (*Frame, error) {
 	args := pargs
 	if l.isVariadric {
 		sargs := args[0 : l.arity-1]
 		rest := args[l.arity-1:]
 		restlist, boxErr := ListType.Box(rest)
 		if boxErr != nil {
-			return NIL, boxErr
+			return nil, boxErr
 		}
 		args = append(sargs, restlist)
 	}
 	f := NewFrame(l.chunk, args)
-	result, err = f.Run()
+	f.fn = l
+	return f, nil
+}
+
+func (l *Func) Invoke(pargs []Value) (Value, error) {
+	f, err := l.frame(pargs)
+	if err != nil {
+		return NIL, err
+	}
+	if envsActive.Load() != 0 {
+		f.setEnv(currentEnv())
+		return runFrame(f)
+	}
+	result, err := f.Run()
 	ReleaseFrame(f)
 	return result, err
 }
@@ -99,6 +128,9 @@ func (l *Func) Chunk() *CodeChunk { return l.chunk }
 // FuncName returns the function name.
 func (l *Func) FuncName() string { return l.name }
 
+// FuncNS returns the namespace the function was defined in, if known.
+func (l *Func) FuncNS() string { return l.ns }
+
 // IsVariadic returns whether the function is variadic.
 func (l *Func) IsVariadic() bool { return l.isVariadric }
 
@@ -140,20 +172,17 @@ func (l *Closure) Arity() int {
 	return l.fn.arity
 }
 
-func (l *Closure) Invoke(pargs []Value) (result Value, err error) {
-	args := pargs
-	if l.fn.isVariadric {
-		sargs := args[0 : l.fn.arity-1]
-		rest := args[l.fn.arity-1:]
-		restlist, boxErr := ListType.Box(rest)
-		if boxErr != nil {
-			return NIL, boxErr
-		}
-		args = append(sargs, restlist)
+func (l *Closure) Invoke(pargs []Value) (Value, error) {
+	f, err := l.fn.frame(pargs)
+	if err != nil {
+		return NIL, err
 	}
-	f := NewFrame(l.fn.chunk, args)
 	f.closedOvers = l.closedOvers
-	result, err = f.Run()
+	if envsActive.Load() != 0 {
+		f.setEnv(currentEnv())
+		return runFrame(f)
+	}
+	result, err := f.Run()
 	ReleaseFrame(f)
 	return result, err
 }
@@ -193,15 +222,23 @@ func (l *MultiArityFn) Arity() int {
 	return l.arity
 }
 
-func (l *MultiArityFn) Invoke(pargs []Value) (Value, error) {
-	le := len(pargs)
-	if f, ok := l.fns[le]; ok {
-		return f.Invoke(pargs)
+// variant picks the function handling n arguments.
+func (l *MultiArityFn) variant(n int) (Fn, error) {
+	if f, ok := l.fns[n]; ok {
+		return f, nil
+	}
+	if l.rest != nil && n >= l.rest.Arity() {
+		return l.rest, nil
 	}
-	if l.rest != nil && le >= l.rest.Arity() {
-		return l.rest.Invoke(pargs)
+	return nil, NewExecutionError(fmt.Sprintf("function %s doesn't have a %d-arity variant", l, n))
+}
+
+func (l *MultiArityFn) Invoke(pargs []Value) (Value, error) {
+	f, err := l.variant(len(pargs))
+	if err != nil {
+		return NIL, err
 	}
-	return NIL, NewExecutionError(fmt.Sprintf("function %s doesn't have a %d-arity variant", l, le))
+	return f.Invoke(pargs)
 }
 
 func (l *MultiArityFn) String() string {
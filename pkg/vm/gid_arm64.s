@@ -0,0 +1,10 @@
+// Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+// SPDX-License-Identifier: MIT
+
+#include "textflag.h"
+
+// func getg() uintptr
+TEXT ·getg(SB),NOSPLIT,$0-8
+	MOVD g, R0
+	MOVD R0, ret+0(FP)
+	RET
@@ -0,0 +1,23 @@
+//go:build amd64 || arm64
+
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+// getg returns the address of the runtime's descriptor of the calling
+// goroutine, see gid_*.s.
+func getg() uintptr
+
+// gid identifies the calling goroutine, for keying per-goroutine state like
+// dynamic bindings. It's the goroutine's descriptor, which stays put for its
+// whole life but is reused once it exits, so state stored under it has to be
+// removed by the goroutine that stored it before it exits. Everything keyed by
+// gid is set and restored in pairs, the restore deferred, and bindings left
+// behind by unbalanced push-thread-bindings are dropped once the outermost
+// let-go call of the goroutine returns (see runJoined).
+func gid() uint64 {
+	return uint64(getg())
+}
@@ -0,0 +1,28 @@
+//go:build !amd64 && !arm64
+
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import "runtime"
+
+// gid identifies the calling goroutine, for keying per-goroutine state like
+// dynamic bindings. Without a way to get at the goroutine's descriptor on
+// this architecture, it's the goroutine id parsed from the header of
+// runtime.Stack ("goroutine 42 [running]:"), which is much slower.
+func gid() uint64 {
+	var buf [32]byte
+	n := runtime.Stack(buf[:], false)
+	var id uint64
+	for i := len("goroutine "); i < n; i++ {
+		c := buf[i]
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + uint64(c-'0')
+	}
+	return id
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import "fmt"
+
+// Hierarchies are plain maps of the form
+//
+//	{:parents {tag #{parent}} :ancestors {tag #{ancestor}} :descendants {tag #{descendant}}}
+//
+// so they can be held in vars and atoms and updated functionally, like in
+// Clojure.
+
+// NewHierarchy returns an empty hierarchy.
+func NewHierarchy() *PersistentMap {
+	h := EmptyPersistentMap.Assoc(Keyword("parents"), EmptyPersistentMap)
+	h = h.Assoc(Keyword("descendants"), EmptyPersistentMap)
+	return h.Assoc(Keyword("ancestors"), EmptyPersistentMap).(*PersistentMap)
+}
+
+// hierarchyTable returns one of the :parents, :ancestors or :descendants
+// tables of h.
+func hierarchyTable(h Value, table Keyword) Lookup {
+	hm, ok := h.(Lookup)
+	if !ok {
+		return EmptyPersistentMap
+	}
+	t, ok := hm.ValueAt(table).(Lookup)
+	if !ok {
+		return EmptyPersistentMap
+	}
+	return t
+}
+
+// hierarchySet returns the set stored for tag in table, or nil.
+func hierarchySet(h Value, table Keyword, tag Value) Keyed {
+	s, ok := hierarchyTable(h, table).ValueAt(tag).(Keyed)
+	if !ok {
+		return nil
+	}
+	return s
+}
+
+// HierarchySet returns the parents, ancestors or descendants of tag in h as
+// a set, or NIL if there are none.
+func HierarchySet(h Value, table Keyword, tag Value) Value {
+	s := hierarchySet(h, table, tag)
+	if s == nil {
+		return NIL
+	}
+	return s
+}
+
+// seqItems returns the elements of a seqable collection.
+func seqItems(v Value) []Value {
+	sq, ok := v.(Sequable)
+	if !ok || v == NIL {
+		return nil
+	}
+	var items []Value
+	for s := sq.Seq(); s != nil && s != EmptyList && s != NIL; s = s.Next() {
+		items = append(items, s.First())
+	}
+	return items
+}
+
+// Isa tells whether child is parent, derives from it in h, or both are
+// vectors of the same length whose elements are pairwise in that relation.
+func Isa(h Value, child, parent Value) bool {
+	if ValueEquals(child, parent) {
+		return true
+	}
+	if s := hierarchySet(h, "ancestors", child); s != nil && bool(s.Contains(parent)) {
+		return true
+	}
+	cs, ok := vectorItems(child)
+	if !ok {
+		return false
+	}
+	ps, ok := vectorItems(parent)
+	if !ok || len(cs) != len(ps) {
+		return false
+	}
+	for i := range cs {
+		if !Isa(h, cs[i], ps[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHierarchyTag(v Value) bool {
+	switch v.(type) {
+	case Keyword, Symbol, ValueType:
+		return true
+	}
+	return false
+}
+
+func conjSet(s Value, vals ...Value) Value {
+	set, ok := s.(Collection)
+	if !ok || s == NIL {
+		set = EmptyPersistentSet
+	}
+	for _, v := range vals {
+		set = set.Conj(v)
+	}
+	return set
+}
+
+// propagate adds target and everything in targets[target] to the sets of
+// source and of everything in sources[source], see Derive.
+func propagate(m Lookup, source Value, sources Lookup, target Value, targets Lookup) Lookup {
+	add := append([]Value{target}, seqItems(targets.ValueAt(target))...)
+	keys := append([]Value{source}, seqItems(sources.ValueAt(source))...)
+	ret := m.(Associative)
+	for _, k := range keys {
+		ret = ret.Assoc(k, conjSet(targets.ValueAt(k), add...))
+	}
+	return ret.(Lookup)
+}
+
+// Derive returns h with tag deriving from parent.
+func Derive(h Value, tag, parent Value) (Value, error) {
+	if !isHierarchyTag(tag) || !isHierarchyTag(parent) {
+		return NIL, fmt.Errorf("derive expects keywords, symbols or types, got %s and %s", tag, parent)
+	}
+	if ValueEquals(tag, parent) {
+		return NIL, fmt.Errorf("can't derive %s from itself", tag)
+	}
+	hm, ok := h.(Associative)
+	if !ok {
+		return NIL, fmt.Errorf("derive expected a hierarchy, got %s", h.Type().Name())
+	}
+	tp := hierarchyTable(h, "parents")
+	ta := hierarchyTable(h, "ancestors")
+	td := hierarchyTable(h, "descendants")
+	if s, ok := tp.ValueAt(tag).(Keyed); ok && bool(s.Contains(parent)) {
+		return h, nil
+	}
+	if s, ok := ta.ValueAt(tag).(Keyed); ok && bool(s.Contains(parent)) {
+		return NIL, fmt.Errorf("%s already has %s as ancestor", tag, parent)
+	}
+	if s, ok := ta.ValueAt(parent).(Keyed); ok && bool(s.Contains(tag)) {
+		return NIL, fmt.Errorf("cyclic derivation: %s has %s as ancestor", parent, tag)
+	}
+	parents := tp.(Associative).Assoc(tag, conjSet(tp.ValueAt(tag), parent))
+	hm = hm.Assoc(Keyword("parents"), parents)
+	hm = hm.Assoc(Keyword("ancestors"), propagate(ta, tag, td, parent, ta))
+	hm = hm.Assoc(Keyword("descendants"), propagate(td, parent, ta, tag, td))
+	return hm, nil
+}
+
+// Underive returns h without tag deriving from parent. The ancestors and
+// descendants are rebuilt from the remaining parent relations.
+func Underive(h Value, tag, parent Value) (Value, error) {
+	tp := hierarchyTable(h, "parents")
+	ps, ok := tp.ValueAt(tag).(Keyed)
+	if !ok || !bool(ps.Contains(parent)) {
+		return h, nil
+	}
+	var ret Value = NewHierarchy()
+	var err error
+	for _, e := range seqItems(tp) {
+		kv, _ := vectorItems(e)
+		if len(kv) != 2 {
+			continue
+		}
+		for _, p := range seqItems(kv[1]) {
+			if ValueEquals(kv[0], tag) && ValueEquals(p, parent) {
+				continue
+			}
+			if ret, err = Derive(ret, kv[0], p); err != nil {
+				return NIL, err
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type theInstType struct{}
+
+func (t *theInstType) String() string     { return t.Name() }
+func (t *theInstType) Type() ValueType    { return TypeType }
+func (t *theInstType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theInstType) Name() string       { return "let-go.lang.Inst" }
+
+func (t *theInstType) Box(bare interface{}) (Value, error) {
+	switch v := bare.(type) {
+	case time.Time:
+		return NewInst(v), nil
+	case *time.Time:
+		return NewInst(*v), nil
+	}
+	return NIL, NewTypeError(bare, "can't be boxed as", t)
+}
+
+// InstType is the type of Inst values.
+var InstType *theInstType = &theInstType{}
+
+// Inst is a point in time, read from #inst literals.
+type Inst struct {
+	t time.Time
+}
+
+// NewInst creates an Inst from t. Insts are kept in UTC.
+func NewInst(t time.Time) Inst {
+	return Inst{t: t.UTC()}
+}
+
+// Time returns the instant as time.Time.
+func (i Inst) Time() time.Time { return i.t }
+
+// Millis returns the number of milliseconds since the Unix epoch.
+func (i Inst) Millis() int64 { return i.t.UnixMilli() }
+
+func (i Inst) Type() ValueType    { return InstType }
+func (i Inst) Unbox() interface{} { return i.t }
+
+// String prints i as an #inst literal, in UTC with millisecond precision
+// unless the instant has a finer one.
+func (i Inst) String() string {
+	return fmt.Sprintf("#inst %q", i.Format())
+}
+
+// Format returns the RFC 3339 timestamp used inside #inst literals.
+func (i Inst) Format() string {
+	layout := "2006-01-02T15:04:05.000"
+	if i.t.Nanosecond()%int(time.Millisecond) != 0 {
+		layout = "2006-01-02T15:04:05.000000000"
+	}
+	return i.t.Format(layout) + "-00:00"
+}
+
+func (i Inst) Hash() uint32 {
+	return hashUint64(uint64(i.t.UnixNano()))
+}
+
+// Equals tells whether other is an Inst denoting the same instant.
+func (i Inst) Equals(other Value) bool {
+	o, ok := other.(Inst)
+	return ok && i.t.Equal(o.t)
+}
+
+var instPattern = regexp.MustCompile(`^(\d{4})(?:-(\d{2})(?:-(\d{2})(?:[Tt](\d{2})(?::(\d{2})(?::(\d{2})(?:\.(\d{1,9})\d*)?)?)?)?)?)?(?:[Zz]|([-+])(\d{2}):(\d{2}))?$`)
+
+// ParseInst parses an RFC 3339 timestamp as accepted by #inst. Like in
+// Clojure, everything after the year is optional and defaults to the start
+// of the period, and a missing offset means UTC.
+func ParseInst(s string) (Inst, error) {
+	m := instPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Inst{}, fmt.Errorf("unrecognized date/time syntax: %s", s)
+	}
+	num := func(s string, dflt int) int {
+		if s == "" {
+			return dflt
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	year, month, day := num(m[1], 0), num(m[2], 1), num(m[3], 1)
+	hour, min, sec := num(m[4], 0), num(m[5], 0), num(m[6], 0)
+	nanos := 0
+	if m[7] != "" {
+		frac := m[7]
+		for len(frac) < 9 {
+			frac += "0"
+		}
+		nanos = num(frac, 0)
+	}
+	offset := 0
+	if m[8] != "" {
+		oh, om := num(m[9], 0), num(m[10], 0)
+		if oh > 23 || om > 59 {
+			return Inst{}, fmt.Errorf("invalid offset in %s", s)
+		}
+		offset = oh*3600 + om*60
+		if m[8] == "-" {
+			offset = -offset
+		}
+	}
+	if month < 1 || month > 12 || hour > 23 || min > 59 || sec > 60 {
+		return Inst{}, fmt.Errorf("invalid date/time: %s", s)
+	}
+	t := time.Date(year, time.Month(month), day, hour, min, sec, nanos, time.FixedZone("", offset))
+	if t.Day() != day {
+		return Inst{}, fmt.Errorf("invalid day in %s", s)
+	}
+	return NewInst(t), nil
+}
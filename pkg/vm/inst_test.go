@@ -0,0 +1,49 @@
+package vm
+
+import "testing"
+
+func TestParseInst(t *testing.T) {
+	cases := map[string]string{
+		"2020":                          "2020-01-01T00:00:00.000-00:00",
+		"2020-02":                       "2020-02-01T00:00:00.000-00:00",
+		"2020-02-03T04:05":              "2020-02-03T04:05:00.000-00:00",
+		"2020-02-03T04:05:06.7Z":        "2020-02-03T04:05:06.700-00:00",
+		"2020-02-03T04:05:06.123+01:30": "2020-02-03T02:35:06.123-00:00",
+		"2020-02-03T04:05:06-00:00":     "2020-02-03T04:05:06.000-00:00",
+		"2020-02-03T04:05:06.000001Z":   "2020-02-03T04:05:06.000001000-00:00",
+	}
+	for in, want := range cases {
+		inst, err := ParseInst(in)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if got := inst.Format(); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+	for _, bad := range []string{"", "20", "2020-13", "2020-02-30", "2020-01-01T25:00", "2020-01-01 00:00"} {
+		if _, err := ParseInst(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestParseUUID(t *testing.T) {
+	u, err := ParseUUID("F81D4FAE-7DEC-11D0-A765-00A0C91E6BF6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Format(); got != "f81d4fae-7dec-11d0-a765-00a0c91e6bf6" {
+		t.Errorf("got %s", got)
+	}
+	for _, bad := range []string{"", "f81d4fae7dec11d0a76500a0c91e6bf6", "f81d4fae-7dec-11d0-a765-00a0c91e6bfx"} {
+		if _, err := ParseUUID(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+	r := RandomUUID()
+	if r[6]>>4 != 4 {
+		t.Errorf("random UUID is not version 4: %s", r.Format())
+	}
+}
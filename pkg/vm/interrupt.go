@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nooga/let-go/pkg/errors"
+)
+
+// InterruptedError is returned when an evaluation is stopped because its
+// context was cancelled. It is not catchable by try/catch.
+type InterruptedError struct {
+	cause error
+}
+
+func (e *InterruptedError) Error() string {
+	return "InterruptedError: evaluation interrupted: " + e.cause.Error()
+}
+
+func (e *InterruptedError) Wrap(err error) errors.Error {
+	e.cause = err
+	return e
+}
+
+func (e *InterruptedError) GetCause() error {
+	return e.cause
+}
+
+// Unwrap lets errors.Is and errors.As look through the cause.
+func (e *InterruptedError) Unwrap() error {
+	return e.cause
+}
+
+func (e *InterruptedError) uncatchable() {}
+
+// uncatchableError marks errors which must unwind the whole evaluation
+// instead of being dispatched to try/catch handlers.
+type uncatchableError interface {
+	uncatchable()
+}
+
+// isUncatchable tells whether err, or anything in its cause chain, must not be caught.
+func isUncatchable(err error) bool {
+	var u uncatchableError
+	return stderrors.As(err, &u)
+}
+
+// IsInterrupted tells whether err was caused by a cancelled evaluation context.
+func IsInterrupted(err error) bool {
+	var ie *InterruptedError
+	return stderrors.As(err, &ie)
+}
+
+// pendingCancels counts evaluation contexts that are done but still running.
+// The run loop only looks up its goroutine's context when this is non-zero,
+// so uncancellable evaluations pay a single atomic load per check.
+var pendingCancels atomic.Int32
+
+// evalContexts maps goroutines (by gid) to the context of the evaluation they run.
+var evalContexts sync.Map
+
+// WithContext runs fn so that every frame it executes on the calling goroutine
+// stops with an InterruptedError once ctx is done. The VM checks the context
+// on calls, recur and backward jumps.
+func WithContext(ctx context.Context, fn func() (Value, error)) (Value, error) {
+	id := gid()
+	prev, hadPrev := evalContexts.Load(id)
+	evalContexts.Store(id, ctx)
+	contextsActive.Add(1)
+	var mu sync.Mutex
+	finished, counted := false, false
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		if !finished {
+			pendingCancels.Add(1)
+			counted = true
+		}
+		mu.Unlock()
+	})
+	defer func() {
+		stop()
+		mu.Lock()
+		finished = true
+		if counted {
+			pendingCancels.Add(-1)
+		}
+		mu.Unlock()
+		if hadPrev {
+			evalContexts.Store(id, prev)
+		} else {
+			evalContexts.Delete(id)
+		}
+		contextsActive.Add(-1)
+	}()
+	return fn()
+}
+
+// RunContext runs the frame like RunProtected, stopping once ctx is done.
+func (f *Frame) RunContext(ctx context.Context) (Value, error) {
+	return WithContext(ctx, f.RunProtected)
+}
+
+// contextsActive counts goroutines running evaluations with a context.
+var contextsActive atomic.Int32
+
+// currentContext returns the context of the evaluation running on the calling
+// goroutine, nil if there's none.
+func currentContext() context.Context {
+	if contextsActive.Load() == 0 {
+		return nil
+	}
+	c, ok := evalContexts.Load(gid())
+	if !ok {
+		return nil
+	}
+	return c.(context.Context)
+}
+
+// checkInterrupt returns an InterruptedError if the evaluation running on the
+// calling goroutine has been cancelled. Callers in the run loop check
+// pendingCancels first.
+func checkInterrupt() error {
+	c := currentContext()
+	if c == nil {
+		return nil
+	}
+	if err := c.Err(); err != nil {
+		return &InterruptedError{cause: err}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// runtimesEntered counts goroutines associated with a runtime through
+// EnterRuntime, so code running outside of any pays a single atomic load.
+var runtimesEntered atomic.Int32
+
+// goroutineRuntimes maps goroutines (by gid) to the runtime they evaluate in.
+var goroutineRuntimes sync.Map
+
+// EnterRuntime associates r with the calling goroutine until the returned
+// function is called. The vm treats r as opaque; the rt package uses it to
+// keep the namespaces of independent runtimes apart.
+func EnterRuntime(r interface{}) func() {
+	id := gid()
+	prev, hadPrev := goroutineRuntimes.Load(id)
+	goroutineRuntimes.Store(id, r)
+	runtimesEntered.Add(1)
+	return func() {
+		if hadPrev {
+			goroutineRuntimes.Store(id, prev)
+		} else {
+			goroutineRuntimes.Delete(id)
+		}
+		runtimesEntered.Add(-1)
+	}
+}
+
+// CurrentRuntime returns the runtime the calling goroutine was associated
+// with by EnterRuntime, or nil.
+func CurrentRuntime() interface{} {
+	if runtimesEntered.Load() == 0 {
+		return nil
+	}
+	r, _ := goroutineRuntimes.Load(gid())
+	return r
+}
+
+// CloneNamespaces copies a set of namespaces so that the copies can evolve
+// independently. Every var is duplicated, sharing its root value, and refers,
+// aliases and var roots pointing into the set are redirected to the copies.
+func CloneNamespaces(nss map[string]*Namespace) map[string]*Namespace {
+	out := make(map[string]*Namespace, len(nss))
+	copies := make(map[*Namespace]*Namespace, len(nss))
+	for name, n := range nss {
+		c := NewNamespace(n.name)
+		c.owner = n.owner
+		out[name] = c
+		copies[n] = c
+	}
+	redirect := func(n *Namespace) *Namespace {
+		if c, ok := copies[n]; ok {
+			return c
+		}
+		return n
+	}
+	for _, n := range nss {
+		c := copies[n]
+		for sym, v := range n.Interns() {
+			cv := &Var{nsref: c, ns: v.ns, name: v.name}
+			cv.isMacro.Store(v.isMacro.Load())
+			cv.isDynamic.Store(v.isDynamic.Load())
+			cv.isPrivate.Store(v.isPrivate.Load())
+			cv.meta.Store(v.meta.Load())
+			if v.HasRoot() {
+				root := v.Root()
+				if rn, ok := root.(*Namespace); ok {
+					root = redirect(rn)
+				}
+				cv.SetRoot(root)
+			}
+			cv.validator.Store(v.validator.Load())
+			c.registry[sym] = cv
+		}
+		n.mu.RLock()
+		for k, r := range n.refers {
+			c.refers[k] = &Refer{ns: redirect(r.ns), all: r.all, only: r.only}
+		}
+		for k := range n.unmapped {
+			c.unmapped[k] = true
+		}
+		n.mu.RUnlock()
+		for k, a := range n.Aliases() {
+			c.aliases[k] = redirect(a)
+		}
+	}
+	return out
+}
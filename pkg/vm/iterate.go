@@ -41,6 +41,7 @@ func (n *Iterate) More() Seq {
 }
 
 func (n *Iterate) Next() Seq {
+	checkpoint()
 	nv, err := n.f.Invoke([]Value{n.state})
 	if err != nil {
 		return nil
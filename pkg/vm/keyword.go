@@ -61,6 +61,13 @@ func (l Keyword) Invoke(pargs []Value) (Value, error) {
 	}
 	as, ok := pargs[0].(Lookup)
 	if !ok {
+		// deftype instances have no map semantics but can be looked into
+		if _, ok := pargs[0].(*Instance); ok {
+			if vl == 2 {
+				return pargs[1], nil
+			}
+			return NIL, nil
+		}
 		return NIL, fmt.Errorf("Keyword expected Lookup")
 	}
 	if vl == 1 {
@@ -27,6 +27,7 @@ func (l *LazySeq) sval() Value {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	if l.fn != nil {
+		checkpoint()
 		sv, err := l.fn.Invoke(nil)
 		if err != nil {
 			l.err = err
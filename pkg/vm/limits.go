@@ -0,0 +1,331 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nooga/let-go/pkg/errors"
+)
+
+// Limits bounds the resources an evaluation may use. Zero values mean no limit.
+type Limits struct {
+	MaxSteps int64         // executed bytecode instructions
+	MaxDepth int           // nested let-go function calls
+	Timeout  time.Duration // wall time of the evaluation
+	Access   *AccessPolicy // vars the evaluation may refer to, nil allows all
+}
+
+type LimitKind int
+
+const (
+	StepLimit LimitKind = iota
+	DepthLimit
+	TimeLimit
+)
+
+// LimitError is returned when an evaluation exceeds one of its Limits.
+// Like InterruptedError it is not catchable by try/catch.
+type LimitError struct {
+	Kind  LimitKind
+	msg   string
+	cause error
+}
+
+func (e *LimitError) Error() string {
+	return "LimitError: " + e.msg
+}
+
+func (e *LimitError) Wrap(err error) errors.Error {
+	e.cause = err
+	return e
+}
+
+func (e *LimitError) GetCause() error {
+	return e.cause
+}
+
+// Unwrap lets errors.Is and errors.As look through the cause.
+func (e *LimitError) Unwrap() error {
+	return e.cause
+}
+
+func (e *LimitError) uncatchable() {}
+
+// AsLimitError finds a LimitError in the cause chain of err.
+func AsLimitError(err error) (*LimitError, bool) {
+	var le *LimitError
+	ok := stderrors.As(err, &le)
+	return le, ok
+}
+
+// budget is shared by all goroutines taking part in one limited evaluation.
+type budget struct {
+	limits  Limits
+	metered bool // whether instructions have to be counted
+	steps   atomic.Int64
+	// done is closed once the evaluation has to stop: its time is up or the
+	// context it runs in is done. stopped says which, and is set first.
+	done    chan struct{}
+	once    sync.Once
+	stopped atomic.Pointer[func() error]
+	ctx     context.Context // the evaluation context WithLimits was called in, if any
+}
+
+// stop makes the evaluation fail with the error returned by reason.
+func (b *budget) stop(reason func() error) {
+	b.once.Do(func() {
+		b.stopped.Store(&reason)
+		close(b.done)
+	})
+}
+
+func (b *budget) timeUp() error {
+	return &LimitError{Kind: TimeLimit, msg: fmt.Sprintf("exceeded time limit of %s", b.limits.Timeout)}
+}
+
+func (b *budget) interrupted() error {
+	return &InterruptedError{cause: b.ctx.Err()}
+}
+
+// err returns the error the evaluation has been stopped with, if any.
+func (b *budget) err() error {
+	if reason := b.stopped.Load(); reason != nil {
+		return (*reason)()
+	}
+	return nil
+}
+
+func (b *budget) step() error {
+	n := b.steps.Add(1)
+	if b.limits.MaxSteps > 0 && n > b.limits.MaxSteps {
+		return &LimitError{Kind: StepLimit, msg: fmt.Sprintf("exceeded %d steps", b.limits.MaxSteps)}
+	}
+	return b.err()
+}
+
+// execEnv is the per-goroutine state of a limited evaluation.
+type execEnv struct {
+	budget *budget
+	depth  int
+}
+
+// envsActive counts goroutines running limited evaluations. Unlimited code
+// only ever pays an atomic load when entering a function.
+var envsActive atomic.Int32
+
+// execEnvs maps goroutines (by gid) to the execEnv of the evaluation they run.
+var execEnvs sync.Map
+
+func currentEnv() *execEnv {
+	e, ok := execEnvs.Load(gid())
+	if !ok {
+		return nil
+	}
+	return e.(*execEnv)
+}
+
+// enterEnv installs env for the calling goroutine and returns a function
+// restoring the previous one.
+func enterEnv(env *execEnv) func() {
+	id := gid()
+	prev, hadPrev := execEnvs.Load(id)
+	execEnvs.Store(id, env)
+	envsActive.Add(1)
+	return func() {
+		if hadPrev {
+			execEnvs.Store(id, prev)
+		} else {
+			execEnvs.Delete(id)
+		}
+		envsActive.Add(-1)
+	}
+}
+
+// conveyedEnv returns the env of the calling goroutine if it runs a limited
+// evaluation. Goroutines spawned from it draw from the same budget but track
+// their own call depth.
+func conveyedEnv() *execEnv {
+	if envsActive.Load() == 0 {
+		return nil
+	}
+	return currentEnv()
+}
+
+// WithLimits runs fn so that all code it evaluates, including go blocks and
+// futures started from it, stays within l. Exceeding a limit stops the
+// evaluation with a LimitError.
+//
+// The time limit is kept by a timer rather than by the code running: natives
+// that block or loop over collections give up once it fires (see Done and
+// Checkpoint), and so does the evaluation, even while it's in a native.
+func WithLimits(l Limits, fn func() (Value, error)) (Value, error) {
+	b := &budget{limits: l, metered: l.MaxSteps > 0 || l.Timeout > 0, done: make(chan struct{})}
+	if l.Timeout > 0 {
+		t := time.AfterFunc(l.Timeout, func() { b.stop(b.timeUp) })
+		defer t.Stop()
+	}
+	if ctx := currentContext(); ctx != nil {
+		b.ctx = ctx
+		defer context.AfterFunc(ctx, func() { b.stop(b.interrupted) })()
+	}
+	defer enterEnv(&execEnv{budget: b})()
+	return fn()
+}
+
+// Done returns a channel that's closed once the evaluation running on the
+// calling goroutine has to stop, because it was interrupted or ran out of
+// time. It's nil, blocking forever, for evaluations which can't be stopped.
+// Natives that block select on it and fail with Stopped when it's closed.
+func Done() <-chan struct{} {
+	if envsActive.Load() != 0 {
+		if env := currentEnv(); env != nil {
+			return env.budget.done
+		}
+	}
+	if ctx := currentContext(); ctx != nil {
+		return ctx.Done()
+	}
+	return nil
+}
+
+// Stopped returns the error the evaluation running on the calling goroutine
+// has been stopped with, nil if it may go on.
+func Stopped() error {
+	if err := checkInterrupt(); err != nil {
+		return err
+	}
+	if envsActive.Load() != 0 {
+		if env := currentEnv(); env != nil {
+			return env.budget.err()
+		}
+	}
+	return nil
+}
+
+// Checkpoint counts a step of the evaluation running on the calling goroutine
+// and tells whether it has to stop. Natives call it for every element of the
+// collections they loop over, so that limits and interrupts hold while they
+// run without ever entering the VM.
+func Checkpoint() error {
+	if envsActive.Load() != 0 {
+		if env := currentEnv(); env != nil && env.budget.metered {
+			if err := env.budget.step(); err != nil {
+				return err
+			}
+		}
+	}
+	if pendingCancels.Load() != 0 {
+		return checkInterrupt()
+	}
+	return nil
+}
+
+// checkpoint is Checkpoint for code which can't return an error, like seqs
+// realizing their elements.
+func checkpoint() {
+	if err := Checkpoint(); err != nil {
+		panic(&thrownPanic{err: err})
+	}
+}
+
+func (f *Frame) setEnv(env *execEnv) {
+	f.env = env
+	f.instrumented = f.debug || (env != nil && env.budget.metered) || instrumenting()
+}
+
+// instrument is the per-instruction slow path taken by traced, metered,
+// debugged, profiled and coverage recording frames.
+func (f *Frame) instrument(inst int32) error {
+	if f.debug {
+		f.stackDbg()
+		fmt.Println("#", f.ip, OpcodeToString(inst))
+	}
+	if f.dbg != nil {
+		if d := activeDebugger.Load(); d != nil {
+			if err := d.check(f); err != nil {
+				return err
+			}
+		}
+	}
+	if p := activeProfiler.Load(); p != nil {
+		p.poll(f)
+	}
+	if cv := activeCoverage.Load(); cv != nil {
+		cv.hit(f)
+	}
+	if f.env != nil && f.env.budget.metered && (f.unwinding == nil || f.loops(inst)) {
+		return f.env.budget.step()
+	}
+	return nil
+}
+
+// loops tells whether inst jumps back. A finally block run for an error that
+// can't be caught is only checked against limits where it loops, so that
+// it gets to clean up but can't run forever.
+func (f *Frame) loops(inst int32) bool {
+	switch inst & 0xff {
+	case OP_RECUR, OP_RECUR_FN:
+		return true
+	case OP_JUMP:
+		return f.code.code[f.ip+1] < 0
+	}
+	return false
+}
+
+// runFrame runs f and releases it, accounting for call depth when f belongs
+// to a limited evaluation.
+func runFrame(f *Frame) (Value, error) {
+	env := f.env
+	if env == nil {
+		result, err := f.Run()
+		ReleaseFrame(f)
+		return result, err
+	}
+	if max := env.budget.limits.MaxDepth; max > 0 && env.depth >= max {
+		ReleaseFrame(f)
+		return NIL, &LimitError{Kind: DepthLimit, msg: fmt.Sprintf("exceeded call depth of %d", max)}
+	}
+	env.depth++
+	defer func() { env.depth-- }()
+	result, err := f.Run()
+	ReleaseFrame(f)
+	return result, err
+}
+
+// invokeIn calls fn on behalf of a frame running under env, handing env
+// straight to let-go functions instead of looking it up again.
+func invokeIn(env *execEnv, fn Fn, args []Value) (Value, error) {
+	switch fn := fn.(type) {
+	case *Func:
+		f, err := fn.frame(args)
+		if err != nil {
+			return NIL, err
+		}
+		f.setEnv(env)
+		return runFrame(f)
+	case *Closure:
+		f, err := fn.fn.frame(args)
+		if err != nil {
+			return NIL, err
+		}
+		f.closedOvers = fn.closedOvers
+		f.setEnv(env)
+		return runFrame(f)
+	case *MultiArityFn:
+		v, err := fn.variant(len(args))
+		if err != nil {
+			return NIL, err
+		}
+		return invokeIn(env, v, args)
+	}
+	return fn.Invoke(args)
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+// LocalKind tells where the value of a local lives in a frame.
+type LocalKind int
+
+const (
+	LocalArg        LocalKind = iota // an argument of the function
+	LocalStack                       // a let, loop or catch binding on the frame stack
+	LocalClosedOver                  // a value closed over by a closure
+)
+
+// LocalVar names a slot of a frame over the range of instructions it's in
+// scope, [StartIP, EndIP). EndIP is -1 when the local is in scope until the
+// end of the chunk.
+type LocalVar struct {
+	Name    string
+	Kind    LocalKind
+	Index   int
+	StartIP int
+	EndIP   int
+}
+
+// AddLocal records a local and returns its handle for EndLocal.
+func (c *CodeChunk) AddLocal(l LocalVar) int {
+	c.locals = append(c.locals, l)
+	return len(c.locals) - 1
+}
+
+// EndLocal records the local going out of scope at the current offset.
+func (c *CodeChunk) EndLocal(handle int) {
+	c.locals[handle].EndIP = c.length
+}
+
+// Locals returns the locals recorded for the chunk.
+func (c *CodeChunk) Locals() []LocalVar { return c.locals }
+
+// Local is a named value visible in a frame.
+type Local struct {
+	Name  string
+	Value Value
+}
+
+// locals returns the named values in scope at the current instruction of f,
+// innermost bindings shadowing outer ones of the same name.
+func (f *Frame) locals() []Local {
+	var out []Local
+	seen := map[string]int{}
+	for _, l := range f.code.locals {
+		if f.ip < l.StartIP || (l.EndIP >= 0 && f.ip >= l.EndIP) {
+			continue
+		}
+		var v Value
+		switch l.Kind {
+		case LocalArg:
+			if l.Index >= f.argc {
+				continue
+			}
+			v = f.args[l.Index]
+		case LocalStack:
+			if l.Index >= f.sp {
+				continue
+			}
+			v = f.stack[l.Index]
+		case LocalClosedOver:
+			if l.Index >= len(f.closedOvers) {
+				continue
+			}
+			v = f.closedOvers[l.Index]
+		}
+		if v == nil {
+			v = NIL
+		}
+		if i, ok := seen[l.Name]; ok {
+			out[i].Value = v
+			continue
+		}
+		seen[l.Name] = len(out)
+		out = append(out, Local{Name: l.Name, Value: v})
+	}
+	return out
+}
@@ -5,23 +5,38 @@
 
 package vm
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 // MultiFn implements Clojure-style multimethods.
 // It holds a dispatch function and a map of dispatch-value → method.
+// Methods are looked up with Isa against the hierarchy held by the
+// hierarchy reference, the results are cached until the methods, the
+// preferences or the hierarchy change.
 type MultiFn struct {
 	name       string
 	dispatchFn Fn
-	methods    *PersistentMap
 	defaultVal Value // dispatch value for the default method
+	hierarchy  Reference
+
+	mu              sync.RWMutex
+	methods         *PersistentMap
+	prefers         *PersistentMap // dispatch value → set of values it's preferred over
+	cache           *PersistentMap
+	cachedHierarchy Value
 }
 
-func NewMultiFn(name string, dispatchFn Fn, defaultVal Value) *MultiFn {
+func NewMultiFn(name string, dispatchFn Fn, defaultVal Value, hierarchy Reference) *MultiFn {
 	return &MultiFn{
 		name:       name,
 		dispatchFn: dispatchFn,
-		methods:    EmptyPersistentMap,
 		defaultVal: defaultVal,
+		hierarchy:  hierarchy,
+		methods:    EmptyPersistentMap,
+		prefers:    EmptyPersistentMap,
+		cache:      EmptyPersistentMap,
 	}
 }
 
@@ -34,22 +49,151 @@ func (m *MultiFn) String() string {
 
 // AddMethod registers an implementation for a dispatch value.
 func (m *MultiFn) AddMethod(dispatchVal Value, method Fn) *MultiFn {
-	return &MultiFn{
-		name:       m.name,
-		dispatchFn: m.dispatchFn,
-		methods:    m.methods.Assoc(dispatchVal, method).(*PersistentMap),
-		defaultVal: m.defaultVal,
-	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.methods = m.methods.Assoc(dispatchVal, method).(*PersistentMap)
+	m.cache = EmptyPersistentMap
+	return m
 }
 
 // RemoveMethod unregisters an implementation.
 func (m *MultiFn) RemoveMethod(dispatchVal Value) *MultiFn {
-	return &MultiFn{
-		name:       m.name,
-		dispatchFn: m.dispatchFn,
-		methods:    m.methods.Dissoc(dispatchVal).(*PersistentMap),
-		defaultVal: m.defaultVal,
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.methods = m.methods.Dissoc(dispatchVal).(*PersistentMap)
+	m.cache = EmptyPersistentMap
+	return m
+}
+
+// RemoveAllMethods unregisters all implementations.
+func (m *MultiFn) RemoveAllMethods() *MultiFn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.methods = EmptyPersistentMap
+	m.cache = EmptyPersistentMap
+	return m
+}
+
+// PreferMethod makes x win over y when a dispatch value isa both.
+func (m *MultiFn) PreferMethod(x, y Value) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.prefersLocked(m.currentHierarchy(), y, x) {
+		return fmt.Errorf("preference conflict in multimethod '%s': %s is already preferred to %s", m.name, y, x)
 	}
+	m.prefers = m.prefers.Assoc(x, conjSet(m.prefers.ValueAt(x), y)).(*PersistentMap)
+	m.cache = EmptyPersistentMap
+	return nil
+}
+
+// Prefers returns the preference map.
+func (m *MultiFn) Prefers() *PersistentMap {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.prefers
+}
+
+// currentHierarchy returns the hierarchy the methods are looked up in.
+func (m *MultiFn) currentHierarchy() Value {
+	if m.hierarchy == nil {
+		return NIL
+	}
+	return m.hierarchy.Deref()
+}
+
+func (m *MultiFn) prefersLocked(h Value, x, y Value) bool {
+	if s, ok := m.prefers.ValueAt(x).(Keyed); ok && bool(s.Contains(y)) {
+		return true
+	}
+	for _, p := range seqItems(HierarchySet(h, "parents", y)) {
+		if m.prefersLocked(h, x, p) {
+			return true
+		}
+	}
+	for _, p := range seqItems(HierarchySet(h, "parents", x)) {
+		if m.prefersLocked(h, p, y) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MultiFn) dominates(h Value, x, y Value) bool {
+	return m.prefersLocked(h, x, y) || Isa(h, x, y)
+}
+
+// sameHierarchy tells whether the cache was computed against h. Hierarchies
+// are immutable maps, so identity is enough.
+func sameHierarchy(a, b Value) bool {
+	if a == NIL || b == NIL {
+		return a == b
+	}
+	pa, ok := a.(*PersistentMap)
+	if !ok {
+		return false
+	}
+	pb, ok := b.(*PersistentMap)
+	return ok && pa == pb
+}
+
+// GetMethod returns the method that handles dispatchVal, or NIL if there's
+// none. It fails if several methods match and none is preferred.
+func (m *MultiFn) GetMethod(dispatchVal Value) (Value, error) {
+	h := m.currentHierarchy()
+	m.mu.RLock()
+	if sameHierarchy(m.cachedHierarchy, h) {
+		if method := m.cache.ValueAt(dispatchVal); method != NIL {
+			m.mu.RUnlock()
+			return method, nil
+		}
+	}
+	m.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !sameHierarchy(m.cachedHierarchy, h) {
+		m.cache = EmptyPersistentMap
+		m.cachedHierarchy = h
+	}
+	// Of the methods whose dispatch value dispatchVal isa, pick the one that
+	// isn't dominated by any other.
+	var keys, vals []Value
+	for _, e := range seqItems(m.methods) {
+		kv, _ := vectorItems(e)
+		if Isa(h, dispatchVal, kv[0]) {
+			keys = append(keys, kv[0])
+			vals = append(vals, kv[1])
+		}
+	}
+	var bestKey, best Value
+	for i, k := range keys {
+		dominated := false
+		for j, other := range keys {
+			if i != j && m.dominates(h, other, k) {
+				dominated = true
+				break
+			}
+		}
+		if dominated {
+			continue
+		}
+		if best != nil {
+			return NIL, fmt.Errorf("multiple methods in multimethod '%s' match dispatch value: %s -> %s and %s, and neither is preferred",
+				m.name, dispatchVal, k, bestKey)
+		}
+		bestKey, best = k, vals[i]
+	}
+	if best == nil && len(keys) > 0 {
+		return NIL, fmt.Errorf("multiple methods in multimethod '%s' match dispatch value: %s, and none is preferred", m.name, dispatchVal)
+	}
+	if best == nil {
+		best = m.methods.ValueAt(m.defaultVal)
+		if best == NIL {
+			return NIL, nil
+		}
+	}
+	m.cache = m.cache.Assoc(dispatchVal, best).(*PersistentMap)
+	return best, nil
 }
 
 // Arity returns -1 (variadic — arity depends on the method).
@@ -57,20 +201,23 @@ func (m *MultiFn) Arity() int { return -1 }
 
 // Invoke calls the dispatch function, looks up the method, and calls it.
 func (m *MultiFn) Invoke(args []Value) (Value, error) {
+	return m.invoke(args, invokeFn)
+}
+
+// invoke dispatches args, making the calls with call.
+func (m *MultiFn) invoke(args []Value, call func(Fn, []Value) (Value, error)) (Value, error) {
 	// Call dispatch function
-	dv, err := m.dispatchFn.Invoke(args)
+	dv, err := call(m.dispatchFn, args)
 	if err != nil {
 		return NIL, fmt.Errorf("multimethod %s dispatch failed: %w", m.name, err)
 	}
 
-	// Look up method for dispatch value
-	method := m.methods.ValueAt(dv)
+	method, err := m.GetMethod(dv)
+	if err != nil {
+		return NIL, err
+	}
 	if method == NIL {
-		// Try default
-		method = m.methods.ValueAt(m.defaultVal)
-		if method == NIL {
-			return NIL, fmt.Errorf("no method in multimethod '%s' for dispatch value: %s", m.name, dv)
-		}
+		return NIL, fmt.Errorf("no method in multimethod '%s' for dispatch value: %s", m.name, dv)
 	}
 
 	fn, ok := method.(Fn)
@@ -78,11 +225,13 @@ func (m *MultiFn) Invoke(args []Value) (Value, error) {
 		return NIL, fmt.Errorf("multimethod '%s' method is not a function", m.name)
 	}
 
-	return fn.Invoke(args)
+	return call(fn, args)
 }
 
 // Methods returns the method map.
 func (m *MultiFn) Methods() *PersistentMap {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.methods
 }
 
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 )
 
 type theNamespaceType struct{}
@@ -33,6 +34,7 @@ func SetNSLookup(fn func(string) *Namespace) {
 	nsLookup = fn
 }
 
+// Refer is never changed once it's in a namespace.
 type Refer struct {
 	ns   *Namespace
 	all  bool
@@ -41,9 +43,12 @@ type Refer struct {
 
 type Namespace struct {
 	name     string
+	mu       sync.RWMutex // guards registry, refers and aliases, sessions share namespaces
 	registry map[Symbol]*Var
 	refers   map[Symbol]*Refer
 	aliases  map[Symbol]*Namespace
+	unmapped map[Symbol]bool // symbols refers no longer provide, see Unmap
+	owner    *AccessPolicy // policy in effect when the namespace was created
 }
 
 func (n *Namespace) Type() ValueType { return NamespaceType }
@@ -59,10 +64,28 @@ func NewNamespace(name string) *Namespace {
 		registry: map[Symbol]*Var{},
 		refers:   map[Symbol]*Refer{},
 		aliases:  map[Symbol]*Namespace{},
+		unmapped: map[Symbol]bool{},
+		owner:    currentPolicy(),
 	}
 }
 
-func (n *Namespace) RegistrySize() int { return len(n.registry) }
+func (n *Namespace) RegistrySize() int {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return len(n.registry)
+}
+
+// referList returns the refers of n, so that other namespaces can be
+// looked into without holding the lock of n.
+func (n *Namespace) referList() []*Refer {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	refs := make([]*Refer, 0, len(n.refers))
+	for _, ref := range n.refers {
+		refs = append(refs, ref)
+	}
+	return refs
+}
 
 func (n *Namespace) Def(name string, val Value) *Var {
 	s := Symbol(name)
@@ -73,33 +96,134 @@ func (n *Namespace) Def(name string, val Value) *Var {
 	}
 	if f, ok := val.(*Func); ok {
 		f.SetName(name)
+		f.SetNS(n.name)
 	}
+	n.mu.Lock()
 	n.registry[s] = va
+	delete(n.unmapped, s)
+	n.mu.Unlock()
 	return va
 }
 
 // LookupLocal checks only the namespace's own registry, not refers or aliases.
 func (n *Namespace) LookupLocal(symbol Symbol) *Var {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	return n.registry[symbol]
 }
 
+// Interns returns the vars defined in the namespace itself.
+func (n *Namespace) Interns() map[Symbol]*Var {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	vars := make(map[Symbol]*Var, len(n.registry))
+	for s, v := range n.registry {
+		vars[s] = v
+	}
+	return vars
+}
+
+// Refers returns the public vars of other namespaces that can be named in
+// this namespace without qualification, either referred or imported.
+func (n *Namespace) Refers() map[Symbol]*Var {
+	vars := map[Symbol]*Var{}
+	for _, ref := range n.referList() {
+		if ref.all {
+			for s, v := range ref.ns.Interns() {
+				if !v.IsPrivate() && v.ns == ref.ns.name {
+					vars[s] = v
+				}
+			}
+			continue
+		}
+		for s := range ref.only {
+			if v := ref.ns.LookupLocal(s); v != nil && !v.IsPrivate() {
+				vars[s] = v
+			}
+		}
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for s := range n.unmapped {
+		delete(vars, s)
+	}
+	for s, v := range n.registry {
+		if v.ns != n.name {
+			vars[s] = v
+		} else {
+			delete(vars, s)
+		}
+	}
+	return vars
+}
+
+// Aliases returns the namespace aliases of this namespace.
+func (n *Namespace) Aliases() map[Symbol]*Namespace {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	aliases := make(map[Symbol]*Namespace, len(n.aliases))
+	for s, a := range n.aliases {
+		aliases[s] = a
+	}
+	return aliases
+}
+
+// Unmap removes the mapping of symbol from the namespace, be it an interned
+// var, an imported one or a referred one. Refers don't provide the symbol
+// anymore until it's defined or referred again.
+func (n *Namespace) Unmap(symbol Symbol) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.registry, symbol)
+	n.unmapped[symbol] = true
+}
+
+// Unalias removes the namespace alias.
+func (n *Namespace) Unalias(alias Symbol) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.aliases, alias)
+}
+
 func (n *Namespace) LookupOrAdd(symbol Symbol) Value {
-	val, ok := n.registry[symbol]
-	if !ok {
-		return n.Def(string(symbol), NIL)
+	return n.Intern(symbol)
+}
+
+// Intern returns the var mapped to symbol in the namespace's own registry,
+// adding an unbound one if there's none.
+func (n *Namespace) Intern(symbol Symbol) *Var {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if v, ok := n.registry[symbol]; ok {
+		return v
 	}
-	return val
+	v := NewVar(n, n.name, string(symbol))
+	n.registry[symbol] = v
+	delete(n.unmapped, symbol)
+	return v
 }
 
+// Lookup resolves symbol in the context of this namespace. Vars hidden by the
+// access policy of the calling goroutine's evaluation resolve to NIL.
 func (n *Namespace) Lookup(symbol Symbol) Value {
+	v := n.lookup(symbol)
+	if v != NIL {
+		if p := currentPolicy(); p != nil && !p.Allows(v.(*Var)) {
+			return NIL
+		}
+	}
+	return v
+}
+
+func (n *Namespace) lookup(symbol Symbol) Value {
 	sns, sym := symbol.Namespaced()
 	if sns == NIL {
-		v := n.registry[sym.(Symbol)]
-		if v == nil {
-			for _, ref := range n.refers {
-				v = ref.ns.registry[sym.(Symbol)]
+		v := n.LookupLocal(sym.(Symbol))
+		if v == nil && !n.isUnmapped(sym.(Symbol)) {
+			for _, ref := range n.referList() {
+				v = ref.ns.LookupLocal(sym.(Symbol))
 				if v != nil {
-					if v.isPrivate {
+					if v.IsPrivate() {
 						return NIL
 					}
 					return v
@@ -112,9 +236,9 @@ func (n *Namespace) Lookup(symbol Symbol) Value {
 		return v
 	}
 	// Alias-qualified resolution via aliases
-	if target, ok := n.aliases[sns.(Symbol)]; ok {
-		v := target.registry[sym.(Symbol)]
-		if v == nil || v.isPrivate {
+	if target := n.ResolveAlias(sns.(Symbol)); target != nil {
+		v := target.LookupLocal(sym.(Symbol))
+		if v == nil || v.IsPrivate() {
 			return NIL
 		}
 		return v
@@ -122,16 +246,19 @@ func (n *Namespace) Lookup(symbol Symbol) Value {
 	// Fallback: direct namespace lookup from global registry
 	if nsLookup != nil {
 		if target := nsLookup(string(sns.(Symbol))); target != nil {
-			v := target.registry[sym.(Symbol)]
-			if v != nil && !v.isPrivate {
+			v := target.LookupLocal(sym.(Symbol))
+			if v != nil && !v.IsPrivate() {
 				return v
 			}
 		}
 	}
 	// Fallback via refers
-	if refer, ok := n.refers[sns.(Symbol)]; ok {
-		v := refer.ns.registry[sym.(Symbol)]
-		if v == nil || v.isPrivate {
+	n.mu.RLock()
+	refer, ok := n.refers[sns.(Symbol)]
+	n.mu.RUnlock()
+	if ok {
+		v := refer.ns.LookupLocal(sym.(Symbol))
+		if v == nil || v.IsPrivate() {
 			return NIL
 		}
 		if !refer.all {
@@ -152,11 +279,20 @@ func (n *Namespace) Refer(ns *Namespace, alias string, all bool) {
 	if alias != "" {
 		nom = alias
 	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.refers[Symbol(nom)] = &Refer{
 		all:  all,
 		ns:   ns,
 		only: nil,
 	}
+	if all {
+		for s := range n.unmapped {
+			if ns == n || ns.LookupLocal(s) != nil {
+				delete(n.unmapped, s)
+			}
+		}
+	}
 }
 
 // ReferList refers only selected symbols from the given namespace into this namespace.
@@ -165,34 +301,52 @@ func (n *Namespace) ReferList(ns *Namespace, symbols []Symbol) {
 	for _, s := range symbols {
 		set[s] = true
 	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.refers[Symbol(ns.Name())] = &Refer{
 		ns:   ns,
 		all:  false,
 		only: set,
 	}
+	for _, s := range symbols {
+		delete(n.unmapped, s)
+	}
 }
 
 // Alias creates a symbol alias to another namespace in this namespace.
 func (n *Namespace) Alias(alias Symbol, target *Namespace) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.aliases[alias] = target
 }
 
 // ImportVar links a var from another namespace into this namespace under the given alias.
 // Returns true when the var exists and is not private.
 func (n *Namespace) ImportVar(from *Namespace, name Symbol, alias Symbol) bool {
-	v := from.registry[name]
-	if v == nil || v.isPrivate {
+	v := from.LookupLocal(name)
+	if v == nil || v.IsPrivate() {
 		return false
 	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
 	n.registry[alias] = v
+	delete(n.unmapped, alias)
 	return true
 }
 
 // ResolveAlias returns the namespace for the given alias, or nil.
 func (n *Namespace) ResolveAlias(alias Symbol) *Namespace {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
 	return n.aliases[alias]
 }
 
+func (n *Namespace) isUnmapped(symbol Symbol) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.unmapped[symbol]
+}
+
 func (n *Namespace) Name() string {
 	return n.name
 }
@@ -203,12 +357,16 @@ func (n *Namespace) String() string {
 
 func FuzzySymbolLookup(ns *Namespace, s Symbol, lookupPrivate bool) []Symbol {
 	ret := []Symbol{}
-	for _, r := range ns.refers {
-		ret = append(ret, FuzzySymbolLookup(r.ns, s, false)...)
+	for _, r := range ns.referList() {
+		for _, k := range FuzzySymbolLookup(r.ns, s, false) {
+			if !ns.isUnmapped(k) {
+				ret = append(ret, k)
+			}
+		}
 	}
-	for k := range ns.registry {
+	for k, v := range ns.Interns() {
 		if strings.HasPrefix(string(k), string(s)) {
-			if ns.registry[k].isPrivate && !lookupPrivate {
+			if v.IsPrivate() && !lookupPrivate {
 				continue
 			}
 			ret = append(ret, k)
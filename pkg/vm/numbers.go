@@ -9,6 +9,7 @@ import (
 // Numeric type promotion and arithmetic dispatch.
 // All functions use direct type assertions (no Unbox) to avoid allocation.
 // Promotion: Int op BigInt → BigInt, BigInt op Float → Float, Int op Float → Float.
+// Ratios and BigDecimals are handled by the tower fallbacks in numtower.go.
 
 // NumAdd adds two numeric Values.
 func NumAdd(a, b Value) (Value, error) {
@@ -50,6 +51,9 @@ func NumAdd(a, b Value) (Value, error) {
 			return NewBigInt(r), nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		return towerArith(opAdd, a, b)
+	}
 	return NIL, fmt.Errorf("cannot add %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -93,6 +97,9 @@ func NumSub(a, b Value) (Value, error) {
 			return NewBigInt(r), nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		return towerArith(opSub, a, b)
+	}
 	return NIL, fmt.Errorf("cannot subtract %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -136,10 +143,13 @@ func NumMul(a, b Value) (Value, error) {
 			return NewBigInt(r), nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		return towerArith(opMul, a, b)
+	}
 	return NIL, fmt.Errorf("cannot multiply %s and %s", a.Type().Name(), b.Type().Name())
 }
 
-// NumDiv divides a by b. Int/Int returns Float when not exact.
+// NumDiv divides a by b. Int/Int returns a Ratio when not exact.
 func NumDiv(a, b Value) (Value, error) {
 	switch av := a.(type) {
 	case Int:
@@ -151,7 +161,7 @@ func NumDiv(a, b Value) (Value, error) {
 			if int(av)%int(bv) == 0 {
 				return MakeInt(int(av) / int(bv)), nil
 			}
-			return Float(float64(av) / float64(bv)), nil
+			return NewRatio(big.NewRat(int64(av), int64(bv))), nil
 		case Float:
 			// Int/Float: IEEE 754 semantics (allows Inf)
 			return Float(float64(av) / float64(bv)), nil
@@ -159,16 +169,7 @@ func NumDiv(a, b Value) (Value, error) {
 			if bv.val.Sign() == 0 {
 				return NIL, fmt.Errorf("divide by zero")
 			}
-			ai := big.NewInt(int64(av))
-			mod := new(big.Int).Mod(ai, bv.val)
-			if mod.Sign() == 0 {
-				r := new(big.Int).Div(ai, bv.val)
-				return NewBigInt(r), nil
-			}
-			af := new(big.Float).SetInt(ai)
-			bf := new(big.Float).SetInt(bv.val)
-			r, _ := new(big.Float).Quo(af, bf).Float64()
-			return Float(r), nil
+			return NewRatio(new(big.Rat).SetFrac(big.NewInt(int64(av)), bv.val)), nil
 		}
 	case Float:
 		switch bv := b.(type) {
@@ -194,16 +195,7 @@ func NumDiv(a, b Value) (Value, error) {
 			if int(bv) == 0 {
 				return NIL, fmt.Errorf("divide by zero")
 			}
-			bi := big.NewInt(int64(bv))
-			mod := new(big.Int).Mod(av.val, bi)
-			if mod.Sign() == 0 {
-				r := new(big.Int).Div(av.val, bi)
-				return NewBigInt(r), nil
-			}
-			af := new(big.Float).SetInt(av.val)
-			bf := new(big.Float).SetInt64(int64(bv))
-			r, _ := new(big.Float).Quo(af, bf).Float64()
-			return Float(r), nil
+			return NewRatio(new(big.Rat).SetFrac(av.val, big.NewInt(int64(bv)))), nil
 		case Float:
 			if float64(bv) == 0 {
 				return NIL, fmt.Errorf("divide by zero")
@@ -216,17 +208,12 @@ func NumDiv(a, b Value) (Value, error) {
 			if bv.val.Sign() == 0 {
 				return NIL, fmt.Errorf("divide by zero")
 			}
-			mod := new(big.Int).Mod(av.val, bv.val)
-			if mod.Sign() == 0 {
-				r := new(big.Int).Div(av.val, bv.val)
-				return NewBigInt(r), nil
-			}
-			af := new(big.Float).SetInt(av.val)
-			bf := new(big.Float).SetInt(bv.val)
-			r, _ := new(big.Float).Quo(af, bf).Float64()
-			return Float(r), nil
+			return NewRatio(new(big.Rat).SetFrac(av.val, bv.val)), nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		return towerArith(opDiv, a, b)
+	}
 	return NIL, fmt.Errorf("cannot divide %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -281,6 +268,9 @@ func NumQuot(a, b Value) (Value, error) {
 			return NewBigInt(r), nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		return towerIntegerOp("quot", a, b)
+	}
 	return NIL, fmt.Errorf("cannot quot %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -336,6 +326,9 @@ func NumRem(a, b Value) (Value, error) {
 			return NewBigInt(r), nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		return towerIntegerOp("rem", a, b)
+	}
 	return NIL, fmt.Errorf("cannot rem %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -407,6 +400,9 @@ func NumMod(a, b Value) (Value, error) {
 			return NewBigInt(r), nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		return towerIntegerOp("mod", a, b)
+	}
 	return NIL, fmt.Errorf("cannot mod %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -419,6 +415,10 @@ func NumNeg(a Value) (Value, error) {
 		return Float(-float64(av)), nil
 	case *BigInt:
 		return MaybeDowngrade(new(big.Int).Neg(av.val)), nil
+	case *Ratio:
+		return NewRatio(new(big.Rat).Neg(av.val)), nil
+	case *BigDecimal:
+		return &BigDecimal{unscaled: new(big.Int).Neg(av.unscaled), scale: av.scale}, nil
 	}
 	return NIL, fmt.Errorf("cannot negate %s", a.Type().Name())
 }
@@ -440,6 +440,10 @@ func NumAbs(a Value) (Value, error) {
 		return Float(v), nil
 	case *BigInt:
 		return MaybeDowngrade(new(big.Int).Abs(av.val)), nil
+	case *Ratio:
+		return NewRatio(new(big.Rat).Abs(av.val)), nil
+	case *BigDecimal:
+		return &BigDecimal{unscaled: new(big.Int).Abs(av.unscaled), scale: av.scale}, nil
 	}
 	return NIL, fmt.Errorf("cannot abs %s", a.Type().Name())
 }
@@ -480,6 +484,10 @@ func NumGt(a, b Value) (bool, error) {
 			return av.val.Cmp(bv.val) > 0, nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		c, err := towerCompare(a, b)
+		return c > 0, err
+	}
 	return false, fmt.Errorf("cannot compare %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -516,6 +524,10 @@ func NumLt(a, b Value) (bool, error) {
 			return av.val.Cmp(bv.val) < 0, nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		c, err := towerCompare(a, b)
+		return c < 0, err
+	}
 	return false, fmt.Errorf("cannot compare %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -551,6 +563,10 @@ func NumGe(a, b Value) (bool, error) {
 			return av.val.Cmp(bv.val) >= 0, nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		c, err := towerCompare(a, b)
+		return c >= 0, err
+	}
 	return false, fmt.Errorf("cannot compare %s and %s", a.Type().Name(), b.Type().Name())
 }
 
@@ -586,10 +602,17 @@ func NumLe(a, b Value) (bool, error) {
 			return av.val.Cmp(bv.val) <= 0, nil
 		}
 	}
+	if isTowerNum(a) || isTowerNum(b) {
+		c, err := towerCompare(a, b)
+		return c <= 0, err
+	}
 	return false, fmt.Errorf("cannot compare %s and %s", a.Type().Name(), b.Type().Name())
 }
 
-// NumEq tests numeric equality (cross-type: 1 == 1.0 is true).
+// NumEq tests numeric equality as = does: numbers of any type are equal when
+// their values are exactly the same (1 equals 1.0 and 1/2 equals 0.5, but 1/10
+// doesn't equal 0.1, which is a binary fraction slightly off). Being exact
+// keeps it transitive, and lets equal numbers hash alike.
 func NumEq(a, b Value) bool {
 	switch av := a.(type) {
 	case Int:
@@ -597,14 +620,14 @@ func NumEq(a, b Value) bool {
 		case Int:
 			return int(av) == int(bv)
 		case Float:
-			return float64(av) == float64(bv)
+			return floatEqualsInt(bv, int64(av))
 		case *BigInt:
 			return big.NewInt(int64(av)).Cmp(bv.val) == 0
 		}
 	case Float:
 		switch bv := b.(type) {
 		case Int:
-			return float64(av) == float64(int(bv))
+			return floatEqualsInt(av, int64(bv))
 		case Float:
 			return float64(av) == float64(bv)
 		}
@@ -616,19 +639,50 @@ func NumEq(a, b Value) bool {
 			return av.val.Cmp(bv.val) == 0
 		}
 	}
-	return false
+	ra, rb := exactRat(a), exactRat(b)
+	return ra != nil && rb != nil && ra.Cmp(rb) == 0
+}
+
+// floatEqualsInt tells whether f is exactly i.
+func floatEqualsInt(f Float, i int64) bool {
+	// float64(1<<63) is the first float beyond int64
+	if f != Float(math.Trunc(float64(f))) || f < -(1<<63) || f >= 1<<63 {
+		return false
+	}
+	return int64(f) == i
 }
 
-// IsNumber returns true if the value is Int, Float, or BigInt.
+// exactRat returns the exact value of a number, nil if it has none (NaN and
+// infinities) or v isn't a number.
+func exactRat(v Value) *big.Rat {
+	if f, ok := v.(Float); ok {
+		if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+			return nil
+		}
+		return new(big.Rat).SetFloat64(float64(f))
+	}
+	return toRat(v)
+}
+
+// NumEquiv tests numeric equality as == does, across all number types.
+func NumEquiv(a, b Value) bool {
+	if isTowerNum(a) || isTowerNum(b) {
+		c, err := towerCompare(a, b)
+		return err == nil && c == 0
+	}
+	return NumEq(a, b)
+}
+
+// IsNumber returns true if the value is Int, Float, BigInt, Ratio or BigDecimal.
 func IsNumber(v Value) bool {
 	switch v.(type) {
-	case Int, Float, *BigInt:
+	case Int, Float, *BigInt, *Ratio, *BigDecimal:
 		return true
 	}
 	return false
 }
 
-// ToFloat converts an Int, Float, or BigInt to float64.
+// ToFloat converts a number to float64.
 func ToFloat(v Value) (float64, bool) {
 	switch n := v.(type) {
 	case Int:
@@ -638,6 +692,11 @@ func ToFloat(v Value) (float64, bool) {
 	case *BigInt:
 		f, _ := new(big.Float).SetInt(n.val).Float64()
 		return f, true
+	case *Ratio:
+		f, _ := n.val.Float64()
+		return f, true
+	case *BigDecimal:
+		return n.Float64(), true
 	}
 	return 0, false
 }
@@ -654,6 +713,13 @@ func ToInt(v Value) (int, bool) {
 			return int(n.val.Int64()), true
 		}
 		return 0, false
+	case *Ratio, *BigDecimal:
+		r := toRat(n)
+		i := new(big.Int).Quo(r.Num(), r.Denom())
+		if i.IsInt64() {
+			return int(i.Int64()), true
+		}
+		return 0, false
 	}
 	return 0, false
 }
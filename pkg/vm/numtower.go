@@ -0,0 +1,406 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Arithmetic on Ratios and BigDecimals. The Num* functions handle Int, Float
+// and BigInt inline and fall back to towerArith when a Ratio or BigDecimal is
+// involved. Promotion follows Clojure: Float wins over everything, then
+// BigDecimal, then Ratio, then the integers.
+
+// RoundingMode selects how BigDecimal results are rounded to a MathContext
+// precision, named like java.math.RoundingMode.
+type RoundingMode int
+
+const (
+	RoundHalfUp RoundingMode = iota
+	RoundHalfEven
+	RoundHalfDown
+	RoundUp
+	RoundDown
+	RoundCeiling
+	RoundFloor
+	RoundUnnecessary
+)
+
+var roundingModes = map[string]RoundingMode{
+	"HALF_UP":     RoundHalfUp,
+	"HALF_EVEN":   RoundHalfEven,
+	"HALF_DOWN":   RoundHalfDown,
+	"UP":          RoundUp,
+	"DOWN":        RoundDown,
+	"CEILING":     RoundCeiling,
+	"FLOOR":       RoundFloor,
+	"UNNECESSARY": RoundUnnecessary,
+}
+
+// ParseRoundingMode looks up a rounding mode by its Java name, e.g. HALF_EVEN.
+func ParseRoundingMode(name string) (RoundingMode, bool) {
+	m, ok := roundingModes[name]
+	return m, ok
+}
+
+// MathContext limits the number of significant digits of BigDecimal results.
+type MathContext struct {
+	Precision int
+	Rounding  RoundingMode
+}
+
+var currentMathContext = func() *MathContext { return nil }
+
+// SetMathContextLookup sets the function returning the math context in
+// effect, i.e. the value of *math-context* (called by rt package during init).
+func SetMathContextLookup(fn func() *MathContext) {
+	currentMathContext = fn
+}
+
+type numCategory int
+
+const (
+	catInteger numCategory = iota
+	catRatio
+	catDecimal
+	catFloat
+)
+
+func categoryOf(v Value) (numCategory, bool) {
+	switch v.(type) {
+	case Int, *BigInt:
+		return catInteger, true
+	case *Ratio:
+		return catRatio, true
+	case *BigDecimal:
+		return catDecimal, true
+	case Float:
+		return catFloat, true
+	}
+	return 0, false
+}
+
+func isTowerNum(v Value) bool {
+	switch v.(type) {
+	case *Ratio, *BigDecimal:
+		return true
+	}
+	return false
+}
+
+// toRat converts an exact number to a fraction.
+func toRat(v Value) *big.Rat {
+	switch n := v.(type) {
+	case Int:
+		return new(big.Rat).SetInt64(int64(n))
+	case *BigInt:
+		return new(big.Rat).SetInt(n.val)
+	case *Ratio:
+		return n.val
+	case *BigDecimal:
+		return n.Rat()
+	}
+	return nil
+}
+
+func toBigInt(v Value) *big.Int {
+	switch n := v.(type) {
+	case Int:
+		return big.NewInt(int64(n))
+	case *BigInt:
+		return n.val
+	}
+	return nil
+}
+
+// ToBigDecimal converts an exact number to a BigDecimal. Ratios without an
+// exact decimal representation need a math context.
+func ToBigDecimal(v Value) (*BigDecimal, error) {
+	switch n := v.(type) {
+	case Int, *BigInt:
+		return &BigDecimal{unscaled: toBigInt(n), scale: 0}, nil
+	case *Ratio:
+		return ratToDecimal(n.val, 0, currentMathContext())
+	case *BigDecimal:
+		return n, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s to BigDecimal", v.Type().Name())
+}
+
+type numOp int
+
+const (
+	opAdd numOp = iota
+	opSub
+	opMul
+	opDiv
+)
+
+// towerArith performs op when at least one operand is a Ratio or BigDecimal.
+func towerArith(op numOp, a, b Value) (Value, error) {
+	ca, oka := categoryOf(a)
+	cb, okb := categoryOf(b)
+	if !oka || !okb {
+		return NIL, fmt.Errorf("%s is not a number", nonNumber(a, b).Type().Name())
+	}
+	switch max(ca, cb) {
+	case catFloat:
+		af, _ := ToFloat(a)
+		bf, _ := ToFloat(b)
+		switch op {
+		case opAdd:
+			return Float(af + bf), nil
+		case opSub:
+			return Float(af - bf), nil
+		case opMul:
+			return Float(af * bf), nil
+		default:
+			return Float(af / bf), nil
+		}
+	case catDecimal:
+		ad, err := ToBigDecimal(a)
+		if err != nil {
+			return NIL, err
+		}
+		bd, err := ToBigDecimal(b)
+		if err != nil {
+			return NIL, err
+		}
+		mc := currentMathContext()
+		switch op {
+		case opAdd, opSub:
+			scale := max(ad.scale, bd.scale)
+			x, y := ad.withScale(scale).unscaled, bd.withScale(scale).unscaled
+			if op == opAdd {
+				return roundDecimal(&BigDecimal{unscaled: new(big.Int).Add(x, y), scale: scale}, mc)
+			}
+			return roundDecimal(&BigDecimal{unscaled: new(big.Int).Sub(x, y), scale: scale}, mc)
+		case opMul:
+			return roundDecimal(&BigDecimal{unscaled: new(big.Int).Mul(ad.unscaled, bd.unscaled), scale: ad.scale + bd.scale}, mc)
+		default:
+			if bd.unscaled.Sign() == 0 {
+				return NIL, fmt.Errorf("divide by zero")
+			}
+			q := new(big.Rat).Quo(ad.Rat(), bd.Rat())
+			return ratToDecimal(q, ad.scale-bd.scale, mc)
+		}
+	default:
+		ar, br := toRat(a), toRat(b)
+		r := new(big.Rat)
+		switch op {
+		case opAdd:
+			r.Add(ar, br)
+		case opSub:
+			r.Sub(ar, br)
+		case opMul:
+			r.Mul(ar, br)
+		default:
+			if br.Sign() == 0 {
+				return NIL, fmt.Errorf("divide by zero")
+			}
+			r.Quo(ar, br)
+		}
+		return NewRatio(r), nil
+	}
+}
+
+// towerIntegerOp implements quot, rem and mod when a Ratio or BigDecimal is
+// involved. Like in Clojure, rem and mod are derived from quot.
+func towerIntegerOp(name string, a, b Value) (Value, error) {
+	ca, oka := categoryOf(a)
+	cb, okb := categoryOf(b)
+	if !oka || !okb {
+		return NIL, fmt.Errorf("cannot %s %s and %s", name, a.Type().Name(), b.Type().Name())
+	}
+	if max(ca, cb) == catFloat {
+		af, _ := ToFloat(a)
+		bf, _ := ToFloat(b)
+		switch name {
+		case "quot":
+			return NumQuot(Float(af), Float(bf))
+		case "rem":
+			return NumRem(Float(af), Float(bf))
+		default:
+			return NumMod(Float(af), Float(bf))
+		}
+	}
+	br := toRat(b)
+	if br.Sign() == 0 {
+		return NIL, fmt.Errorf("divide by zero")
+	}
+	q := new(big.Rat).Quo(toRat(a), br)
+	qi := new(big.Int).Quo(q.Num(), q.Denom())
+	var quot Value = MaybeDowngrade(qi)
+	if max(ca, cb) == catDecimal {
+		ad, _ := ToBigDecimal(a)
+		bd, _ := ToBigDecimal(b)
+		quot = &BigDecimal{unscaled: qi, scale: 0}
+		if s := ad.scale - bd.scale; s > 0 {
+			quot = quot.(*BigDecimal).withScale(s)
+		}
+	}
+	if name == "quot" {
+		return quot, nil
+	}
+	p, err := NumMul(b, quot)
+	if err != nil {
+		return NIL, err
+	}
+	rem, err := NumSub(a, p)
+	if err != nil || name == "rem" {
+		return rem, err
+	}
+	rr := toRat(rem)
+	if rr.Sign() == 0 || rr.Sign() == br.Sign() {
+		return rem, nil
+	}
+	return NumAdd(rem, b)
+}
+
+// towerCompare orders two numbers when a Ratio or BigDecimal is involved.
+func towerCompare(a, b Value) (int, error) {
+	ca, oka := categoryOf(a)
+	cb, okb := categoryOf(b)
+	if !oka || !okb {
+		return 0, fmt.Errorf("cannot compare %s and %s", a.Type().Name(), b.Type().Name())
+	}
+	if max(ca, cb) == catFloat {
+		af, _ := ToFloat(a)
+		bf, _ := ToFloat(b)
+		switch {
+		case af < bf:
+			return -1, nil
+		case af > bf:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return toRat(a).Cmp(toRat(b)), nil
+}
+
+func nonNumber(a, b Value) Value {
+	if _, ok := categoryOf(a); !ok {
+		return a
+	}
+	return b
+}
+
+// ratToDecimal converts r to a BigDecimal. The result uses the preferred
+// scale when it's exact at that scale. Without a math context r must have a
+// terminating decimal expansion, otherwise the result is rounded to the
+// context precision.
+func ratToDecimal(r *big.Rat, preferred int, mc *MathContext) (*BigDecimal, error) {
+	num, den := r.Num(), r.Denom()
+	// r terminates iff its denominator has no prime factors besides 2 and 5
+	rest := new(big.Int).Set(den)
+	twos, fives := 0, 0
+	two, five, m := big.NewInt(2), big.NewInt(5), new(big.Int)
+	for m.Mod(rest, two).Sign() == 0 {
+		rest.Quo(rest, two)
+		twos++
+	}
+	for m.Mod(rest, five).Sign() == 0 {
+		rest.Quo(rest, five)
+		fives++
+	}
+	if rest.Cmp(big.NewInt(1)) == 0 {
+		scale := max(twos, fives)
+		u := new(big.Int).Mul(num, pow10(scale))
+		u.Quo(u, den)
+		d := (&BigDecimal{unscaled: u, scale: scale}).stripZeros(preferred)
+		if d.scale < preferred {
+			d = d.withScale(preferred)
+		}
+		if mc == nil || numDigits(d.unscaled) <= mc.Precision {
+			return d, nil
+		}
+		return roundDecimal(d, mc)
+	}
+	if mc == nil || mc.Precision == 0 {
+		return nil, fmt.Errorf("non-terminating decimal expansion; no exact representable decimal result")
+	}
+	// find e such that 10^e <= |r| < 10^(e+1)
+	an := new(big.Int).Abs(num)
+	e := numDigits(an) - numDigits(den)
+	lhs, rhs := new(big.Int).Set(an), new(big.Int).Set(den)
+	if e >= 0 {
+		rhs.Mul(rhs, pow10(e))
+	} else {
+		lhs.Mul(lhs, pow10(-e))
+	}
+	if lhs.Cmp(rhs) < 0 {
+		e--
+	}
+	scale := mc.Precision - 1 - e
+	n, dd := new(big.Int).Set(num), new(big.Int).Set(den)
+	if scale >= 0 {
+		n.Mul(n, pow10(scale))
+	} else {
+		dd.Mul(dd, pow10(-scale))
+	}
+	u, err := roundQuo(n, dd, mc.Rounding)
+	if err != nil {
+		return nil, err
+	}
+	d, err := roundDecimal(&BigDecimal{unscaled: u, scale: scale}, mc)
+	if err != nil {
+		return nil, err
+	}
+	return d.stripZeros(preferred), nil
+}
+
+// roundDecimal rounds d to the precision of mc.
+func roundDecimal(d *BigDecimal, mc *MathContext) (*BigDecimal, error) {
+	if mc == nil || mc.Precision == 0 {
+		return d, nil
+	}
+	for {
+		drop := numDigits(d.unscaled) - mc.Precision
+		if drop <= 0 {
+			return d, nil
+		}
+		u, err := roundQuo(d.unscaled, pow10(drop), mc.Rounding)
+		if err != nil {
+			return nil, err
+		}
+		d = &BigDecimal{unscaled: u, scale: d.scale - drop}
+	}
+}
+
+// roundQuo divides n by d, rounding the quotient with mode.
+func roundQuo(n, d *big.Int, mode RoundingMode) (*big.Int, error) {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 {
+		return q, nil
+	}
+	sign := n.Sign() * d.Sign()
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	cmp := half.Cmp(new(big.Int).Abs(d))
+	away := false
+	switch mode {
+	case RoundUp:
+		away = true
+	case RoundDown:
+	case RoundCeiling:
+		away = sign > 0
+	case RoundFloor:
+		away = sign < 0
+	case RoundHalfUp:
+		away = cmp >= 0
+	case RoundHalfDown:
+		away = cmp > 0
+	case RoundHalfEven:
+		away = cmp > 0 || (cmp == 0 && q.Bit(0) == 1)
+	case RoundUnnecessary:
+		return nil, fmt.Errorf("rounding necessary")
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q, nil
+}
@@ -0,0 +1,221 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMaxCallDepth is how deep let-go calls may nest unless changed with
+// SetMaxCallDepth. It's well below the depth at which the Go runtime would
+// run out of stack and kill the process.
+const DefaultMaxCallDepth = 100000
+
+var maxCallDepth atomic.Int64
+
+func init() {
+	maxCallDepth.Store(DefaultMaxCallDepth)
+}
+
+// SetMaxCallDepth sets how deep let-go calls may nest, on any goroutine,
+// before failing with a StackOverflowError. Zero or less disables the check.
+func SetMaxCallDepth(n int) {
+	maxCallDepth.Store(int64(n))
+}
+
+// MaxCallDepth returns the current call depth limit, 0 if there's none.
+func MaxCallDepth() int {
+	n := maxCallDepth.Load()
+	if n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+// StackOverflowError is returned when let-go calls nest deeper than
+// MaxCallDepth. Unlike a LimitError it can be caught by try/catch.
+type StackOverflowError struct {
+	Depth int
+}
+
+func (e *StackOverflowError) Error() string {
+	return fmt.Sprintf("StackOverflowError: exceeded call depth of %d", e.Depth)
+}
+
+// AsStackOverflowError finds a StackOverflowError in the cause chain of err.
+func AsStackOverflowError(err error) (*StackOverflowError, bool) {
+	var se *StackOverflowError
+	ok := errors.As(err, &se)
+	return se, ok
+}
+
+// invokeFn calls fn from Go code, where there's no calling frame.
+func invokeFn(fn Fn, args []Value) (Value, error) {
+	return fn.Invoke(args)
+}
+
+// callChain is shared by the frames of a goroutine's let-go calls, which
+// note their depth in it before calling native code. Functions the native
+// calls back then continue from that depth rather than from zero, so that
+// recursion through apply, map or swap! is caught as well.
+type callChain struct {
+	depth int
+	gid   uint64
+}
+
+// chainSlots holds the chains of running goroutines, at the slot picked by
+// chainSlot from their gid. Natives like reduce call back into let-go once
+// per item, so finding the chain has to be cheap. Chains of goroutines
+// whose slot is taken go to callChains instead.
+var chainSlots [256]atomic.Pointer[callChain]
+
+var callChains sync.Map
+
+func chainSlot(id uint64) *atomic.Pointer[callChain] {
+	return &chainSlots[(id*0x9e3779b97f4a7c15)>>56]
+}
+
+// joinChain puts f, run from Go code, on the call chain of the calling
+// goroutine, below the frame whose native call led to it. Without one, f
+// starts a chain and the returned function ends it. Ending a chain also
+// drops dynamic bindings pushed and never popped during it, so that they
+// don't outlive the call and get picked up by a later goroutine reusing the
+// gid.
+func (f *Frame) joinChain() (func(), error) {
+	id := gid()
+	slot := chainSlot(id)
+	c := slot.Load()
+	if c == nil || c.gid != id {
+		if cc, ok := callChains.Load(id); ok {
+			c = cc.(*callChain)
+		} else {
+			c = nil
+		}
+	}
+	if c != nil {
+		f.chain = c
+		f.depth = c.depth + 1
+		if max := maxCallDepth.Load(); max > 0 && int64(f.depth) > max {
+			return nil, &StackOverflowError{Depth: int(max)}
+		}
+		return nil, nil
+	}
+	c = &callChain{gid: id}
+	f.chain = c
+	if !slot.CompareAndSwap(nil, c) {
+		callChains.Store(id, c)
+	}
+	bindings := currentFrame()
+	return func() {
+		if !slot.CompareAndSwap(c, nil) {
+			callChains.Delete(id)
+		}
+		if currentFrame() != bindings {
+			ResetThreadBindingFrame(bindings)
+		}
+	}, nil
+}
+
+// aloneRuns counts let-go calls from Go code running on a chain of their own
+// rather than the one of their goroutine.
+var aloneRuns atomic.Int32
+
+// maxAloneRuns bounds aloneRuns. Recursion through natives nests such calls
+// until it's reached, after which they join the chains of their goroutines
+// and count towards the depth limit again.
+const maxAloneRuns = 64
+
+// runAlone runs f on a new chain without looking up the calling goroutine.
+// Natives f calls then can't pass their depth on to the functions they call
+// back, and bindings f leaves behind can't be told from those of an
+// enclosing call, so it's only done while no goroutine has bindings and
+// few such calls run at once.
+func (f *Frame) runAlone() (Value, error) {
+	f.chain = &callChain{}
+	aloneRuns.Add(1)
+	defer func() {
+		aloneRuns.Add(-1)
+		if framesLive.Load() != 0 && currentFrame() != nil {
+			ResetThreadBindingFrame(nil)
+		}
+	}()
+	return f.Run()
+}
+
+// call invokes fn from f. Let-go functions run one level deeper than f,
+// which is how runaway recursion gets caught before it exhausts the Go
+// stack. Callables dispatching to let-go functions pass the depth on, and
+// natives leave it in the call chain for the functions they call back.
+func (f *Frame) call(fn Fn, args []Value) (Value, error) {
+	var nf *Frame
+	var err error
+	switch fn := fn.(type) {
+	case *Func:
+		nf, err = fn.frame(args)
+	case *Closure:
+		nf, err = fn.fn.frame(args)
+		if nf != nil {
+			nf.closedOvers = fn.closedOvers
+		}
+	case *MultiArityFn:
+		v, err := fn.variant(len(args))
+		if err != nil {
+			return NIL, err
+		}
+		return f.call(v, args)
+	case *MultiFn:
+		return fn.invoke(args, f.call)
+	case *ProtocolFn:
+		return fn.invoke(args, f.call)
+	case *Var:
+		if root, ok := fn.Root().(Fn); ok {
+			return f.call(root, args)
+		}
+		return fn.Invoke(args)
+	default:
+		c := f.chain
+		if c == nil {
+			if f.env != nil {
+				return invokeIn(f.env, fn, args)
+			}
+			return fn.Invoke(args)
+		}
+		prev := c.depth
+		c.depth = f.depth
+		var result Value
+		if f.env != nil {
+			result, err = invokeIn(f.env, fn, args)
+		} else {
+			result, err = fn.Invoke(args)
+		}
+		c.depth = prev
+		return result, err
+	}
+	if err != nil {
+		return NIL, err
+	}
+	nf.depth = f.depth + 1
+	nf.parent = f
+	nf.chain = f.chain
+	if max := maxCallDepth.Load(); max > 0 && int64(nf.depth) > max {
+		ReleaseFrame(nf)
+		return NIL, &StackOverflowError{Depth: int(max)}
+	}
+	if f.env != nil {
+		nf.setEnv(f.env)
+		return runFrame(nf)
+	}
+	if envsActive.Load() != 0 {
+		nf.setEnv(currentEnv())
+		return runFrame(nf)
+	}
+	result, err := nf.Run()
+	ReleaseFrame(nf)
+	return result, err
+}
@@ -665,6 +665,9 @@ func (m *PersistentMap) entries() []Value {
 // --- Equals ---
 
 func (m *PersistentMap) Equals(other Value) bool {
+	if sm, ok := other.(*PersistentSortedMap); ok {
+		return sm.Equals(m)
+	}
 	o, ok := other.(*PersistentMap)
 	if !ok {
 		return false
@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// Field numbers of the messages in pprof's profile.proto.
+const (
+	pprofProfileSampleType    = 1
+	pprofProfileSample        = 2
+	pprofProfileLocation      = 4
+	pprofProfileFunction      = 5
+	pprofProfileStringTable   = 6
+	pprofProfileTimeNanos     = 9
+	pprofProfileDurationNanos = 10
+	pprofProfilePeriodType    = 11
+	pprofProfilePeriod        = 12
+
+	pprofValueTypeType = 1
+	pprofValueTypeUnit = 2
+
+	pprofSampleLocationID = 1
+	pprofSampleValue      = 2
+
+	pprofLocationID   = 1
+	pprofLocationLine = 4
+
+	pprofLineFunctionID = 1
+	pprofLineLine       = 2
+
+	pprofFunctionID         = 1
+	pprofFunctionName       = 2
+	pprofFunctionSystemName = 3
+	pprofFunctionFilename   = 4
+)
+
+// protoBuffer is a minimal protobuf encoder, enough for profile.proto.
+type protoBuffer struct {
+	data []byte
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protoBuffer) key(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int64(field int, x int64) {
+	b.uint64(field, uint64(x))
+}
+
+func (b *protoBuffer) bytes(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	b.bytes(field, []byte(s))
+}
+
+func (b *protoBuffer) message(field int, fill func(m *protoBuffer)) {
+	m := &protoBuffer{}
+	fill(m)
+	b.bytes(field, m.data)
+}
+
+func (b *protoBuffer) packedUint64(field int, xs []uint64) {
+	p := &protoBuffer{}
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.bytes(field, p.data)
+}
+
+// WritePprof writes the profile in gzipped pprof protobuf format, readable by
+// go tool pprof. Functions are named after let-go functions, with the lines
+// they were at when sampled.
+func (p *Profile) WritePprof(w io.Writer) error {
+	strs := []string{""}
+	strIndex := map[string]int64{"": 0}
+	str := func(s string) int64 {
+		if i, ok := strIndex[s]; ok {
+			return i
+		}
+		strIndex[s] = int64(len(strs))
+		strs = append(strs, s)
+		return strIndex[s]
+	}
+
+	type funcKey struct{ name, file string }
+	type locKey struct {
+		fn   uint64
+		line int
+	}
+	funcs := map[funcKey]uint64{}
+	locs := map[locKey]uint64{}
+	var funcList []funcKey
+	var locList []locKey
+
+	out := &protoBuffer{}
+	valueType := func(field int, typ, unit string) {
+		out.message(field, func(m *protoBuffer) {
+			m.int64(pprofValueTypeType, str(typ))
+			m.int64(pprofValueTypeUnit, str(unit))
+		})
+	}
+	valueType(pprofProfileSampleType, "samples", "count")
+	valueType(pprofProfileSampleType, "cpu", "nanoseconds")
+
+	for _, s := range p.Samples {
+		ids := make([]uint64, len(s.Stack))
+		for i, sf := range s.Stack {
+			fk := funcKey{name: sf.Name()}
+			if sf.Fn == "" {
+				// pprof strips anything in angle brackets from names
+				fk.name = "top level"
+			}
+			line := 0
+			if sf.Source != nil {
+				fk.file = sf.Source.File
+				line = sf.Source.Line + 1
+			}
+			fid, ok := funcs[fk]
+			if !ok {
+				funcList = append(funcList, fk)
+				fid = uint64(len(funcList))
+				funcs[fk] = fid
+			}
+			lk := locKey{fn: fid, line: line}
+			lid, ok := locs[lk]
+			if !ok {
+				locList = append(locList, lk)
+				lid = uint64(len(locList))
+				locs[lk] = lid
+			}
+			ids[i] = lid
+		}
+		count, nanos := s.Count, s.Nanos
+		out.message(pprofProfileSample, func(m *protoBuffer) {
+			m.packedUint64(pprofSampleLocationID, ids)
+			m.packedUint64(pprofSampleValue, []uint64{uint64(count), uint64(nanos)})
+		})
+	}
+	for i, lk := range locList {
+		out.message(pprofProfileLocation, func(m *protoBuffer) {
+			m.uint64(pprofLocationID, uint64(i+1))
+			m.message(pprofLocationLine, func(l *protoBuffer) {
+				l.uint64(pprofLineFunctionID, lk.fn)
+				l.int64(pprofLineLine, int64(lk.line))
+			})
+		})
+	}
+	for i, fk := range funcList {
+		out.message(pprofProfileFunction, func(m *protoBuffer) {
+			m.uint64(pprofFunctionID, uint64(i+1))
+			m.int64(pprofFunctionName, str(fk.name))
+			m.int64(pprofFunctionSystemName, str(fk.name))
+			m.int64(pprofFunctionFilename, str(fk.file))
+		})
+	}
+	out.int64(pprofProfileTimeNanos, p.Start.UnixNano())
+	out.int64(pprofProfileDurationNanos, int64(p.Duration))
+	valueType(pprofProfilePeriodType, "cpu", "nanoseconds")
+	out.int64(pprofProfilePeriod, int64(p.Interval))
+	// the string table goes last as the messages above add to it
+	for _, s := range strs {
+		out.string(pprofProfileStringTable, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(out.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
@@ -0,0 +1,100 @@
+package vm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+)
+
+// protoFields splits a protobuf message into its fields, keeping varints and
+// length-delimited payloads.
+func protoFields(t *testing.T, data []byte) map[int][][]byte {
+	fields := map[int][][]byte{}
+	varint := func() uint64 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			b := data[0]
+			data = data[1:]
+			x |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				return x
+			}
+		}
+	}
+	for len(data) > 0 {
+		key := varint()
+		switch key & 7 {
+		case 0:
+			v := varint()
+			fields[int(key>>3)] = append(fields[int(key>>3)], []byte{byte(v)})
+		case 2:
+			n := varint()
+			fields[int(key>>3)] = append(fields[int(key>>3)], data[:n])
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields
+}
+
+func TestProfileWritePprof(t *testing.T) {
+	src := &SourceInfo{File: "prof.lg", Line: 4}
+	p := &Profile{
+		Start:    time.Now(),
+		Duration: time.Second,
+		Interval: 10 * time.Millisecond,
+		Samples: []ProfileSample{
+			{Stack: []StackFrame{{Fn: "fib", NS: "user", Source: src}, {Source: src}}, Count: 3, Nanos: 30},
+			{Stack: []StackFrame{{Fn: "fib", NS: "user", Source: src}}, Count: 1, Nanos: 10},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := p.WritePprof(buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := protoFields(t, data)
+	var strs []string
+	for _, s := range fields[pprofProfileStringTable] {
+		strs = append(strs, string(s))
+	}
+	if strs[0] != "" {
+		t.Fatalf("string table must start with an empty string, got %q", strs[0])
+	}
+	for _, want := range []string{"samples", "count", "cpu", "nanoseconds", "user/fib", "top level", "prof.lg"} {
+		found := false
+		for _, s := range strs {
+			found = found || s == want
+		}
+		if !found {
+			t.Errorf("string table is missing %q: %q", want, strs)
+		}
+	}
+	if n := len(fields[pprofProfileSample]); n != 2 {
+		t.Fatalf("expected 2 samples, got %d", n)
+	}
+	if n := len(fields[pprofProfileFunction]); n != 2 {
+		t.Fatalf("expected 2 functions, got %d", n)
+	}
+	if n := len(fields[pprofProfileLocation]); n != 2 {
+		t.Fatalf("expected 2 locations, got %d", n)
+	}
+	sample := protoFields(t, fields[pprofProfileSample][0])
+	if got := sample[pprofSampleValue][0]; !bytes.Equal(got, []byte{3, 30}) {
+		t.Fatalf("expected sample values [3 30], got %v", got)
+	}
+	if got := sample[pprofSampleLocationID][0]; !bytes.Equal(got, []byte{1, 2}) {
+		t.Fatalf("expected sample locations [1 2], got %v", got)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultProfileInterval is how often the profiler samples unless told
+// otherwise, the same rate Go's CPU profiler uses.
+const DefaultProfileInterval = 10 * time.Millisecond
+
+// Profile is what the profiler collected between StartProfile and
+// StopProfile.
+type Profile struct {
+	Start    time.Time
+	Duration time.Duration
+	Interval time.Duration
+	Samples  []ProfileSample
+}
+
+// ProfileSample is a let-go stack seen Count times while sampling, with the
+// time attributed to it.
+type ProfileSample struct {
+	Stack []StackFrame // innermost frame first
+	Count int64
+	Nanos int64
+}
+
+// profilePollMask sets how many instructions run between the profiler
+// looking at the clock.
+const profilePollMask = 0xff
+
+// profiler samples the let-go stacks of running frames. Every few hundred
+// instructions an instrumented frame checks whether a sample is due and
+// records its stack, weighted with the time since the last sample. That
+// attributes time spent in natives to the let-go function calling them, while
+// time with no let-go code running at all isn't counted.
+type profiler struct {
+	start    time.Time
+	interval time.Duration
+
+	polls   atomic.Uint32
+	running atomic.Int32 // let-go call stacks being run
+	next    atomic.Int64 // when the next sample is due, in ns since start
+
+	mu      sync.Mutex
+	last    int64 // when the last sample was taken, in ns since start
+	samples map[string]*ProfileSample
+}
+
+var activeProfiler atomic.Pointer[profiler]
+
+// StartProfile starts sampling let-go code every interval, or every
+// DefaultProfileInterval when interval is 0 or less.
+func StartProfile(interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultProfileInterval
+	}
+	p := &profiler{
+		start:    time.Now(),
+		interval: interval,
+		samples:  map[string]*ProfileSample{},
+	}
+	if !activeProfiler.CompareAndSwap(nil, p) {
+		return fmt.Errorf("profiler already running")
+	}
+	return nil
+}
+
+// StopProfile stops sampling and returns the collected profile.
+func StopProfile() (*Profile, error) {
+	p := activeProfiler.Swap(nil)
+	if p == nil {
+		return nil, fmt.Errorf("profiler not running")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prof := &Profile{Start: p.start, Duration: time.Since(p.start), Interval: p.interval}
+	for _, s := range p.samples {
+		prof.Samples = append(prof.Samples, *s)
+	}
+	return prof, nil
+}
+
+// Profiling tells whether the profiler is running.
+func Profiling() bool {
+	return activeProfiler.Load() != nil
+}
+
+// run runs f, a frame with no let-go caller, counting it as running.
+func (p *profiler) run(f *Frame) (Value, error) {
+	if p.running.Add(1) == 1 {
+		// nothing ran since the last sample, start measuring afresh
+		p.mu.Lock()
+		p.last = int64(time.Since(p.start))
+		p.next.Store(p.last + int64(p.interval))
+		p.mu.Unlock()
+	}
+	defer p.running.Add(-1)
+	v, err := f.run()
+	if err != nil {
+		return NIL, f.traced(f.escaping(err))
+	}
+	return v, nil
+}
+
+// poll records the stack of f once in a while if a sample is due.
+func (p *profiler) poll(f *Frame) {
+	if p.polls.Add(1)&profilePollMask != 0 {
+		return
+	}
+	now := int64(time.Since(p.start))
+	if now < p.next.Load() {
+		return
+	}
+	var stack []StackFrame
+	var key strings.Builder
+	for fr := f; fr != nil; fr = fr.parent {
+		sf := fr.stackFrame()
+		stack = append(stack, sf)
+		key.WriteString(sf.Name())
+		if sf.Source != nil {
+			key.WriteByte('@')
+			key.WriteString(sf.Source.File)
+			key.WriteByte(':')
+			key.WriteString(strconv.Itoa(sf.Source.Line))
+		}
+		key.WriteByte(';')
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now < p.next.Load() {
+		return // another goroutine took this sample
+	}
+	elapsed := now - p.last
+	p.last = now
+	p.next.Store(now + int64(p.interval))
+	if s, ok := p.samples[key.String()]; ok {
+		s.Count++
+		s.Nanos += elapsed
+	} else {
+		p.samples[key.String()] = &ProfileSample{Stack: stack, Count: 1, Nanos: elapsed}
+	}
+}
+
+// instrumenting tells whether new frames should run instrumented for the
+// debugger, the profiler or coverage.
+func instrumenting() bool {
+	return activeDebugger.Load() != nil || activeProfiler.Load() != nil || activeCoverage.Load() != nil
+}
@@ -44,6 +44,22 @@ func (p *Promise) Deref() Value {
 	return p.value
 }
 
+// DerefUntil is Deref giving up once done is closed, in which case it
+// returns false.
+func (p *Promise) DerefUntil(done <-chan struct{}) (Value, bool) {
+	select {
+	case <-p.ch:
+		return p.value, true
+	default:
+	}
+	select {
+	case <-p.ch:
+		return p.value, true
+	case <-done:
+		return NIL, false
+	}
+}
+
 func (p *Promise) IsRealized() bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
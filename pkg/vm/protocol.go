@@ -33,6 +33,15 @@ func (p *Protocol) Methods() []Symbol  { return p.methods }
 // implMap is a PersistentMap of {method-keyword → fn}.
 func (p *Protocol) Extend(vt ValueType, implMap *PersistentMap) {
 	p.impls[vt] = implMap
+	if dt, ok := vt.(*DefType); ok {
+		dt.impls = append(dt.impls, implMap)
+	}
+}
+
+// implementer is satisfied by values carrying their own protocol
+// implementations, like the ones created by reify.
+type implementer interface {
+	protocolImpl(p *Protocol) *PersistentMap
 }
 
 // ExtendNil adds implementations for nil.
@@ -56,10 +65,15 @@ func (p *Protocol) Lookup(methodName Symbol, target Value) (Fn, bool) {
 		return nil, false
 	}
 
-	vt := target.Type()
-	implMap, ok := p.impls[vt]
+	implMap, ok := p.impls[target.Type()]
 	if !ok {
-		return nil, false
+		r, isImpl := target.(implementer)
+		if !isImpl {
+			return nil, false
+		}
+		if implMap = r.protocolImpl(p); implMap == nil {
+			return nil, false
+		}
 	}
 
 	v := implMap.ValueAt(key)
@@ -76,8 +90,13 @@ func (p *Protocol) Satisfies(target Value) bool {
 	if target == NIL {
 		return p.nilImpl != nil
 	}
-	_, ok := p.impls[target.Type()]
-	return ok
+	if _, ok := p.impls[target.Type()]; ok {
+		return true
+	}
+	if r, ok := target.(implementer); ok {
+		return r.protocolImpl(p) != nil
+	}
+	return false
 }
 
 // ProtocolFn is a function that dispatches on the first arg's type via a protocol.
@@ -101,6 +120,11 @@ func (f *ProtocolFn) String() string     { return fmt.Sprintf("<protocol-fn %s/%
 func (f *ProtocolFn) Arity() int         { return -1 }
 
 func (f *ProtocolFn) Invoke(args []Value) (Value, error) {
+	return f.invoke(args, invokeFn)
+}
+
+// invoke calls the implementation for args[0] with call.
+func (f *ProtocolFn) invoke(args []Value, call func(Fn, []Value) (Value, error)) (Value, error) {
 	if len(args) == 0 {
 		return NIL, fmt.Errorf("protocol fn %s requires at least one argument", f.name)
 	}
@@ -115,7 +139,7 @@ func (f *ProtocolFn) Invoke(args []Value) (Value, error) {
 			f.protocol.name, f.name, typeName)
 	}
 
-	return impl.Invoke(args)
+	return call(impl, args)
 }
 
 // Protocol type metadata
@@ -54,6 +54,7 @@ func (l *Range) First() Value {
 
 // More implements Seq
 func (l *Range) More() Seq {
+	checkpoint()
 	nexts := l.start + l.step
 	if l.inBounds(nexts) {
 		return &Range{nexts, l.end, l.step}
@@ -63,6 +64,7 @@ func (l *Range) More() Seq {
 
 // Next implements Seq
 func (l *Range) Next() Seq {
+	checkpoint()
 	nexts := l.start + l.step
 	if l.inBounds(nexts) {
 		return &Range{nexts, l.end, l.step}
@@ -157,6 +159,7 @@ func (r *InfiniteRange) Unbox() interface{} { return nil }
 func (r *InfiniteRange) First() Value       { return Int(r.start) }
 
 func (r *InfiniteRange) Next() Seq {
+	checkpoint()
 	return &InfiniteRange{start: r.start + r.step, step: r.step}
 }
 
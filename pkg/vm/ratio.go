@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"math/big"
+	"reflect"
+)
+
+type theRatioType struct{}
+
+func (t *theRatioType) String() string     { return t.Name() }
+func (t *theRatioType) Type() ValueType    { return TypeType }
+func (t *theRatioType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theRatioType) Name() string       { return "let-go.lang.Ratio" }
+
+func (t *theRatioType) Box(bare interface{}) (Value, error) {
+	if r, ok := bare.(*big.Rat); ok {
+		return NewRatio(r), nil
+	}
+	return NIL, NewTypeError(bare, "can't be boxed as", t)
+}
+
+var RatioType *theRatioType = &theRatioType{}
+
+// Ratio is an exact fraction, the result of dividing integers that don't
+// divide evenly. A Ratio never has a denominator of 1.
+type Ratio struct {
+	val *big.Rat
+}
+
+// NewRatio returns r as a number, normalized so that whole numbers become
+// Int or BigInt.
+func NewRatio(r *big.Rat) Value {
+	if r.IsInt() {
+		return MaybeDowngrade(new(big.Int).Set(r.Num()))
+	}
+	return &Ratio{val: r}
+}
+
+func (r *Ratio) Val() *big.Rat         { return r.val }
+func (r *Ratio) Numerator() *big.Int   { return r.val.Num() }
+func (r *Ratio) Denominator() *big.Int { return r.val.Denom() }
+
+func (r *Ratio) Float64() float64 {
+	f, _ := r.val.Float64()
+	return f
+}
+
+func (r *Ratio) Type() ValueType    { return RatioType }
+func (r *Ratio) Unbox() interface{} { return r.val }
+
+func (r *Ratio) String() string {
+	return r.val.Num().String() + "/" + r.val.Denom().String()
+}
+
+func (r *Ratio) Hash() uint32 {
+	return hashRat(r.val)
+}
+
+// Equals tells whether other is a number equal to r.
+func (r *Ratio) Equals(other Value) bool {
+	return NumEq(r, other)
+}
+
+// hashRat hashes an exact number so that equal Ints, BigInts, Ratios and
+// BigDecimals hash alike.
+func hashRat(r *big.Rat) uint32 {
+	if r.IsInt() {
+		return NewBigInt(r.Num()).Hash()
+	}
+	return NewBigInt(r.Num()).Hash()*31 ^ NewBigInt(r.Denom()).Hash()
+}
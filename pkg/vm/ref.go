@@ -0,0 +1,231 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+type theRefType struct{}
+
+func (t *theRefType) String() string     { return t.Name() }
+func (t *theRefType) Type() ValueType    { return TypeType }
+func (t *theRefType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theRefType) Name() string       { return "let-go.lang.Ref" }
+
+func (t *theRefType) Box(b interface{}) (Value, error) {
+	val, err := BoxValue(reflect.ValueOf(b))
+	if err != nil {
+		return NIL, err
+	}
+	return NewRef(val), nil
+}
+
+var RefType *theRefType = &theRefType{}
+
+// refVersion is a committed value of a Ref together with the commit point
+// at which it was written.
+type refVersion struct {
+	val   Value
+	point uint64
+}
+
+var refIDs atomic.Uint64
+
+// Ref is a transactional reference. Refs can only be changed inside a
+// transaction (see RunInTransaction) and keep a short history of committed
+// values so that transactions can read a consistent snapshot without locking.
+type Ref struct {
+	id uint64
+
+	// commit is held by a transaction while it validates and publishes the
+	// new value of the ref.
+	commit sync.Mutex
+
+	mu         sync.RWMutex
+	history    []refVersion // newest first
+	faults     int          // reads that found no version old enough
+	minHistory int
+	maxHistory int
+
+	meta      Value
+	watches   map[Value]Fn
+	validator Fn
+}
+
+func NewRef(val Value) *Ref {
+	return &Ref{
+		id:         refIDs.Add(1),
+		history:    []refVersion{{val: val}},
+		maxHistory: 10,
+	}
+}
+
+// Deref returns the in-transaction value of r when called from within a
+// transaction, and the latest committed value otherwise.
+func (r *Ref) Deref() Value {
+	if tx := currentTransaction(); tx != nil {
+		v, err := tx.read(r)
+		if err == nil {
+			return v
+		}
+		// The snapshot is gone; the transaction will notice and retry.
+		tx.fault = err
+	}
+	return r.latest().val
+}
+
+func (r *Ref) latest() refVersion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.history[0]
+}
+
+// at returns the newest value committed no later than point.
+func (r *Ref) at(point uint64) (Value, bool) {
+	r.mu.RLock()
+	for _, v := range r.history {
+		if v.point <= point {
+			r.mu.RUnlock()
+			return v.val, true
+		}
+	}
+	r.mu.RUnlock()
+	r.mu.Lock()
+	r.faults++
+	r.mu.Unlock()
+	return NIL, false
+}
+
+// install publishes val as committed at point, growing the history when
+// readers recently failed to find old enough values.
+func (r *Ref) install(val Value, point uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.history)
+	if (r.faults > 0 && n < r.maxHistory) || n < r.minHistory {
+		r.faults = 0
+	} else {
+		n = max(n-1, 0)
+	}
+	h := make([]refVersion, 0, n+1)
+	h = append(h, refVersion{val: val, point: point})
+	r.history = append(h, r.history[:n]...)
+}
+
+// HistoryCount returns the number of committed values r currently keeps.
+func (r *Ref) HistoryCount() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.history)
+}
+
+func (r *Ref) MinHistory() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.minHistory
+}
+
+func (r *Ref) MaxHistory() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.maxHistory
+}
+
+func (r *Ref) SetMinHistory(n int) {
+	r.mu.Lock()
+	r.minHistory = n
+	r.mu.Unlock()
+}
+
+func (r *Ref) SetMaxHistory(n int) {
+	r.mu.Lock()
+	r.maxHistory = n
+	r.mu.Unlock()
+}
+
+// SetValidator installs fn as the validator of r. The current value has to
+// pass it. A nil fn removes the validator.
+func (r *Ref) SetValidator(fn Fn) error {
+	if err := validate(fn, r.latest().val); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.validator = fn
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Ref) Validator() Fn {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.validator
+}
+
+func (r *Ref) AddWatch(key Value, fn Fn) {
+	r.mu.Lock()
+	if r.watches == nil {
+		r.watches = make(map[Value]Fn)
+	}
+	r.watches[key] = fn
+	r.mu.Unlock()
+}
+
+func (r *Ref) RemoveWatch(key Value) {
+	r.mu.Lock()
+	delete(r.watches, key)
+	r.mu.Unlock()
+}
+
+func (r *Ref) notifyWatches(oldVal, newVal Value) {
+	r.mu.RLock()
+	watches := make(map[Value]Fn, len(r.watches))
+	for k, fn := range r.watches {
+		watches[k] = fn
+	}
+	r.mu.RUnlock()
+	for key, fn := range watches {
+		fn.Invoke([]Value{key, r, oldVal, newVal})
+	}
+}
+
+func (r *Ref) Meta() Value {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.meta == nil {
+		return NIL
+	}
+	return r.meta
+}
+
+// ResetMeta replaces the metadata of r in place, refs have identity.
+func (r *Ref) ResetMeta(m Value) {
+	r.mu.Lock()
+	r.meta = m
+	r.mu.Unlock()
+}
+
+func (r *Ref) AlterMeta(fn Fn, args []Value) (Value, error) {
+	newMeta, err := fn.Invoke(append([]Value{r.Meta()}, args...))
+	if err != nil {
+		return NIL, err
+	}
+	r.ResetMeta(newMeta)
+	return newMeta, nil
+}
+
+func (r *Ref) Type() ValueType { return RefType }
+
+func (r *Ref) Unbox() interface{} {
+	return r.Deref().Unbox()
+}
+
+func (r *Ref) String() string {
+	return fmt.Sprintf("<%s %s>", RefType, r.Deref())
+}
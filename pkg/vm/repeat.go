@@ -51,6 +51,7 @@ func (n *Repeat) Next() Seq {
 	if n.i == 0 || n.i == 1 {
 		return nil
 	}
+	checkpoint()
 	i := n.i
 	if i > 0 {
 		i--
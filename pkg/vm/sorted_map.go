@@ -0,0 +1,584 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// --- Type metadata ---
+
+type theSortedMapType struct{}
+
+func (t *theSortedMapType) String() string     { return t.Name() }
+func (t *theSortedMapType) Type() ValueType    { return TypeType }
+func (t *theSortedMapType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theSortedMapType) Name() string       { return "let-go.lang.SortedMap" }
+
+func (t *theSortedMapType) Box(bare interface{}) (Value, error) {
+	if m, ok := bare.(*PersistentSortedMap); ok {
+		return m, nil
+	}
+	return NIL, NewTypeError(bare, "can't be boxed as", t)
+}
+
+// SortedMapType is the type of PersistentSortedMap values.
+var SortedMapType *theSortedMapType = &theSortedMapType{}
+
+// --- Persistent left-leaning red-black tree ---
+
+// rbNode is a node of a persistent left-leaning red-black tree. Nodes are
+// never modified once they are reachable from a map, updates copy the path
+// from the root instead.
+type rbNode struct {
+	key, val    Value
+	left, right *rbNode
+	red         bool
+}
+
+func isRed(n *rbNode) bool { return n != nil && n.red }
+
+func (n *rbNode) with(left, right *rbNode, red bool) *rbNode {
+	return &rbNode{key: n.key, val: n.val, left: left, right: right, red: red}
+}
+
+func (n *rbNode) recolor(red bool) *rbNode {
+	if n.red == red {
+		return n
+	}
+	return n.with(n.left, n.right, red)
+}
+
+func rbRotateLeft(h *rbNode) *rbNode {
+	x := h.right
+	return x.with(h.with(h.left, x.left, true), x.right, h.red)
+}
+
+func rbRotateRight(h *rbNode) *rbNode {
+	x := h.left
+	return x.with(x.left, h.with(x.right, h.right, true), h.red)
+}
+
+func rbFlip(h *rbNode) *rbNode {
+	return h.with(h.left.recolor(!h.left.red), h.right.recolor(!h.right.red), !h.red)
+}
+
+func rbBalance(h *rbNode) *rbNode {
+	if isRed(h.right) && !isRed(h.left) {
+		h = rbRotateLeft(h)
+	}
+	if isRed(h.left) && isRed(h.left.left) {
+		h = rbRotateRight(h)
+	}
+	if isRed(h.left) && isRed(h.right) {
+		h = rbFlip(h)
+	}
+	return h
+}
+
+func rbMoveRedLeft(h *rbNode) *rbNode {
+	h = rbFlip(h)
+	if isRed(h.right.left) {
+		h = rbRotateLeft(h.with(h.left, rbRotateRight(h.right), h.red))
+		h = rbFlip(h)
+	}
+	return h
+}
+
+func rbMoveRedRight(h *rbNode) *rbNode {
+	h = rbFlip(h)
+	if isRed(h.left.left) {
+		h = rbFlip(rbRotateRight(h))
+	}
+	return h
+}
+
+func rbMin(h *rbNode) *rbNode {
+	for h.left != nil {
+		h = h.left
+	}
+	return h
+}
+
+func rbDeleteMin(h *rbNode) *rbNode {
+	if h.left == nil {
+		return nil
+	}
+	if !isRed(h.left) && !isRed(h.left.left) {
+		h = rbMoveRedLeft(h)
+	}
+	return rbBalance(h.with(rbDeleteMin(h.left), h.right, h.red))
+}
+
+// --- PersistentSortedMap ---
+
+// PersistentSortedMap is an immutable map keeping its entries ordered by key.
+// Keys are ordered with Compare unless a comparator function is given.
+type PersistentSortedMap struct {
+	cmp      Fn
+	root     *rbNode
+	count    int
+	meta     Value
+	_hash    uint32
+	_hasHash bool
+}
+
+// EmptySortedMap is the empty sorted map using the default ordering.
+var EmptySortedMap = &PersistentSortedMap{}
+
+// NewSortedMap creates a sorted map ordered by cmp from an alternating
+// key-value slice. A nil cmp selects the default ordering.
+func NewSortedMap(cmp Fn, kvs []Value) (m *PersistentSortedMap, err error) {
+	defer recoverThrownPanic(&err)
+	if len(kvs)%2 != 0 {
+		return nil, fmt.Errorf("sorted map needs an even number of arguments")
+	}
+	m = EmptySortedMap
+	if cmp != nil {
+		m = &PersistentSortedMap{cmp: cmp}
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		m = m.Assoc(kvs[i], kvs[i+1]).(*PersistentSortedMap)
+	}
+	return m, nil
+}
+
+// Comparator returns the comparator function ordering m, or NIL when m uses
+// the default ordering.
+func (m *PersistentSortedMap) Comparator() Value {
+	if m.cmp == nil {
+		return NIL
+	}
+	return m.cmp
+}
+
+// compare orders keys of m. Comparator errors escape as thrownPanic since
+// most collection interfaces have no way of returning them.
+func (m *PersistentSortedMap) compare(a, b Value) int {
+	var c int
+	var err error
+	if m.cmp == nil {
+		c, err = Compare(a, b)
+	} else {
+		c, err = CompareWith(m.cmp, a, b)
+	}
+	if err != nil {
+		panic(&thrownPanic{err: err})
+	}
+	return c
+}
+
+func (m *PersistentSortedMap) find(key Value) *rbNode {
+	n := m.root
+	for n != nil {
+		c := m.compare(key, n.key)
+		switch {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+func (m *PersistentSortedMap) insert(h *rbNode, key, val Value, added *bool) *rbNode {
+	if h == nil {
+		*added = true
+		return &rbNode{key: key, val: val, red: true}
+	}
+	c := m.compare(key, h.key)
+	switch {
+	case c < 0:
+		h = h.with(m.insert(h.left, key, val, added), h.right, h.red)
+	case c > 0:
+		h = h.with(h.left, m.insert(h.right, key, val, added), h.red)
+	default:
+		return &rbNode{key: h.key, val: val, left: h.left, right: h.right, red: h.red}
+	}
+	return rbBalance(h)
+}
+
+// delete removes key, which must be present, from the tree rooted at h.
+func (m *PersistentSortedMap) delete(h *rbNode, key Value) *rbNode {
+	if m.compare(key, h.key) < 0 {
+		if !isRed(h.left) && !isRed(h.left.left) {
+			h = rbMoveRedLeft(h)
+		}
+		h = h.with(m.delete(h.left, key), h.right, h.red)
+		return rbBalance(h)
+	}
+	if isRed(h.left) {
+		h = rbRotateRight(h)
+	}
+	if h.right == nil && m.compare(key, h.key) == 0 {
+		return nil
+	}
+	if !isRed(h.right) && !isRed(h.right.left) {
+		h = rbMoveRedRight(h)
+	}
+	if m.compare(key, h.key) == 0 {
+		min := rbMin(h.right)
+		h = &rbNode{key: min.key, val: min.val, left: h.left, right: rbDeleteMin(h.right), red: h.red}
+	} else {
+		h = h.with(h.left, m.delete(h.right, key), h.red)
+	}
+	return rbBalance(h)
+}
+
+func (m *PersistentSortedMap) withRoot(root *rbNode, count int) *PersistentSortedMap {
+	return &PersistentSortedMap{cmp: m.cmp, root: root, count: count, meta: m.meta}
+}
+
+// --- Value interface ---
+
+func (m *PersistentSortedMap) Type() ValueType    { return SortedMapType }
+func (m *PersistentSortedMap) Unbox() interface{} { return m }
+
+func (m *PersistentSortedMap) String() string {
+	b := &strings.Builder{}
+	b.WriteRune('{')
+	for s := m.Seq(); s != EmptyList && s != nil; s = s.Next() {
+		if b.Len() > 1 {
+			b.WriteRune(' ')
+		}
+		entry := s.First().(ArrayVector)
+		b.WriteString(entry[0].String())
+		b.WriteRune(' ')
+		b.WriteString(entry[1].String())
+	}
+	b.WriteRune('}')
+	return b.String()
+}
+
+// --- IMeta ---
+
+func (m *PersistentSortedMap) Meta() Value {
+	if m.meta == nil {
+		return NIL
+	}
+	return m.meta
+}
+
+func (m *PersistentSortedMap) WithMeta(meta Value) Value {
+	cp := *m
+	cp.meta = meta
+	return &cp
+}
+
+// --- Hashable ---
+
+// Hash matches PersistentMap so that equal maps hash alike regardless of order.
+func (m *PersistentSortedMap) Hash() uint32 {
+	if m._hasHash {
+		return m._hash
+	}
+	var h uint32
+	m.each(func(n *rbNode) { h += hashValue(n.key) ^ hashValue(n.val) })
+	m._hash = mixFinish(h)
+	m._hasHash = true
+	return m._hash
+}
+
+func (m *PersistentSortedMap) each(fn func(n *rbNode)) {
+	var walk func(n *rbNode)
+	walk = func(n *rbNode) {
+		if n == nil {
+			return
+		}
+		walk(n.left)
+		fn(n)
+		walk(n.right)
+	}
+	walk(m.root)
+}
+
+// --- Collection interface ---
+
+func (m *PersistentSortedMap) Count() Value  { return Int(m.count) }
+func (m *PersistentSortedMap) RawCount() int { return m.count }
+
+func (m *PersistentSortedMap) Empty() Collection {
+	return m.withRoot(nil, 0)
+}
+
+func (m *PersistentSortedMap) Conj(value Value) Collection {
+	if av, ok := value.(ArrayVector); ok && len(av) == 2 {
+		return m.Assoc(av[0], av[1]).(*PersistentSortedMap)
+	}
+	if l, ok := value.(Lookup); ok {
+		if c, ok := value.(Counted); ok && c.RawCount() == 2 {
+			return m.Assoc(l.ValueAt(Int(0)), l.ValueAt(Int(1))).(*PersistentSortedMap)
+		}
+	}
+	return m
+}
+
+// --- Associative interface ---
+
+func (m *PersistentSortedMap) Assoc(key Value, val Value) Associative {
+	added := false
+	root := m.insert(m.root, key, val, &added).recolor(false)
+	count := m.count
+	if added {
+		count++
+	}
+	return m.withRoot(root, count)
+}
+
+func (m *PersistentSortedMap) Dissoc(key Value) Associative {
+	if m.find(key) == nil {
+		return m
+	}
+	root := m.root
+	if !isRed(root.left) && !isRed(root.right) {
+		root = root.recolor(true)
+	}
+	root = m.delete(root, key)
+	if root != nil {
+		root = root.recolor(false)
+	}
+	return m.withRoot(root, m.count-1)
+}
+
+// --- Lookup interface ---
+
+func (m *PersistentSortedMap) ValueAt(key Value) Value {
+	return m.ValueAtOr(key, NIL)
+}
+
+func (m *PersistentSortedMap) ValueAtOr(key Value, dflt Value) Value {
+	if n := m.find(key); n != nil {
+		return n.val
+	}
+	return dflt
+}
+
+// --- Keyed interface ---
+
+func (m *PersistentSortedMap) Contains(key Value) Boolean {
+	return Boolean(m.find(key) != nil)
+}
+
+// --- Fn interface ---
+
+func (m *PersistentSortedMap) Arity() int { return -1 }
+
+func (m *PersistentSortedMap) Invoke(pargs []Value) (ret Value, err error) {
+	defer recoverThrownPanic(&err)
+	vl := len(pargs)
+	if vl < 1 || vl > 2 {
+		return NIL, fmt.Errorf("wrong number of arguments %d", vl)
+	}
+	if vl == 1 {
+		return m.ValueAt(pargs[0]), nil
+	}
+	return m.ValueAtOr(pargs[0], pargs[1]), nil
+}
+
+// --- Sequable interface ---
+
+func (m *PersistentSortedMap) Seq() Seq {
+	return newSortedSeq(m, m.root, true, false, nil)
+}
+
+// RSeq returns the entries of m in descending key order.
+func (m *PersistentSortedMap) RSeq() Seq {
+	return newSortedSeq(m, m.root, false, false, nil)
+}
+
+// --- Equals ---
+
+// Equals tells whether other is a map with the same entries, regardless of
+// how either map is ordered.
+func (m *PersistentSortedMap) Equals(other Value) bool {
+	var o interface {
+		Lookup
+		Keyed
+		Counted
+	}
+	switch other := other.(type) {
+	case *PersistentSortedMap:
+		o = other
+	case *PersistentMap:
+		o = other
+	default:
+		return false
+	}
+	if m.count != o.RawCount() {
+		return false
+	}
+	equal := true
+	m.each(func(n *rbNode) {
+		if equal && (!bool(o.Contains(n.key)) || !valueEquiv(n.val, o.ValueAt(n.key))) {
+			equal = false
+		}
+	})
+	return equal
+}
+
+// --- Sorted seqs ---
+
+// seqBound limits a sorted seq to the keys k for which (test (cmp k key) 0)
+// holds, as required by subseq and rsubseq.
+type seqBound struct {
+	test Fn
+	key  Value
+}
+
+func (b *seqBound) includes(m *PersistentSortedMap, key Value) bool {
+	r, err := b.test.Invoke([]Value{Int(m.compare(key, b.key)), Int(0)})
+	if err != nil {
+		panic(&thrownPanic{err: err})
+	}
+	return IsTruthy(r)
+}
+
+// isLowerBound tells whether test, one of <, <=, > or >=, admits keys
+// greater than the bound.
+func isLowerBound(test Fn) (bool, error) {
+	r, err := test.Invoke([]Value{Int(1), Int(0)})
+	if err != nil {
+		return false, err
+	}
+	return IsTruthy(r), nil
+}
+
+// rbStack is the persistent stack of nodes still to visit by a sorted seq.
+type rbStack struct {
+	node *rbNode
+	next *rbStack
+}
+
+func pushNodes(s *rbStack, n *rbNode, asc bool) *rbStack {
+	for n != nil {
+		s = &rbStack{node: n, next: s}
+		if asc {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return s
+}
+
+// SortedSeq walks a sorted map or set in order without materializing it.
+type SortedSeq struct {
+	m     *PersistentSortedMap
+	stack *rbStack
+	asc   bool
+	keys  bool // yield keys instead of [k v] entries
+	end   *seqBound
+}
+
+func newSortedSeq(m *PersistentSortedMap, root *rbNode, asc bool, keys bool, end *seqBound) Seq {
+	return sortedSeqFrom(m, pushNodes(nil, root, asc), asc, keys, end)
+}
+
+func sortedSeqFrom(m *PersistentSortedMap, stack *rbStack, asc bool, keys bool, end *seqBound) Seq {
+	if stack == nil || (end != nil && !end.includes(m, stack.node.key)) {
+		return EmptyList
+	}
+	return &SortedSeq{m: m, stack: stack, asc: asc, keys: keys, end: end}
+}
+
+// seqFrom returns a seq starting at the first key not before key in the
+// direction of the walk.
+func (m *PersistentSortedMap) seqFrom(key Value, asc bool, keys bool, end *seqBound) Seq {
+	var stack *rbStack
+	for n := m.root; n != nil; {
+		c := m.compare(key, n.key)
+		if c == 0 {
+			stack = &rbStack{node: n, next: stack}
+			break
+		}
+		if asc == (c < 0) {
+			stack = &rbStack{node: n, next: stack}
+		}
+		if c < 0 {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return sortedSeqFrom(m, stack, asc, keys, end)
+}
+
+// SubSeq implements subseq and rsubseq: it returns the keys (or entries) of m
+// satisfying the given tests, in ascending or descending order. Pass a nil
+// endTest for the three argument forms.
+func (m *PersistentSortedMap) SubSeq(asc bool, keys bool, test Fn, key Value, endTest Fn, endKey Value) (s Seq, err error) {
+	defer recoverThrownPanic(&err)
+	start := &seqBound{test: test, key: key}
+	if endTest == nil {
+		lower, err := isLowerBound(test)
+		if err != nil {
+			return nil, err
+		}
+		if lower != asc {
+			return newSortedSeq(m, m.root, asc, keys, start), nil
+		}
+	}
+	s = m.seqFrom(key, asc, keys, nil)
+	if s != EmptyList && !start.includes(m, s.(*SortedSeq).stack.node.key) {
+		s = s.More()
+	}
+	if endTest != nil && s != EmptyList {
+		ss := s.(*SortedSeq)
+		s = sortedSeqFrom(m, ss.stack, asc, keys, &seqBound{test: endTest, key: endKey})
+	}
+	return s, nil
+}
+
+func (s *SortedSeq) Type() ValueType    { return ListType }
+func (s *SortedSeq) Unbox() interface{} { return s }
+
+func (s *SortedSeq) String() string {
+	b := &strings.Builder{}
+	b.WriteRune('(')
+	for sq := Seq(s); sq != nil; sq = sq.Next() {
+		if sq != Seq(s) {
+			b.WriteRune(' ')
+		}
+		b.WriteString(sq.First().String())
+	}
+	b.WriteRune(')')
+	return b.String()
+}
+
+func (s *SortedSeq) First() Value {
+	n := s.stack.node
+	if s.keys {
+		return n.key
+	}
+	return ArrayVector{n.key, n.val}
+}
+
+func (s *SortedSeq) More() Seq {
+	n := s.stack.node
+	child := n.right
+	if !s.asc {
+		child = n.left
+	}
+	return sortedSeqFrom(s.m, pushNodes(s.stack.next, child, s.asc), s.asc, s.keys, s.end)
+}
+
+func (s *SortedSeq) Next() Seq {
+	if next := s.More(); next != EmptyList {
+		return next
+	}
+	return nil
+}
+
+func (s *SortedSeq) Cons(val Value) Seq {
+	return NewCons(val, s)
+}
+
+func (s *SortedSeq) Seq() Seq { return s }
@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// checkRB verifies the left-leaning red-black invariants and returns the
+// black height of n.
+func checkRB(t *testing.T, n *rbNode) int {
+	t.Helper()
+	if n == nil {
+		return 1
+	}
+	if isRed(n.right) {
+		t.Fatalf("right-leaning red link at %v", n.key)
+	}
+	if n.red && isRed(n.left) {
+		t.Fatalf("two red links in a row at %v", n.key)
+	}
+	l, r := checkRB(t, n.left), checkRB(t, n.right)
+	if l != r {
+		t.Fatalf("unbalanced black height at %v: %d vs %d", n.key, l, r)
+	}
+	if !n.red {
+		l++
+	}
+	return l
+}
+
+func TestSortedMapRandomOps(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	m := EmptySortedMap
+	ref := map[int]int{}
+	for i := 0; i < 5000; i++ {
+		k := rng.Intn(500)
+		if rng.Intn(3) == 0 {
+			m = m.Dissoc(Int(k)).(*PersistentSortedMap)
+			delete(ref, k)
+		} else {
+			m = m.Assoc(Int(k), Int(i)).(*PersistentSortedMap)
+			ref[k] = i
+		}
+		if isRed(m.root) {
+			t.Fatal("red root")
+		}
+		checkRB(t, m.root)
+	}
+	if m.RawCount() != len(ref) {
+		t.Fatalf("count: got %d, want %d", m.RawCount(), len(ref))
+	}
+	prev := -1
+	for s := m.Seq(); s != EmptyList && s != nil; s = s.Next() {
+		e := s.First().(ArrayVector)
+		k := int(e[0].(Int))
+		if k <= prev {
+			t.Fatalf("keys out of order: %d after %d", k, prev)
+		}
+		if ref[k] != int(e[1].(Int)) {
+			t.Fatalf("value for %d: got %v, want %d", k, e[1], ref[k])
+		}
+		prev = k
+	}
+}
+
+func TestSortedMapImmutability(t *testing.T) {
+	m, _ := NewSortedMap(nil, []Value{Int(1), Int(1), Int(2), Int(2)})
+	m2 := m.Assoc(Int(3), Int(3)).(*PersistentSortedMap)
+	m3 := m2.Dissoc(Int(1)).(*PersistentSortedMap)
+	if m.RawCount() != 2 || m2.RawCount() != 3 || m3.RawCount() != 2 {
+		t.Errorf("counts: %d %d %d", m.RawCount(), m2.RawCount(), m3.RawCount())
+	}
+	if m2.Contains(Int(1)) != TRUE || m.Contains(Int(3)) != FALSE {
+		t.Error("earlier versions changed")
+	}
+}
+
+func TestSortedMapComparatorError(t *testing.T) {
+	_, err := NewSortedMap(nil, []Value{Int(1), Int(1), Keyword("a"), Int(2)})
+	if err == nil {
+		t.Fatal("expected an error comparing Int and Keyword")
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type theSortedSetType struct{}
+
+func (t *theSortedSetType) String() string     { return t.Name() }
+func (t *theSortedSetType) Type() ValueType    { return TypeType }
+func (t *theSortedSetType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theSortedSetType) Name() string       { return "let-go.lang.SortedSet" }
+
+func (t *theSortedSetType) Box(bare interface{}) (Value, error) {
+	if s, ok := bare.(*PersistentSortedSet); ok {
+		return s, nil
+	}
+	return NIL, NewTypeError(bare, "can't be boxed as", t)
+}
+
+// SortedSetType is the type of PersistentSortedSet values.
+var SortedSetType *theSortedSetType = &theSortedSetType{}
+
+// PersistentSortedSet is an immutable set backed by a PersistentSortedMap,
+// iterating its elements in order.
+type PersistentSortedSet struct {
+	impl     *PersistentSortedMap
+	meta     Value
+	_hash    uint32
+	_hasHash bool
+}
+
+// EmptySortedSet is the empty sorted set using the default ordering.
+var EmptySortedSet = &PersistentSortedSet{impl: EmptySortedMap}
+
+// NewSortedSet creates a sorted set ordered by cmp. A nil cmp selects the
+// default ordering.
+func NewSortedSet(cmp Fn, vals []Value) (s *PersistentSortedSet, err error) {
+	defer recoverThrownPanic(&err)
+	m := EmptySortedMap
+	if cmp != nil {
+		m = &PersistentSortedMap{cmp: cmp}
+	}
+	for _, v := range vals {
+		m = m.Assoc(v, v).(*PersistentSortedMap)
+	}
+	return &PersistentSortedSet{impl: m}, nil
+}
+
+// Comparator returns the comparator function ordering s, or NIL when s uses
+// the default ordering.
+func (s *PersistentSortedSet) Comparator() Value { return s.impl.Comparator() }
+
+// --- Value interface ---
+
+func (s *PersistentSortedSet) Type() ValueType    { return SortedSetType }
+func (s *PersistentSortedSet) Unbox() interface{} { return s.keys() }
+
+func (s *PersistentSortedSet) String() string {
+	b := &strings.Builder{}
+	b.WriteString("#{")
+	for i, k := range s.keys() {
+		if i > 0 {
+			b.WriteRune(' ')
+		}
+		b.WriteString(k.String())
+	}
+	b.WriteRune('}')
+	return b.String()
+}
+
+func (s *PersistentSortedSet) keys() []Value {
+	keys := make([]Value, 0, s.impl.count)
+	s.impl.each(func(n *rbNode) { keys = append(keys, n.key) })
+	return keys
+}
+
+// --- Hashable ---
+
+// Hash matches PersistentSet so that equal sets hash alike regardless of order.
+func (s *PersistentSortedSet) Hash() uint32 {
+	if s._hasHash {
+		return s._hash
+	}
+	var h uint32
+	s.impl.each(func(n *rbNode) { h += hashValue(n.key) })
+	s._hash = mixFinish(h)
+	s._hasHash = true
+	return s._hash
+}
+
+// --- IMeta ---
+
+func (s *PersistentSortedSet) Meta() Value {
+	if s.meta == nil {
+		return NIL
+	}
+	return s.meta
+}
+
+func (s *PersistentSortedSet) WithMeta(m Value) Value {
+	cp := *s
+	cp.meta = m
+	return &cp
+}
+
+// --- Collection ---
+
+func (s *PersistentSortedSet) Count() Value  { return s.impl.Count() }
+func (s *PersistentSortedSet) RawCount() int { return s.impl.RawCount() }
+
+func (s *PersistentSortedSet) Empty() Collection {
+	return &PersistentSortedSet{impl: s.impl.Empty().(*PersistentSortedMap), meta: s.meta}
+}
+
+func (s *PersistentSortedSet) Conj(value Value) Collection {
+	if s.impl.find(value) != nil {
+		return s
+	}
+	return &PersistentSortedSet{impl: s.impl.Assoc(value, value).(*PersistentSortedMap), meta: s.meta}
+}
+
+// --- Set-specific ---
+
+func (s *PersistentSortedSet) Disj(value Value) *PersistentSortedSet {
+	impl := s.impl.Dissoc(value).(*PersistentSortedMap)
+	if impl == s.impl {
+		return s
+	}
+	return &PersistentSortedSet{impl: impl, meta: s.meta}
+}
+
+func (s *PersistentSortedSet) Contains(value Value) Boolean {
+	return s.impl.Contains(value)
+}
+
+// --- Sequable ---
+
+func (s *PersistentSortedSet) Seq() Seq {
+	return newSortedSeq(s.impl, s.impl.root, true, true, nil)
+}
+
+// RSeq returns the elements of s in descending order.
+func (s *PersistentSortedSet) RSeq() Seq {
+	return newSortedSeq(s.impl, s.impl.root, false, true, nil)
+}
+
+// SubSeq is like PersistentSortedMap.SubSeq but yields elements.
+func (s *PersistentSortedSet) SubSeq(asc bool, test Fn, key Value, endTest Fn, endKey Value) (Seq, error) {
+	return s.impl.SubSeq(asc, true, test, key, endTest, endKey)
+}
+
+// --- Fn (set as function) ---
+
+func (s *PersistentSortedSet) Arity() int { return 1 }
+
+func (s *PersistentSortedSet) Invoke(pargs []Value) (ret Value, err error) {
+	defer recoverThrownPanic(&err)
+	if len(pargs) != 1 {
+		return NIL, fmt.Errorf("wrong number of arguments %d", len(pargs))
+	}
+	if n := s.impl.find(pargs[0]); n != nil {
+		return n.key, nil
+	}
+	return NIL, nil
+}
+
+// --- Equals ---
+
+// Equals tells whether other is a set with the same elements.
+func (s *PersistentSortedSet) Equals(other Value) bool {
+	var o Keyed
+	switch other := other.(type) {
+	case *PersistentSortedSet:
+		o = other
+	case *PersistentSet:
+		o = other
+	default:
+		return false
+	}
+	if s.RawCount() != other.(Counted).RawCount() {
+		return false
+	}
+	equal := true
+	s.impl.each(func(n *rbNode) {
+		if equal && !bool(o.Contains(n.key)) {
+			equal = false
+		}
+	})
+	return equal
+}
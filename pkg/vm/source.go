@@ -7,6 +7,8 @@ package vm
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"sync"
 )
 
@@ -100,6 +102,47 @@ func (r *sourceRegistry) Register(name string, src string) {
 	r.mu.Unlock()
 }
 
+// RegisterAt stores src as the part of the named file that starts at the
+// given 0-based line and column, keeping the rest of the text registered for
+// it, or of the file on disk, so lines keep their numbers.
+func (r *sourceRegistry) RegisterAt(name string, src string, line, column int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	base, ok := r.sources[name]
+	if !ok {
+		if b, err := os.ReadFile(name); err == nil {
+			base = string(b)
+		}
+	}
+	lines := splitLines(base)
+	for len(lines) < line+1 {
+		lines = append(lines, "")
+	}
+	prefix := []rune(lines[line])
+	if len(prefix) > column {
+		prefix = prefix[:column]
+	}
+	for len(prefix) < column {
+		prefix = append(prefix, ' ')
+	}
+	snippet := splitLines(src)
+	snippet[0] = string(prefix) + snippet[0]
+	end := line + len(snippet)
+	if end > len(lines) {
+		end = len(lines)
+	}
+	lines = append(lines[:line], append(snippet, lines[end:]...)...)
+	r.sources[name] = strings.Join(lines, "\n")
+}
+
+// Get returns the source text registered for the named file.
+func (r *sourceRegistry) Get(file string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	src, ok := r.sources[file]
+	return src, ok
+}
+
 // GetLine returns the line at the given 0-based index for the named file.
 func (r *sourceRegistry) GetLine(file string, line int) string {
 	r.mu.RLock()
@@ -134,11 +177,13 @@ func splitLines(s string) []string {
 // Used by the compiler to attach source info to bytecode.
 // Only pointer-based types (like *List) can be tracked; slice/value types
 // are not hashable and are silently ignored.
-var FormSource = &formSourceMap{m: map[interface{}]*SourceInfo{}}
+var FormSource = &formSourceMap{m: map[interface{}]*SourceInfo{}, elems: map[interface{}]map[Value]*SourceInfo{}}
 
 type formSourceMap struct {
 	mu sync.RWMutex
 	m  map[interface{}]*SourceInfo
+	// atoms inside forms, which have no identity of their own
+	elems map[interface{}]map[Value]*SourceInfo
 }
 
 // Set associates a source location with a form value.
@@ -172,3 +217,73 @@ func (f *formSourceMap) Get(form Value) *SourceInfo {
 	f.mu.RUnlock()
 	return info
 }
+
+// atom tells if v is a value that can only be located through the form
+// it's in.
+func atom(v Value) bool {
+	switch v.(type) {
+	case Symbol, Keyword, String, Int, Float, Boolean, Char, *Nil:
+		return true
+	}
+	return false
+}
+
+// SetElem associates a source location with an atom like a symbol or a
+// number inside form. The first location set for an atom in a form sticks.
+func (f *formSourceMap) SetElem(form Value, elem Value, info SourceInfo) {
+	switch form.(type) {
+	case *List, *Cons:
+	default:
+		return
+	}
+	if !atom(elem) {
+		return
+	}
+	f.mu.Lock()
+	elems := f.elems[form]
+	if elems == nil {
+		elems = map[Value]*SourceInfo{}
+		f.elems[form] = elems
+	}
+	if _, ok := elems[elem]; !ok {
+		cp := info
+		elems[elem] = &cp
+	}
+	f.mu.Unlock()
+}
+
+// GetElem retrieves the source location of an atom inside form.
+func (f *formSourceMap) GetElem(form Value, elem Value) *SourceInfo {
+	switch form.(type) {
+	case *List, *Cons:
+	default:
+		return nil
+	}
+	if !atom(elem) {
+		return nil
+	}
+	f.mu.RLock()
+	info := f.elems[form][elem]
+	f.mu.RUnlock()
+	return info
+}
+
+// CopyElems makes the atom locations of form known in to as well, for
+// forms made from others like macro expansions.
+func (f *formSourceMap) CopyElems(to Value, form Value) {
+	switch form.(type) {
+	case *List, *Cons:
+	default:
+		return
+	}
+	switch to.(type) {
+	case *List, *Cons:
+	default:
+		return
+	}
+	f.mu.Lock()
+	if elems, ok := f.elems[form]; ok && f.elems[to] == nil {
+		f.elems[to] = elems
+	}
+	f.mu.Unlock()
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+// StackFrame is a single entry of a let-go stack trace: the function a frame
+// was running and where it was in the source when an error went through it.
+type StackFrame struct {
+	Fn     string      // function name, "" for top-level code
+	NS     string      // namespace the function was defined in, if known
+	Source *SourceInfo // nil for native functions
+}
+
+// Name returns the qualified function name of the frame.
+func (sf StackFrame) Name() string {
+	switch {
+	case sf.Fn == "":
+		return "<top level>"
+	case sf.NS != "":
+		return sf.NS + "/" + sf.Fn
+	}
+	return sf.Fn
+}
+
+func (sf StackFrame) String() string {
+	if sf.Source == nil {
+		return sf.Name() + " (native)"
+	}
+	return sf.Name() + " (" + sf.Source.String() + ")"
+}
+
+// Value returns the frame as a map with :fn, :ns, :file, :line and :column
+// keys, lines and columns being 1-based.
+func (sf StackFrame) Value() Value {
+	var m Associative = EmptyPersistentMap
+	if sf.Fn != "" {
+		m = m.Assoc(Keyword("fn"), String(sf.Fn))
+	}
+	if sf.NS != "" {
+		m = m.Assoc(Keyword("ns"), String(sf.NS))
+	}
+	if sf.Source != nil {
+		m = m.Assoc(Keyword("file"), String(sf.Source.File))
+		m = m.Assoc(Keyword("line"), Int(sf.Source.Line+1))
+		m = m.Assoc(Keyword("column"), Int(sf.Source.Column+1))
+	}
+	return m
+}
+
+// StackValue returns stack as a vector of frame maps.
+func StackValue(stack []StackFrame) Value {
+	out := make(ArrayVector, len(stack))
+	for i := range stack {
+		out[i] = stack[i].Value()
+	}
+	return out
+}
+
+// stackFrame describes where f currently is.
+func (f *Frame) stackFrame() StackFrame {
+	sf := StackFrame{Source: f.code.LookupSource(f.ip)}
+	if f.fn != nil {
+		sf.Fn = fnName(f.fn)
+		sf.NS = f.fn.ns
+	}
+	return sf
+}
+
+// traced records f in the stack trace of err as err leaves or is caught in f.
+// Frames are kept in transparent ExecutionErrors wrapping the error, so err
+// itself is never mutated and may be shared between goroutines.
+func (f *Frame) traced(err error) error {
+	if f.code == nil {
+		return err
+	}
+	sf := f.stackFrame()
+	return &ExecutionError{frame: &sf, cause: err}
+}
+
+// callError wraps err returned by fn called from f. Native functions that
+// fail get a frame of their own since they don't run in a Frame; throw just
+// passes its value on.
+func (f *Frame) callError(fn Fn, err error) *ExecutionError {
+	ee := NewExecutionError("calling " + fnName(fn)).WithSource(f.code.LookupSource(f.ip))
+	_, thrown := err.(*ThrownError)
+	if nf, ok := fn.(*NativeFn); ok && !thrown {
+		ee.frame = &StackFrame{Fn: fnName(nf)}
+	}
+	ee.cause = err
+	return ee
+}
+
+// StackTrace returns the let-go stack trace recorded in err, innermost frame
+// first. When err was thrown with a value caught and rethrown earlier, the
+// stack of the original throw is returned.
+func StackTrace(err error) []StackFrame {
+	var stack []StackFrame
+	for err != nil {
+		switch e := err.(type) {
+		case *ExecutionError:
+			if e.frame != nil {
+				stack = append(stack, *e.frame)
+			}
+			err = e.cause
+		case *ThrownError:
+			if ei, ok := e.Value.(*ExInfo); ok && len(ei.stack) > 0 {
+				return ei.stack
+			}
+			err = nil
+		default:
+			err = nil
+		}
+	}
+	for i, j := 0, len(stack)-1; i < j; i, j = i+1, j-1 {
+		stack[i], stack[j] = stack[j], stack[i]
+	}
+	return stack
+}
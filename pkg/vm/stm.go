@@ -0,0 +1,359 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	stderrors "errors"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nooga/let-go/pkg/errors"
+)
+
+// Software transactional memory for Refs.
+//
+// Every commit gets a point on a global clock. A transaction reads the
+// values of refs as of the point at which it started (its read point), so it
+// always sees a consistent snapshot. Writes are kept in the transaction until
+// it commits. At commit the written and ensured refs are locked in id order
+// and checked for commits newer than the read point; if there are any, the
+// transaction runs again from scratch. Commuted refs don't conflict: their
+// commute fns are applied again to the latest values at commit.
+
+// stmClock is the point of the latest commit.
+var stmClock atomic.Uint64
+
+// commitMu makes publishing a commit atomic with respect to transactions
+// taking their read point: a read point never falls in the middle of a
+// commit being installed.
+var commitMu sync.RWMutex
+
+// RetryLimit is how many times a transaction is attempted before giving up.
+const RetryLimit = 10000
+
+// runningTransactions counts transactions in progress, so that derefs
+// outside of transactions can skip looking one up.
+var runningTransactions atomic.Int32
+
+// transactions maps goroutines (by gid) to the transaction they run.
+var transactions sync.Map
+
+type commuteCall struct {
+	fn   Fn
+	args []Value
+}
+
+// Transaction holds the state of a single attempt of a dosync body.
+type Transaction struct {
+	readPoint uint64
+	vals      map[*Ref]Value
+	sets      map[*Ref]bool
+	ensures   map[*Ref]bool
+	commutes  map[*Ref][]commuteCall
+	order     []*Ref // refs in the order they were first written to
+	fault     error  // set when a deref couldn't be served from history
+	actions   []func()
+}
+
+// retryError unwinds a transaction attempt so that it can start over. It
+// can't be caught by try/catch in the transaction body.
+type retryError struct {
+	tx    *Transaction
+	cause error
+}
+
+func (e *retryError) Error() string {
+	return "transaction retry"
+}
+
+func (e *retryError) Wrap(err error) errors.Error {
+	e.cause = err
+	return e
+}
+
+func (e *retryError) GetCause() error {
+	return e.cause
+}
+
+// Unwrap lets errors.Is and errors.As look through the cause.
+func (e *retryError) Unwrap() error {
+	return e.cause
+}
+
+func (e *retryError) uncatchable() {}
+
+// isRetryOf tells whether err asks tx to retry.
+func isRetryOf(err error, tx *Transaction) bool {
+	var re *retryError
+	return stderrors.As(err, &re) && re.tx == tx
+}
+
+func currentTransaction() *Transaction {
+	if runningTransactions.Load() == 0 {
+		return nil
+	}
+	tx, ok := transactions.Load(gid())
+	if !ok {
+		return nil
+	}
+	return tx.(*Transaction)
+}
+
+// InTransaction tells whether the calling goroutine runs a transaction.
+func InTransaction() bool {
+	return currentTransaction() != nil
+}
+
+// RunInTransaction runs fn in a transaction and returns its result. fn may
+// be run several times, so it should be free of side effects. Transactions
+// nest: calling RunInTransaction from within one just runs fn as part of it.
+func RunInTransaction(fn Fn) (Value, error) {
+	if currentTransaction() != nil {
+		return fn.Invoke(nil)
+	}
+	id := gid()
+	runningTransactions.Add(1)
+	defer runningTransactions.Add(-1)
+	defer transactions.Delete(id)
+
+	frame := currentFrame()
+	for attempt := 0; attempt < RetryLimit; attempt++ {
+		if attempt > 0 {
+			backoff(attempt)
+		}
+		tx := newTransaction()
+		transactions.Store(id, tx)
+		ret, err := fn.Invoke(nil)
+		if err == nil && tx.fault != nil {
+			err = tx.fault
+		}
+		if err == nil {
+			err = tx.commit()
+		}
+		if err == nil {
+			tx.runActions()
+			return ret, nil
+		}
+		if !isRetryOf(err, tx) {
+			return NIL, err
+		}
+		// finally blocks run on retry, but bindings pushed and never popped
+		// mustn't pile up over the attempts
+		ResetThreadBindingFrame(frame)
+	}
+	return NIL, NewExecutionError("transaction failed after reaching retry limit")
+}
+
+func backoff(attempt int) {
+	if attempt < 10 {
+		runtime.Gosched()
+		return
+	}
+	time.Sleep(time.Duration(min(attempt, 100)) * 10 * time.Microsecond)
+}
+
+func newTransaction() *Transaction {
+	commitMu.RLock()
+	point := stmClock.Load()
+	commitMu.RUnlock()
+	return &Transaction{
+		readPoint: point,
+		vals:      map[*Ref]Value{},
+		sets:      map[*Ref]bool{},
+		ensures:   map[*Ref]bool{},
+		commutes:  map[*Ref][]commuteCall{},
+	}
+}
+
+func (tx *Transaction) retry() error {
+	return &retryError{tx: tx}
+}
+
+func (tx *Transaction) read(r *Ref) (Value, error) {
+	if v, ok := tx.vals[r]; ok {
+		return v, nil
+	}
+	v, ok := r.at(tx.readPoint)
+	if !ok {
+		return NIL, tx.retry()
+	}
+	return v, nil
+}
+
+// checkWritable fails the attempt early when r was committed to since the
+// transaction started, which would make the commit fail anyway.
+func (tx *Transaction) checkWritable(r *Ref) error {
+	if tx.fault != nil {
+		return tx.fault
+	}
+	if r.latest().point > tx.readPoint {
+		return tx.retry()
+	}
+	return nil
+}
+
+func (tx *Transaction) touch(r *Ref) {
+	if !tx.sets[r] && tx.commutes[r] == nil {
+		tx.order = append(tx.order, r)
+	}
+}
+
+// Set sets the in-transaction value of r.
+func (tx *Transaction) Set(r *Ref, val Value) (Value, error) {
+	if tx.commutes[r] != nil && !tx.sets[r] {
+		return NIL, NewExecutionError("can't set after commute")
+	}
+	if !tx.sets[r] {
+		if err := tx.checkWritable(r); err != nil {
+			return NIL, err
+		}
+		tx.touch(r)
+		tx.sets[r] = true
+	}
+	tx.vals[r] = val
+	return val, nil
+}
+
+// Alter sets the in-transaction value of r to (apply fn value args).
+func (tx *Transaction) Alter(r *Ref, fn Fn, args []Value) (Value, error) {
+	cur, err := tx.read(r)
+	if err != nil {
+		return NIL, err
+	}
+	val, err := fn.Invoke(append([]Value{cur}, args...))
+	if err != nil {
+		return NIL, err
+	}
+	return tx.Set(r, val)
+}
+
+// Commute sets the in-transaction value of r to (apply fn value args) and
+// arranges for fn to be applied again to the latest value of r at commit.
+func (tx *Transaction) Commute(r *Ref, fn Fn, args []Value) (Value, error) {
+	cur, err := tx.read(r)
+	if err != nil {
+		return NIL, err
+	}
+	val, err := fn.Invoke(append([]Value{cur}, args...))
+	if err != nil {
+		return NIL, err
+	}
+	tx.touch(r)
+	tx.commutes[r] = append(tx.commutes[r], commuteCall{fn: fn, args: args})
+	tx.vals[r] = val
+	return val, nil
+}
+
+// Ensure protects r from being changed by other transactions until this
+// one commits, and returns its in-transaction value.
+func (tx *Transaction) Ensure(r *Ref) (Value, error) {
+	v, err := tx.read(r)
+	if err != nil {
+		return NIL, err
+	}
+	if !tx.sets[r] {
+		if err := tx.checkWritable(r); err != nil {
+			return NIL, err
+		}
+		tx.ensures[r] = true
+	}
+	return v, nil
+}
+
+// AfterCommit schedules fn to run once the transaction has committed. It is
+// dropped when the attempt is retried.
+func (tx *Transaction) AfterCommit(fn func()) {
+	tx.actions = append(tx.actions, fn)
+}
+
+func (tx *Transaction) runActions() {
+	for _, fn := range tx.actions {
+		fn()
+	}
+}
+
+type refChange struct {
+	ref      *Ref
+	old, new Value
+}
+
+func (tx *Transaction) commit() error {
+	locked := make([]*Ref, 0, len(tx.order)+len(tx.ensures))
+	locked = append(locked, tx.order...)
+	for r := range tx.ensures {
+		if !tx.sets[r] && tx.commutes[r] == nil {
+			locked = append(locked, r)
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].id < locked[j].id })
+	for _, r := range locked {
+		r.commit.Lock()
+	}
+	defer func() {
+		for _, r := range locked {
+			r.commit.Unlock()
+		}
+	}()
+
+	for _, r := range locked {
+		if (tx.sets[r] || tx.ensures[r]) && r.latest().point > tx.readPoint {
+			return tx.retry()
+		}
+	}
+
+	changes := make([]refChange, 0, len(tx.order))
+	for _, r := range tx.order {
+		old := r.latest().val
+		val := tx.vals[r]
+		if !tx.sets[r] {
+			// Only commuted: replay the commutes on the latest value
+			val = old
+			for _, c := range tx.commutes[r] {
+				var err error
+				val, err = c.fn.Invoke(append([]Value{val}, c.args...))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if err := validate(r.Validator(), val); err != nil {
+			return err
+		}
+		changes = append(changes, refChange{ref: r, old: old, new: val})
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	commitMu.Lock()
+	point := stmClock.Add(1)
+	for _, c := range changes {
+		c.ref.install(c.new, point)
+	}
+	commitMu.Unlock()
+
+	// Watches are notified before other actions scheduled by the body run
+	notify := func() {
+		for _, c := range changes {
+			c.ref.notifyWatches(c.old, c.new)
+		}
+	}
+	tx.actions = append([]func(){notify}, tx.actions...)
+	return nil
+}
+
+// CurrentTransaction returns the transaction run by the calling goroutine,
+// or an error when there is none.
+func CurrentTransaction() (*Transaction, error) {
+	tx := currentTransaction()
+	if tx == nil {
+		return nil, NewExecutionError("no transaction running")
+	}
+	return tx, nil
+}
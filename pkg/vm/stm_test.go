@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"sync"
+	"testing"
+)
+
+func fnOf(f func(args []Value) (Value, error)) Fn {
+	fn, _ := NativeFnType.Wrap(f)
+	return fn.(Fn)
+}
+
+func TestTransactionsKeepTotals(t *testing.T) {
+	refs := make([]*Ref, 5)
+	for i := range refs {
+		refs[i] = NewRef(Int(100))
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				from, to := refs[(w+i)%5], refs[(w*3+i+1)%5]
+				_, err := RunInTransaction(fnOf(func([]Value) (Value, error) {
+					tx, err := CurrentTransaction()
+					if err != nil {
+						return NIL, err
+					}
+					a := from.Deref().(Int)
+					if _, err := tx.Set(from, a-1); err != nil {
+						return NIL, err
+					}
+					return tx.Set(to, to.Deref().(Int)+1)
+				}))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	sum := 0
+	for _, r := range refs {
+		sum += int(r.Deref().(Int))
+	}
+	if sum != 500 {
+		t.Errorf("sum of refs: got %d, want 500", sum)
+	}
+}
+
+func TestTransactionValidatorAndWatches(t *testing.T) {
+	r := NewRef(Int(1))
+	pos := fnOf(func(args []Value) (Value, error) { return Boolean(args[0].(Int) > 0), nil })
+	if err := r.SetValidator(pos); err != nil {
+		t.Fatal(err)
+	}
+	var seen []Value
+	r.AddWatch(Keyword("w"), fnOf(func(args []Value) (Value, error) {
+		seen = append(seen, args[2], args[3])
+		return NIL, nil
+	}))
+	set := func(v Value) error {
+		_, err := RunInTransaction(fnOf(func([]Value) (Value, error) {
+			tx, _ := CurrentTransaction()
+			return tx.Set(r, v)
+		}))
+		return err
+	}
+	if err := set(Int(-1)); err == nil {
+		t.Error("expected the validator to reject -1")
+	}
+	if err := set(Int(2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != Int(1) || seen[1] != Int(2) {
+		t.Errorf("watch saw %v", seen)
+	}
+	if _, err := CurrentTransaction(); err == nil {
+		t.Error("no transaction should be running")
+	}
+}
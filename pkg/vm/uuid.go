@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2021-2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package vm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+)
+
+type theUUIDType struct{}
+
+func (t *theUUIDType) String() string     { return t.Name() }
+func (t *theUUIDType) Type() ValueType    { return TypeType }
+func (t *theUUIDType) Unbox() interface{} { return reflect.TypeOf(t) }
+func (t *theUUIDType) Name() string       { return "let-go.lang.UUID" }
+
+func (t *theUUIDType) Box(bare interface{}) (Value, error) {
+	switch v := bare.(type) {
+	case [16]byte:
+		return UUID(v), nil
+	case string:
+		return ParseUUID(v)
+	}
+	return NIL, NewTypeError(bare, "can't be boxed as", t)
+}
+
+// UUIDType is the type of UUID values.
+var UUIDType *theUUIDType = &theUUIDType{}
+
+// UUID is a 128-bit universally unique identifier, read from #uuid literals.
+type UUID [16]byte
+
+// ParseUUID parses the canonical 8-4-4-4-12 hex form of a UUID.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID string: %s", s)
+	}
+	groups := []string{s[0:8], s[9:13], s[14:18], s[19:23], s[24:36]}
+	off := 0
+	for _, g := range groups {
+		n, err := hex.Decode(u[off:], []byte(g))
+		if err != nil {
+			return u, fmt.Errorf("invalid UUID string: %s", s)
+		}
+		off += n
+	}
+	return u, nil
+}
+
+// RandomUUID returns a new version 4 (random) UUID.
+func RandomUUID() UUID {
+	var u UUID
+	_, _ = rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+func (u UUID) Type() ValueType    { return UUIDType }
+func (u UUID) Unbox() interface{} { return [16]byte(u) }
+
+// String prints u as a #uuid literal.
+func (u UUID) String() string {
+	return fmt.Sprintf("#uuid %q", u.Format())
+}
+
+// Format returns the canonical 8-4-4-4-12 hex form of u.
+func (u UUID) Format() string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+func (u UUID) Hash() uint32 {
+	return hashBytes(u[:])
+}
@@ -96,6 +96,36 @@ type Reference interface {
 	Deref() Value
 }
 
+// Watchable is a reference that calls watch fns with (key ref old new)
+// whenever its state changes.
+type Watchable interface {
+	Reference
+	AddWatch(key Value, fn Fn)
+	RemoveWatch(key Value)
+}
+
+// Validatable is a reference whose new states have to pass a validator fn.
+type Validatable interface {
+	Reference
+	SetValidator(fn Fn) error
+	Validator() Fn
+}
+
+// validate runs validator fn on val. A nil fn accepts everything.
+func validate(fn Fn, val Value) error {
+	if fn == nil {
+		return nil
+	}
+	ok, err := fn.Invoke([]Value{val})
+	if err != nil {
+		return err
+	}
+	if !IsTruthy(ok) {
+		return NewExecutionError("invalid reference state")
+	}
+	return nil
+}
+
 type theTypeType struct{}
 
 var TypeType *theTypeType = &theTypeType{}
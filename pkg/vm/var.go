@@ -5,29 +5,35 @@
 
 package vm
 
-import "fmt"
+import (
+	"fmt"
+	"sync/atomic"
+)
 
 type Var struct {
-	root      Value
-	bindings  []Value // dynamic binding stack (nil when unused — zero cost)
+	root      atomic.Pointer[Value] // nil until a root is set, see HasRoot
+	bindings  atomic.Int32          // goroutines with a dynamic binding of the var, see switchFrame
 	nsref     *Namespace
 	ns        string
 	name      string
-	isMacro   bool
-	isDynamic bool
-	isPrivate bool
+	isMacro   atomic.Bool
+	isDynamic atomic.Bool
+	isPrivate atomic.Bool
+	meta      atomic.Pointer[Value] // nil until ResetMeta, sessions may def concurrently
+	validator atomic.Pointer[Fn]
 }
 
 func (v *Var) Invoke(values []Value) (Value, error) {
-	f, ok := v.root.(Fn)
+	root := v.Root()
+	f, ok := root.(Fn)
 	if !ok {
-		return NIL, fmt.Errorf("%v root does not implement Fn", v.root)
+		return NIL, fmt.Errorf("%v root does not implement Fn", root)
 	}
 	return f.Invoke(values)
 }
 
 func (v *Var) Arity() int {
-	f, ok := v.root.(Fn)
+	f, ok := v.Root().(Fn)
 	if !ok {
 		return 0
 	}
@@ -36,38 +42,111 @@ func (v *Var) Arity() int {
 
 func NewVar(nsref *Namespace, ns string, name string) *Var {
 	return &Var{
-		nsref:     nsref,
-		ns:        ns,
-		name:      name,
-		root:      NIL,
-		isMacro:   false,
-		isDynamic: false,
-		isPrivate: false,
+		nsref: nsref,
+		ns:    ns,
+		name:  name,
 	}
 }
 
 func (v *Var) SetRoot(val Value) *Var {
-	v.root = val
+	v.root.Store(&val)
 	return v
 }
 
+// HasRoot tells whether v was given a root value, (def x) and declare
+// leave it unbound.
+func (v *Var) HasRoot() bool {
+	return v.root.Load() != nil
+}
+
+// IsBound tells whether v has a root value or a binding on the calling
+// goroutine.
+func (v *Var) IsBound() bool {
+	if v.HasRoot() {
+		return true
+	}
+	return v.bindings.Load() != 0 && currentFrame().lookup(v) != nil
+}
+
+// BindRoot sets the root of v to val if it passes the validator of v.
+func (v *Var) BindRoot(val Value) error {
+	if err := validate(v.Validator(), val); err != nil {
+		return err
+	}
+	v.SetRoot(val)
+	return nil
+}
+
+// AlterRoot atomically sets the root of v to (apply fn root args) and
+// returns it. Like swap!, fn is retried if the root changes meanwhile.
+func (v *Var) AlterRoot(fn Fn, args []Value) (Value, error) {
+	for {
+		old := v.root.Load()
+		var oldVal Value = NIL
+		if old != nil {
+			oldVal = *old
+		}
+		val, err := fn.Invoke(append([]Value{oldVal}, args...))
+		if err != nil {
+			return NIL, err
+		}
+		if err := validate(v.Validator(), val); err != nil {
+			return NIL, err
+		}
+		if v.root.CompareAndSwap(old, &val) {
+			return val, nil
+		}
+	}
+}
+
+// SetValidator installs fn as the validator of v. The current root has to
+// pass it. A nil fn removes the validator.
+func (v *Var) SetValidator(fn Fn) error {
+	if v.HasRoot() {
+		if err := validate(fn, v.Root()); err != nil {
+			return err
+		}
+	}
+	if fn == nil {
+		v.validator.Store(nil)
+	} else {
+		v.validator.Store(&fn)
+	}
+	return nil
+}
+
+func (v *Var) Validator() Fn {
+	if fn := v.validator.Load(); fn != nil {
+		return *fn
+	}
+	return nil
+}
+
 func (v *Var) Deref() Value {
-	if len(v.bindings) > 0 {
-		return v.bindings[len(v.bindings)-1]
+	if v.bindings.Load() != 0 {
+		if c := currentFrame().lookup(v); c != nil {
+			return c.get()
+		}
 	}
-	return v.root
+	return v.Root()
 }
 
-// PushBinding pushes a dynamic binding value.
+// Root returns the root value, ignoring dynamic bindings.
+func (v *Var) Root() Value {
+	if root := v.root.Load(); root != nil {
+		return *root
+	}
+	return NIL
+}
+
+// PushBinding pushes a dynamic binding value for the calling goroutine.
 func (v *Var) PushBinding(val Value) {
-	v.bindings = append(v.bindings, val)
+	PushThreadBindings(map[*Var]Value{v: val})
 }
 
-// PopBinding removes the most recent dynamic binding.
+// PopBinding removes the most recent dynamic binding of the calling goroutine.
 func (v *Var) PopBinding() {
-	if len(v.bindings) > 0 {
-		v.bindings = v.bindings[:len(v.bindings)-1]
-	}
+	_ = PopThreadBindings()
 }
 
 func (v *Var) Type() ValueType {
@@ -83,15 +162,15 @@ func (v *Var) String() string {
 }
 
 func (v *Var) IsMacro() bool {
-	return v.isMacro
+	return v.isMacro.Load()
 }
 
 func (v *Var) IsDynamic() bool {
-	return v.isDynamic
+	return v.isDynamic.Load()
 }
 
 func (v *Var) IsPrivate() bool {
-	return v.isPrivate
+	return v.isPrivate.Load()
 }
 
 // NS returns the namespace name.
@@ -101,13 +180,60 @@ func (v *Var) NS() string { return v.ns }
 func (v *Var) VarName() string { return v.name }
 
 func (v *Var) SetMacro() {
-	v.isMacro = true
+	v.isMacro.Store(true)
 }
 
 func (v *Var) SetDynamic() {
-	v.isDynamic = true
+	v.isDynamic.Store(true)
 }
 
 func (v *Var) SetPrivate() {
-	v.isPrivate = true
+	v.isPrivate.Store(true)
+}
+
+// Meta returns the metadata of v, i.e. what was given at def time (:doc,
+// :arglists, :file, :line, ...) plus :ns, :name and the var flags.
+func (v *Var) Meta() Value {
+	var m Associative = EmptyPersistentMap
+	if p := v.meta.Load(); p != nil {
+		if pm, ok := (*p).(Associative); ok && *p != NIL {
+			m = pm
+		}
+	}
+	if v.nsref != nil {
+		m = m.Assoc(Keyword("ns"), v.nsref)
+	}
+	m = m.Assoc(Keyword("name"), Symbol(v.name))
+	if v.isMacro.Load() {
+		m = m.Assoc(Keyword("macro"), TRUE)
+	}
+	if v.isDynamic.Load() {
+		m = m.Assoc(Keyword("dynamic"), TRUE)
+	}
+	if v.isPrivate.Load() {
+		m = m.Assoc(Keyword("private"), TRUE)
+	}
+	return m
+}
+
+// ResetMeta replaces the metadata of v, vars have identity.
+func (v *Var) ResetMeta(m Value) {
+	v.meta.Store(&m)
+	if l, ok := m.(Lookup); ok && m != NIL {
+		if IsTruthy(l.ValueAt(Keyword("dynamic"))) {
+			v.SetDynamic()
+		}
+		if IsTruthy(l.ValueAt(Keyword("private"))) {
+			v.SetPrivate()
+		}
+	}
+}
+
+func (v *Var) AlterMeta(fn Fn, args []Value) (Value, error) {
+	newMeta, err := fn.Invoke(append([]Value{v.Meta()}, args...))
+	if err != nil {
+		return NIL, err
+	}
+	v.ResetMeta(newMeta)
+	return newMeta, nil
 }
@@ -0,0 +1,177 @@
+package vm
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestVarAlterRootIsAtomic(t *testing.T) {
+	v := NewNamespace("test").Def("counter", Int(0))
+	inc := fnOf(func(args []Value) (Value, error) {
+		return args[0].(Int) + 1, nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := v.AlterRoot(inc, nil); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if v.Root() != Int(800) {
+		t.Fatalf("expected 800, got %v", v.Root())
+	}
+}
+
+func TestVarRootIsReadWhileItChanges(t *testing.T) {
+	v := NewNamespace("test").Def("x", Int(0))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			v.SetRoot(Int(i))
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if v.Deref() != Int(999) {
+				t.Fatalf("expected 999, got %v", v.Deref())
+			}
+			return
+		default:
+			if _, ok := v.Deref().(Int); !ok {
+				t.Fatalf("expected an Int, got %v", v.Deref())
+			}
+		}
+	}
+}
+
+func TestVarValidator(t *testing.T) {
+	v := NewNamespace("test").Def("x", Int(1))
+	positive := fnOf(func(args []Value) (Value, error) {
+		return Boolean(args[0].(Int) > 0), nil
+	})
+	if err := v.SetValidator(positive); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.BindRoot(Int(-1)); err == nil {
+		t.Fatal("expected BindRoot to fail validation")
+	}
+	neg := fnOf(func(args []Value) (Value, error) {
+		return -args[0].(Int), nil
+	})
+	if _, err := v.AlterRoot(neg, nil); err == nil {
+		t.Fatal("expected AlterRoot to fail validation")
+	}
+	if v.Root() != Int(1) {
+		t.Fatalf("expected root to stay 1, got %v", v.Root())
+	}
+}
+
+func TestVarHasRoot(t *testing.T) {
+	ns := NewNamespace("test")
+	v := ns.Intern("declared")
+	if v.HasRoot() || v.IsBound() {
+		t.Fatal("interned var should be unbound")
+	}
+	if ns.Intern("declared") != v {
+		t.Fatal("Intern should return the existing var")
+	}
+	v.SetRoot(NIL)
+	if !v.HasRoot() {
+		t.Fatal("var should be bound after SetRoot")
+	}
+}
+
+func TestVarBindingsAreCountedWhileLive(t *testing.T) {
+	ns := NewNamespace("test")
+	a, b := ns.Def("a", Int(1)), ns.Def("b", Int(1))
+	PushThreadBindings(map[*Var]Value{a: Int(2)})
+	PushThreadBindings(map[*Var]Value{b: Int(3)})
+	if a.Deref() != Int(2) || b.Deref() != Int(3) {
+		t.Fatalf("expected chained bindings, got %v %v", a.Deref(), b.Deref())
+	}
+
+	// a conveyed frame keeps the bindings alive on the other goroutine after
+	// they're popped here
+	frame := CloneThreadBindingFrame()
+	popped, done := make(chan struct{}), make(chan Value)
+	go func() {
+		prev := ResetThreadBindingFrame(frame)
+		<-popped
+		done <- a.Deref()
+		ResetThreadBindingFrame(prev)
+		done <- nil
+	}()
+	PopThreadBindings()
+	PopThreadBindings()
+	close(popped)
+	if v := <-done; v != Int(2) {
+		t.Fatalf("expected the conveyed binding, got %v", v)
+	}
+	<-done
+	if a.bindings.Load() != 0 || b.bindings.Load() != 0 {
+		t.Fatalf("expected no live bindings, got %d %d", a.bindings.Load(), b.bindings.Load())
+	}
+	if a.Deref() != Int(1) || b.Deref() != Int(1) {
+		t.Fatalf("expected roots, got %v %v", a.Deref(), b.Deref())
+	}
+}
+
+func TestVarSetIsSeenByConveyedFrames(t *testing.T) {
+	v := NewNamespace("test").Def("s", Int(0))
+	v.PushBinding(Int(0))
+	defer v.PopBinding()
+	frame := CloneThreadBindingFrame()
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		prev := ResetThreadBindingFrame(frame)
+		defer ResetThreadBindingFrame(prev)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if _, ok := v.Deref().(Int); !ok {
+					t.Error("expected an Int binding")
+					return
+				}
+			}
+		}
+	}()
+	for i := 1; i <= 1000; i++ {
+		if err := v.Set(Int(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(stop)
+	<-done
+	if v.Deref() != Int(1000) {
+		t.Fatalf("expected 1000, got %v", v.Deref())
+	}
+}
+
+func BenchmarkVarDerefAfterBinding(b *testing.B) {
+	v := NewNamespace("test").Def("d", Int(1))
+	v.PushBinding(Int(2))
+	v.PopBinding()
+	for i := 0; i < b.N; i++ {
+		v.Deref()
+	}
+}
+
+func BenchmarkVarDerefBound(b *testing.B) {
+	v := NewNamespace("test").Def("d", Int(1))
+	v.PushBinding(Int(2))
+	defer v.PopBinding()
+	for i := 0; i < b.N; i++ {
+		v.Deref()
+	}
+}
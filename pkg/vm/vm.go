@@ -60,6 +60,8 @@ const (
 	OP_EQ  // = (2 args)
 	OP_INC // inc (1 arg)
 	OP_DEC // dec (1 arg)
+
+	OP_SET_VAR_META // set var metadata (stack: [var meta] -> [var])
 )
 
 func OpcodeToString(op int32) string {
@@ -101,6 +103,7 @@ func OpcodeToString(op int32) string {
 		"EQ",
 		"INC",
 		"DEC",
+		"SET_VAR_META",
 	}
 	if int(inst) < len(ops) {
 		return fmt.Sprintf("%d/%-16s", sp, ops[inst])
@@ -115,14 +118,31 @@ type CodeChunk struct {
 	code      []int32
 	length    int
 	sourceMap *SourceMap
+	locals    []LocalVar // names of args and locals, for debuggers
 }
 
 func NewCodeChunk(consts *Consts) *CodeChunk {
-	return &CodeChunk{
+	c := &CodeChunk{
 		consts: consts,
 		code:   []int32{},
 		length: 0,
 	}
+	coverChunk(c)
+	return c
+}
+
+// NewSharedCodeChunk makes a chunk running code, with the given source map.
+// Both are shared with other chunks instead of copied and must not change.
+func NewSharedCodeChunk(consts *Consts, code []int32, maxStack int, sourceMap *SourceMap) *CodeChunk {
+	c := &CodeChunk{
+		consts:    consts,
+		code:      code[:len(code):len(code)], // appending copies
+		length:    len(code),
+		maxStack:  maxStack,
+		sourceMap: sourceMap,
+	}
+	coverChunk(c)
+	return c
 }
 
 func (c *CodeChunk) Debug() {
@@ -185,6 +205,13 @@ func (c *CodeChunk) AppendChunk(o *CodeChunk) {
 			c.sourceMap.Add(base+e.startIP, e.info)
 		}
 	}
+	for _, l := range o.locals {
+		l.StartIP += c.length
+		if l.EndIP >= 0 {
+			l.EndIP += c.length
+		}
+		c.locals = append(c.locals, l)
+	}
 	c.code = append(c.code, o.code...)
 	c.length += len(o.code)
 }
@@ -239,7 +266,7 @@ func (c *CodeChunk) Consts() *Consts { return c.consts }
 
 type exHandler struct {
 	catchIP   int // absolute IP of catch block
-	finallyIP int // absolute IP of finally block (-1 if none)
+	finallyIP int // absolute IP of the block running finally and rethrowing (-1 if none)
 	savedSP   int // stack depth to restore
 }
 
@@ -252,10 +279,25 @@ type Frame struct {
 	consts      *Consts
 	constsc     int
 	code        *CodeChunk
+	fn          *Func      // function being run, nil for top-level code
+	parent      *Frame     // let-go frame that called this one, if known
+	depth       int        // number of let-go calls this frame is nested in
+	chain       *callChain // shared with the other frames on this goroutine
 	ip          int
 	sp          int
 	debug       bool
 	handlers    []exHandler // exception handler stack (nil when unused)
+	env         *execEnv    // limited evaluation this frame belongs to, if any
+	// instrumented routes every instruction through instrument (tracing, metering,
+	// debugging, profiling or coverage)
+	instrumented bool
+	dbg          *debugState // set while running under a debugger
+	cov          []uint32    // coverage counters of covCode
+	covCode      *CodeChunk
+	// unwinding is the uncatchable error the finally block being run was
+	// entered for, unwindLevel the number of handlers outside of that block
+	unwinding   error
+	unwindLevel int
 }
 
 // framePool reuses Frame structs to avoid per-call heap allocation.
@@ -283,9 +325,18 @@ func NewFrame(code *CodeChunk, args []Value) *Frame {
 	f.consts = code.consts
 	f.constsc = code.consts.count()
 	f.code = code
+	f.fn = nil
+	f.depth = 0
 	f.ip = 0
 	f.sp = 0
 	f.debug = false
+	f.env = nil
+	f.instrumented = instrumenting()
+	f.dbg = nil
+	f.parent = nil
+	f.chain = nil
+	f.cov, f.covCode = nil, nil
+	f.unwinding = nil
 	if f.handlers != nil {
 		f.handlers = f.handlers[:0]
 	}
@@ -300,13 +351,21 @@ func ReleaseFrame(f *Frame) {
 	f.closedOvers = nil
 	f.consts = nil
 	f.code = nil
+	f.fn = nil
 	f.handlers = nil
+	f.unwinding = nil
+	f.env = nil
+	f.dbg = nil
+	f.parent = nil
+	f.chain = nil
+	f.cov, f.covCode = nil, nil
 	framePool.Put(f)
 }
 
 func NewDebugFrame(code *CodeChunk, args []Value) *Frame {
 	f := NewFrame(code, args)
 	f.debug = true
+	f.instrumented = true
 	return f
 }
 
@@ -393,18 +452,42 @@ func (f *Frame) stackDbg() {
 
 // handleError checks if there's an active try/catch handler and dispatches to it.
 // Returns true if the error was handled (caller should continue the dispatch loop).
+// Errors which can't be caught skip catch blocks but still run finally blocks,
+// which rethrow them once done. Whatever else fails in such a finally block
+// gives way to the error it was entered for.
 func (f *Frame) handleError(err error) bool {
-	if len(f.handlers) > 0 {
+	for len(f.handlers) > 0 {
+		if f.unwinding != nil && len(f.handlers) <= f.unwindLevel {
+			err = f.unwinding
+		}
 		h := f.handlers[len(f.handlers)-1]
 		f.handlers = f.handlers[:len(f.handlers)-1]
 		f.sp = h.savedSP
-		f.push(errorToValue(err))
+		if isUncatchable(err) {
+			if h.finallyIP < 0 {
+				continue
+			}
+			f.unwinding, f.unwindLevel = err, len(f.handlers)
+			f.push(NIL)
+			f.ip = h.finallyIP
+			return true
+		}
+		f.push(errorToValue(f.traced(err)))
 		f.ip = h.catchIP
 		return true
 	}
 	return false
 }
 
+// escaping returns the error the frame fails with when err gets out of it,
+// which is the uncatchable one if it was running a finally block for it.
+func (f *Frame) escaping(err error) error {
+	if f.unwinding != nil {
+		return f.unwinding
+	}
+	return err
+}
+
 // RunProtected runs the frame with panic recovery for thrownPanic.
 // Use at top-level entry points (REPL, file eval). Internal calls use Run() directly.
 func (f *Frame) RunProtected() (result Value, err error) {
@@ -420,19 +503,65 @@ func (f *Frame) RunProtected() (result Value, err error) {
 			}
 		}
 	}()
+	if f.env == nil && envsActive.Load() != 0 {
+		f.setEnv(currentEnv())
+	}
 	return f.Run()
 }
 
+// Run runs the frame, recording it in the stack trace of the error it fails
+// with, if any.
 func (f *Frame) Run() (Value, error) {
+	if f.chain == nil {
+		return f.runJoined()
+	}
+	if f.instrumented {
+		if d := activeDebugger.Load(); d != nil {
+			return d.run(f)
+		}
+		if p := activeProfiler.Load(); p != nil && f.parent == nil {
+			return p.run(f)
+		}
+	}
+	v, err := f.run()
+	if err != nil {
+		return NIL, f.traced(f.escaping(err))
+	}
+	return v, nil
+}
+
+// runJoined runs f, called from Go code, on the call chain of the calling
+// goroutine. Finding the chain means identifying the goroutine, which is
+// slow on some platforms, so f gets a chain of its own instead as long as
+// nothing depends on it, see runAlone.
+func (f *Frame) runJoined() (Value, error) {
+	if framesLive.Load() == 0 && aloneRuns.Load() < maxAloneRuns {
+		return f.runAlone()
+	}
+	end, err := f.joinChain()
+	if err != nil {
+		return NIL, err
+	}
+	if end != nil {
+		defer end()
+	}
+	return f.Run()
+}
+
+func (f *Frame) run() (Value, error) {
 	if f.debug {
 		fmt.Print("run", f.args, "\n")
 		f.code.Debug()
 	}
 	for {
 		inst := f.code.code[f.ip]
-		if f.debug {
-			f.stackDbg()
-			fmt.Println("#", f.ip, OpcodeToString(inst))
+		if f.instrumented {
+			if err := f.instrument(inst); err != nil {
+				if isUncatchable(err) && f.handleError(err) {
+					continue
+				}
+				return NIL, err
+			}
 		}
 		switch inst & 0xff {
 		case OP_NOOP:
@@ -442,10 +571,12 @@ func (f *Frame) Run() (Value, error) {
 			fmt.Print("# tracing frame, args: ", f.args, "\n")
 			f.code.Debug()
 			f.debug = true
+			f.setEnv(f.env)
 			f.ip += 1
 
 		case OP_TRACE_DISABLE:
 			f.debug = false
+			f.setEnv(f.env)
 			f.ip += 1
 
 		case OP_LOAD_CONST:
@@ -478,6 +609,14 @@ func (f *Frame) Run() (Value, error) {
 			return v, nil
 
 		case OP_INVOKE:
+			if pendingCancels.Load() != 0 && f.unwinding == nil {
+				if err := checkInterrupt(); err != nil {
+					if f.handleError(err) {
+						continue
+					}
+					return NIL, err
+				}
+			}
 			arity := f.code.code[f.ip+1]
 			var out Value
 			if arity > 0 {
@@ -493,16 +632,10 @@ func (f *Frame) Run() (Value, error) {
 				if err != nil {
 					return NIL, NewExecutionError("popping arguments failed").Wrap(err)
 				}
-				out, err = fn.Invoke(a)
+				out, err = f.call(fn, a)
 				if err != nil {
-					srcInfo := f.code.LookupSource(f.ip)
-					wrapped := NewExecutionError(fmt.Sprintf("calling %s", fnName(fn))).WithSource(srcInfo).Wrap(err)
-					if len(f.handlers) > 0 {
-						h := f.handlers[len(f.handlers)-1]
-						f.handlers = f.handlers[:len(f.handlers)-1]
-						f.sp = h.savedSP
-						f.push(errorToValue(wrapped))
-						f.ip = h.catchIP
+					wrapped := f.callError(fn, err)
+					if f.handleError(wrapped) {
 						continue
 					}
 					return NIL, wrapped
@@ -520,16 +653,10 @@ func (f *Frame) Run() (Value, error) {
 				if !ok {
 					return NIL, NewTypeError(fraw, "is not a function", nil)
 				}
-				out, err = fn.Invoke(nil)
+				out, err = f.call(fn, nil)
 				if err != nil {
-					srcInfo := f.code.LookupSource(f.ip)
-					wrapped := NewExecutionError(fmt.Sprintf("calling %s", fnName(fn))).WithSource(srcInfo).Wrap(err)
-					if len(f.handlers) > 0 {
-						h := f.handlers[len(f.handlers)-1]
-						f.handlers = f.handlers[:len(f.handlers)-1]
-						f.sp = h.savedSP
-						f.push(errorToValue(wrapped))
-						f.ip = h.catchIP
+					wrapped := f.callError(fn, err)
+					if f.handleError(wrapped) {
 						continue
 					}
 					return NIL, wrapped
@@ -542,6 +669,14 @@ func (f *Frame) Run() (Value, error) {
 			f.ip += 2
 
 		case OP_TAIL_CALL:
+			if pendingCancels.Load() != 0 && f.unwinding == nil {
+				if err := checkInterrupt(); err != nil {
+					if f.handleError(err) {
+						continue
+					}
+					return NIL, err
+				}
+			}
 			arity := f.code.code[f.ip+1]
 			var out Value
 			if arity > 0 {
@@ -558,16 +693,10 @@ func (f *Frame) Run() (Value, error) {
 					return NIL, NewExecutionError("popping arguments failed").Wrap(err)
 				}
 				if ff, ok := fn.(*Func); !ok {
-					out, err = fn.Invoke(a)
+					out, err = f.call(fn, a)
 					if err != nil {
-						srcInfo := f.code.LookupSource(f.ip)
-						wrapped := NewExecutionError(fmt.Sprintf("calling %s", fnName(fn))).WithSource(srcInfo).Wrap(err)
-						if len(f.handlers) > 0 {
-							h := f.handlers[len(f.handlers)-1]
-							f.handlers = f.handlers[:len(f.handlers)-1]
-							f.sp = h.savedSP
-							f.push(errorToValue(wrapped))
-							f.ip = h.catchIP
+						wrapped := f.callError(fn, err)
+						if f.handleError(wrapped) {
 							continue
 						}
 						return NIL, wrapped
@@ -578,6 +707,7 @@ func (f *Frame) Run() (Value, error) {
 					}
 				} else {
 					f.code = ff.chunk
+					f.fn = ff
 					f.consts = f.code.consts
 					f.constsc = f.code.consts.count()
 					f.ip = 0
@@ -608,22 +738,17 @@ func (f *Frame) Run() (Value, error) {
 					return NIL, NewTypeError(fraw, "is not a function", nil)
 				}
 				if ff, ok := fn.(*Func); !ok {
-					out, err = fn.Invoke(nil)
+					out, err = f.call(fn, nil)
 					if err != nil {
-						srcInfo := f.code.LookupSource(f.ip)
-						wrapped := NewExecutionError(fmt.Sprintf("calling %s", fnName(fn))).WithSource(srcInfo).Wrap(err)
-						if len(f.handlers) > 0 {
-							h := f.handlers[len(f.handlers)-1]
-							f.handlers = f.handlers[:len(f.handlers)-1]
-							f.sp = h.savedSP
-							f.push(errorToValue(wrapped))
-							f.ip = h.catchIP
+						wrapped := f.callError(fn, err)
+						if f.handleError(wrapped) {
 							continue
 						}
 						return NIL, wrapped
 					}
 				} else {
 					f.code = ff.chunk
+					f.fn = ff
 					f.consts = f.code.consts
 					f.constsc = f.code.consts.count()
 					f.ip = 0
@@ -668,6 +793,14 @@ func (f *Frame) Run() (Value, error) {
 
 		case OP_JUMP:
 			offset := f.code.code[f.ip+1]
+			if offset < 0 && pendingCancels.Load() != 0 {
+				if err := checkInterrupt(); err != nil {
+					if f.handleError(err) {
+						continue
+					}
+					return NIL, err
+				}
+			}
 			f.ip += int(offset)
 
 		case OP_POP:
@@ -718,7 +851,12 @@ func (f *Frame) Run() (Value, error) {
 			if !ok {
 				return NIL, NewExecutionError("SET_VAR invalid Var").Wrap(err)
 			}
-			varrd.SetRoot(val)
+			if err := varrd.BindRoot(val); err != nil {
+				if f.handleError(err) {
+					continue
+				}
+				return NIL, NewExecutionError(fmt.Sprintf("defining %s", varrd)).Wrap(err)
+			}
 			err = f.push(varr)
 			if err != nil {
 				return NIL, NewExecutionError("SET_VAR push var failed").Wrap(err)
@@ -780,6 +918,14 @@ func (f *Frame) Run() (Value, error) {
 			f.ip++
 
 		case OP_RECUR_FN:
+			if pendingCancels.Load() != 0 {
+				if err := checkInterrupt(); err != nil {
+					if f.handleError(err) {
+						continue
+					}
+					return NIL, err
+				}
+			}
 			arity := f.code.code[f.ip+1]
 			a, err := f.mult(0, int(arity))
 			if err != nil {
@@ -791,6 +937,14 @@ func (f *Frame) Run() (Value, error) {
 			f.ip = 0
 
 		case OP_RECUR:
+			if pendingCancels.Load() != 0 {
+				if err := checkInterrupt(); err != nil {
+					if f.handleError(err) {
+						continue
+					}
+					return NIL, err
+				}
+			}
 			offset := f.code.code[f.ip+1]
 			argc := f.code.code[f.ip+2]
 			ignore := f.code.code[f.ip+3]
@@ -854,12 +1008,7 @@ func (f *Frame) Run() (Value, error) {
 		case OP_THROW:
 			v, _ := f.pop()
 			thrown := NewThrownError(v)
-			if len(f.handlers) > 0 {
-				h := f.handlers[len(f.handlers)-1]
-				f.handlers = f.handlers[:len(f.handlers)-1]
-				f.sp = h.savedSP
-				f.push(v)
-				f.ip = h.catchIP
+			if f.handleError(thrown) {
 				continue
 			}
 			return NIL, thrown
@@ -1075,6 +1224,18 @@ func (f *Frame) Run() (Value, error) {
 			f.stack[f.sp-1] = r
 			f.ip++
 
+		case OP_SET_VAR_META:
+			meta, err := f.pop()
+			if err != nil {
+				return NIL, NewExecutionError("SET_VAR_META pop meta failed").Wrap(err)
+			}
+			varr, ok := f.stack[f.sp-1].(*Var)
+			if !ok {
+				return NIL, NewExecutionError("SET_VAR_META invalid Var")
+			}
+			varr.ResetMeta(meta)
+			f.ip++
+
 		default:
 			return NIL, NewExecutionError("unknown instruction")
 		}
@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2026 Marcin Gasperowicz <xnooga@gmail.com>
+ * SPDX-License-Identifier: MIT
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/nooga/let-go/pkg/compiler"
+	"github.com/nooga/let-go/pkg/rt"
+	"github.com/nooga/let-go/pkg/vm"
+)
+
+// socketServe listens on port and serves a REPL to every connection: a plain
+// one like the terminal REPL, or with prepl set, one answering each form with
+// EDN maps tagged :ret, :out, :err and :tap. Connections start in ns and run
+// in the runtime of the caller.
+func socketServe(ns *vm.Namespace, port int, prepl bool) error {
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return err
+	}
+	r := rt.Current()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer r.Enter()()
+				serveSocketREPL(conn, ns, prepl)
+			}()
+		}
+	}()
+	return nil
+}
+
+// connWriter serializes writes to a connection from evals, go blocks and taps.
+type connWriter struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *connWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.Write(p)
+}
+
+// send writes a prepl message with the given tag and fields.
+func (w *connWriter) send(tag string, kvs ...vm.Value) {
+	m := vm.EmptyPersistentMap.Assoc(vm.Keyword("tag"), vm.Keyword(tag))
+	for i := 0; i+1 < len(kvs); i += 2 {
+		m = m.Assoc(kvs[i], kvs[i+1])
+	}
+	w.Write([]byte(m.String() + "\n")) //nolint:errcheck
+}
+
+// preplWriter sends what's written to it as prepl messages tagged :out or :err.
+type preplWriter struct {
+	w   *connWriter
+	tag string
+}
+
+func (p *preplWriter) Write(b []byte) (int, error) {
+	p.w.send(p.tag, vm.Keyword("val"), vm.String(b))
+	return len(b), nil
+}
+
+func serveSocketREPL(conn net.Conn, ns *vm.Namespace, prepl bool) {
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	w := &connWriter{conn: conn}
+	var stdout, stderr io.Writer = w, w
+	if prepl {
+		stdout, stderr = &preplWriter{w, "out"}, &preplWriter{w, "err"}
+	}
+
+	r := rt.Current()
+	bindings := rt.ReplBindings(ns)
+	bindings[r.StdinVar()] = vm.NewBoxed(rt.NewStreamHandle("socket-in", in, nil))
+	bindings[r.StdoutVar()] = vm.NewBoxed(rt.NewStreamHandle("socket-out", nil, stdout))
+	bindings[r.StderrVar()] = vm.NewBoxed(rt.NewStreamHandle("socket-err", nil, stderr))
+	vm.PushThreadBindings(bindings)
+	defer vm.PopThreadBindings()
+	ctx := compiler.NewCompiler(vm.NewConsts(), ns)
+
+	if prepl {
+		tap, _ := vm.NativeFnType.Wrap(func(vs []vm.Value) (vm.Value, error) {
+			w.send("tap", vm.Keyword("val"), vm.String(vs[0].String()))
+			return vm.NIL, nil
+		})
+		rt.AddTap(tap.(vm.Fn))
+		defer rt.RemoveTap(tap)
+	}
+
+	for {
+		if !prepl {
+			fmt.Fprintf(w, "%s=> ", ctx.CurrentNS().Name())
+		}
+		form, err := readForm(in)
+		if err != nil {
+			return
+		}
+		form = strings.TrimSpace(form)
+		if form == ":repl/quit" {
+			return
+		}
+		start := time.Now()
+		val, err := replEval(ctx, form)
+		if !prepl {
+			if err != nil {
+				fmt.Fprint(w, vm.FormatError(err))
+			} else {
+				fmt.Fprintln(w, val.String())
+			}
+			continue
+		}
+		kvs := []vm.Value{
+			vm.Keyword("ns"), vm.String(ctx.CurrentNS().Name()),
+			vm.Keyword("ms"), vm.Int(time.Since(start).Milliseconds()),
+			vm.Keyword("form"), vm.String(form),
+		}
+		if err != nil {
+			kvs = append(kvs, vm.Keyword("val"), vm.String(errorMap(err).String()), vm.Keyword("exception"), vm.TRUE)
+		} else {
+			kvs = append(kvs, vm.Keyword("val"), vm.String(val.String()))
+		}
+		w.send("ret", kvs...)
+	}
+}
+
+// errorMap describes err for a prepl :ret, with its message and ex-data.
+func errorMap(err error) vm.Value {
+	m := vm.EmptyPersistentMap.Assoc(vm.Keyword("cause"), vm.String(err.Error()))
+	if ei, ok := vm.ErrorValue(err).(*vm.ExInfo); ok {
+		m = m.Assoc(vm.Keyword("cause"), vm.String(ei.Message()))
+		// :trace is the stack trace again, for errors that weren't thrown
+		if ei.Data() != nil {
+			if data := ei.Data().Dissoc(vm.Keyword("trace")).(*vm.PersistentMap); data.RawCount() > 0 {
+				m = m.Assoc(vm.Keyword("data"), data)
+			}
+		}
+	}
+	return m
+}
+
+// readForm reads the text of the next top-level form from in, leaving what
+// follows it in in, except for the end of the line it's on. Reading stops at
+// the first point where the text reads as a complete form, so a client gets
+// an answer without sending anything after the form.
+func readForm(in *bufio.Reader) (string, error) {
+	var b strings.Builder
+	depth := 0
+	inString, escaped, inComment := false, false, false
+	for {
+		c, _, err := in.ReadRune()
+		if err != nil {
+			if err == io.EOF && completeForm(b.String()) {
+				return b.String(), nil
+			}
+			return "", err
+		}
+		switch {
+		case inComment:
+			if c != '\n' {
+				continue
+			}
+			inComment = false
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				b.WriteRune(c)
+				if depth == 0 && completeForm(b.String()) {
+					return b.String(), skipEOL(in)
+				}
+				continue
+			}
+		case depth == 0 && (unicode.IsSpace(c) || c == ',' || strings.ContainsRune("([{\";", c)) && completeForm(b.String()):
+			// the form before c is a symbol, number or the like
+			if !unicode.IsSpace(c) && c != ',' {
+				in.UnreadRune() //nolint:errcheck
+			} else if c != '\n' {
+				return b.String(), skipEOL(in)
+			}
+			return b.String(), nil
+		case c == ';':
+			inComment = true
+			continue
+		case c == '"':
+			inString = true
+		case c == '\\':
+			// a character literal, \( doesn't open anything
+			b.WriteRune(c)
+			if c, _, err = in.ReadRune(); err != nil {
+				return "", err
+			}
+		case strings.ContainsRune("([{", c):
+			depth++
+		case strings.ContainsRune(")]}", c):
+			depth--
+			b.WriteRune(c)
+			if depth <= 0 && completeForm(b.String()) {
+				return b.String(), skipEOL(in)
+			}
+			continue
+		}
+		b.WriteRune(c)
+	}
+}
+
+// completeForm tells if src holds a form that can be read, or fails to read
+// for reasons other than running out of input.
+func completeForm(src string) bool {
+	if strings.TrimSpace(src) == "" {
+		return false
+	}
+	_, err := compiler.NewLispReader(strings.NewReader(src), "REPL").Read()
+	if re, ok := err.(*compiler.ReaderError); ok {
+		return !re.IsEOF()
+	}
+	return true
+}
+
+// skipEOL consumes the rest of the line if it's blank and already arrived,
+// so that reading *in* right after a form starts on the next line.
+func skipEOL(in *bufio.Reader) error {
+	for in.Buffered() > 0 {
+		c, _, err := in.ReadRune()
+		if err != nil || c == '\n' {
+			return nil
+		}
+		if c != ' ' && c != '\t' && c != '\r' && c != ',' {
+			return in.UnreadRune()
+		}
+	}
+	return nil
+}
@@ -49,11 +49,7 @@ func main() {
 
 	resolve := func(nsName, name string) *vm.Var {
 		n := rt.DefNSBare(nsName)
-		v := n.LookupLocal(vm.Symbol(name))
-		if v == nil {
-			return n.Def(name, vm.NIL)
-		}
-		return v
+		return n.Intern(vm.Symbol(name))
 	}
 
 	unit, err := bytecode.DecodeToExecUnit(bytes.NewReader(lgbData), resolve)